@@ -0,0 +1,64 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"time"
+)
+
+// the flush interval used when the batches are aggregated and no flush interval is configured
+const defaultFlushInterval = time.Second
+
+// the batches appended to an in process file are aggregated in the write buffer of the writer and
+// written to the file in a single append when the buffered bytes or records reach the flush limits
+// or the flush interval elapses, so that many small batches do not wear out flash storage; the
+// aggregated batches are lost if the process crashes before they are flushed
+
+// validateAggregation checks the flush limits are not negative
+func (cfg *Config) validateAggregation() error {
+	if cfg.FlushBytes < 0 || cfg.FlushRecords < 0 || cfg.FlushIntervalMs < 0 {
+		return errors.New("flushBytes, flushRecords and flushIntervalMs must not be negative")
+	}
+	return nil
+}
+
+// flushInterval returns the longest time batches are aggregated, zero if the batches are not aggregated
+func (cfg *Config) flushInterval() time.Duration {
+	if cfg.FlushBytes == 0 && cfg.FlushRecords == 0 && cfg.FlushIntervalMs == 0 {
+		return 0
+	}
+	if cfg.FlushIntervalMs == 0 {
+		return defaultFlushInterval
+	}
+	return time.Duration(cfg.FlushIntervalMs) * time.Millisecond
+}
+
+// aggregating returns true if the batches are aggregated before being written
+func (e *fileExporter) aggregating() bool {
+	return e.flushInterval > 0
+}
+
+// shouldFlush returns true if the batches buffered by the writer must be written to the file
+func (e *fileExporter) shouldFlush(w *fileWriter) bool {
+	if !e.aggregating() {
+		return true
+	}
+	return (e.flushBytes > 0 && w.pendingBytes >= e.flushBytes) ||
+		(e.flushRecords > 0 && w.pendingRecords >= e.flushRecords)
+}
+
+// flushIfDue writes the batches buffered by the writer if the oldest has been buffered for the flush
+// interval, it must be called holding the writer lock
+func (e *fileExporter) flushIfDue(w *fileWriter, now time.Time) error {
+	if !e.aggregating() || w.firstPending.IsZero() || now.Sub(w.firstPending) < e.flushInterval {
+		return nil
+	}
+	return w.flush()
+}
@@ -0,0 +1,137 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	BacklogBlock    = "block"
+	BacklogDrop     = "drop"
+	BacklogContinue = "continue"
+
+	// how long the number of pending files of a path is reused before it is counted again
+	backlogCountTTL = time.Second
+)
+
+// ErrBacklog is returned when the number of finished files waiting to be collected reaches the limit
+// and the backlog behaviour is block, the error is retryable so the data is held by the queues upstream
+var ErrBacklog = errors.New("too many finished files pending")
+
+// validateBacklog checks the pending files limit and the backlog behaviour
+func (cfg *Config) validateBacklog() error {
+	if cfg.MaxPendingFiles < 0 {
+		return errors.New("maxPendingFiles must not be negative")
+	}
+	switch strings.ToLower(cfg.OnBacklog) {
+	case "", BacklogBlock, BacklogDrop, BacklogContinue:
+	default:
+		return fmt.Errorf("invalid onBacklog [%s], valid values are [ %s, %s or %s ]", cfg.OnBacklog, BacklogBlock, BacklogDrop, BacklogContinue)
+	}
+	if cfg.MaxPendingFiles > 0 && strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("maxPendingFiles requires rotation as there are no finished files")
+	}
+	return nil
+}
+
+// pendingCount is the number of pending files of a path and when they were counted
+type pendingCount struct {
+	count int
+	at    time.Time
+}
+
+// checkBacklog checks the number of finished files pending under the root path is below the limit, it
+// returns false if the batch must be dropped; if the limit is reached and the behaviour is block a
+// retryable error is returned
+func (e *fileExporter) checkBacklog(root string) (bool, error) {
+	if e.maxPendingFiles == 0 {
+		return true, nil
+	}
+	pending, err := e.pendingFiles(root)
+	if err != nil {
+		return false, err
+	}
+	if pending < e.maxPendingFiles {
+		return true, nil
+	}
+	switch e.onBacklog {
+	case BacklogDrop:
+		e.logger.Warn("dropping batch as the finished files backlog is full", zap.String("path", root), zap.Int("pending", pending))
+		return false, nil
+	case BacklogContinue:
+		e.debug("finished files backlog is full", zap.String("path", root), zap.Int("pending", pending))
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: %d finished files under %s, the limit is %d", ErrBacklog, pending, root, e.maxPendingFiles)
+	}
+}
+
+// pendingFiles returns the number of finished files under the root path, the count is reused for a
+// short time so that the path is not walked on every write
+func (e *fileExporter) pendingFiles(root string) (int, error) {
+	e.mutex.Lock()
+	c, ok := e.pendingCounts[root]
+	e.mutex.Unlock()
+	if ok && time.Since(c.at) < backlogCountTTL {
+		return c.count, nil
+	}
+	count := 0
+	err := filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == bundleDir {
+				// staged files are counted once bundled
+				return filepath.SkipDir
+			}
+			if d.Name() == trashDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e.isDataFile(d.Name()) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	e.mutex.Lock()
+	e.pendingCounts[root] = pendingCount{count: count, at: time.Now()}
+	e.mutex.Unlock()
+	return count, nil
+}
+
+// isDataFile returns true if the file is a finished file rather than an in process file, a sidecar,
+// a manifest or a marker
+func (e *fileExporter) isDataFile(name string) bool {
+	if e.isInProcessName(name) || name == manifestRollingFile || name == bundleTmpFile || name == e.rotateTrigger || name == layoutFile || name == dirLockFile || isStateName(name) || isQuarantineName(name) || isStagingName(name) || isReportName(name) || isStatusName(name) {
+		return false
+	}
+	for _, sidecar := range []string{manifestSidecarExt, ChecksumSHA256, ChecksumCRC32, doneMarkerExt} {
+		if strings.HasSuffix(name, "."+sidecar) {
+			return false
+		}
+	}
+	return !strings.HasPrefix(name, ".probe-")
+}
@@ -0,0 +1,243 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const (
+	// BatchSequenceResource adds the sequence and source of every batch as resource attributes
+	BatchSequenceResource = "resource"
+	// BatchSequenceHeader writes a header record holding the sequence and source before every batch
+	BatchSequenceHeader = "header"
+
+	// the resource attribute keys of the sequence and source of the batches
+	batchSequenceKey = "exporter.batch.sequence"
+	batchSourceKey   = "exporter.batch.source"
+	// the protobuf field number of the batch header record, next to the file header field
+	batchHeaderProtoField = fileHeaderProtoField + 1
+	// the suffix of the file holding the last sequence number under the path, named as a state file so that
+	// it is excluded from the finished files
+	batchSequenceSuffix = "sequence." + stateSuffix
+	// batchSequenceBlock is the number of sequence numbers reserved at a time, the file holding the last
+	// reserved number is only written once the block is used up
+	batchSequenceBlock = 1000
+)
+
+// BatchSequenceConfig stamps every written batch with a sequence number increasing by one from batch to
+// batch and the source that wrote it, so that the consumers can detect the missing and replayed batches;
+// the numbers are reserved by blocks of a thousand, so the numbering skips the rest of a block on restart
+type BatchSequenceConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Target defines how the batches are stamped, valid values are resource and header; it defaults to resource
+	Target string `mapstructure:"target"`
+	// Source identifies the device writing the batches, it defaults to the host name followed by the
+	// instance id when the path is shared
+	Source string `mapstructure:"source"`
+}
+
+// validateBatchSequence checks the batches can be stamped in the format
+func (cfg *Config) validateBatchSequence() error {
+	if cfg.BatchSequence == nil || !cfg.BatchSequence.Enabled {
+		return nil
+	}
+	switch strings.ToLower(cfg.BatchSequence.Target) {
+	case "", BatchSequenceResource:
+		if strings.EqualFold(cfg.Format, Csv) || strings.EqualFold(cfg.Format, Prometheus) {
+			return errors.New("the batch sequence resource target is not supported for the csv and prometheus formats, they do not write the resource attributes")
+		}
+	case BatchSequenceHeader:
+		if !strings.EqualFold(cfg.Format, Json) && !strings.EqualFold(cfg.Format, OtlpJson) && !strings.EqualFold(cfg.Format, Protobuf) {
+			return errors.New("the batch sequence header target is only supported for the json, otlp-json and protobuf formats")
+		}
+	default:
+		return fmt.Errorf("invalid batch sequence target [%s], valid values are [ %s or %s ]", cfg.BatchSequence.Target, BatchSequenceResource, BatchSequenceHeader)
+	}
+	return nil
+}
+
+// batchHeader is the header record written before every batch with the header target
+type batchHeader struct {
+	Sequence uint64 `json:"sequence"`
+	Source   string `json:"source"`
+}
+
+// batchSequence numbers the written batches, the numbers are reserved by blocks saved under the path so
+// the numbering continues after a restart from the end of the last reserved block
+type batchSequence struct {
+	resource bool
+	source   string
+	// file is the file holding the last reserved number, empty in dry run
+	file     string
+	mutex    sync.Mutex
+	last     uint64
+	reserved uint64
+}
+
+// newBatchSequence returns the sequence of the configuration, nil if the batches are not numbered
+func newBatchSequence(cfg *Config, hostname string) *batchSequence {
+	if cfg.BatchSequence == nil || !cfg.BatchSequence.Enabled {
+		return nil
+	}
+	s := &batchSequence{
+		resource: !strings.EqualFold(cfg.BatchSequence.Target, BatchSequenceHeader),
+		source:   cfg.BatchSequence.Source,
+	}
+	if len(s.source) == 0 {
+		s.source = hostname
+		if instance := instanceOf(cfg); len(instance) > 0 {
+			s.source += "/" + instance
+		}
+	}
+	if !cfg.DryRun {
+		name := "." + batchSequenceSuffix
+		if instance := instanceOf(cfg); len(instance) > 0 {
+			name = "." + instance + "." + batchSequenceSuffix
+		}
+		s.file = filepath.Join(cfg.Path, name)
+	}
+	return s
+}
+
+// load reads the last number reserved by a previous run, the numbers it did not use are skipped
+func (s *batchSequence) load(fsys fileSystem) error {
+	if len(s.file) == 0 {
+		return nil
+	}
+	content, err := readFileFrom(fsys, s.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read the batch sequence: %w", err)
+	}
+	var h batchHeader
+	if err = json.Unmarshal(content, &h); err != nil {
+		return fmt.Errorf("invalid batch sequence file %s: %w", s.file, err)
+	}
+	s.mutex.Lock()
+	s.last, s.reserved = h.Sequence, h.Sequence
+	s.mutex.Unlock()
+	return nil
+}
+
+// nextBatchSequence returns the number of the next batch, the block of the number is reserved before it is
+// used so that it is never reused after a restart; a batch that then fails to be written leaves a gap its
+// retry does not fill, and a restart skips the rest of the block
+func (e *fileExporter) nextBatchSequence() uint64 {
+	s := e.batchSeq
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.last++
+	if len(s.file) > 0 && s.last > s.reserved {
+		reserved := s.last + batchSequenceBlock - 1
+		content, err := json.Marshal(batchHeader{Sequence: reserved, Source: s.source})
+		if err == nil {
+			err = replaceFile(e.fs, s.file, append(content, '\n'))
+		}
+		if err != nil {
+			// the block is reserved again by the next batch
+			e.logger.Warn("failed to save the batch sequence", zap.String("file", s.file), zap.Error(err))
+		} else {
+			s.reserved = reserved
+		}
+	}
+	return s.last
+}
+
+// stampsResources returns true if the batches are numbered with resource attributes
+func (s *batchSequence) stampsResources() bool {
+	return s != nil && s.resource
+}
+
+// stamp sets the sequence and source attributes of the resource, replacing the attributes of the same keys
+func (s *batchSequence) stamp(resource pcommon.Resource, seq uint64) {
+	resource.Attributes().PutInt(batchSequenceKey, int64(seq))
+	resource.Attributes().PutStr(batchSourceKey, s.source)
+}
+
+// sequenceTraces returns a copy of the traces with the sequence added to their resources
+func (e *fileExporter) sequenceTraces(td ptrace.Traces, seq uint64) ptrace.Traces {
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	for i := 0; i < out.ResourceSpans().Len(); i++ {
+		e.batchSeq.stamp(out.ResourceSpans().At(i).Resource(), seq)
+	}
+	return out
+}
+
+// sequenceMetrics returns a copy of the metrics with the sequence added to their resources
+func (e *fileExporter) sequenceMetrics(md pmetric.Metrics, seq uint64) pmetric.Metrics {
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	for i := 0; i < out.ResourceMetrics().Len(); i++ {
+		e.batchSeq.stamp(out.ResourceMetrics().At(i).Resource(), seq)
+	}
+	return out
+}
+
+// sequenceLogs returns a copy of the logs with the sequence added to their resources
+func (e *fileExporter) sequenceLogs(ld plog.Logs, seq uint64) plog.Logs {
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	for i := 0; i < out.ResourceLogs().Len(); i++ {
+		e.batchSeq.stamp(out.ResourceLogs().At(i).Resource(), seq)
+	}
+	return out
+}
+
+// sequenceHeader prefixes the batch with the header record of its sequence when the batches are numbered
+// with a header, the header is written to the path and every mirror path with the same number
+func (e *fileExporter) sequenceHeader(b *batch) {
+	if e.batchSeq == nil || e.batchSeq.resource {
+		return
+	}
+	content, err := json.Marshal(batchHeader{Sequence: e.nextBatchSequence(), Source: e.batchSeq.source})
+	if err != nil {
+		return
+	}
+	var record []byte
+	if e.format == formatProtobuf {
+		// a length delimited unknown field
+		record = binary.AppendUvarint(nil, batchHeaderProtoField<<3|2)
+		record = binary.AppendUvarint(record, uint64(len(content)))
+		record = append(record, content...)
+	} else {
+		if e.recordSep == RecordSeparatorJSONSeq {
+			record = append(record, recordSeparatorChar)
+		}
+		record = append(record, `{"batchHeader":`...)
+		record = append(record, content...)
+		record = append(record, '}', '\n')
+		if e.jsonArray {
+			// the header and the batch are two documents of the array
+			record = append(record, ',')
+		}
+	}
+	b.buf = append(record, b.buf...)
+}
+
+// isBatchHeader returns true if the json document is a batch header record
+func isBatchHeader(doc []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(doc), []byte(`{"batchHeader":`))
+}
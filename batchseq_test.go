@@ -0,0 +1,91 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// batchSequencesOf returns the sequence numbers of the batch headers in the content
+func batchSequencesOf(t *testing.T, content string) []uint64 {
+	var sequences []uint64
+	decoder := json.NewDecoder(strings.NewReader(content))
+	for decoder.More() {
+		var doc json.RawMessage
+		if err := decoder.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if !isBatchHeader(doc) {
+			continue
+		}
+		var record struct {
+			BatchHeader batchHeader `json:"batchHeader"`
+		}
+		if err := json.Unmarshal(doc, &record); err != nil {
+			t.Fatal(err)
+		}
+		sequences = append(sequences, record.BatchHeader.Sequence)
+	}
+	return sequences
+}
+
+// savedBatchSequence returns the number saved in the batch sequence file under the path
+func savedBatchSequence(t *testing.T, path string) uint64 {
+	var h batchHeader
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join(path, "."+batchSequenceSuffix))), &h); err != nil {
+		t.Fatal(err)
+	}
+	return h.Sequence
+}
+
+func TestBatchSequenceIsReservedByBlock(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.BatchSequence = &BatchSequenceConfig{Enabled: true, Target: BatchSequenceHeader}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestExporter(t, cfg)
+	if err := e.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, e, `{"a":1}`)
+	sequenceFile := filepath.Join(cfg.Path, "."+batchSequenceSuffix)
+	before, err := os.Stat(sequenceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, e, `{"a":2}`, `{"a":3}`)
+	after, err := os.Stat(sequenceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) || savedBatchSequence(t, cfg.Path) != batchSequenceBlock {
+		t.Fatalf("expected the block to be saved once, got %d", savedBatchSequence(t, cfg.Path))
+	}
+	if sequences := batchSequencesOf(t, readFile(t, filepath.Join(cfg.Path, inProcessName))); !reflect.DeepEqual(sequences, []uint64{1, 2, 3}) {
+		t.Fatalf("expected the batches to be numbered from one, got %v", sequences)
+	}
+	if err = e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// a restart continues after the reserved block, so that no number is reused
+	r := startTestExporter(t, cfg)
+	if seq := r.nextBatchSequence(); seq != batchSequenceBlock+1 {
+		t.Fatalf("expected the numbering to continue after the block, got %d", seq)
+	}
+	if saved := savedBatchSequence(t, cfg.Path); saved != 2*batchSequenceBlock {
+		t.Fatalf("expected the next block to be reserved, got %d", saved)
+	}
+}
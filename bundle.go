@@ -0,0 +1,201 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// bundleDir is the sub directory where finished files wait to be bundled, files in it are not ready for upload
+	bundleDir = ".bundle"
+	// bundleTmpFile is the name of the bundle being written
+	bundleTmpFile = ".bundle.tmp"
+	// signalBundle is the value of the {signal} placeholder for bundles
+	signalBundle = "bundle"
+
+	BundleCompressionNone = "none"
+	BundleCompressionGzip = "gzip"
+)
+
+// BundleConfig defines how finished files are grouped into tar archives before they are ready for upload
+type BundleConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Compression of the archives, valid values are none (tar) and gzip (tar.gz)
+	Compression string `mapstructure:"compression"`
+	// MaxSizeKb is the size of the waiting finished files that triggers a bundle
+	MaxSizeKb int64 `mapstructure:"maxSizeKb"`
+	// MaxAge is the time the oldest finished file can wait before a bundle is written
+	MaxAge time.Duration `mapstructure:"maxAge"`
+}
+
+// Validate checks if the bundle configuration is valid
+func (cfg *BundleConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	switch strings.ToLower(cfg.Compression) {
+	case "", BundleCompressionNone, BundleCompressionGzip:
+	default:
+		return fmt.Errorf("invalid bundle compression [%s], valid values are [ %s or %s ]", cfg.Compression, BundleCompressionNone, BundleCompressionGzip)
+	}
+	if cfg.MaxSizeKb < 0 || cfg.MaxAge < 0 {
+		return errors.New("bundle maxSizeKb and maxAge must not be negative")
+	}
+	if cfg.MaxSizeKb == 0 && cfg.MaxAge == 0 {
+		return errors.New("bundle maxSizeKb or maxAge must be defined when bundling is enabled")
+	}
+	return nil
+}
+
+// ext returns the extension of the archives
+func (cfg *BundleConfig) ext() string {
+	if strings.EqualFold(cfg.Compression, BundleCompressionGzip) {
+		return "tar.gz"
+	}
+	return "tar"
+}
+
+// stageForBundle moves the finished file and its sidecar files to the bundle directory
+func (e *fileExporter) stageForBundle(f string) error {
+	dir := filepath.Join(filepath.Dir(f), bundleDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(f + ".*")
+	if err != nil {
+		return err
+	}
+	for _, file := range append([]string{f}, files...) {
+		if err = os.Rename(file, filepath.Join(dir, filepath.Base(file))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleIfDue writes the files waiting in the bundle directory of the writer to an archive if their
+// size or age exceeds the configured limits, it must be called holding the writer lock
+func (e *fileExporter) bundleIfDue(w *fileWriter, now time.Time) error {
+	dir := filepath.Join(w.path, bundleDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var files []os.FileInfo
+	var size int64
+	oldest := now
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+		if info.ModTime().Before(oldest) {
+			oldest = info.ModTime()
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if (e.bundle.MaxSizeKb > 0 && size >= e.bundle.MaxSizeKb*1024) || (e.bundle.MaxAge > 0 && now.Sub(oldest) >= e.bundle.MaxAge) {
+		return e.writeBundle(w, files, now)
+	}
+	return nil
+}
+
+// writeBundle writes the files to an archive in the writer directory and removes them from the bundle directory
+func (e *fileExporter) writeBundle(w *fileWriter, files []os.FileInfo, now time.Time) error {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	dir := filepath.Join(w.path, bundleDir)
+	tmp := filepath.Join(w.path, bundleTmpFile)
+	if err := writeTar(tmp, dir, files, strings.EqualFold(e.bundle.Compression, BundleCompressionGzip)); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	name, err := e.renameFinished(tmp, w.path, signalBundle, now.UTC(), e.bucketName(e.bucketStart(now)), &w.bundleSeq, w.shard, int64(len(files)), e.bundle.ext(), "")
+	if err != nil {
+		return err
+	}
+	for _, info := range files {
+		if err := os.Remove(filepath.Join(dir, info.Name())); err != nil {
+			e.logger.Warn("failed to remove bundled file", zap.String("file", info.Name()), zap.Error(err))
+		}
+	}
+	e.debug("finished files bundled", zap.String("bundle", name), zap.Int("files", len(files)))
+	e.notifyRotated(RotatedFile{Path: name})
+	return nil
+}
+
+// writeTar writes the files of the directory to a tar archive, optionally gzip compressed
+func writeTar(archive, dir string, files []os.FileInfo, compress bool) error {
+	out, err := os.OpenFile(archive, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	var dest io.Writer = out
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(out)
+		dest = zw
+	}
+	tw := tar.NewWriter(dest)
+	for _, info := range files {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err = copyFile(tw, filepath.Join(dir, info.Name())); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return err
+		}
+	}
+	return out.Sync()
+}
+
+func copyFile(w io.Writer, f string) error {
+	in, err := os.Open(f)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(w, in)
+	return err
+}
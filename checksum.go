@@ -0,0 +1,101 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ChecksumNone   = "none"
+	ChecksumSHA256 = "sha256"
+	ChecksumCRC32  = "crc32"
+)
+
+// validateChecksum checks the checksum algorithm is supported
+func validateChecksum(algorithm string) error {
+	switch strings.ToLower(algorithm) {
+	case "", ChecksumNone, ChecksumSHA256, ChecksumCRC32:
+		return nil
+	}
+	return fmt.Errorf("invalid checksum [%s], valid values are [ %s, %s or %s ]", algorithm, ChecksumSHA256, ChecksumCRC32, ChecksumNone)
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumCRC32:
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm [%s]", algorithm)
+}
+
+// FileChecksum computes the hex encoded checksum of a file using the passed in algorithm
+func FileChecksum(path string, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksum writes a sidecar file named <file>.<algorithm> with the checksum of the finished file,
+// the sidecar uses the sha256sum output format so it can also be verified with standard tools; it returns the checksum
+func writeChecksum(path string, algorithm string) (string, error) {
+	sum, err := FileChecksum(path, algorithm)
+	if err != nil {
+		return "", err
+	}
+	sidecar := fmt.Sprintf("%s.%s", path, strings.ToLower(algorithm))
+	return sum, os.WriteFile(sidecar, []byte(fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))), 0644)
+}
+
+// VerifyChecksum verifies the integrity of a finished file using its checksum sidecar file, it looks
+// for a sha256 sidecar first and then for a crc32 one
+func VerifyChecksum(path string) error {
+	for _, algorithm := range []string{ChecksumSHA256, ChecksumCRC32} {
+		content, err := os.ReadFile(fmt.Sprintf("%s.%s", path, algorithm))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(string(content))
+		if len(fields) == 0 {
+			return fmt.Errorf("invalid %s checksum file for %s", algorithm, path)
+		}
+		sum, err := FileChecksum(path, algorithm)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, fields[0]) {
+			return fmt.Errorf("%s checksum mismatch for %s, expected %s but found %s", algorithm, path, fields[0], sum)
+		}
+		return nil
+	}
+	return fmt.Errorf("no checksum file found for %s", path)
+}
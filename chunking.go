@@ -0,0 +1,124 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// ChunkingConfig finishes the files at content defined boundaries rather than at the file size, so that
+// the same telemetry written again produces identical files for the storage backends deduplicating them.
+//
+// A rolling hash of the last 64 bytes written is checked at every byte once the file holds the minimum
+// size, and the file is finished after the batch where the hash matches; the file size remains the
+// maximum size. The files are only cut between batches so that every file can be read on its own, and
+// the options writing content unique to each file, such as the file header or the batch sequence, defeat
+// the deduplication.
+type ChunkingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MinSize is the size below which a file is never finished at a boundary, it defaults to a quarter of
+	// the average size
+	MinSize string `mapstructure:"minSize"`
+	// AverageSize is the average distance between two boundaries such as 1MiB, it defaults to a quarter of
+	// the file size
+	AverageSize string `mapstructure:"averageSize"`
+}
+
+// validateChunking checks the files are rotated by size and the chunk sizes are below the file size
+func (cfg *Config) validateChunking() error {
+	if cfg.Chunking == nil || !cfg.Chunking.Enabled {
+		return nil
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) || cfg.fileSizeBytes() == 0 {
+		return errors.New("chunking requires the files to be rotated by size")
+	}
+	if _, err := parseSize(cfg.Chunking.MinSize); err != nil {
+		return fmt.Errorf("invalid chunking minSize: %w", err)
+	}
+	if _, err := parseSize(cfg.Chunking.AverageSize); err != nil {
+		return fmt.Errorf("invalid chunking averageSize: %w", err)
+	}
+	min, average := cfg.chunkSizes()
+	if average < 64 {
+		return errors.New("chunking averageSize must be at least 64 bytes")
+	}
+	if min >= average || average >= cfg.fileSizeBytes() {
+		return fmt.Errorf("chunking minSize [%d] must be less than averageSize [%d] which must be less than the file size [%d]", min, average, cfg.fileSizeBytes())
+	}
+	return nil
+}
+
+// chunkSizes returns the minimum and average sizes of the chunks in bytes
+func (cfg *Config) chunkSizes() (int64, int64) {
+	// an invalid size is rejected by the validation of the configuration
+	min, _ := parseSize(cfg.Chunking.MinSize)
+	average, _ := parseSize(cfg.Chunking.AverageSize)
+	if average == 0 {
+		average = cfg.fileSizeBytes() / 4
+	}
+	if min == 0 {
+		min = average / 4
+	}
+	return min, average
+}
+
+// gearTable holds the random values of the bytes in the rolling hash, generated from a fixed seed so that
+// the boundaries are the same on every device and across restarts
+var gearTable = func() (table [256]uint64) {
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker finds the content defined boundaries of the files
+type chunker struct {
+	minSize int64
+	// mask has as many bits as the average size so that a boundary is expected every average size bytes,
+	// the top bits of the hash are used as they depend on the most bytes
+	mask uint64
+}
+
+// newChunker returns the chunker of the exporter, nil if the files are finished at the file size
+func newChunker(cfg *Config) *chunker {
+	if cfg.Chunking == nil || !cfg.Chunking.Enabled {
+		return nil
+	}
+	min, average := cfg.chunkSizes()
+	shift := bits.Len64(uint64(average)) - 1
+	return &chunker{minSize: min, mask: ((uint64(1) << shift) - 1) << (64 - shift)}
+}
+
+// boundary rolls the hash of the writer over the batch written at the offset and returns true if the file
+// crosses a boundary past the minimum size, the hash is reset when a new file is started
+func (c *chunker) boundary(w *fileWriter, offset int64, data []byte) bool {
+	if c == nil {
+		return false
+	}
+	if offset == 0 {
+		w.chunkHash = 0
+	}
+	found := false
+	for i, b := range data {
+		w.chunkHash = (w.chunkHash << 1) + gearTable[b]
+		if !found && offset+int64(i) >= c.minSize && w.chunkHash&c.mask == 0 {
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,326 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	fileexporter "southwinds.dev/file-exporter"
+)
+
+// list prints the finished files found under the directories
+func list(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	paths, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tFORMAT\tCOMPRESSION\tENCRYPTED\tBYTES\tMODIFIED")
+	for _, root := range paths {
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, ok := fileexporter.ParseFileName(d.Name())
+			if d.IsDir() || !ok {
+				return nil
+			}
+			stat, err := d.Info()
+			if err != nil {
+				return err
+			}
+			compression := info.Compression
+			if len(compression) == 0 {
+				compression = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%d\t%s\n", p, info.Format, compression, info.Encrypted, stat.Size(),
+				stat.ModTime().UTC().Format(time.RFC3339))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// validate verifies the checksum of the files that have a checksum sidecar and decodes all their records
+func validate(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var o fileOptions
+	o.register(flags)
+	paths, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	var failed bool
+	for _, path := range paths {
+		checksum := "no checksum"
+		if hasChecksum(path) {
+			if err = fileexporter.VerifyChecksum(path); err != nil {
+				fmt.Fprintf(out, "%s: FAILED %v\n", path, err)
+				failed = true
+				continue
+			}
+			checksum = "checksum ok"
+		}
+		records := 0
+		if err = iterate(path, &o, func(record) error {
+			records++
+			return nil
+		}); err != nil {
+			fmt.Fprintf(out, "%s: FAILED %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(out, "%s: OK %d records, %s\n", path, records, checksum)
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// hasChecksum returns true if the file has a checksum sidecar
+func hasChecksum(path string) bool {
+	for _, algorithm := range []string{fileexporter.ChecksumSHA256, fileexporter.ChecksumCRC32} {
+		if _, err := os.Stat(path + "." + algorithm); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// convert writes the records of a file in the json or protobuf format, the json records are written one
+// per line and the protobuf records one after the other so that they decode as a single message
+func convert(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	var o fileOptions
+	o.register(flags)
+	to := flags.String("to", "json", "the format written, json or proto")
+	output := flags.String("o", "", "the file written, the standard output if not set")
+	paths, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	var marshal func(r record) ([]byte, error)
+	switch strings.ToLower(*to) {
+	case "json":
+		marshal = marshalJSON
+	case "proto", fileexporter.Protobuf:
+		marshal = marshalProto
+	default:
+		return fmt.Errorf("invalid format [%s], valid values are json and proto", *to)
+	}
+	return writeOutput(*output, out, func(w io.Writer) error {
+		for _, path := range paths {
+			err := iterate(path, &o, func(r record) error {
+				data, err := marshal(r)
+				if err == nil {
+					_, err = w.Write(data)
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// decompress writes the decrypted and decompressed content of a file
+func decompress(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("decompress", flag.ContinueOnError)
+	var o fileOptions
+	o.register(flags)
+	output := flags.String("o", "", "the file written, the standard output if not set")
+	paths, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, out, func(w io.Writer) error {
+		for _, path := range paths {
+			data, _, err := fileexporter.DecodeFile(path, o.readOptions()...)
+			if err != nil {
+				return err
+			}
+			if _, err = w.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeOutput calls write with the output file, or with the standard output if no file is set; the file is
+// removed if write fails
+func writeOutput(path string, stdout io.Writer, write func(w io.Writer) error) error {
+	if len(path) == 0 {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// fileSummary accumulates the records of a file
+type fileSummary struct {
+	records   map[string]int
+	resources int
+	services  map[string]bool
+	first     pcommon.Timestamp
+	last      pcommon.Timestamp
+}
+
+// summary prints the records, resources, services and time range of each file
+func summary(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("summary", flag.ContinueOnError)
+	var o fileOptions
+	o.register(flags)
+	paths, err := parse(flags, args)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		s := fileSummary{records: make(map[string]int), services: make(map[string]bool)}
+		if err = iterate(path, &o, s.add); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", path)
+		for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+			if count, ok := s.records[signal]; ok {
+				fmt.Fprintf(out, "  %-10s %d\n", signal, count)
+			}
+		}
+		fmt.Fprintf(out, "  %-10s %d\n", "resources", s.resources)
+		services := make([]string, 0, len(s.services))
+		for service := range s.services {
+			services = append(services, service)
+		}
+		if len(services) > 0 {
+			sort.Strings(services)
+			fmt.Fprintf(out, "  %-10s %s\n", "services", strings.Join(services, ", "))
+		}
+		if s.first > 0 {
+			fmt.Fprintf(out, "  %-10s %s\n", "first", s.first.AsTime().UTC().Format(time.RFC3339Nano))
+			fmt.Fprintf(out, "  %-10s %s\n", "last", s.last.AsTime().UTC().Format(time.RFC3339Nano))
+		}
+	}
+	return nil
+}
+
+// add accounts the record
+func (s *fileSummary) add(r record) error {
+	switch r.signal {
+	case signalTraces:
+		s.records[signalTraces] += r.traces.SpanCount()
+		for i := 0; i < r.traces.ResourceSpans().Len(); i++ {
+			rs := r.traces.ResourceSpans().At(i)
+			s.addResource(rs.Resource())
+			for j := 0; j < rs.ScopeSpans().Len(); j++ {
+				spans := rs.ScopeSpans().At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					s.addTime(spans.At(k).StartTimestamp())
+					s.addTime(spans.At(k).EndTimestamp())
+				}
+			}
+		}
+	case signalMetrics:
+		s.records[signalMetrics] += r.metrics.DataPointCount()
+		for i := 0; i < r.metrics.ResourceMetrics().Len(); i++ {
+			rm := r.metrics.ResourceMetrics().At(i)
+			s.addResource(rm.Resource())
+			for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+				metrics := rm.ScopeMetrics().At(j).Metrics()
+				for k := 0; k < metrics.Len(); k++ {
+					s.addMetricTimes(metrics.At(k))
+				}
+			}
+		}
+	case signalLogs:
+		s.records[signalLogs] += r.logs.LogRecordCount()
+		for i := 0; i < r.logs.ResourceLogs().Len(); i++ {
+			rl := r.logs.ResourceLogs().At(i)
+			s.addResource(rl.Resource())
+			for j := 0; j < rl.ScopeLogs().Len(); j++ {
+				logs := rl.ScopeLogs().At(j).LogRecords()
+				for k := 0; k < logs.Len(); k++ {
+					s.addTime(logs.At(k).Timestamp())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fileSummary) addResource(resource pcommon.Resource) {
+	s.resources++
+	if service, ok := resource.Attributes().Get("service.name"); ok {
+		s.services[service.AsString()] = true
+	}
+}
+
+func (s *fileSummary) addMetricTimes(metric pmetric.Metric) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			s.addTime(metric.Gauge().DataPoints().At(i).Timestamp())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			s.addTime(metric.Sum().DataPoints().At(i).Timestamp())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			s.addTime(metric.Histogram().DataPoints().At(i).Timestamp())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			s.addTime(metric.ExponentialHistogram().DataPoints().At(i).Timestamp())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			s.addTime(metric.Summary().DataPoints().At(i).Timestamp())
+		}
+	}
+}
+
+func (s *fileSummary) addTime(ts pcommon.Timestamp) {
+	if ts == 0 {
+		return
+	}
+	if s.first == 0 || ts < s.first {
+		s.first = ts
+	}
+	if ts > s.last {
+		s.last = ts
+	}
+}
@@ -0,0 +1,101 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+// fileexp inspects and converts the files written by the file exporter, it lists the finished files of a
+// directory, validates their checksum and records, converts them between the json and protobuf formats,
+// writes their decrypted and decompressed content and prints a summary of their records
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	fileexporter "southwinds.dev/file-exporter"
+)
+
+const usage = `usage: fileexp <command> [options] <path>...
+
+commands:
+  list        lists the finished files of the directories with their encoding and size
+  validate    verifies the checksum and decodes every record of the files
+  convert     converts a file to the json or protobuf format
+  decompress  writes the decrypted and decompressed content of a file
+  summary     prints the number of records, resources and the time range of the files
+
+run fileexp <command> -h for the options of a command
+`
+
+// the signals of the records, as named in the file names and manifests
+const (
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+)
+
+// errFailed is returned when a command reported failures for some of the files
+var errFailed = errors.New("failed")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	commands := map[string]func(args []string, out io.Writer) error{
+		"list":       list,
+		"validate":   validate,
+		"convert":    convert,
+		"decompress": decompress,
+		"summary":    summary,
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err := command(os.Args[2:], os.Stdout); err != nil {
+		if !errors.Is(err, errFailed) {
+			fmt.Fprintf(os.Stderr, "fileexp %s: %v\n", os.Args[1], err)
+		}
+		os.Exit(1)
+	}
+}
+
+// fileOptions are the options of the commands reading the records of the files
+type fileOptions struct {
+	keyFile string
+	signal  string
+}
+
+// register adds the options to the flags of the command
+func (o *fileOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.keyFile, "key", "", "the file holding the key of the encrypted files")
+	flags.StringVar(&o.signal, "signal", "", "the signal of the records, traces, metrics or logs; detected from the manifest, the json records or the file name if not set")
+}
+
+// readOptions returns the options reading the files
+func (o *fileOptions) readOptions() []fileexporter.ReadOption {
+	if len(o.keyFile) == 0 {
+		return nil
+	}
+	return []fileexporter.ReadOption{fileexporter.WithDecryptionKey(fileexporter.FileKeyProvider{Path: o.keyFile})}
+}
+
+// parse parses the arguments of the command and returns the paths, at least one path is required
+func parse(flags *flag.FlagSet, args []string) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return nil, errors.New("a path is required")
+	}
+	return flags.Args(), nil
+}
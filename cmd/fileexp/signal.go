@@ -0,0 +1,146 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	fileexporter "southwinds.dev/file-exporter"
+)
+
+// record is a decoded record of a file, only the telemetry of its signal is set
+type record struct {
+	signal  string
+	traces  ptrace.Traces
+	metrics pmetric.Metrics
+	logs    plog.Logs
+}
+
+// iterate decodes every record of the file and calls fn with it, the signal of the json records is read
+// from their content and the signal of the protobuf records must be known from the options, the manifest
+// or the file name
+func iterate(path string, o *fileOptions, fn func(r record) error) error {
+	fileSignal := o.signal
+	if len(fileSignal) == 0 {
+		fileSignal = signalOfFile(path)
+	}
+	return fileexporter.IterateRecords(path, func(data []byte, isJSON bool) error {
+		signal := fileSignal
+		if isJSON && len(o.signal) == 0 {
+			signal = signalOfJSON(data)
+		}
+		r := record{signal: signal}
+		var err error
+		switch signal {
+		case signalTraces:
+			if isJSON {
+				r.traces, err = (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(data)
+			} else {
+				r.traces, err = (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(data)
+			}
+		case signalMetrics:
+			if isJSON {
+				r.metrics, err = (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(data)
+			} else {
+				r.metrics, err = (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(data)
+			}
+		case signalLogs:
+			if isJSON {
+				r.logs, err = (&plog.JSONUnmarshaler{}).UnmarshalLogs(data)
+			} else {
+				r.logs, err = (&plog.ProtoUnmarshaler{}).UnmarshalLogs(data)
+			}
+		case "":
+			return fmt.Errorf("the signal of %s cannot be detected, set it with -signal", path)
+		default:
+			return fmt.Errorf("invalid signal [%s], valid values are traces, metrics and logs", signal)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode %s of %s: %w", signal, path, err)
+		}
+		return fn(r)
+	}, o.readOptions()...)
+}
+
+// signalOfJSON returns the signal of a json record from its first field, both the OTLP json of the
+// telemetry and of the export requests start with the resources of the signal
+func signalOfJSON(data []byte) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+	for key, signal := range map[string]string{"resourceSpans": signalTraces, "resourceMetrics": signalMetrics, "resourceLogs": signalLogs} {
+		if _, ok := fields[key]; ok {
+			return signal
+		}
+	}
+	return ""
+}
+
+// signalOfFile returns the signal of the records of a file from its manifest sidecar or from its name,
+// empty if it cannot be found or the file holds several signals
+func signalOfFile(path string) string {
+	if content, err := os.ReadFile(path + ".manifest.json"); err == nil {
+		var m struct {
+			Signals []string `json:"signals"`
+		}
+		if json.Unmarshal(content, &m) == nil && len(m.Signals) == 1 {
+			return m.Signals[0]
+		}
+	}
+	var found string
+	name := filepath.Base(path)
+	for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+		if strings.Contains(name, signal) {
+			if len(found) > 0 {
+				return ""
+			}
+			found = signal
+		}
+	}
+	return found
+}
+
+// marshalJSON returns the OTLP json of the record followed by a newline
+func marshalJSON(r record) ([]byte, error) {
+	var data []byte
+	var err error
+	switch r.signal {
+	case signalTraces:
+		data, err = (&ptrace.JSONMarshaler{}).MarshalTraces(r.traces)
+	case signalMetrics:
+		data, err = (&pmetric.JSONMarshaler{}).MarshalMetrics(r.metrics)
+	default:
+		data, err = (&plog.JSONMarshaler{}).MarshalLogs(r.logs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.TrimSpace(data), '\n'), nil
+}
+
+// marshalProto returns the OTLP protobuf of the record
+func marshalProto(r record) ([]byte, error) {
+	switch r.signal {
+	case signalTraces:
+		return (&ptrace.ProtoMarshaler{}).MarshalTraces(r.traces)
+	case signalMetrics:
+		return (&pmetric.ProtoMarshaler{}).MarshalMetrics(r.metrics)
+	default:
+		return (&plog.ProtoMarshaler{}).MarshalLogs(r.logs)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionLz4  = "lz4"
+)
+
+// the extension appended to the name of finished files compressed with each codec
+var compressionExt = map[string]string{
+	CompressionGzip: "gz",
+	CompressionZstd: "zst",
+	CompressionLz4:  "lz4",
+}
+
+// validateCompression checks the codec is supported and the level is valid for it, a zero level
+// uses the default level of the codec
+func validateCompression(codec string, level int) error {
+	switch strings.ToLower(codec) {
+	case "", CompressionNone:
+		if level != 0 {
+			return fmt.Errorf("compressionLevel requires a compression codec")
+		}
+	case CompressionGzip:
+		if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+			return fmt.Errorf("invalid gzip compressionLevel [%d], valid values are between %d and %d", level, gzip.HuffmanOnly, gzip.BestCompression)
+		}
+	case CompressionZstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("invalid zstd compressionLevel [%d], valid values are between 1 and 22", level)
+		}
+	case CompressionLz4:
+		if level != 0 {
+			return fmt.Errorf("lz4 does not support compression levels, compressionLevel must not be defined")
+		}
+	default:
+		return fmt.Errorf("invalid compression [%s], valid values are [ %s, %s, %s or %s ]", codec, CompressionNone, CompressionGzip, CompressionZstd, CompressionLz4)
+	}
+	return nil
+}
+
+// newCompressor returns a writer compressing to w with the codec
+func newCompressor(w io.Writer, codec string, level int) (io.WriteCloser, error) {
+	switch codec {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionZstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	case CompressionLz4:
+		return newLz4Writer(w), nil
+	}
+	return nil, fmt.Errorf("unsupported compression [%s]", codec)
+}
@@ -9,11 +9,17 @@
 package fileexporter
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"strings"
 )
 
@@ -24,18 +30,310 @@ const (
 	eventsSize       = "EventsPerFile"
 	Json             = "json"
 	Protobuf         = "protobuf"
+	// OtlpJson writes one OTLP JSON ExportRequest per line, as expected by the upstream file receiver
+	OtlpJson = "otlp-json"
+	// Parquet writes flattened telemetry as parquet files with a row group per batch
+	Parquet = "parquet"
+	// Csv writes metric data points as csv rows, it is only supported for metrics
+	Csv = "csv"
+	// Prometheus writes metrics in the prometheus text exposition format, one block per batch, it is only
+	// supported for metrics
+	Prometheus = "prometheus"
+	// JaegerJson writes traces in the json format of the jaeger UI file import, one document per batch and
+	// line; it is only supported for traces
+	JaegerJson = "jaeger-json"
+	// ZipkinJson writes traces as zipkin v2 json lists of spans, one list per batch and line; it is only
+	// supported for traces
+	ZipkinJson = "zipkin-json"
+	// Custom writes telemetry with the marshaler registered with WithMarshaler
+	Custom = "custom"
 )
 
 // Config defines configuration for file exporter.
 type Config struct {
 	config.ExporterSettings `mapstructure:",squash"` // squash ensures fields are correctly decoded in embedded struct
+	// QueueSettings buffers batches in memory so bursts are absorbed while files are written
+	exporterhelper.QueueSettings `mapstructure:"sending_queue"`
+	// RetrySettings retries failed writes with backoff, e.g. on transient disk errors
+	exporterhelper.RetrySettings `mapstructure:"retry_on_failure"`
+	// TimeoutSettings is the deadline of every write, including the rotation and compression it triggers, a
+	// write exceeding it is abandoned and completes in the background, and the batch is retried as per the
+	// retry settings; the retry waits for the abandoned write and skips the batch if it landed. The delivery
+	// is at least once as a batch numbered with the resource target differs when it is retried, and is
+	// written again; zero means no timeout
+	exporterhelper.TimeoutSettings `mapstructure:",squash"`
 
 	// Path of the file to write to. Path is relative to current directory.
-	Path          string `mapstructure:"path"`
-	FileSizeKb    int64  `mapstructure:"filesizekb"`
+	// The ${env:NAME} and ${file:path} placeholders of the paths, the encryption key and key file and the batch
+	// sequence source are replaced with the environment variable or the content of the file when the exporter
+	// starts, so that the paths and keys of every device can be provisioned with the same configuration
+	Path string `mapstructure:"path"`
+	// Deprecated: FileSizeKb is kept for compatibility, use FileSize instead; its key is lower case unlike
+	// the other keys and it is converted to FileSizeBytes when the configuration is loaded
+	FileSizeKb int64 `mapstructure:"filesizekb"`
+	// FileSizeBytes is the size in bytes at which the in process file is rotated
+	FileSizeBytes int64 `mapstructure:"fileSizeBytes"`
+	// FileSize is the size at which the in process file is rotated with its unit, such as 5MiB or 500KB; the
+	// IEC units KiB, MiB, GiB and TiB are powers of 1024 and the SI units KB, MB, GB and TB powers of 1000
+	FileSize      string `mapstructure:"fileSize"`
 	EventsPerFile int64  `mapstructure:"eventsPerFile"`
 	Format        string `mapstructure:"format"`
 	Default       string `mapstructure:"default"`
+	// Formats writes every batch in each of the formats instead of the single format, each format to its
+	// own sub directory of the path named after the format
+	Formats []string `mapstructure:"formats"`
+	// FileNameTemplate defines the name of finished files, it supports the {signal}, {hostname},
+	// {timestamp}, {seq}, {ext}, {count}, {bytes} and {shard} placeholders; {count} is the number of records written
+	// to the file and {bytes} the size of the finished file, after compression and encryption
+	FileNameTemplate string `mapstructure:"fileNameTemplate"`
+	// Verbosity defines the amount of logging of the exporter, valid values are none, basic, normal and detailed
+	Verbosity configtelemetry.Level `mapstructure:"verbosity"`
+	// PartitionBy lists the resource attribute keys used to split telemetry into sub directories of the path
+	PartitionBy []string `mapstructure:"partitionBy"`
+	// Compression is the codec used to compress finished files, valid values are none, gzip, zstd and lz4,
+	// the codec extension is appended to the name of the finished file
+	Compression string `mapstructure:"compression"`
+	// CompressionLevel is the level of the codec, zero uses the default level of the codec
+	CompressionLevel int `mapstructure:"compressionLevel"`
+	// Encryption defines how finished files are encrypted at rest
+	Encryption EncryptionConfig `mapstructure:"encryption"`
+	// Checksum is the algorithm used to write a checksum sidecar file for each finished file,
+	// valid values are sha256, crc32 and none
+	Checksum string `mapstructure:"checksum"`
+	// MaxRecordSizeKb is the maximum size of a single marshaled batch, zero means no limit
+	MaxRecordSizeKb int64 `mapstructure:"maxRecordSizeKb"`
+	// OversizeBehavior defines what happens to batches exceeding MaxRecordSizeKb, valid values are split, drop and error
+	OversizeBehavior string `mapstructure:"oversizeBehavior"`
+	// CsvColumns lists the columns written by the csv format, attribute values can be written to their own
+	// column using the attr.<key> and resource.<key> columns
+	CsvColumns []string `mapstructure:"csvColumns"`
+	// MinFreeDiskMb is the minimum free space to keep on the file system of the path, zero means no limit
+	MinFreeDiskMb int64 `mapstructure:"minFreeDiskMb"`
+	// MaxDirSizeMb is the maximum total size of the files under the path, zero means no limit
+	MaxDirSizeMb int64 `mapstructure:"maxDirSizeMb"`
+	// MinFreeDisk and MaxDirSize are the disk limits with their unit, such as 2GiB, instead of megabytes
+	MinFreeDisk string `mapstructure:"minFreeDisk"`
+	MaxDirSize  string `mapstructure:"maxDirSize"`
+	// OnDiskFull defines what happens when a disk usage limit is reached, valid values are drop, block and purge-oldest
+	OnDiskFull string `mapstructure:"onDiskFull"`
+	// Bundle defines how finished files are grouped into tar archives
+	Bundle BundleConfig `mapstructure:"bundle"`
+	// Rotation set to none appends everything to a single fixed file instead of rotating files
+	Rotation string `mapstructure:"rotation"`
+	// FileName is the name of the fixed file when rotation is none, it defaults to telemetry.<ext>
+	FileName string `mapstructure:"fileName"`
+	// TruncateOnStart empties the fixed file when the exporter starts
+	TruncateOnStart bool `mapstructure:"truncateOnStart"`
+	// Include restricts the written telemetry to the telemetry matching the filter
+	Include *MatchConfig `mapstructure:"include"`
+	// Exclude prevents the telemetry matching the filter from being written
+	Exclude *MatchConfig `mapstructure:"exclude"`
+	// Manifest writes a JSON manifest describing each finished file, valid values are none, sidecar
+	// for a <file>.manifest.json file and rolling for a manifest.jsonl file per directory
+	Manifest string `mapstructure:"manifest"`
+	// Identity adds the identity of the collector or host to the resources or manifests
+	Identity IdentityConfig `mapstructure:"identity"`
+	// IdleFlushSeconds finishes a non empty in process file when no data has been written to it for the
+	// number of seconds, zero disables it
+	IdleFlushSeconds int64 `mapstructure:"idleFlushSeconds"`
+	// RecoverInProcess defines what happens on start to the in process files left by a previous run,
+	// valid values are resume, finalize and discard, it defaults to resume and to discard for parquet
+	RecoverInProcess string `mapstructure:"recoverInProcess"`
+	// RouteBySeverity writes the log records at or above a severity to their own directory and file series
+	RouteBySeverity *SeverityRoutingConfig `mapstructure:"routeBySeverity"`
+	// FlushBytes, FlushRecords and FlushIntervalMs aggregate the batches in memory and write them in a
+	// single append once the buffered bytes or records reach the limits or the oldest batch has been
+	// buffered for the interval, zero values disable the aggregation; the interval defaults to one second
+	FlushBytes      int64 `mapstructure:"flushBytes"`
+	FlushRecords    int64 `mapstructure:"flushRecords"`
+	FlushIntervalMs int64 `mapstructure:"flushIntervalMs"`
+	// TenantAttribute is the resource attribute, or else the client metadata key, holding the tenant of
+	// the telemetry; the files of each tenant are written under path/<tenant> so tenants never share a file
+	TenantAttribute string `mapstructure:"tenantAttribute"`
+	// FileHeader writes a header record at the start of each file with the schema version, format,
+	// exporter version, creation time and identity labels; it is supported by json, otlp-json and protobuf
+	FileHeader bool `mapstructure:"fileHeader"`
+	// MaxPendingFiles is the number of finished files under the path from which the uploader is considered
+	// behind and OnBacklog applies, zero means no limit
+	MaxPendingFiles int `mapstructure:"maxPendingFiles"`
+	// OnBacklog defines what happens when the pending files limit is reached, valid values are block to
+	// return a retryable error so the data is held upstream, drop and continue; it defaults to block
+	OnBacklog string `mapstructure:"onBacklog"`
+	// RotateAt finishes the in process files at hourly or daily clock boundaries so that each finished file
+	// holds the data of one bucket, the default file name template is then {bucket}-{seq}.{ext}
+	RotateAt string `mapstructure:"rotateAt"`
+	// RotateTimezone is the IANA time zone of the clock boundaries, it defaults to UTC
+	RotateTimezone string `mapstructure:"rotateTimezone"`
+	// RotateTriggerFile is the name of a marker file which, when created in the path, finishes all the
+	// in process files regardless of their size or count; the marker is removed once they are finished
+	RotateTriggerFile string `mapstructure:"rotateTriggerFile"`
+	// ProtobufExportRequest wraps each protobuf payload in the OTLP Export*ServiceRequest message so that
+	// the files hold what an OTLP/gRPC client sends and can be replayed to an OTLP endpoint
+	ProtobufExportRequest bool `mapstructure:"protobufExportRequest"`
+	// MirrorPaths are directories written with the same data as the path, each with its own files
+	MirrorPaths []string `mapstructure:"mirrorPaths"`
+	// MirrorPolicy decides if a failed mirror fails the write, valid values are all for every path to
+	// succeed, any for at least one path to succeed and primary for the path to succeed, it defaults to all
+	MirrorPolicy string `mapstructure:"mirrorPolicy"`
+	// DeadLetterPath is the directory where the payloads that failed to be written are kept with an error
+	// record so they can be inspected and reprocessed, empty disables it
+	DeadLetterPath string `mapstructure:"deadLetterPath"`
+	// DeadLetterMaxSizeMb is the maximum total size of the dead letter directory, zero means no limit
+	DeadLetterMaxSizeMb int64 `mapstructure:"deadLetterMaxSizeMb"`
+	// MetricsTransform converts the temporality of the metrics or drops their exemplars or histogram
+	// buckets before they are written
+	MetricsTransform *MetricsTransformConfig `mapstructure:"metricsTransform"`
+	// MaxLogBodyBytes truncates the log bodies longer than the number of bytes, MaxAttributeValueLength
+	// truncates the log record attribute values and MaxAttributesPerRecord drops the attributes of a log
+	// record beyond the number; zero values mean no limit
+	MaxLogBodyBytes         int `mapstructure:"maxLogBodyBytes"`
+	MaxAttributeValueLength int `mapstructure:"maxAttributeValueLength"`
+	MaxAttributesPerRecord  int `mapstructure:"maxAttributesPerRecord"`
+	// Redact replaces the values of the matching resource, scope and record attributes of all signals with
+	// a mask or their hash before they are written
+	Redact *RedactConfig `mapstructure:"redact"`
+	// TimestampLayout is the format of the {timestamp} placeholder of the file names, one of epochMillis,
+	// epochNanos, rfc3339, rfc3339nano or a go time layout; it defaults to 2006_01_02_15_04_05_999999999
+	TimestampLayout string `mapstructure:"timestampLayout"`
+	// TimestampTimezone is the IANA time zone of the {timestamp} placeholder, it defaults to UTC
+	TimestampTimezone string `mapstructure:"timestampTimezone"`
+	// Handoff defines how the finished files are made available to the uploaders, valid values are none,
+	// ready to finish them in a pending sub directory and move them to a ready sub directory once post
+	// processed, and marker to write a <file>.done marker once they are complete; it defaults to none
+	Handoff string `mapstructure:"handoff"`
+	// WriteMode defines how the in process files are written, valid values are append, preallocate to
+	// allocate the blocks of the files rotated by size when they are created and mmap to also write them
+	// through a memory mapping, on linux only; it defaults to append
+	WriteMode string `mapstructure:"writeMode"`
+	// MaxWriteBytesPerSecond limits the bytes written to the in process files per second, the exports wait
+	// for the rate to allow their batch and fail with a retryable error if their deadline would pass first;
+	// the limit applies to each path and mirror path write, zero means no limit
+	MaxWriteBytesPerSecond int64 `mapstructure:"maxWriteBytesPerSecond"`
+	// InstanceID identifies the collector process when several processes write to the same path, it must
+	// be unique among them; each instance writes its own in process files and only recovers its own, the
+	// finished files are renamed holding a lock on their directory and the default file name templates
+	// include the {instance} placeholder so the final names do not collide; the name of a named exporter
+	// entry, a for file/a, is appended to the instance so the entries of a collector sharing the path do not
+	// collide either
+	InstanceID string `mapstructure:"instanceId"`
+	// Transforms are statements executed on the telemetry passing the include and exclude filters before
+	// it is redacted and written, for instance to drop attributes or set the tenant attribute of the files
+	Transforms *TransformConfig `mapstructure:"transforms"`
+	// SignalDirectories writes each signal to its own sub directory of the path, so that every finished file
+	// holds a single signal and the {signal} placeholder of the file names never resolves to mixed; parquet
+	// files and the signals of pipelines of different signals sharing the exporter are always written this way
+	SignalDirectories bool `mapstructure:"signalDirectories"`
+	// OnRotate runs a command with the path of every finished file once it is complete, nil if no
+	// command is run
+	OnRotate *OnRotateConfig `mapstructure:"onRotate"`
+	// PersistState saves the rotation counters and sequence numbers of each directory in a state file
+	// once a batch is written, so that a restart resumes the in process file with its exact event count
+	// and statistics and continues the sequence numbers; it costs a small write per batch
+	PersistState bool `mapstructure:"persistState"`
+	// DryRun marshals the telemetry and accounts the files that would be written and finished by size or
+	// event count without writing anything, the exporter metrics then report what would have been written
+	// with the dry_run attribute; the output paths are neither created nor checked
+	DryRun bool `mapstructure:"dryRun"`
+	// WriteBufferKb is the size in KB of the buffer aggregating the writes to each in process file, it is
+	// enlarged to hold the flush bytes; it defaults to 64KB
+	WriteBufferKb int64 `mapstructure:"writeBufferKb"`
+	// DirectIO writes the in process files bypassing the page cache in aligned blocks, so that the telemetry
+	// does not evict the pages of the other processes of the host; it is only supported on linux and the
+	// partial last block of the file is rewritten on every flush
+	DirectIO bool `mapstructure:"directIO"`
+	// Fallback is the local path written to while the path is on a network file system that is unavailable,
+	// the files written there are moved to the path once it is available again; nil if there is none
+	Fallback *FallbackConfig `mapstructure:"fallback"`
+	// PrettyPrint indents the json documents of the json and otlp-json formats instead of writing them on
+	// a single line
+	PrettyPrint bool `mapstructure:"prettyPrint"`
+	// RecordSeparator defines how the json documents are delimited, valid values are none, newline and
+	// json-seq to write JSON text sequences as per RFC 7464; it defaults to none for the json format and
+	// newline for the otlp-json format
+	RecordSeparator string `mapstructure:"recordSeparator"`
+	// BatchSequence stamps every written batch with an increasing sequence number and the source that wrote
+	// it, the last number is saved under the path so the numbering continues after a restart
+	BatchSequence *BatchSequenceConfig `mapstructure:"batchSequence"`
+	// SkipEmpty removes the resources, scopes and metrics holding no records and skips the batches left
+	// without records, for instance the logs of a partition all routed by severity, so that they are not
+	// written nor counted towards the events per file; it defaults to true
+	SkipEmpty bool `mapstructure:"skipEmpty"`
+	// QuarantineAfter is the number of consecutive failed writes after which the in process file is renamed
+	// with the .failed extension and a new in process file is started, zero never quarantines the file;
+	// it defaults to 5
+	QuarantineAfter int `mapstructure:"quarantineAfter"`
+	// InProcessSuffix is the name of the in process files, it must start with a dot to hide them or with an
+	// underscore; the in process files of the instances sharing the path are named with the first character
+	// of the suffix, the instance id and the suffix. It defaults to .inproc, and to _inproc.tmp on windows
+	InProcessSuffix string `mapstructure:"inProcessSuffix"`
+	// CountBy defines what eventsPerFile counts, valid values are batches to count the batches written and
+	// records to count the spans, metric data points and log records; it defaults to batches
+	CountBy string `mapstructure:"countBy"`
+	// FileTimestamp defines the time of the {timestamp} placeholder of the file names, valid values are
+	// rename for the time the file is finished and firstRecord for the timestamp of its earliest record;
+	// it defaults to rename. The time never goes back from the previous file of the directory
+	FileTimestamp string `mapstructure:"fileTimestamp"`
+	// Precreate creates the partition, route and signal sub directories on start instead of on their first
+	// write; nil if they are only created when written to
+	Precreate *PrecreateConfig `mapstructure:"precreate"`
+	// AdaptiveDegradation reduces the fidelity of the metrics and traces written as the finished files pile up
+	// or the disk fills, nil writes them in full
+	AdaptiveDegradation *AdaptiveDegradationConfig `mapstructure:"adaptiveDegradation"`
+	// Shards spreads the batches round robin across that many in process files per directory, each rotated
+	// on its own, so that the writes do not wait for a single file; the finished files are told apart by the
+	// {shard} placeholder added to the default file name template. Zero or one writes a single file
+	Shards int `mapstructure:"shards"`
+	// Notify sends the path of every finished file to pilot over a unix domain socket or gRPC, nil if pilot
+	// polls the output directory
+	Notify *NotifyConfig `mapstructure:"notify"`
+	// InterleaveSignals writes the signals of the pipelines of different signals sharing the exporter to the
+	// same files instead of the traces, metrics and logs sub directories of the path
+	InterleaveSignals bool `mapstructure:"interleaveSignals"`
+	// RotationHeadroomBytes rotates the in process files as soon as a batch would take them over the file size
+	// less the headroom, so that the headers, footers and encryption added to the batches never take a
+	// finished file over the file size
+	RotationHeadroomBytes int64 `mapstructure:"rotationHeadroomBytes"`
+	// ProtobufDescriptor writes the file header of the protobuf files with the OTLP version, the message type
+	// of the batches and the descriptors of the OTLP protos, so that the files can be decoded by generic
+	// tools long after the OTLP protos have changed
+	ProtobufDescriptor bool `mapstructure:"protobufDescriptor"`
+	// Lanes write the matching telemetry to their own sub directories of the path with their own rotation,
+	// for instance to finish the files of high priority telemetry sooner; the telemetry is written to the first
+	// lane it matches and the telemetry matching no lane to the files of the path
+	Lanes []LaneConfig `mapstructure:"lanes"`
+	// DailyReport writes a report of the telemetry written during the day to the reports sub directory of the
+	// path, with the records and bytes of each signal, the files rotated, the errors and the dropped batches,
+	// so that a device can be checked locally; the day is in the timezone of the file name timestamps
+	DailyReport bool `mapstructure:"dailyReport"`
+	// MaxInProcessAgeSeconds finishes an in process file once its first batch was written the number of
+	// seconds ago, whatever its size or count, so that the last telemetry of a quiet period is not held back
+	// until the file fills up; it is checked before every write and in the background, zero disables it
+	MaxInProcessAgeSeconds int64 `mapstructure:"maxInProcessAgeSeconds"`
+	// Dedupe skips the batches identical to a batch written within a window, such as the batches retried by
+	// the pipeline after their export timed out once they were written
+	Dedupe *DedupeConfig `mapstructure:"dedupe"`
+	// DataAge tracks the age of the records when they are written and flags the files holding stale records
+	DataAge *DataAgeConfig `mapstructure:"dataAge"`
+	// JSONLayout defines how the json documents of the batches are laid out in the files of the json formats,
+	// valid values are documents to write them one after the other and array to write every file as a single
+	// json array closed when the file is finished, for the tools importing a single json document per file;
+	// it defaults to documents
+	JSONLayout string `mapstructure:"jsonLayout"`
+	// Trash moves the files purged when the disk usage limit is reached to a trash directory instead of deleting them
+	Trash *TrashConfig `mapstructure:"trash"`
+	// StatusFile writes the size of the in process files, the number of pending files, the last rotation and the
+	// last error to the .status.json file of the path, updated atomically
+	StatusFile *StatusFileConfig `mapstructure:"statusFile"`
+	// Chunking finishes the files at content defined boundaries so that repeated telemetry produces identical files
+	Chunking *ChunkingConfig `mapstructure:"chunking"`
+	// OnForeignFiles defines what happens on start when the path holds files not named by the exporter, valid
+	// values are ignore, quarantine to rename them with the failed extension and refuse to fail the start; it
+	// defaults to ignore
+	OnForeignFiles string `mapstructure:"onForeignFiles"`
+
+	// deprecatedKeys are the deprecated keys found when the configuration was loaded and the keys replacing
+	// them, they are logged when the exporter is created
+	deprecatedKeys map[string]string
 }
 
 var _ component.ExporterConfig = (*Config)(nil)
@@ -43,31 +341,267 @@ var _ component.ExporterConfig = (*Config)(nil)
 // Validate checks if the exporter configuration is valid
 func (cfg *Config) Validate() error {
 
+	if len(cfg.Formats) > 0 {
+		return cfg.validateFormats()
+	}
 	if len(cfg.Path) == 0 {
 		return errors.New("path must be defined")
 	}
-	if len(cfg.Format) == 0 {
-		return errors.New("format must be defined as either json or protobuf")
+	if _, err := parseFormat(cfg.Format); err != nil {
+		return err
+	}
+
+	if err := cfg.QueueSettings.Validate(); err != nil {
+		return fmt.Errorf("invalid sending_queue configuration: %w", err)
+	}
+
+	if len(cfg.FileNameTemplate) == 0 {
+		cfg.FileNameTemplate = defaultFileNameTemplate
+	}
+	if err := validateFileNameTemplate(cfg.FileNameTemplate); err != nil {
+		return err
+	}
+	if err := cfg.validateTimestampLayout(); err != nil {
+		return err
 	}
 
-	if !strings.EqualFold(cfg.Format, Json) && !strings.EqualFold(cfg.Format, Protobuf) {
-		return fmt.Errorf("invalid format [%s] , valid format value is either [ json or protobuf]", cfg.Format)
+	for _, key := range cfg.PartitionBy {
+		if len(strings.TrimSpace(key)) == 0 {
+			return errors.New("partitionBy must not contain empty attribute keys")
+		}
+	}
+	if len(cfg.TenantAttribute) > 0 && len(strings.TrimSpace(cfg.TenantAttribute)) == 0 {
+		return errors.New("tenantAttribute must not be blank")
 	}
 
-	if cfg.FileSizeKb > 0 && cfg.EventsPerFile > 0 && len(cfg.Default) > 0 {
-		return fmt.Errorf("mention either fileSizeKb or eventsPerFile or default in telem.yaml file")
-	} else if cfg.FileSizeKb > 0 && cfg.EventsPerFile > 0 {
-		return fmt.Errorf("mention either fileSizeKb or eventsPerFile")
-	} else if cfg.FileSizeKb > 0 && len(cfg.Default) > 0 {
-		return fmt.Errorf("mention either fileSizeKb or default")
+	if err := validateCompression(cfg.Compression, cfg.CompressionLevel); err != nil {
+		return err
+	}
+	if err := cfg.Encryption.Validate(); err != nil {
+		return err
+	}
+	if err := validateChecksum(cfg.Checksum); err != nil {
+		return err
+	}
+	if cfg.MaxRecordSizeKb < 0 {
+		return errors.New("maxRecordSizeKb must not be negative")
+	}
+	if err := validateOversizeBehavior(cfg.OversizeBehavior); err != nil {
+		return err
+	}
+	if err := validateCsvColumns(cfg.CsvColumns); err != nil {
+		return err
+	}
+	if err := cfg.validateJSONOutput(); err != nil {
+		return err
+	}
+	if err := cfg.validateBatchSequence(); err != nil {
+		return err
+	}
+	if cfg.MinFreeDiskMb < 0 || cfg.MaxDirSizeMb < 0 {
+		return errors.New("minFreeDiskMb and maxDirSizeMb must not be negative")
+	}
+	if err := cfg.validateSizes(); err != nil {
+		return err
+	}
+	if err := validateOnDiskFull(cfg.OnDiskFull); err != nil {
+		return err
+	}
+	if err := cfg.Bundle.Validate(); err != nil {
+		return err
+	}
+	for _, filter := range []*MatchConfig{cfg.Include, cfg.Exclude} {
+		if filter == nil {
+			continue
+		}
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.RouteBySeverity != nil {
+		if err := cfg.RouteBySeverity.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.MetricsTransform != nil {
+		if err := cfg.MetricsTransform.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Redact != nil {
+		if err := cfg.Redact.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Transforms != nil {
+		if err := cfg.Transforms.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.OnRotate != nil {
+		if err := cfg.OnRotate.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := cfg.validateLogLimits(); err != nil {
+		return err
+	}
+	if err := cfg.validateWriteRate(); err != nil {
+		return err
+	}
+	if err := cfg.validateWriteBuffer(); err != nil {
+		return err
+	}
+	if err := cfg.validateQuarantine(); err != nil {
+		return err
+	}
+	if err := cfg.validateInProcessSuffix(); err != nil {
+		return err
+	}
+	if err := validateCountBy(cfg.CountBy); err != nil {
+		return err
+	}
+	if err := validateFileTimestamp(cfg.FileTimestamp); err != nil {
+		return err
+	}
+	if err := cfg.validatePrecreate(); err != nil {
+		return err
+	}
+	if err := cfg.validateAdaptiveDegradation(); err != nil {
+		return err
+	}
+	if err := cfg.validateShards(); err != nil {
+		return err
+	}
+	if err := cfg.validateNotify(); err != nil {
+		return err
+	}
+	if err := cfg.validateInterleaveSignals(); err != nil {
+		return err
+	}
+	if err := cfg.validateTimeout(); err != nil {
+		return err
+	}
+	if err := cfg.validateRotationHeadroom(); err != nil {
+		return err
+	}
+	if err := cfg.validateLanes(); err != nil {
+		return err
+	}
+	if err := cfg.validateMaxInProcessAge(); err != nil {
+		return err
+	}
+	if err := cfg.validateDedupe(); err != nil {
+		return err
+	}
+	if err := cfg.validateDataAge(); err != nil {
+		return err
+	}
+	if err := cfg.validateTrash(); err != nil {
+		return err
+	}
+	if err := cfg.validateStatusFile(); err != nil {
+		return err
+	}
+	if err := cfg.validateChunking(); err != nil {
+		return err
+	}
+	if err := cfg.validateForeignFiles(); err != nil {
+		return err
+	}
+	if err := cfg.validateInstanceID(); err != nil {
+		return err
+	}
+	if err := cfg.validatePersistState(); err != nil {
+		return err
+	}
+	if err := cfg.validateAggregation(); err != nil {
+		return err
+	}
+	if err := cfg.validatePersistentQueue(); err != nil {
+		return err
+	}
+	if err := validateRotateTrigger(cfg.RotateTriggerFile); err != nil {
+		return err
+	}
+	if cfg.ProtobufExportRequest && !strings.EqualFold(cfg.Format, Protobuf) {
+		return errors.New("protobufExportRequest requires the protobuf format")
+	}
+	if err := cfg.validateMirrors(); err != nil {
+		return err
+	}
+	if err := cfg.validateFallback(); err != nil {
+		return err
+	}
+	if cfg.DeadLetterMaxSizeMb < 0 {
+		return errors.New("deadLetterMaxSizeMb must not be negative")
+	}
+	if cfg.DeadLetterMaxSizeMb > 0 && len(cfg.DeadLetterPath) == 0 {
+		return errors.New("deadLetterMaxSizeMb requires deadLetterPath to be defined")
+	}
+	if err := validateManifest(cfg.Manifest); err != nil {
+		return err
+	}
+	if err := cfg.Identity.Validate(); err != nil {
+		return err
+	}
+	if cfg.Identity.Enabled && (strings.EqualFold(cfg.Identity.Target, IdentityManifest) || strings.EqualFold(cfg.Identity.Target, IdentityAll)) &&
+		(len(cfg.Manifest) == 0 || strings.EqualFold(cfg.Manifest, ManifestNone)) {
+		return errors.New("the identity manifest target requires the manifest to be enabled")
+	}
+	if cfg.IdleFlushSeconds < 0 {
+		return errors.New("idleFlushSeconds must not be negative")
+	}
+	if err := validateRecoverInProcess(cfg.RecoverInProcess, cfg.Format); err != nil {
+		return err
+	}
+	if err := validateFileHeader(cfg.FileHeader, cfg.Format); err != nil {
+		return err
+	}
+	if err := validateProtobufDescriptor(cfg.ProtobufDescriptor, cfg.Format); err != nil {
+		return err
+	}
+	if err := cfg.validateBacklog(); err != nil {
+		return err
+	}
+	if err := cfg.validateWriteMode(); err != nil {
+		return err
+	}
+	if err := cfg.validateHandoff(); err != nil {
+		return err
+	}
+	if err := cfg.validateRotateAt(); err != nil {
+		return err
+	}
+	if err := cfg.validateRotation(); err != nil {
+		return err
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		// the file size and events per file settings only apply to rotated files
+		return nil
+	}
+
+	if cfg.FileSizeKb < 0 || cfg.FileSizeBytes < 0 {
+		return errors.New("fileSizeKb and fileSizeBytes must not be negative")
+	}
+	if cfg.FileSizeKb > 0 && cfg.FileSizeBytes > 0 {
+		return fmt.Errorf("mention either fileSizeKb or fileSizeBytes")
+	}
+	sizeDefined := cfg.fileSizeBytes() > 0
+	if sizeDefined && cfg.EventsPerFile > 0 && len(cfg.Default) > 0 {
+		return fmt.Errorf("mention either fileSize or eventsPerFile or default in telem.yaml file")
+	} else if sizeDefined && cfg.EventsPerFile > 0 {
+		return fmt.Errorf("mention either fileSize or eventsPerFile")
+	} else if sizeDefined && len(cfg.Default) > 0 {
+		return fmt.Errorf("mention either fileSize or default")
 	} else if len(cfg.Default) > 0 && cfg.EventsPerFile > 0 {
 		return fmt.Errorf("mention either default or eventsPerFile")
 	}
 
-	if cfg.FileSizeKb == 0 && cfg.EventsPerFile == 0 && len(cfg.Default) == 0 {
-		return fmt.Errorf("fileSizeKb or eventsPerFile or default value must be defined in telem.yaml file")
+	if !sizeDefined && cfg.EventsPerFile == 0 && len(cfg.Default) == 0 {
+		return fmt.Errorf("fileSize or eventsPerFile or default value must be defined in telem.yaml file")
 	}
-	if cfg.FileSizeKb == 0 && cfg.EventsPerFile == 0 {
+	if !sizeDefined && cfg.EventsPerFile == 0 {
 		if strings.EqualFold(cfg.Default, fileSize) {
 			cfg.FileSizeKb = maxfilesize
 		} else if strings.EqualFold(cfg.Default, eventsSize) {
@@ -79,3 +613,37 @@ func (cfg *Config) Validate() error {
 
 	return nil
 }
+
+// fileSizeBytes returns the rotation size in bytes
+func (cfg *Config) fileSizeBytes() int64 {
+	if cfg.FileSizeBytes > 0 {
+		return cfg.FileSizeBytes
+	}
+	return sizeBytes(cfg.FileSize, cfg.FileSizeKb, 1024)
+}
+
+// key returns the exporter id followed by the hash of the normalized configuration, so that every exporter
+// entry has its own instance shared by its pipelines and an entry whose settings change gets a new instance
+func (cfg *Config) key() string {
+	n := *cfg
+	n.ExporterSettings = config.ExporterSettings{}
+	if path, err := filepath.Abs(n.Path); err == nil {
+		n.Path = path
+	}
+	n.Path = filepath.Clean(n.Path)
+	n.Format = strings.ToLower(n.Format)
+	n.Checksum = strings.ToLower(n.Checksum)
+	n.Compression = strings.ToLower(n.Compression)
+	n.OversizeBehavior = strings.ToLower(n.OversizeBehavior)
+	n.OnDiskFull = strings.ToLower(n.OnDiskFull)
+	if len(n.FileNameTemplate) == 0 {
+		n.FileNameTemplate = defaultFileNameTemplate
+	}
+	b, err := json.Marshal(n)
+	if err != nil {
+		// fall back to a fmt representation if the configuration holds values json cannot encode
+		b = []byte(fmt.Sprintf("%+v", n))
+	}
+	sum := sha256.Sum256(b)
+	return cfg.ID().String() + "/" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,30 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import "testing"
+
+func TestKeySeparatesExporterEntries(t *testing.T) {
+	a := createDefaultConfig().(*Config)
+	a.SetIDName("a")
+	a.Path = t.TempDir()
+	b := *a
+	b.SetIDName("b")
+	if a.key() == b.key() {
+		t.Fatal("exporter entries with the same settings must not share an instance")
+	}
+	same := *a
+	if a.key() != same.key() {
+		t.Fatal("the pipelines of an exporter entry must share its instance")
+	}
+	same.Path += "/other"
+	if a.key() == same.key() {
+		t.Fatal("an exporter entry whose settings change must get a new instance")
+	}
+}
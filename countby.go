@@ -0,0 +1,38 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// CountByBatches counts every batch written as one event
+	CountByBatches = "batches"
+	// CountByRecords counts the spans, metric data points and log records of the batches written
+	CountByRecords = "records"
+)
+
+// validateCountBy checks what the events per file count is supported
+func validateCountBy(countBy string) error {
+	switch strings.ToLower(countBy) {
+	case "", CountByBatches, CountByRecords:
+		return nil
+	}
+	return fmt.Errorf("invalid countBy [%s], valid values are [ %s or %s ]", countBy, CountByBatches, CountByRecords)
+}
+
+// eventsOf returns the number of events of the batch counted towards the events per file
+func (e *fileExporter) eventsOf(b *batch) int64 {
+	if e.countRecords {
+		return int64(b.records)
+	}
+	return 1
+}
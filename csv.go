@@ -0,0 +1,117 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	// the prefix of columns holding the value of a data point attribute
+	csvAttrPrefix = "attr."
+	// the prefix of columns holding the value of a resource attribute
+	csvResourcePrefix = "resource."
+)
+
+// the columns written when no csv columns are configured
+var defaultCsvColumns = []string{"timestamp", "metric_name", "metric_type", "unit", "value", "attributes", "resource_attributes"}
+
+// csvColumnValues maps the supported csv column names to the function returning their value
+var csvColumnValues = map[string]func(p metricPoint) string{
+	"timestamp": func(p metricPoint) string {
+		return p.timestamp.AsTime().UTC().Format(time.RFC3339Nano)
+	},
+	"time_unix_nano": func(p metricPoint) string {
+		return strconv.FormatUint(uint64(p.timestamp), 10)
+	},
+	"metric_name":         func(p metricPoint) string { return p.metric.Name() },
+	"metric_type":         func(p metricPoint) string { return p.metric.Type().String() },
+	"description":         func(p metricPoint) string { return p.metric.Description() },
+	"unit":                func(p metricPoint) string { return p.metric.Unit() },
+	"value":               func(p metricPoint) string { return strconv.FormatFloat(p.value, 'g', -1, 64) },
+	"count":               func(p metricPoint) string { return strconv.FormatUint(p.count, 10) },
+	"attributes":          func(p metricPoint) string { return attributesJSON(p.attributes) },
+	"resource_attributes": func(p metricPoint) string { return attributesJSON(p.resource.Attributes()) },
+}
+
+// isCsv returns true if the exporter writes csv files
+func (e *fileExporter) isCsv() bool {
+	return e.format == formatCsv
+}
+
+// validateCsvColumns checks that all the columns are supported
+func validateCsvColumns(columns []string) error {
+	for _, c := range columns {
+		if strings.HasPrefix(c, csvAttrPrefix) || strings.HasPrefix(c, csvResourcePrefix) {
+			if len(c[strings.Index(c, ".")+1:]) == 0 {
+				return fmt.Errorf("invalid csv column [%s], the attribute key must be defined", c)
+			}
+			continue
+		}
+		if _, ok := csvColumnValues[c]; !ok {
+			return fmt.Errorf("invalid csv column [%s]", c)
+		}
+	}
+	return nil
+}
+
+// csvValue returns the value of the column for the data point
+func csvValue(column string, p metricPoint) string {
+	if strings.HasPrefix(column, csvAttrPrefix) {
+		if v, ok := p.attributes.Get(strings.TrimPrefix(column, csvAttrPrefix)); ok {
+			return v.AsString()
+		}
+		return ""
+	}
+	if strings.HasPrefix(column, csvResourcePrefix) {
+		if v, ok := p.resource.Attributes().Get(strings.TrimPrefix(column, csvResourcePrefix)); ok {
+			return v.AsString()
+		}
+		return ""
+	}
+	return csvColumnValues[column](p)
+}
+
+// csvHeader returns the header row of the csv files
+func csvHeader(columns []string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(columns)
+	w.Flush()
+	return buf.Bytes()
+}
+
+// metricsCsv flattens the metrics into one csv row per data point
+func metricsCsv(md pmetric.Metrics, columns []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	var err error
+	record := make([]string, len(columns))
+	forEachMetricPoint(md, func(p metricPoint) {
+		if err != nil {
+			return
+		}
+		for i, c := range columns {
+			record[i] = csvValue(c, p)
+		}
+		err = w.Write(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
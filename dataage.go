@@ -0,0 +1,85 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DataAgeConfig tracks the age of the telemetry written, the time between the timestamp of a record and the
+// time it is written, so that the devices writing stale telemetry because of their clock or their buffers can
+// be found; the age is recorded in the internal metrics and in the manifests when they are enabled
+type DataAgeConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// StaleThreshold flags the finished files holding records older than the threshold when written, zero
+	// never flags them
+	StaleThreshold time.Duration `mapstructure:"staleThreshold"`
+}
+
+// validateDataAge checks the stale threshold is not negative
+func (cfg *Config) validateDataAge() error {
+	if cfg.DataAge != nil && cfg.DataAge.Enabled && cfg.DataAge.StaleThreshold < 0 {
+		return errors.New("dataAge staleThreshold must not be negative")
+	}
+	return nil
+}
+
+// newDataAge returns the data age configuration, nil if the age is not tracked
+func newDataAge(cfg *DataAgeConfig) *DataAgeConfig {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	dataAge := *cfg
+	return &dataAge
+}
+
+// batchAge returns the age of the oldest record of the batch written at now, zero if the batch has no
+// timestamps or its records are from the future
+func batchAge(b *batch, now time.Time) time.Duration {
+	if b.first == 0 {
+		return 0
+	}
+	if age := now.Sub(b.first.AsTime()); age > 0 {
+		return age
+	}
+	return 0
+}
+
+// isOutOfOrder returns true if the newest record of the batch is older than the newest record already
+// written to the file, that is the batch arrived after more recent telemetry
+func (s *fileStats) isOutOfOrder(b *batch) bool {
+	return b.last != 0 && s.last != 0 && b.last < s.last
+}
+
+// trackDataAge records the age of the batch written to the in process file of the writer and whether it is
+// out of order, it must be called before the batch is added to the statistics of the file
+func (e *fileExporter) trackDataAge(w *fileWriter, b *batch) {
+	if e.dataAge == nil {
+		return
+	}
+	e.telemetry.recordDataAge(batchAge(b, time.Now()), w.stats.isOutOfOrder(b))
+}
+
+// isStale returns true if the file with the statistics holds records older than the stale threshold
+func (e *fileExporter) isStale(stats fileStats) bool {
+	return e.dataAge != nil && e.dataAge.StaleThreshold > 0 && stats.maxAge > e.dataAge.StaleThreshold
+}
+
+// flagStale reports the finished file if it holds records older than the stale threshold
+func (e *fileExporter) flagStale(f string, stats fileStats) {
+	if !e.isStale(stats) {
+		return
+	}
+	e.telemetry.recordStaleFile()
+	e.logger.Warn("finished file holds stale telemetry", zap.String("file", f), zap.Duration("maxAge", stats.maxAge),
+		zap.Duration("staleThreshold", e.dataAge.StaleThreshold), zap.Int64("outOfOrderBatches", stats.outOfOrder))
+}
@@ -0,0 +1,142 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// deadLetter keeps the payloads that failed to be written so that they can be inspected and reprocessed,
+// each payload is written as OTLP protobuf next to a JSON error record
+type deadLetter struct {
+	path string
+	// maxSize is the maximum total size in bytes of the dead letter directory, zero means no limit
+	maxSize int64
+	mutex   sync.Mutex
+	seq     int64
+}
+
+// deadLetterRecord describes why a payload was dead lettered
+type deadLetterRecord struct {
+	Time    time.Time `json:"time"`
+	Signal  string    `json:"signal"`
+	Records int       `json:"records"`
+	Payload string    `json:"payload"`
+	Error   string    `json:"error"`
+}
+
+// newDeadLetter returns the dead letter of the path, nil if no dead letter path is configured
+func newDeadLetter(path string, maxSizeMb int64) *deadLetter {
+	if len(path) == 0 {
+		return nil
+	}
+	return &deadLetter{path: path, maxSize: maxSizeMb * 1024 * 1024}
+}
+
+// deadLetterTraces dead letters the traces if writing them failed, see deadLetterPayload
+func (e *fileExporter) deadLetterTraces(td ptrace.Traces, err error) error {
+	if !e.shouldDeadLetter(err) {
+		return err
+	}
+	payload, mErr := pbTracesMarshaller.MarshalTraces(td)
+	return e.deadLetterPayload(signalTraces, td.SpanCount(), payload, mErr, err)
+}
+
+// deadLetterMetrics dead letters the metrics if writing them failed, see deadLetterPayload
+func (e *fileExporter) deadLetterMetrics(md pmetric.Metrics, err error) error {
+	if !e.shouldDeadLetter(err) {
+		return err
+	}
+	payload, mErr := pbMetricsMarshaller.MarshalMetrics(md)
+	return e.deadLetterPayload(signalMetrics, md.DataPointCount(), payload, mErr, err)
+}
+
+// deadLetterLogs dead letters the logs if writing them failed, see deadLetterPayload
+func (e *fileExporter) deadLetterLogs(ld plog.Logs, err error) error {
+	if !e.shouldDeadLetter(err) {
+		return err
+	}
+	payload, mErr := pbLogsMarshaller.MarshalLogs(ld)
+	return e.deadLetterPayload(signalLogs, ld.LogRecordCount(), payload, mErr, err)
+}
+
+// shouldDeadLetter returns true if the dead letter is enabled and the write failed, a write abandoned
+// because its context is done is not dead lettered so that the pipeline can retry it
+func (e *fileExporter) shouldDeadLetter(err error) bool {
+	return err != nil && e.deadLetter != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// deadLetterPayload writes the payload that failed to be written with the write error, once the payload
+// is dead lettered the error is permanent so the pipeline does not retry and duplicate it; if the payload
+// cannot be dead lettered the write error is returned unchanged
+func (e *fileExporter) deadLetterPayload(signal string, records int, payload []byte, marshalErr error, writeErr error) error {
+	if marshalErr != nil {
+		e.logger.Error("failed to marshal payload for the dead letter", zap.String("signal", signal), zap.Error(marshalErr))
+		return writeErr
+	}
+	d := e.deadLetter
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now().UTC()
+	d.seq++
+	name := fmt.Sprintf("%s-%d-%s", now.Format("20060102T150405.000000000"), d.seq, signal)
+	payloadFile := filepath.Join(d.path, name+".pb")
+	record, err := json.Marshal(deadLetterRecord{
+		Time:    now,
+		Signal:  signal,
+		Records: records,
+		Payload: filepath.Base(payloadFile),
+		Error:   writeErr.Error(),
+	})
+	if err != nil {
+		e.logger.Error("failed to marshal dead letter record", zap.Error(err))
+		return writeErr
+	}
+	if d.maxSize > 0 {
+		used, err := dirSize(d.path)
+		if err != nil {
+			e.logger.Error("failed to retrieve size of the dead letter path", zap.String("path", d.path), zap.Error(err))
+			return writeErr
+		}
+		if used+int64(len(payload)+len(record)) > d.maxSize {
+			e.logger.Warn("the dead letter path is full, the payload is not dead lettered",
+				zap.String("path", d.path), zap.String("signal", signal), zap.Int("records", records))
+			return writeErr
+		}
+	}
+	if err = os.MkdirAll(d.path, 0755); err != nil {
+		e.logger.Error("failed to create the dead letter path", zap.String("path", d.path), zap.Error(err))
+		return writeErr
+	}
+	if err = os.WriteFile(payloadFile, payload, 0644); err != nil {
+		e.logger.Error("failed to write dead letter payload", zap.String("file", payloadFile), zap.Error(err))
+		return writeErr
+	}
+	if err = os.WriteFile(filepath.Join(d.path, name+".error.json"), record, 0644); err != nil {
+		e.logger.Error("failed to write dead letter record", zap.String("file", payloadFile), zap.Error(err))
+		return writeErr
+	}
+	e.logger.Warn("payload that failed to be written was dead lettered",
+		zap.String("file", payloadFile), zap.String("signal", signal), zap.Int("records", records), zap.Error(writeErr))
+	return consumererror.NewPermanent(fmt.Errorf("payload dead lettered to %s: %w", payloadFile, writeErr))
+}
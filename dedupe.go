@@ -0,0 +1,266 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDedupeWindowSize = 1000
+	defaultDedupeWindow     = 5 * time.Minute
+	// the suffix of the file remembering the batches written under the path, named as a state file so that
+	// it is excluded from the finished files
+	dedupeSuffix = "dedupe." + stateSuffix
+)
+
+// DedupeConfig skips the batches identical to a batch written recently, such as a batch delivered again
+// by the pipeline because its export timed out after it was written; the batches are remembered by the
+// hash of their content in a file under the path so that the duplicates are also skipped after a restart
+type DedupeConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// WindowSize is the number of most recent batches remembered, it defaults to 1000
+	WindowSize int `mapstructure:"windowSize"`
+	// Window is how long a written batch is remembered, it defaults to five minutes
+	Window time.Duration `mapstructure:"window"`
+}
+
+// validateDedupe checks the window is not negative
+func (cfg *Config) validateDedupe() error {
+	if cfg.Dedupe == nil || !cfg.Dedupe.Enabled {
+		return nil
+	}
+	if cfg.Dedupe.WindowSize < 0 || cfg.Dedupe.Window < 0 {
+		return errors.New("dedupe windowSize and window must not be negative")
+	}
+	return nil
+}
+
+// dedupeEntry is a batch remembered by the hash of its content
+type dedupeEntry struct {
+	key     [sha256.Size]byte
+	written time.Time
+}
+
+// deduper remembers the batches written within the window, oldest first
+type deduper struct {
+	size   int
+	window time.Duration
+	// file is the file the batches are appended to, empty in dry run
+	file    string
+	mutex   sync.Mutex
+	out     *os.File
+	entries []dedupeEntry
+	keys    map[[sha256.Size]byte]int
+	// lines is the number of lines of the file, it is compacted once it holds twice the window size
+	lines  int
+	logger *zap.Logger
+}
+
+// newDeduper returns the deduper of the configuration, nil if the batches are not deduplicated
+func newDeduper(cfg *Config, logger *zap.Logger) *deduper {
+	if cfg.Dedupe == nil || !cfg.Dedupe.Enabled {
+		return nil
+	}
+	d := &deduper{
+		size:   cfg.Dedupe.WindowSize,
+		window: cfg.Dedupe.Window,
+		keys:   make(map[[sha256.Size]byte]int),
+		logger: logger,
+	}
+	if d.size == 0 {
+		d.size = defaultDedupeWindowSize
+	}
+	if d.window == 0 {
+		d.window = defaultDedupeWindow
+	}
+	if !cfg.DryRun {
+		name := "." + dedupeSuffix
+		if instance := instanceOf(cfg); len(instance) > 0 {
+			name = "." + instance + "." + dedupeSuffix
+		}
+		d.file = filepath.Join(cfg.Path, name)
+	}
+	return d
+}
+
+// dedupeKey returns the key of the batch of the signal
+func dedupeKey(signal string, content []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(signal))
+	h.Write([]byte{0})
+	h.Write(content)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// duplicateOf returns true if an identical batch of the signal was written within the window, and otherwise
+// the key to remember the batch by once it is written, nil if the batches are not deduplicated or cannot
+// be encoded
+func (e *fileExporter) duplicateOf(signal string, marshal func() ([]byte, error)) (*[sha256.Size]byte, bool) {
+	if e.dedupe == nil {
+		return nil, false
+	}
+	content, err := marshal()
+	if err != nil {
+		return nil, false
+	}
+	key := dedupeKey(signal, content)
+	if e.dedupe.seen(key, time.Now()) {
+		e.debug("skipping batch identical to a batch written recently", zap.String("signal", signal))
+		return nil, true
+	}
+	return &key, false
+}
+
+// load reads the batches remembered by a previous run that are still within the window, and rewrites the
+// file with them only
+func (d *deduper) load(now time.Time) error {
+	if len(d.file) == 0 {
+		return nil
+	}
+	content, err := os.ReadFile(d.file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read the dedupe file: %w", err)
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// a line left partial by a crash is ignored
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		key, err := hex.DecodeString(fields[0])
+		nanos, timeErr := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || timeErr != nil || len(key) != sha256.Size {
+			continue
+		}
+		entry := dedupeEntry{written: time.Unix(0, nanos)}
+		copy(entry.key[:], key)
+		d.add(entry)
+	}
+	d.expire(now)
+	return d.compact()
+}
+
+// add remembers the entry, forgetting the oldest entry once the window is full; it must be called holding
+// the mutex
+func (d *deduper) add(entry dedupeEntry) {
+	d.entries = append(d.entries, entry)
+	d.keys[entry.key]++
+	if len(d.entries) > d.size {
+		d.forgetOldest()
+	}
+}
+
+// expire forgets the entries written before the window, it must be called holding the mutex
+func (d *deduper) expire(now time.Time) {
+	for len(d.entries) > 0 && now.Sub(d.entries[0].written) >= d.window {
+		d.forgetOldest()
+	}
+}
+
+func (d *deduper) forgetOldest() {
+	oldest := d.entries[0]
+	d.entries = d.entries[1:]
+	if d.keys[oldest.key]--; d.keys[oldest.key] <= 0 {
+		delete(d.keys, oldest.key)
+	}
+}
+
+// seen returns true if a batch with the key was written within the window
+func (d *deduper) seen(key [sha256.Size]byte, now time.Time) bool {
+	if d == nil {
+		return false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.expire(now)
+	return d.keys[key] > 0
+}
+
+// remember records the batch with the key as written and appends it to the file, a batch that cannot be
+// saved is still remembered until the exporter stops; nothing is remembered without a key
+func (d *deduper) remember(key *[sha256.Size]byte, now time.Time) {
+	if d == nil || key == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.add(dedupeEntry{key: *key, written: now})
+	if len(d.file) == 0 {
+		return
+	}
+	var err error
+	if d.lines >= 2*d.size {
+		err = d.compact()
+	} else if d.out != nil {
+		_, err = fmt.Fprintf(d.out, "%s %d\n", hex.EncodeToString(key[:]), now.UnixNano())
+		d.lines++
+	}
+	if err != nil {
+		d.logger.Warn("failed to save the deduplicated batches", zap.String("file", d.file), zap.Error(err))
+	}
+}
+
+// compact rewrites the file with the remembered entries and reopens it for appending, it must be called
+// holding the mutex
+func (d *deduper) compact() error {
+	if d.out != nil {
+		_ = d.out.Close()
+		d.out = nil
+	}
+	var buf bytes.Buffer
+	for _, entry := range d.entries {
+		fmt.Fprintf(&buf, "%s %d\n", hex.EncodeToString(entry.key[:]), entry.written.UnixNano())
+	}
+	if err := os.WriteFile(d.file+".tmp", buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(d.file+".tmp", d.file); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(d.file, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	d.out, d.lines = out, len(d.entries)
+	return nil
+}
+
+// close closes the file the batches are appended to
+func (d *deduper) close() error {
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.out == nil {
+		return nil
+	}
+	err := d.out.Close()
+	d.out = nil
+	return err
+}
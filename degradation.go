@@ -0,0 +1,295 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// AdaptiveDegradationConfig reduces the fidelity of the metrics and traces written while the finished files
+// pile up or the disk fills, so that less data is written instead of dropping the batches once the limits are reached
+type AdaptiveDegradationConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Steps are ordered from the least to the most severe, the last step whose threshold is reached applies
+	Steps []DegradationStep `mapstructure:"steps"`
+}
+
+// DegradationStep is a level of degradation and the pressure it applies from, the step applies when any
+// of its thresholds is reached
+type DegradationStep struct {
+	// PendingFiles applies the step when the number of finished files under the path reaches it
+	PendingFiles int `mapstructure:"pendingFiles"`
+	// FreeDiskMb applies the step when the free space of the file system of the path falls below it
+	FreeDiskMb int64 `mapstructure:"freeDiskMb"`
+	// DropExemplars removes the exemplars of the metric data points
+	DropExemplars bool `mapstructure:"dropExemplars"`
+	// ThinHistogramBuckets merges every n adjacent buckets of the histograms, the exponential histograms are
+	// downscaled by the largest power of two not above n
+	ThinHistogramBuckets int `mapstructure:"thinHistogramBuckets"`
+	// TraceSampleRatio is the ratio of the traces kept, sampled by trace id so that the spans of a trace
+	// are kept or dropped together; zero keeps all the traces
+	TraceSampleRatio float64 `mapstructure:"traceSampleRatio"`
+}
+
+// validateAdaptiveDegradation checks every step has a threshold and an action
+func (cfg *Config) validateAdaptiveDegradation() error {
+	if cfg.AdaptiveDegradation == nil || !cfg.AdaptiveDegradation.Enabled {
+		return nil
+	}
+	if len(cfg.AdaptiveDegradation.Steps) == 0 {
+		return errors.New("adaptiveDegradation requires at least one step")
+	}
+	for i, step := range cfg.AdaptiveDegradation.Steps {
+		if step.PendingFiles < 0 || step.FreeDiskMb < 0 {
+			return fmt.Errorf("adaptiveDegradation step %d thresholds must not be negative", i)
+		}
+		if step.PendingFiles == 0 && step.FreeDiskMb == 0 {
+			return fmt.Errorf("adaptiveDegradation step %d requires pendingFiles or freeDiskMb", i)
+		}
+		if step.PendingFiles > 0 && strings.EqualFold(cfg.Rotation, RotationNone) {
+			return fmt.Errorf("adaptiveDegradation step %d pendingFiles requires rotation as there are no finished files", i)
+		}
+		if step.ThinHistogramBuckets < 0 || step.ThinHistogramBuckets == 1 {
+			return fmt.Errorf("adaptiveDegradation step %d thinHistogramBuckets must be 2 or more", i)
+		}
+		if step.TraceSampleRatio < 0 || step.TraceSampleRatio >= 1 {
+			return fmt.Errorf("adaptiveDegradation step %d traceSampleRatio must be between 0 and 1", i)
+		}
+		if !step.DropExemplars && step.ThinHistogramBuckets == 0 && step.TraceSampleRatio == 0 {
+			return fmt.Errorf("adaptiveDegradation step %d does not degrade anything", i)
+		}
+	}
+	return nil
+}
+
+// the lowest scale of the exponential histograms
+const minExponentialScale = -10
+
+// degradation is the resolved adaptive degradation configuration and the step currently applied
+type degradation struct {
+	steps []DegradationStep
+	mutex sync.Mutex
+	// current is the index of the step applied, -1 if none, and checked the time the pressure was measured
+	current int
+	checked time.Time
+}
+
+func newDegradation(cfg *AdaptiveDegradationConfig) *degradation {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	return &degradation{steps: cfg.Steps, current: -1}
+}
+
+// degradationStep returns the step to apply given the pressure on the path, nil if the data is written in full;
+// the pressure is measured again at most every second
+func (e *fileExporter) degradationStep() *DegradationStep {
+	d := e.degradation
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if time.Since(d.checked) >= backlogCountTTL {
+		d.checked = time.Now()
+		current := e.pressureStep(e.primaryRoot())
+		if current != d.current {
+			if current < 0 {
+				e.logger.Info("writing data in full as the file pressure is relieved", zap.Int("previousStep", d.current))
+			} else {
+				e.logger.Warn("degrading data as the file pressure increased", zap.Int("step", current), zap.Int("previousStep", d.current))
+			}
+			d.current = current
+		}
+	}
+	if d.current < 0 {
+		return nil
+	}
+	return &d.steps[d.current]
+}
+
+// pressureStep returns the index of the last step whose threshold is reached under the root path, -1 if
+// none is; the pressure that cannot be measured is logged and ignored
+func (e *fileExporter) pressureStep(root string) int {
+	pending, free := -1, int64(-1)
+	for i := len(e.degradation.steps) - 1; i >= 0; i-- {
+		step := e.degradation.steps[i]
+		if step.PendingFiles > 0 {
+			if pending < 0 {
+				var err error
+				if pending, err = e.pendingFiles(root); err != nil {
+					e.logger.Warn("failed to count pending files for adaptive degradation", zap.String("path", root), zap.Error(err))
+					pending = 0
+				}
+			}
+			if pending >= step.PendingFiles {
+				return i
+			}
+		}
+		if step.FreeDiskMb > 0 {
+			if free < 0 {
+				var err error
+				if free, err = diskFree(root); err != nil {
+					e.logger.Warn("failed to retrieve free disk space for adaptive degradation", zap.String("path", root), zap.Error(err))
+					free = math.MaxInt64
+				}
+			}
+			if free < step.FreeDiskMb*1024*1024 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// degradeMetrics returns a copy of the metrics with the exemplars and buckets reduced as per the current step
+func (e *fileExporter) degradeMetrics(md pmetric.Metrics) pmetric.Metrics {
+	step := e.degradationStep()
+	if step == nil || (!step.DropExemplars && step.ThinHistogramBuckets == 0) {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	rms := out.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			metrics := sms.At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				degradeMetric(metrics.At(k), step)
+			}
+		}
+	}
+	return out
+}
+
+// degradeMetric drops the exemplars and thins the histogram buckets of the metric
+func degradeMetric(metric pmetric.Metric, step *DegradationStep) {
+	dropAll := func(pmetric.Exemplar) bool { return true }
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSum:
+		if !step.DropExemplars {
+			return
+		}
+		var points pmetric.NumberDataPointSlice
+		if metric.Type() == pmetric.MetricTypeSum {
+			points = metric.Sum().DataPoints()
+		} else {
+			points = metric.Gauge().DataPoints()
+		}
+		for i := 0; i < points.Len(); i++ {
+			points.At(i).Exemplars().RemoveIf(dropAll)
+		}
+	case pmetric.MetricTypeHistogram:
+		points := metric.Histogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			p := points.At(i)
+			if step.DropExemplars {
+				p.Exemplars().RemoveIf(dropAll)
+			}
+			if step.ThinHistogramBuckets > 0 {
+				thinBuckets(p, step.ThinHistogramBuckets)
+			}
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		points := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			p := points.At(i)
+			if step.DropExemplars {
+				p.Exemplars().RemoveIf(dropAll)
+			}
+			if step.ThinHistogramBuckets > 0 {
+				downscale(p, int32(bits.Len(uint(step.ThinHistogramBuckets))-1))
+			}
+		}
+	}
+}
+
+// thinBuckets merges every n adjacent buckets of the histogram point, a merged bucket keeps the upper bound of
+// its last bucket and the last merged bucket stays unbounded
+func thinBuckets(p pmetric.HistogramDataPoint, n int) {
+	counts, bounds := p.BucketCounts().AsRaw(), p.ExplicitBounds().AsRaw()
+	if len(counts) <= 1 || len(counts) != len(bounds)+1 {
+		return
+	}
+	merged := make([]uint64, (len(counts)+n-1)/n)
+	for i, c := range counts {
+		merged[i/n] += c
+	}
+	thinned := make([]float64, 0, len(merged)-1)
+	for i := n - 1; i < len(bounds) && len(thinned) < len(merged)-1; i += n {
+		thinned = append(thinned, bounds[i])
+	}
+	p.BucketCounts().FromRaw(merged)
+	p.ExplicitBounds().FromRaw(thinned)
+}
+
+// downscale lowers the scale of the exponential histogram point by shift, merging 2^shift adjacent buckets,
+// the scale is not lowered below the minimum of the data model
+func downscale(p pmetric.ExponentialHistogramDataPoint, shift int32) {
+	if p.Scale()-shift < minExponentialScale {
+		shift = p.Scale() - minExponentialScale
+	}
+	if shift <= 0 {
+		return
+	}
+	downscaleBuckets(p.Positive(), shift)
+	downscaleBuckets(p.Negative(), shift)
+	p.SetScale(p.Scale() - shift)
+}
+
+// downscaleBuckets merges the buckets whose indexes map to the same index at the lower scale
+func downscaleBuckets(b pmetric.ExponentialHistogramDataPointBuckets, shift int32) {
+	counts := b.BucketCounts().AsRaw()
+	if len(counts) == 0 {
+		return
+	}
+	offset := b.Offset() >> shift
+	last := (b.Offset() + int32(len(counts)) - 1) >> shift
+	merged := make([]uint64, last-offset+1)
+	for i, c := range counts {
+		merged[((b.Offset()+int32(i))>>shift)-offset] += c
+	}
+	b.SetOffset(offset)
+	b.BucketCounts().FromRaw(merged)
+}
+
+// degradeTraces returns the traces sampled as per the current step
+func (e *fileExporter) degradeTraces(td ptrace.Traces) ptrace.Traces {
+	step := e.degradationStep()
+	if step == nil || step.TraceSampleRatio == 0 {
+		return td
+	}
+	threshold := uint64(step.TraceSampleRatio * math.MaxUint64)
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	out.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				id := span.TraceID()
+				// the low half of the trace id is random so it is kept or dropped consistently by every exporter
+				return binary.BigEndian.Uint64(id[8:]) > threshold
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return out
+}
@@ -0,0 +1,135 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"unsafe"
+)
+
+// the alignment of the offsets, sizes and memory of the direct writes, a multiple of the logical block
+// size of the common file systems
+const directIOAlignment = 4096
+
+// fileBuffer buffers the writes to the in process file until they are flushed
+type fileBuffer interface {
+	io.Writer
+	Flush() error
+}
+
+// validateWriteBuffer checks the write buffer size and that direct writes are supported on the platform
+func (cfg *Config) validateWriteBuffer() error {
+	if cfg.WriteBufferKb < 0 {
+		return errors.New("writeBufferKb must not be negative")
+	}
+	if cfg.DirectIO && !directIOSupported {
+		return errors.New("directIO is only supported on linux")
+	}
+	if cfg.DirectIO && strings.EqualFold(cfg.WriteMode, WriteModePreallocate) {
+		return errors.New("directIO cannot be used with the preallocate write mode as every flush truncates the file to its size")
+	}
+	return nil
+}
+
+// writeBufferBytes returns the size in bytes of the write buffer
+func writeBufferBytes(kb int64) int {
+	if kb > 0 {
+		return int(kb * 1024)
+	}
+	return writeBufferSize
+}
+
+// directWriter writes to a file opened for direct I/O, bypassing the page cache; the writes are buffered
+// in aligned memory and written in whole aligned blocks, the last partial block is written padded and
+// the file truncated to its size, and kept in memory so the next flush rewrites it with the data appended
+type directWriter struct {
+	file fsFile
+	buf  []byte
+	// n is the number of bytes in buf and offset the aligned file offset of buf
+	n      int
+	offset int64
+}
+
+// newDirectWriter creates a writer appending to the file of the size, the partial last block of the
+// file is read so that it is rewritten with the next data
+func newDirectWriter(file fsFile, size int64, bufferSize int) (*directWriter, error) {
+	if bufferSize < directIOAlignment {
+		bufferSize = directIOAlignment
+	}
+	bufferSize = (bufferSize + directIOAlignment - 1) / directIOAlignment * directIOAlignment
+	w := &directWriter{
+		file:   file,
+		buf:    alignedBuffer(bufferSize),
+		offset: size / directIOAlignment * directIOAlignment,
+	}
+	if tail := int(size - w.offset); tail > 0 {
+		n, err := file.ReadAt(w.buf[:directIOAlignment], w.offset)
+		if n < tail {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		w.n = tail
+	}
+	return w, nil
+}
+
+// alignedBuffer returns a buffer of the size whose memory is aligned for direct I/O
+func alignedBuffer(size int) []byte {
+	b := make([]byte, size+directIOAlignment)
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) % directIOAlignment); rem != 0 {
+		shift = directIOAlignment - rem
+	}
+	return b[shift : shift+size : shift+size]
+}
+
+func (w *directWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[w.n:], p)
+		w.n += n
+		written += n
+		p = p[n:]
+		if w.n == len(w.buf) {
+			if _, err := w.file.WriteAt(w.buf, w.offset); err != nil {
+				return written, err
+			}
+			w.offset += int64(len(w.buf))
+			w.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Flush writes the buffered bytes, the whole blocks are released from the buffer and the partial last
+// block is kept to be rewritten
+func (w *directWriter) Flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	blocks := (w.n + directIOAlignment - 1) / directIOAlignment * directIOAlignment
+	for i := w.n; i < blocks; i++ {
+		w.buf[i] = 0
+	}
+	if _, err := w.file.WriteAt(w.buf[:blocks], w.offset); err != nil {
+		return err
+	}
+	if err := w.file.Truncate(w.offset + int64(w.n)); err != nil {
+		return err
+	}
+	full := w.n / directIOAlignment * directIOAlignment
+	copy(w.buf, w.buf[full:w.n])
+	w.offset += int64(full)
+	w.n -= full
+	return nil
+}
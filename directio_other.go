@@ -0,0 +1,17 @@
+//go:build !linux
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+// direct writes are rejected by the configuration validation on the other platforms
+const (
+	directIOSupported = false
+	directIOFlag      = 0
+)
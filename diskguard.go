@@ -0,0 +1,212 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DiskFullDrop        = "drop"
+	DiskFullBlock       = "block"
+	DiskFullPurgeOldest = "purge-oldest"
+)
+
+// validateOnDiskFull checks the behaviour when the disk usage limits are reached is supported
+func validateOnDiskFull(behavior string) error {
+	switch strings.ToLower(behavior) {
+	case "", DiskFullDrop, DiskFullBlock, DiskFullPurgeOldest:
+		return nil
+	}
+	return fmt.Errorf("invalid onDiskFull [%s], valid values are [ %s, %s or %s ]", behavior, DiskFullDrop, DiskFullBlock, DiskFullPurgeOldest)
+}
+
+// diskGuardEnabled returns true if any disk usage limit is configured
+func (e *fileExporter) diskGuardEnabled() bool {
+	return e.minFreeDisk > 0 || e.maxDirSize > 0
+}
+
+// checkDiskUsage checks that writing size bytes under the root path keeps the disk usage within the configured limits,
+// it returns false if the batch must be dropped; if the limits are exceeded and the behaviour is block
+// a retryable error is returned so the pipeline can try again later
+func (e *fileExporter) checkDiskUsage(root string, size int64) (bool, error) {
+	if !e.diskGuardEnabled() {
+		return true, nil
+	}
+	exceeded, reason, err := e.diskUsageExceeded(root, size)
+	if err != nil || !exceeded {
+		return err == nil, err
+	}
+	switch e.onDiskFull {
+	case DiskFullDrop:
+		e.logger.Warn("dropping batch as the disk usage limit is reached", zap.String("reason", reason), zap.Int64("size", size))
+		return false, nil
+	case DiskFullPurgeOldest:
+		for exceeded {
+			purged, err := e.purgeOldest(root, size)
+			if err != nil {
+				return false, err
+			}
+			if !purged {
+				return false, fmt.Errorf("%w and there are no finished files left to purge: %s", ErrDiskFull, reason)
+			}
+			if exceeded, reason, err = e.diskUsageExceeded(root, size); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w: %s", ErrDiskFull, reason)
+	}
+}
+
+// diskUsageExceeded returns true and the reason if writing size bytes under the root path would exceed the disk usage limits
+func (e *fileExporter) diskUsageExceeded(root string, size int64) (bool, string, error) {
+	if e.minFreeDisk > 0 {
+		free, err := diskFree(root)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to retrieve free disk space of %s: %w", root, err)
+		}
+		if free-size < e.minFreeDisk {
+			return true, fmt.Sprintf("free disk space of %d bytes is below the minimum of %d bytes", free-size, e.minFreeDisk), nil
+		}
+	}
+	if e.maxDirSize > 0 {
+		used, err := dirSize(root)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to retrieve size of %s: %w", root, err)
+		}
+		// the mapped in process files count for the data written to them
+		used -= e.mappedSlack(root)
+		if used+size > e.maxDirSize {
+			return true, fmt.Sprintf("directory size of %d bytes exceeds the maximum of %d bytes", used+size, e.maxDirSize), nil
+		}
+	}
+	return false, "", nil
+}
+
+// dirSize returns the total size of the files under the path
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == trashDir {
+				// the trashed files are held within their own size
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// finishedFile is a finished file and its modification time
+type finishedFile struct {
+	path string
+	info fs.FileInfo
+}
+
+// finishedFiles returns the finished files under the path sorted from oldest to newest, in process
+// files, rolling manifests and layout files are never returned
+func (e *fileExporter) finishedFiles(path string) ([]finishedFile, error) {
+	var files []finishedFile
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == trashDir {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || e.isInProcessName(d.Name()) || d.Name() == manifestRollingFile || d.Name() == layoutFile || d.Name() == dirLockFile || isStateName(d.Name()) || isQuarantineName(d.Name()) || isStagingName(d.Name()) || isReportName(d.Name()) || isStatusName(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, finishedFile{path: p, info: info})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+	return files, err
+}
+
+// purgeOldest deletes the oldest finished file under the root path, or moves it to the trash, it returns false if
+// there is no file left to delete; as moving a file to the trash frees no disk space, the trashed files are deleted
+// first while writing size bytes would leave less free disk space than the minimum
+func (e *fileExporter) purgeOldest(root string, size int64) (bool, error) {
+	if e.trash != nil && e.minFreeDisk > 0 {
+		free, err := diskFree(root)
+		if err != nil {
+			return false, fmt.Errorf("failed to retrieve free disk space of %s: %w", root, err)
+		}
+		if free-size < e.minFreeDisk {
+			if purged, err := e.trash.purgeOldest(root); err != nil || purged {
+				return purged, err
+			}
+		}
+	}
+	files, err := e.finishedFiles(root)
+	if err != nil {
+		return false, err
+	}
+	if e.isRotationNone() {
+		// the fixed file is still being written to so it is never purged
+		var finished []finishedFile
+		for _, f := range files {
+			if filepath.Base(f.path) != e.singleFileName() {
+				finished = append(finished, f)
+			}
+		}
+		files = finished
+	}
+	if len(files) == 0 {
+		return false, nil
+	}
+	oldest := files[0]
+	if e.trash != nil {
+		e.logger.Warn("moving oldest finished file to the trash as the disk usage limit is reached",
+			zap.String("file", oldest.path), zap.Int64("size", oldest.info.Size()))
+		if err = e.trash.move(root, oldest.path); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		return true, nil
+	}
+	e.logger.Warn("purging oldest finished file as the disk usage limit is reached",
+		zap.String("file", oldest.path), zap.Int64("size", oldest.info.Size()))
+	// the file may have been purged meanwhile by the writer of another directory under the root
+	if err = os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,51 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dryRunWrite accounts the batch in the writer as if it was appended to the in process file and counts
+// the files that would be finished by size or event count, nothing is written to disk; it must be called
+// holding the writer lock
+func (e *fileExporter) dryRunWrite(w *fileWriter, b *batch) {
+	if e.isRotationNone() {
+		return
+	}
+	if w.fileSize > 0 && w.size > 0 && w.size+int64(len(b.buf)) > w.fileSize {
+		e.dryRunFinish(w)
+	}
+	if w.eventsPerFile > 0 && w.currentEventCount > 0 && w.currentEventCount+e.eventsOf(b) > w.eventsPerFile {
+		e.dryRunFinish(w)
+	}
+	w.size += int64(len(b.buf))
+	w.currentEventCount += e.eventsOf(b)
+	w.signals[b.signal] = true
+	w.stats.add(b)
+	w.lastWrite = time.Now()
+	if w.eventsPerFile > 0 && w.currentEventCount >= w.eventsPerFile {
+		e.dryRunFinish(w)
+	}
+}
+
+// dryRunFinish records the file the writer would finish and resets its accounting
+func (e *fileExporter) dryRunFinish(w *fileWriter) {
+	w.seq++
+	e.debug("dry run would finish a file", zap.String("path", w.path), zap.Int64("seq", w.seq),
+		zap.Int64("size", w.size), zap.Int64("records", w.stats.records), zap.Strings("signals", signalList(w.signals)))
+	e.telemetry.recordRotation(time.Now())
+	e.recordRotated()
+	w.size, w.currentEventCount = 0, 0
+	w.signals = make(map[string]bool)
+	w.stats = fileStats{}
+	w.opened = time.Time{}
+}
@@ -0,0 +1,324 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// the extension appended to encrypted finished files
+	encryptedExt = "enc"
+	// the size in bytes of an AES-256 key
+	encryptionKeySize = 32
+	// the version of the format of the encrypted files, the first byte of the files
+	encryptionVersion = 1
+	// the size of the random prefix of the nonces of the chunks of an encrypted file
+	encryptionNoncePrefixSize = 7
+	// the size of the data sealed in each chunk of an encrypted file
+	encryptionChunkSize = 64 * 1024
+)
+
+// EncryptionConfig defines how finished files are encrypted at rest using AES-256-GCM, the files are sealed
+// in chunks so that they are encrypted and decrypted without being held in memory
+type EncryptionConfig struct {
+	// Enabled turns on the encryption of finished files
+	Enabled bool `mapstructure:"enabled"`
+	// KeyFile is the path to a file containing the 32 bytes key, either raw or hex encoded
+	KeyFile string `mapstructure:"keyFile"`
+	// Key is the hex encoded 32 bytes key, it is meant to be provisioned with the ${env:NAME} or ${file:path}
+	// placeholders rather than written in the configuration
+	Key string `mapstructure:"key"`
+	// KeyProvider retrieves the key from an external key management system, it takes precedence over KeyFile
+	// and can only be set programmatically
+	KeyProvider KeyProvider `mapstructure:"-"`
+}
+
+// KeyProvider provides the AES-256 key used to encrypt and decrypt files
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// FileKeyProvider reads the encryption key from a file
+type FileKeyProvider struct {
+	Path string
+}
+
+// Key reads the key file, the key can be stored as 32 raw bytes or 64 hex characters
+func (p FileKeyProvider) Key() ([]byte, error) {
+	content, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read encryption key file %s: %w", p.Path, err)
+	}
+	if len(content) == encryptionKeySize {
+		return content, nil
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(content)))
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key file %s, it must contain %d raw bytes or %d hex characters", p.Path, encryptionKeySize, encryptionKeySize*2)
+	}
+	return key, nil
+}
+
+// hexKeyProvider provides the hex encoded key of the configuration
+type hexKeyProvider string
+
+// Key decodes the key
+func (p hexKeyProvider) Key() ([]byte, error) {
+	key, err := hex.DecodeString(string(bytes.TrimSpace([]byte(p))))
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key, it must be %d hex characters", encryptionKeySize*2)
+	}
+	return key, nil
+}
+
+// Validate checks if the encryption configuration is valid
+func (cfg *EncryptionConfig) Validate() error {
+	if !cfg.Enabled || cfg.KeyProvider != nil {
+		return nil
+	}
+	if len(cfg.KeyFile) > 0 && len(cfg.Key) > 0 {
+		return errors.New("mention either the encryption keyFile or key")
+	}
+	if len(cfg.KeyFile) == 0 && len(cfg.Key) == 0 {
+		return errors.New("encryption keyFile or key must be defined when encryption is enabled")
+	}
+	return nil
+}
+
+// provider returns the key provider of the configuration, or nil if encryption is disabled
+func (cfg *EncryptionConfig) provider() KeyProvider {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.KeyProvider != nil {
+		return cfg.KeyProvider
+	}
+	if len(cfg.Key) > 0 {
+		return hexKeyProvider(cfg.Key)
+	}
+	return FileKeyProvider{Path: cfg.KeyFile}
+}
+
+// Encrypt encrypts the data in the chunked format of the encrypted files, see NewEncryptWriter
+func Encrypt(data []byte, key []byte) ([]byte, error) {
+	var out bytes.Buffer
+	w, err := NewEncryptWriter(&out, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt
+func Decrypt(data []byte, key []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(data), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// DecryptFile writes the decrypted content of a finished file encrypted by the exporter to w, the file is
+// decrypted a chunk at a time
+func DecryptFile(path string, provider KeyProvider, w io.Writer) error {
+	key, err := provider.Key()
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, err := NewDecryptReader(file, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key size %d, AES-256 requires %d bytes", len(key), encryptionKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of the chunk of the stream: the random prefix of the stream, the big endian
+// number of the chunk and whether it is the last chunk, so that the chunks cannot be reordered, dropped or
+// truncated without failing their authentication
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, encryptionNoncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptWriter seals the data written to it a chunk at a time
+type encryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	chunk   []byte
+	sealed  []byte
+}
+
+// NewEncryptWriter returns a writer encrypting the data written to it to w with AES-256-GCM, Close must be
+// called to write the last chunk. The encrypted stream is a version byte and a random nonce prefix followed
+// by the chunks of 64KiB of data each sealed with its own nonce, the last chunk is flagged in its nonce and
+// may be shorter, it is empty only if there is no data
+func NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 1+encryptionNoncePrefixSize)
+	header[0] = encryptionVersion
+	if _, err = io.ReadFull(rand.Reader, header[1:]); err != nil {
+		return nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, gcm: gcm, prefix: header[1:], chunk: make([]byte, 0, encryptionChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data follows, as the last chunk is sealed on close
+		if len(e.chunk) == encryptionChunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		copied := copy(e.chunk[len(e.chunk):encryptionChunkSize], p)
+		e.chunk = e.chunk[:len(e.chunk)+copied]
+		p = p[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// Close seals the last chunk, it does not close the underlying writer
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	if e.counter == math.MaxUint32 {
+		return errors.New("the data exceeds the maximum size of an encrypted file")
+	}
+	e.sealed = e.gcm.Seal(e.sealed[:0], chunkNonce(e.prefix, e.counter, last), e.chunk, nil)
+	e.counter++
+	e.chunk = e.chunk[:0]
+	_, err := e.w.Write(e.sealed)
+	return err
+}
+
+// decryptReader opens the chunks of an encrypted stream one at a time
+type decryptReader struct {
+	r       *bufio.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	sealed  []byte
+	opened  []byte
+	plain   []byte
+	done    bool
+}
+
+// NewDecryptReader returns a reader of the data encrypted to r by NewEncryptWriter, reading fails if a chunk
+// does not authenticate or if the stream ends before its last chunk
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 1+encryptionNoncePrefixSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, errors.New("encrypted data is too short")
+	}
+	if header[0] != encryptionVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", header[0])
+	}
+	return &decryptReader{
+		r:      bufio.NewReaderSize(r, encryptionChunkSize+gcm.Overhead()),
+		gcm:    gcm,
+		prefix: header[1:],
+		sealed: make([]byte, encryptionChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open reads and authenticates the next chunk, a chunk shorter than a full chunk or followed by the end
+// of the stream is the last one
+func (d *decryptReader) open() error {
+	n, err := io.ReadFull(d.r, d.sealed)
+	if err == io.EOF {
+		return errors.New("encrypted data is truncated, its last chunk is missing")
+	}
+	last := err == io.ErrUnexpectedEOF
+	if err != nil && !last {
+		return err
+	}
+	if !last {
+		if _, err = d.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if d.opened, err = d.gcm.Open(d.opened[:0], chunkNonce(d.prefix, d.counter, last), d.sealed[:n], nil); err != nil {
+		return fmt.Errorf("encrypted chunk %d cannot be authenticated: %w", d.counter, err)
+	}
+	d.counter++
+	d.plain, d.done = d.opened, last
+	return nil
+}
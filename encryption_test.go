@@ -0,0 +1,103 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// testKey returns a random encryption key
+func testKey(t *testing.T) []byte {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1, 3*encryptionChunkSize + 5} {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal(err)
+		}
+		encrypted, err := Encrypt(data, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks := (size + encryptionChunkSize - 1) / encryptionChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		if want := 1 + encryptionNoncePrefixSize + size + chunks*16; len(encrypted) != want {
+			t.Errorf("expected %d encrypted bytes of %d bytes, got %d", want, size, len(encrypted))
+		}
+		decrypted, err := Decrypt(encrypted, key)
+		if err != nil || !bytes.Equal(decrypted, data) {
+			t.Errorf("expected the %d bytes to decrypt, got %d bytes, %v", size, len(decrypted), err)
+		}
+	}
+}
+
+func TestDecryptRejectsAlteredData(t *testing.T) {
+	key := testKey(t)
+	data := bytes.Repeat([]byte("x"), 2*encryptionChunkSize+10)
+	encrypted, err := Encrypt(data, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, sealed := 1+encryptionNoncePrefixSize, encryptionChunkSize+16
+	tampered := append([]byte(nil), encrypted...)
+	tampered[header+10] ^= 1
+	swapped := append([]byte(nil), encrypted[:header]...)
+	swapped = append(swapped, encrypted[header+sealed:header+2*sealed]...)
+	swapped = append(swapped, encrypted[header:header+sealed]...)
+	swapped = append(swapped, encrypted[header+2*sealed:]...)
+	for name, altered := range map[string][]byte{
+		"truncated at a chunk boundary": encrypted[:header+2*sealed],
+		"truncated within a chunk":      encrypted[:header+sealed+100],
+		"without its chunks":            encrypted[:header],
+		"tampered":                      tampered,
+		"reordered":                     swapped,
+	} {
+		if _, err := Decrypt(altered, key); err == nil {
+			t.Errorf("expected the data %s to be rejected", name)
+		}
+	}
+	if _, err := Decrypt(encrypted, testKey(t)); err == nil {
+		t.Error("expected the data to be rejected with another key")
+	}
+}
+
+func TestEncryptedFinishedFileDecrypts(t *testing.T) {
+	key := testKey(t)
+	cfg := testConfig(t, 16)
+	cfg.Encryption = EncryptionConfig{Enabled: true, Key: hex.EncodeToString(key)}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e := startTestExporter(t, cfg)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`, `{"a":3}`)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || !strings.HasSuffix(files[0], "."+encryptedExt) {
+		t.Fatalf("expected one encrypted finished file, got %v", files)
+	}
+	var out bytes.Buffer
+	if err := DecryptFile(files[0], hexKeyProvider(hex.EncodeToString(key)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != `{"a":1}{"a":2}` {
+		t.Fatalf("unexpected decrypted content %q", out.String())
+	}
+}
@@ -0,0 +1,79 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// the classes of failures returned by the exporter, they are matched with errors.Is
+var (
+	// ErrInvalidFormat is returned when the format is not supported
+	ErrInvalidFormat = errors.New("invalid format")
+	// ErrNoRotationPolicy is returned when neither the file size nor the events per file are defined
+	ErrNoRotationPolicy = errors.New("neither file size nor events per file is defined")
+	// ErrDiskFull is returned when a disk usage limit is reached
+	ErrDiskFull = errors.New("disk usage limit reached")
+	// ErrRotateFailed is returned when an in process file cannot be finished
+	ErrRotateFailed = errors.New("failed to finish inprocess file")
+)
+
+// FormatError describes a format that is not supported, it matches ErrInvalidFormat with errors.Is so that
+// it is reported when the configuration is validated rather than by every write
+type FormatError struct {
+	// Format is the configured format, empty if no format is configured
+	Format string
+	// Reason explains why a supported format cannot be used, empty if the format is not supported at all
+	Reason string
+}
+
+func (e *FormatError) Error() string {
+	valid := strings.Join(supportedFormats[:len(supportedFormats)-1], ", ") + " or " + supportedFormats[len(supportedFormats)-1]
+	if len(e.Format) == 0 {
+		return fmt.Sprintf("%v: format must be defined as either %s", ErrInvalidFormat, valid)
+	}
+	if len(e.Reason) > 0 {
+		return fmt.Sprintf("%v [%s], %s", ErrInvalidFormat, e.Format, e.Reason)
+	}
+	return fmt.Sprintf("%v [%s], valid format value is either [ %s ]", ErrInvalidFormat, e.Format, valid)
+}
+
+func (e *FormatError) Is(target error) bool {
+	return target == ErrInvalidFormat
+}
+
+// classifiedError is an error of one of the failure classes wrapping the error that caused it
+type classifiedError struct {
+	class error
+	msg   string
+	err   error
+}
+
+// classify returns an error of the class with the message wrapping err, so that both the class and
+// the cause can be matched with errors.Is and errors.As
+func classify(class error, err error, format string, args ...interface{}) error {
+	return &classifiedError{class: class, msg: fmt.Sprintf(format, args...), err: err}
+}
+
+func (e *classifiedError) Error() string {
+	if e.err == nil {
+		return e.msg
+	}
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
@@ -0,0 +1,107 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// FileEventType is the type of a file lifecycle event
+type FileEventType int
+
+const (
+	// FileOpened is sent when the first batch is written to a new in process file
+	FileOpened FileEventType = iota + 1
+	// FileRotated is sent once a file is finished, post processed and handed off, or once a bundle is written
+	FileRotated
+	// WriteError is sent when writing a batch or finishing a file fails, a file failing to finish while a
+	// batch is written is reported for the file and then for the batch
+	WriteError
+)
+
+func (t FileEventType) String() string {
+	switch t {
+	case FileOpened:
+		return "FileOpened"
+	case FileRotated:
+		return "FileRotated"
+	case WriteError:
+		return "WriteError"
+	}
+	return "Unknown"
+}
+
+// FileEvent is a lifecycle event of the files of an Exporter
+type FileEvent struct {
+	Type FileEventType
+	// Path is the in process file for FileOpened and the finished file for FileRotated, empty for WriteError
+	Path string
+	// Size is the size of the finished file on disk, Records the number of records and Signals the signals
+	// it holds; they are only set for FileRotated and are empty for bundles
+	Size    int64
+	Records int64
+	Signals []string
+	// Err is the error of a WriteError
+	Err  error
+	Time time.Time
+	// Dropped is the number of events dropped before this one because the channel was full
+	Dropped uint64
+}
+
+// WithEvents sends the lifecycle events of the files to the channel, so that the application can upload or
+// alert on the files without watching the file system.
+//
+// The events of a directory are sent in the order they happen, FileOpened before the FileRotated of the
+// same file, while the events of different directories and formats may interleave. The events are sent
+// without blocking so that a slow reader never holds up the writes: an event that does not fit in the
+// channel is dropped and counted in the Dropped field of the next event sent, so the channel should be
+// buffered and drained continuously. The channel is never closed by the exporter and no event is sent
+// once Close returns.
+func WithEvents(events chan<- FileEvent) Option {
+	return func(o *options) {
+		o.events = events
+	}
+}
+
+// setEvents sets the channel the file events of the exporter and its siblings are sent to
+func (e *fileExporter) setEvents(events chan<- FileEvent) {
+	e.events = events
+	for _, s := range e.siblings {
+		s.setEvents(events)
+	}
+}
+
+// emit sends the event without blocking, counting it as dropped if the channel is full
+func (e *fileExporter) emit(event FileEvent) {
+	if e.events == nil {
+		return
+	}
+	event.Time = time.Now()
+	event.Dropped = atomic.LoadUint64(&e.eventsDropped)
+	select {
+	case e.events <- event:
+		// the drops reported are subtracted so that the drops meanwhile are reported with the next event
+		atomic.AddUint64(&e.eventsDropped, ^(event.Dropped - 1))
+	default:
+		atomic.AddUint64(&e.eventsDropped, 1)
+	}
+}
+
+// emitRotated sends the FileRotated event of the finished file
+func (e *fileExporter) emitRotated(file RotatedFile) {
+	if e.events == nil {
+		return
+	}
+	event := FileEvent{Type: FileRotated, Path: file.Path, Records: file.Records, Signals: file.Signals}
+	if stat, err := e.fs.Stat(file.Path); err == nil {
+		event.Size = stat.Size()
+	}
+	e.emit(event)
+}
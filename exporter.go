@@ -0,0 +1,135 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// Exporter writes telemetry to files using the same rotation and formats as the collector exporter,
+// it allows applications using the OpenTelemetry SDK directly to write telemetry without running a collector
+type Exporter struct {
+	fe *fileExporter
+}
+
+// Option customises an Exporter created with New
+type Option func(o *options)
+
+// options holds the settings of an Exporter created with New
+type options struct {
+	settings  component.ExporterCreateSettings
+	marshaler Marshaler
+	onRotate  OnRotateFunc
+	events    chan<- FileEvent
+}
+
+// WithLogger sets the logger of the exporter, by default nothing is logged
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.settings.Logger = logger
+	}
+}
+
+// WithMeterProvider sets the meter provider recording the exporter internal metrics, by default they are not recorded
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.settings.MeterProvider = provider
+	}
+}
+
+// WithCustomMarshaler sets the marshaler used when the format is custom
+func WithCustomMarshaler(m Marshaler) Option {
+	return func(o *options) {
+		o.marshaler = m
+	}
+}
+
+// WithOnRotateCallback sets the function called for every finished file
+func WithOnRotateCallback(fn OnRotateFunc) Option {
+	return func(o *options) {
+		o.onRotate = fn
+	}
+}
+
+// DefaultConfig returns the default configuration of the exporter, the path, format and rotation must be set
+func DefaultConfig() Config {
+	return *createDefaultConfig().(*Config)
+}
+
+// New validates the configuration and creates a started exporter, Close must be called to release it
+func New(cfg Config, opts ...Option) (*Exporter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	resolved, err := cfg.resolve()
+	if err != nil {
+		return nil, err
+	}
+	o := options{
+		settings: component.ExporterCreateSettings{
+			TelemetrySettings: component.TelemetrySettings{
+				Logger:        zap.NewNop(),
+				MeterProvider: metric.NewNoopMeterProvider(),
+			},
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for _, format := range cfg.formats() {
+		if err := checkMarshaler(format, o.marshaler); err != nil {
+			return nil, err
+		}
+	}
+	fe := newFileExporter(resolved, o.settings, o.marshaler, o.onRotate)
+	if o.events != nil {
+		fe.setEvents(o.events)
+	}
+	if err := fe.Start(context.Background(), nil); err != nil {
+		return nil, err
+	}
+	return &Exporter{fe: fe}, nil
+}
+
+// WriteTraces writes the traces to file
+func (e *Exporter) WriteTraces(ctx context.Context, td ptrace.Traces) error {
+	return e.fe.ConsumeTraces(ctx, td)
+}
+
+// WriteMetrics writes the metrics to file
+func (e *Exporter) WriteMetrics(ctx context.Context, md pmetric.Metrics) error {
+	return e.fe.ConsumeMetrics(ctx, md)
+}
+
+// WriteLogs writes the logs to file
+func (e *Exporter) WriteLogs(ctx context.Context, ld plog.Logs) error {
+	return e.fe.ConsumeLogs(ctx, ld)
+}
+
+// Rotate finishes all the in process files now, regardless of the size and count thresholds
+func (e *Exporter) Rotate(ctx context.Context) error {
+	return e.fe.rotateAll(ctx)
+}
+
+// Status returns the current state of the exporter
+func (e *Exporter) Status() Status {
+	return e.fe.Status()
+}
+
+// Close stops the exporter, it must be called once
+func (e *Exporter) Close(ctx context.Context) error {
+	return e.fe.Shutdown(ctx)
+}
@@ -10,8 +10,10 @@ package fileexporter
 
 import (
 	"context"
+	"strings"
 
 	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/configtelemetry"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
@@ -24,81 +26,140 @@ const (
 	stability = component.StabilityLevelAlpha
 )
 
+// factory holds the options of the exporters created by the factory
+type factory struct {
+	// marshaler encodes the telemetry when the format is custom
+	marshaler Marshaler
+	// onRotate is called for every finished file
+	onRotate OnRotateFunc
+}
+
 // NewFactory creates a factory for OTLP exporter.
-func NewFactory() component.ExporterFactory {
+func NewFactory(opts ...FactoryOption) component.ExporterFactory {
+	f := &factory{}
+	for _, opt := range opts {
+		opt(f)
+	}
 	return component.NewExporterFactory(
 		typeStr,
 		createDefaultConfig,
-		component.WithTracesExporter(createTracesExporter, stability),
-		component.WithMetricsExporter(createMetricsExporter, stability),
-		component.WithLogsExporter(createLogsExporter, stability))
+		component.WithTracesExporter(f.createTracesExporter, stability),
+		component.WithMetricsExporter(f.createMetricsExporter, stability),
+		component.WithLogsExporter(f.createLogsExporter, stability))
 }
 
 func createDefaultConfig() component.ExporterConfig {
 
 	return &Config{
 		ExporterSettings: config.NewExporterSettings(component.NewID(typeStr)),
+		QueueSettings:    exporterhelper.NewDefaultQueueSettings(),
+		RetrySettings:    exporterhelper.NewDefaultRetrySettings(),
+		FileNameTemplate: defaultFileNameTemplate,
+		Verbosity:        configtelemetry.LevelNormal,
+		Checksum:         ChecksumNone,
+		OversizeBehavior: OversizeError,
+		OnDiskFull:       DiskFullBlock,
+		SkipEmpty:        true,
+		QuarantineAfter:  defaultQuarantineAfter,
 	}
 }
 
-func createTracesExporter(
+func (f *factory) createTracesExporter(
 	ctx context.Context,
 	set component.ExporterCreateSettings,
 	cfg component.ExporterConfig,
 ) (component.TracesExporter, error) {
-	fe := exporters.GetOrAdd(cfg, func() component.Component {
-		return &fileExporter{path: cfg.(*Config).Path, fileSizeKb: cfg.(*Config).FileSizeKb,
-			eventsPerFile: cfg.(*Config).EventsPerFile, format: cfg.(*Config).Format}
-	})
+	if err := checkFormats(cfg.(*Config), f.marshaler, signalTraces); err != nil {
+		return nil, err
+	}
+	fe := f.getOrCreate(cfg.(*Config), set)
+	fe.Unwrap().(*resolvingExporter).usedBy(signalTraces)
 	return exporterhelper.NewTracesExporter(
 		ctx,
 		set,
 		cfg,
-		fe.Unwrap().(*fileExporter).ConsumeTraces,
+		fe.Unwrap().(*resolvingExporter).ConsumeTraces,
 		exporterhelper.WithStart(fe.Start),
 		exporterhelper.WithShutdown(fe.Shutdown),
+		exporterhelper.WithTimeout(cfg.(*Config).TimeoutSettings),
+		exporterhelper.WithQueue(cfg.(*Config).QueueSettings),
+		exporterhelper.WithRetry(cfg.(*Config).RetrySettings),
 	)
 }
 
-func createMetricsExporter(
+func (f *factory) createMetricsExporter(
 	ctx context.Context,
 	set component.ExporterCreateSettings,
 	cfg component.ExporterConfig,
 ) (component.MetricsExporter, error) {
-	fe := exporters.GetOrAdd(cfg, func() component.Component {
-		return &fileExporter{path: cfg.(*Config).Path, fileSizeKb: cfg.(*Config).FileSizeKb,
-			eventsPerFile: cfg.(*Config).EventsPerFile, format: cfg.(*Config).Format}
-	})
+	if err := checkFormats(cfg.(*Config), f.marshaler, signalMetrics); err != nil {
+		return nil, err
+	}
+	fe := f.getOrCreate(cfg.(*Config), set)
+	fe.Unwrap().(*resolvingExporter).usedBy(signalMetrics)
 	return exporterhelper.NewMetricsExporter(
 		ctx,
 		set,
 		cfg,
-		fe.Unwrap().(*fileExporter).ConsumeMetrics,
+		fe.Unwrap().(*resolvingExporter).ConsumeMetrics,
 		exporterhelper.WithStart(fe.Start),
 		exporterhelper.WithShutdown(fe.Shutdown),
+		exporterhelper.WithTimeout(cfg.(*Config).TimeoutSettings),
+		exporterhelper.WithQueue(cfg.(*Config).QueueSettings),
+		exporterhelper.WithRetry(cfg.(*Config).RetrySettings),
 	)
 }
 
-func createLogsExporter(
+func (f *factory) createLogsExporter(
 	ctx context.Context,
 	set component.ExporterCreateSettings,
 	cfg component.ExporterConfig,
 ) (component.LogsExporter, error) {
-	fe := exporters.GetOrAdd(cfg, func() component.Component {
-		return &fileExporter{path: cfg.(*Config).Path, fileSizeKb: cfg.(*Config).FileSizeKb,
-			eventsPerFile: cfg.(*Config).EventsPerFile, format: cfg.(*Config).Format}
-	})
+	if err := checkFormats(cfg.(*Config), f.marshaler, signalLogs); err != nil {
+		return nil, err
+	}
+	fe := f.getOrCreate(cfg.(*Config), set)
+	fe.Unwrap().(*resolvingExporter).usedBy(signalLogs)
 	return exporterhelper.NewLogsExporter(
 		ctx,
 		set,
 		cfg,
-		fe.Unwrap().(*fileExporter).ConsumeLogs,
+		fe.Unwrap().(*resolvingExporter).ConsumeLogs,
 		exporterhelper.WithStart(fe.Start),
 		exporterhelper.WithShutdown(fe.Shutdown),
+		exporterhelper.WithTimeout(cfg.(*Config).TimeoutSettings),
+		exporterhelper.WithQueue(cfg.(*Config).QueueSettings),
+		exporterhelper.WithRetry(cfg.(*Config).RetrySettings),
 	)
 }
 
-// This is the map of already created File exporters for particular configurations.
+// checkFormats checks the formats of the configuration can be written by the exporter of the signal
+func checkFormats(cfg *Config, marshaler Marshaler, signal string) error {
+	for _, format := range cfg.formats() {
+		if signal != signalMetrics && isMetricsOnly(format) {
+			return &FormatError{Format: strings.ToLower(format), Reason: "it is only supported for metrics"}
+		}
+		if signal != signalTraces && isTracesOnly(format) {
+			return &FormatError{Format: strings.ToLower(format), Reason: "it is only supported for traces"}
+		}
+		if err := checkMarshaler(format, marshaler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getOrCreate returns the exporter of the configuration shared by its pipelines, it is keyed by the
+// configuration as loaded since the placeholders of the configuration are only resolved when it starts
+func (f *factory) getOrCreate(cfg *Config, set component.ExporterCreateSettings) *SharedComponent {
+	return exporters.GetOrAdd(cfg.key(), func() component.Component {
+		return &resolvingExporter{cfg: cfg, set: set, marshaler: f.marshaler, onRotate: f.onRotate}
+	})
+}
+
+// This is the map of already created File exporters for particular configurations, keyed by the exporter
+// id and the hash of its normalized configuration so that every exporter entry, even with the same settings
+// as another, has independent files, locks, rotation and counters.
 // We maintain this map because the Factory is asked trace and metric receivers separately
 // when it gets CreateTracesReceiver() and CreateMetricsReceiver() but they must not
 // create separate objects, they must use one Receiver object per configuration.
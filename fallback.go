@@ -0,0 +1,287 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the interval at which the path is probed while the fallback path is written to, when none is configured
+const defaultFallbackProbeInterval = 30 * time.Second
+
+// FallbackConfig defines the local path written to while the path is on a network file system that is
+// unavailable, the files written there are moved to the path once it is available again
+type FallbackConfig struct {
+	// Path is the local directory written to while the path is unavailable
+	Path string `mapstructure:"path"`
+	// ProbeInterval is how often the path is checked while the fallback path is written to, it defaults
+	// to 30 seconds
+	ProbeInterval time.Duration `mapstructure:"probeInterval"`
+}
+
+// Validate checks the fallback path is defined
+func (cfg *FallbackConfig) Validate() error {
+	if len(strings.TrimSpace(cfg.Path)) == 0 {
+		return errors.New("fallback requires a path to be defined")
+	}
+	if cfg.ProbeInterval < 0 {
+		return errors.New("fallback probeInterval must not be negative")
+	}
+	return nil
+}
+
+// validateFallback checks the fallback path is distinct from the other paths and the files can be moved
+func (cfg *Config) validateFallback() error {
+	if cfg.Fallback == nil {
+		return nil
+	}
+	if err := cfg.Fallback.Validate(); err != nil {
+		return err
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("fallback requires rotation as only finished files are moved back to the path")
+	}
+	for _, path := range append([]string{cfg.Path, cfg.DeadLetterPath}, cfg.MirrorPaths...) {
+		if len(path) > 0 && (isUnder(path, cfg.Fallback.Path) || isUnder(cfg.Fallback.Path, path)) {
+			return fmt.Errorf("fallback path [%s] must not be within or contain the path [%s]", cfg.Fallback.Path, path)
+		}
+	}
+	return nil
+}
+
+// isUnder returns true if the path is the root or is within it
+func isUnder(root, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fallback tracks whether the batches are written to the fallback path instead of the path
+type fallback struct {
+	path          string
+	probeInterval time.Duration
+	mutex         sync.Mutex
+	// active is true while the path is unavailable, since is when it became unavailable and lastProbe
+	// the last time it was checked
+	active    bool
+	since     time.Time
+	lastProbe time.Time
+}
+
+// newFallback returns the fallback of the configuration, nil if there is no fallback path
+func newFallback(cfg *FallbackConfig) *fallback {
+	if cfg == nil {
+		return nil
+	}
+	interval := cfg.ProbeInterval
+	if interval == 0 {
+		interval = defaultFallbackProbeInterval
+	}
+	return &fallback{path: cfg.Path, probeInterval: interval}
+}
+
+// isActive returns true if the batches are written to the fallback path
+func (f *fallback) isActive() bool {
+	if f == nil {
+		return false
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.active
+}
+
+// primaryRoot returns the root written to instead of the path, the fallback path while the path is unavailable
+func (e *fileExporter) primaryRoot() string {
+	if e.fallback.isActive() {
+		return e.fallback.path
+	}
+	return e.path
+}
+
+// writeFallback writes the batch to the fallback path if the write to the path failed because its network
+// file system is unavailable, it returns the root and error of the write to the path otherwise
+func (e *fileExporter) writeFallback(ctx context.Context, root string, partition string, b *batch, err error) (string, error) {
+	if e.fallback == nil || root != e.path || !isMountLost(err) {
+		return root, err
+	}
+	e.activateFallback(ctx, err)
+	return e.fallback.path, e.exportTo(ctx, e.fallback.path, partition, b)
+}
+
+// activateFallback switches the writes to the fallback path, the in process files of the path are closed
+// as their handles are no longer valid and are resumed once the path is available again
+func (e *fileExporter) activateFallback(ctx context.Context, cause error) {
+	f := e.fallback
+	f.mutex.Lock()
+	if f.active {
+		f.mutex.Unlock()
+		return
+	}
+	f.active, f.since, f.lastProbe = true, time.Now(), time.Now()
+	f.mutex.Unlock()
+	e.logger.Warn("the path is unavailable, writing to the fallback path until it is available again",
+		zap.String("path", e.path), zap.String("fallbackPath", f.path), zap.Error(cause))
+	_ = e.eachWriter(ctx, func(w *fileWriter) error {
+		if !isUnder(e.path, w.path) {
+			return nil
+		}
+		if err := w.close(); err != nil {
+			e.logger.Warn("failed to close inprocess file of the unavailable path", zap.String("path", w.path), zap.Error(err))
+		}
+		w.dirReady = false
+		return nil
+	})
+}
+
+// probeFallback checks if the path is available again once the probe interval has passed and if so moves
+// the files written to the fallback path to it
+func (e *fileExporter) probeFallback(now time.Time) {
+	f := e.fallback
+	f.mutex.Lock()
+	if !f.active || now.Sub(f.lastProbe) < f.probeInterval {
+		f.mutex.Unlock()
+		return
+	}
+	f.lastProbe = now
+	f.mutex.Unlock()
+	if err := checkOutputPath(e.path); err != nil {
+		e.debug("the path is still unavailable", zap.String("path", e.path), zap.Error(err))
+		return
+	}
+	f.mutex.Lock()
+	f.active = false
+	since := f.since
+	f.mutex.Unlock()
+	e.logger.Info("the path is available again, moving the files written to the fallback path",
+		zap.String("path", e.path), zap.String("fallbackPath", f.path), zap.Duration("unavailableFor", now.Sub(since)))
+	if err := e.migrateFallback(); err != nil {
+		e.logger.Error("failed to move the files of the fallback path", zap.String("fallbackPath", f.path), zap.Error(err))
+	}
+}
+
+// migrateFallback finishes the in process files of the fallback path and moves the files of the fallback
+// path to the same sub directories of the path; the files that cannot be moved are left to the next
+// migration
+func (e *fileExporter) migrateFallback() error {
+	f := e.fallback
+	err := e.eachWriter(context.Background(), func(w *fileWriter) error {
+		if !isUnder(f.path, w.path) {
+			return nil
+		}
+		if file, ok := e.pendingInProcess(w); ok {
+			w.lastWrite = time.Time{}
+			return e.finishFile(w, file)
+		}
+		return w.close()
+	})
+	if err != nil {
+		return err
+	}
+	var moved int
+	err = filepath.WalkDir(f.path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == trashDir {
+			// the files purged from the fallback path are left in its trash
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !e.isMigratedFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(f.path, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(e.path, rel)
+		if err = moveFile(p, dest); err != nil {
+			e.logger.Warn("failed to move file of the fallback path", zap.String("file", p), zap.String("newFile", dest), zap.Error(err))
+			return nil
+		}
+		moved++
+		return nil
+	})
+	if moved > 0 {
+		e.logger.Info("moved the files of the fallback path", zap.String("fallbackPath", f.path), zap.Int("files", moved))
+	}
+	return err
+}
+
+// isMigratedFile returns true if the file of the fallback path is moved to the path, the control files
+// and the rolling manifest of the fallback path are left in place
+func (e *fileExporter) isMigratedFile(name string) bool {
+	return !e.isInProcessName(name) && name != manifestRollingFile && name != bundleTmpFile && name != e.rotateTrigger &&
+		name != layoutFile && name != dirLockFile && !isStateName(name) && !isStagingName(name) && !isReportName(name) && !isStatusName(name) && !strings.HasPrefix(name, ".probe-")
+}
+
+// moveFile moves the file, copying it when it is on another file system; the copy is written under a
+// dot name and renamed once complete so that the uploaders never see a partial file, and an existing
+// file is never replaced
+func moveFile(src, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	tmp := filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp")
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = copyFile(out, src)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cErr := out.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// startFallback writes to the fallback path from the start if the path is unavailable, otherwise the
+// files left in the fallback path by a previous run are recovered and moved to the path
+func (e *fileExporter) startFallback() error {
+	if err := checkOutputPath(e.fallback.path); err != nil {
+		return err
+	}
+	if err := checkOutputPath(e.path); err != nil {
+		if !isMountLost(err) {
+			return err
+		}
+		e.activateFallback(context.Background(), err)
+		return nil
+	}
+	if err := e.recoverInProcessFilesOf(e.fallback.path); err != nil {
+		return err
+	}
+	return e.migrateFallback()
+}
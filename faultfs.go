@@ -0,0 +1,208 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// the operations faults are injected in
+const (
+	faultOpen   = "open"
+	faultWrite  = "write"
+	faultSync   = "sync"
+	faultRename = "rename"
+	faultLink   = "link"
+	faultStat   = "stat"
+	faultRemove = "remove"
+	faultMkdir  = "mkdir"
+)
+
+// fault fails the operations on the files matching the pattern with the error, such as syscall.ENOSPC or
+// syscall.EIO; a write fault writes the bytes allowed before failing so that partial writes are simulated
+type fault struct {
+	op      string
+	pattern string
+	err     error
+	// delay holds the matching operations before they run so that a slow disk is simulated, the operations
+	// of a fault with a delay and no error do not fail
+	delay time.Duration
+	// allowed is the number of bytes written to the file before the write fails
+	allowed int64
+	// times is the number of matching operations before the fault clears, zero matches them until it is cleared
+	times int
+}
+
+// faultFS wraps a file system and fails the operations matching its faults, a crash between writing a
+// file and renaming it is simulated by a rename fault followed by the recovery of a new exporter
+type faultFS struct {
+	fileSystem
+	mutex  sync.Mutex
+	faults []*fault
+	// written are the bytes written to the files since they were opened
+	written map[string]int64
+}
+
+func newFaultFS(fsys fileSystem) *faultFS {
+	return &faultFS{fileSystem: fsys, written: make(map[string]int64)}
+}
+
+// inject adds the fault, the faults are matched in the order they are added
+func (f *faultFS) inject(ft fault) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.faults = append(f.faults, &ft)
+}
+
+// clear removes every fault
+func (f *faultFS) clear() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.faults = nil
+}
+
+// match returns the fault of the operation on the file after its delay, nil if it does not fail
+func (f *faultFS) match(op, name string) *fault {
+	ft := f.find(op, name)
+	if ft == nil {
+		return nil
+	}
+	// the operations on other files are not held while the operation is delayed
+	time.Sleep(ft.delay)
+	if ft.err == nil {
+		return nil
+	}
+	return ft
+}
+
+// find returns the first fault matching the operation on the file and counts the match
+func (f *faultFS) find(op, name string) *fault {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i, ft := range f.faults {
+		if ft.op != op {
+			continue
+		}
+		if ok, _ := filepath.Match(ft.pattern, filepath.Base(name)); !ok && ft.pattern != name {
+			continue
+		}
+		if ft.times > 0 {
+			if ft.times--; ft.times == 0 {
+				f.faults = append(f.faults[:i:i], f.faults[i+1:]...)
+			}
+		}
+		return ft
+	}
+	return nil
+}
+
+func (f *faultFS) OpenFile(name string, flag int, perm os.FileMode) (fsFile, error) {
+	if ft := f.match(faultOpen, name); ft != nil {
+		return nil, &os.PathError{Op: faultOpen, Path: name, Err: ft.err}
+	}
+	file, err := f.fileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	f.written[name] = 0
+	f.mutex.Unlock()
+	return &faultFile{fsFile: file, fs: f, name: name}, nil
+}
+
+func (f *faultFS) Rename(oldName, newName string) error {
+	if ft := f.match(faultRename, oldName); ft != nil {
+		return &os.LinkError{Op: faultRename, Old: oldName, New: newName, Err: ft.err}
+	}
+	return f.fileSystem.Rename(oldName, newName)
+}
+
+func (f *faultFS) Link(oldName, newName string) error {
+	if ft := f.match(faultLink, oldName); ft != nil {
+		return &os.LinkError{Op: faultLink, Old: oldName, New: newName, Err: ft.err}
+	}
+	return f.fileSystem.Link(oldName, newName)
+}
+
+func (f *faultFS) Stat(name string) (os.FileInfo, error) {
+	if ft := f.match(faultStat, name); ft != nil {
+		return nil, &os.PathError{Op: faultStat, Path: name, Err: ft.err}
+	}
+	return f.fileSystem.Stat(name)
+}
+
+func (f *faultFS) Remove(name string) error {
+	if ft := f.match(faultRemove, name); ft != nil {
+		return &os.PathError{Op: faultRemove, Path: name, Err: ft.err}
+	}
+	return f.fileSystem.Remove(name)
+}
+
+func (f *faultFS) MkdirAll(path string, perm os.FileMode) error {
+	if ft := f.match(faultMkdir, path); ft != nil {
+		return &os.PathError{Op: faultMkdir, Path: path, Err: ft.err}
+	}
+	return f.fileSystem.MkdirAll(path, perm)
+}
+
+// faultFile fails the writes and syncs of a file of a faultFS
+type faultFile struct {
+	fsFile
+	fs   *faultFS
+	name string
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	return f.write(p, func(p []byte) (int, error) {
+		return f.fsFile.Write(p)
+	})
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	return f.write(p, func(p []byte) (int, error) {
+		return f.fsFile.WriteAt(p, off)
+	})
+}
+
+// write writes the bytes allowed by the write fault of the file before failing
+func (f *faultFile) write(p []byte, write func([]byte) (int, error)) (int, error) {
+	ft := f.fs.match(faultWrite, f.name)
+	f.fs.mutex.Lock()
+	written := f.fs.written[f.name]
+	f.fs.mutex.Unlock()
+	partial := p
+	if ft != nil {
+		if allowed := ft.allowed - written; allowed <= 0 {
+			partial = nil
+		} else if allowed < int64(len(p)) {
+			partial = p[:allowed]
+		}
+	}
+	n, err := 0, error(nil)
+	if len(partial) > 0 {
+		n, err = write(partial)
+	}
+	f.fs.mutex.Lock()
+	f.fs.written[f.name] += int64(n)
+	f.fs.mutex.Unlock()
+	if err == nil && ft != nil && n < len(p) {
+		err = &os.PathError{Op: faultWrite, Path: f.name, Err: ft.err}
+	}
+	return n, err
+}
+
+func (f *faultFile) Sync() error {
+	if ft := f.fs.match(faultSync, f.name); ft != nil {
+		return &os.PathError{Op: faultSync, Path: f.name, Err: ft.err}
+	}
+	return f.fsFile.Sync()
+}
@@ -0,0 +1,327 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// newFaultExporter creates the exporter of the configuration writing through a faultFS, it is not started
+func newFaultExporter(t *testing.T, cfg *Config) (*fileExporter, *faultFS) {
+	e := newTestExporter(t, cfg)
+	fsys := newFaultFS(osFS{})
+	e.fs = fsys
+	return e, fsys
+}
+
+// writeLines writes the lines, failing the test if any write fails
+func writeLines(t *testing.T, e *fileExporter, lines ...string) {
+	for _, line := range lines {
+		if err := writeLine(e, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// quarantinedFiles returns the quarantined in process files under the path
+func quarantinedFiles(t *testing.T, path string) []string {
+	var files []string
+	for _, f := range listFiles(t, path) {
+		if isQuarantineName(f) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func TestDiskFullQuarantinesPartialInProcessFile(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.QuarantineAfter = 2
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	writeLines(t, e, `{"a":1}`)
+	// the disk fills up in the middle of the second batch
+	fsys.inject(fault{op: faultWrite, pattern: inProcessName, err: syscall.ENOSPC, allowed: 10, times: 1})
+	fsys.inject(fault{op: faultWrite, pattern: inProcessName, err: syscall.ENOSPC})
+	for _, line := range []string{`{"a":2}`, `{"a":3}`} {
+		if err := writeLine(e, line); !errors.Is(err, syscall.ENOSPC) {
+			t.Fatalf("expected the write to fail as the disk is full, got %v", err)
+		}
+	}
+	quarantined := quarantinedFiles(t, cfg.Path)
+	if len(quarantined) != 1 {
+		t.Fatalf("expected the in process file to be quarantined, got %v", listFiles(t, cfg.Path))
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, quarantined[0])); content != `{"a":1}` {
+		t.Fatalf("expected the quarantined file to be truncated to its whole batches, got %q", content)
+	}
+	fsys.clear()
+	writeLines(t, e, `{"a":4}`)
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":4}` {
+		t.Fatalf("expected a new in process file once the disk has space, got %q", content)
+	}
+	if files := finishedFilesOf(t, e); len(files) != 0 {
+		t.Fatalf("expected no finished file, got %v", files)
+	}
+}
+
+func TestIOErrorKeepsWrittenBatches(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.QuarantineAfter = 2
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	writeLines(t, e, `{"a":1}`)
+	fsys.inject(fault{op: faultWrite, pattern: inProcessName, err: syscall.EIO})
+	if err := writeLine(e, `{"a":2}`); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the write to fail with an io error, got %v", err)
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}` {
+		t.Fatalf("expected the in process file to keep the batches written before the error, got %q", content)
+	}
+	if err := writeLine(e, `{"a":3}`); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the write to fail with an io error, got %v", err)
+	}
+	quarantined := quarantinedFiles(t, cfg.Path)
+	if len(quarantined) != 1 || readFile(t, filepath.Join(cfg.Path, quarantined[0])) != `{"a":1}` {
+		t.Fatalf("expected the in process file to be quarantined with its batches, got %v", listFiles(t, cfg.Path))
+	}
+	fsys.clear()
+	writeLines(t, e, `{"a":4}`)
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":4}` {
+		t.Fatalf("expected a new in process file once the error clears, got %q", content)
+	}
+}
+
+// failRename fails the link and the rename of the in process file to its finished name
+func failRename(fsys *faultFS) {
+	fsys.inject(fault{op: faultLink, pattern: inProcessName, err: syscall.EIO})
+	fsys.inject(fault{op: faultRename, pattern: inProcessName, err: syscall.EIO})
+}
+
+func TestRenameFailureKeepsInProcessFile(t *testing.T) {
+	cfg := testConfig(t, 16)
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`)
+	failRename(fsys)
+	if err := writeLine(e, `{"a":3}`); !errors.Is(err, ErrRotateFailed) || !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the rotation to fail, got %v", err)
+	}
+	if files := finishedFilesOf(t, e); len(files) != 0 {
+		t.Fatalf("expected no finished file, got %v", files)
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}{"a":2}` {
+		t.Fatalf("expected the in process file to keep its batches, got %q", content)
+	}
+	// the pipeline retries the batch once the rename succeeds
+	fsys.clear()
+	writeLines(t, e, `{"a":3}`)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || readFile(t, files[0]) != `{"a":1}{"a":2}` {
+		t.Fatalf("expected the in process file to be finished on retry, got %v", files)
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":3}` {
+		t.Fatalf("expected the retried batch in a new in process file, got %q", content)
+	}
+}
+
+func TestCrashBeforeRenameIsRecovered(t *testing.T) {
+	for _, mode := range []string{RecoverFinalize, RecoverResume} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := testConfig(t, 16)
+			cfg.RecoverInProcess = mode
+			e, fsys := newFaultExporter(t, cfg)
+			if err := e.Start(context.Background(), nil); err != nil {
+				t.Fatal(err)
+			}
+			writeLines(t, e, `{"a":1}`, `{"a":2}`)
+			// the exporter stops after the batches were written and before the file was renamed, the in
+			// process file is left behind as by a crash
+			failRename(fsys)
+			if err := writeLine(e, `{"a":3}`); err == nil {
+				t.Fatal("expected the rotation to fail")
+			}
+			if err := e.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			r := startTestExporter(t, cfg)
+			files := finishedFilesOf(t, r)
+			inProcess := filepath.Join(cfg.Path, inProcessName)
+			switch mode {
+			case RecoverFinalize:
+				if len(files) != 1 || readFile(t, files[0]) != `{"a":1}{"a":2}` {
+					t.Fatalf("expected the in process file to be finished on start, got %v", files)
+				}
+				for _, f := range listFiles(t, cfg.Path) {
+					if strings.HasSuffix(f, inProcessName) {
+						t.Fatalf("in process file %s left after recovery", f)
+					}
+				}
+			case RecoverResume:
+				if len(files) != 0 || readFile(t, inProcess) != `{"a":1}{"a":2}` {
+					t.Fatalf("expected the in process file to be resumed, got %v", files)
+				}
+			}
+			writeLines(t, r, `{"a":3}`)
+			files = finishedFilesOf(t, r)
+			if len(files) != 1 || readFile(t, files[0]) != `{"a":1}{"a":2}` || readFile(t, inProcess) != `{"a":3}` {
+				t.Fatalf("expected the batches to be written once, got %v", files)
+			}
+		})
+	}
+}
+
+// testTraces returns traces of one span
+func testTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetName("span")
+	span.SetTraceID(pcommon.TraceID([16]byte{1}))
+	span.SetSpanID(pcommon.SpanID([8]byte{1}))
+	return td
+}
+
+func TestMkdirFailureFailsWrite(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	fsys.inject(fault{op: faultMkdir, pattern: cfg.Path, err: syscall.EIO})
+	if err := writeLine(e, `{"a":1}`); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the write to fail as the path cannot be created, got %v", err)
+	}
+	fsys.clear()
+	writeLines(t, e, `{"a":2}`)
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":2}` {
+		t.Fatalf("expected the batch to be written once the path is created, got %q", content)
+	}
+}
+
+func TestParquetFooterIsWrittenOnce(t *testing.T) {
+	cfg := testConfig(t, 1)
+	cfg.Format = Parquet
+	cfg.RecoverInProcess = RecoverDiscard
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	ctx := context.Background()
+	if err := e.ConsumeTraces(ctx, testTraces()); err != nil {
+		t.Fatal(err)
+	}
+	// the parquet files of a signal are written to its sub directory
+	stat, err := os.Stat(filepath.Join(cfg.Path, "traces", inProcessName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the footer is partially written, then the rename of the file with its footer fails
+	fsys.inject(fault{op: faultWrite, pattern: inProcessName, err: syscall.ENOSPC, allowed: 5, times: 1})
+	if err = e.ConsumeTraces(ctx, testTraces()); !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("expected the footer write to fail, got %v", err)
+	}
+	failRename(fsys)
+	if err = e.ConsumeTraces(ctx, testTraces()); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the rename to fail, got %v", err)
+	}
+	fsys.clear()
+	if err = e.ConsumeTraces(ctx, testTraces()); err != nil {
+		t.Fatal(err)
+	}
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 {
+		t.Fatalf("expected one finished file, got %v", files)
+	}
+	content := []byte(readFile(t, files[0]))
+	if !bytes.HasPrefix(content, []byte(parquetMagic)) || !bytes.HasSuffix(content, []byte(parquetMagic)) {
+		t.Fatal("expected the finished file to start and end with the parquet magic")
+	}
+	footer := int64(binary.LittleEndian.Uint32(content[len(content)-8:]))
+	if size := int64(len(content)); size != stat.Size()+footer+8 {
+		t.Fatalf("expected the %d bytes of row groups followed by one footer of %d bytes, got %d bytes", stat.Size(), footer, size)
+	}
+}
+
+func TestRecoveryCountsBatchesThroughFileSystem(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = t.TempDir()
+	cfg.Format = "json"
+	cfg.EventsPerFile = 3
+	cfg.RecoverInProcess = RecoverResume
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Path, inProcessName), []byte(`{"a":1}{"a":2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, fsys := newFaultExporter(t, cfg)
+	fsys.inject(fault{op: faultOpen, pattern: inProcessName, err: syscall.EIO})
+	if err := e.Start(context.Background(), nil); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the start to fail as the batches cannot be counted, got %v", err)
+	}
+	r := startTestExporter(t, cfg)
+	writeLines(t, r, `{"a":3}`)
+	files := finishedFilesOf(t, r)
+	if len(files) != 1 || readFile(t, files[0]) != `{"a":1}{"a":2}{"a":3}` {
+		t.Fatalf("expected the resumed file to be finished at its third batch, got %v", files)
+	}
+}
+
+func TestBatchSequenceSaveFailureKeepsWriting(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.BatchSequence = &BatchSequenceConfig{Enabled: true, Target: BatchSequenceHeader}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	fsys.inject(fault{op: faultOpen, pattern: "*" + batchSequenceSuffix + ".tmp", err: syscall.ENOSPC})
+	writeLines(t, e, `{"a":1}`)
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); !strings.HasSuffix(content, `{"a":1}`) {
+		t.Fatalf("expected the batch to be written when its sequence cannot be saved, got %q", content)
+	}
+	for _, f := range listFiles(t, cfg.Path) {
+		if strings.HasSuffix(f, ".tmp") {
+			t.Fatalf("temporary sequence file %s left under the path", f)
+		}
+	}
+}
+
+func TestRotatedEventWithoutSizeWhenStatFails(t *testing.T) {
+	cfg := testConfig(t, 16)
+	cfg.FileNameTemplate = "file-{seq}.{ext}"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, fsys := newFaultExporter(t, cfg)
+	events := make(chan FileEvent, 16)
+	e.setEvents(events)
+	startExporter(t, e)
+	fsys.inject(fault{op: faultStat, pattern: "file-*", err: syscall.EIO})
+	writeLines(t, e, `{"a":1}`, `{"a":2}`, `{"a":3}`)
+	for len(events) > 0 {
+		if event := <-events; event.Type == FileRotated {
+			if filepath.Base(event.Path) != "file-000001.json" || event.Size != 0 || event.Records != 2 {
+				t.Fatalf("expected the rotated event of the file without its size, got %+v", event)
+			}
+			return
+		}
+	}
+	t.Fatal("expected a rotated event")
+}
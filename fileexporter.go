@@ -10,30 +10,30 @@ package fileexporter
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"encoding/binary"
-
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configtelemetry"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
 	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
-	resx "southwinds.dev/os"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
 )
 
 const (
 	timeFormat = "2006_01_02_15_04_05_999999999"
 	ext        = "inproc"
-	json       = "json"
-	protobuf   = "proto"
 )
 
 // Marshaller configuration used for marshaling Protobuf.
@@ -49,185 +49,943 @@ var jsonLogsMarshaller = plog.JSONMarshaler{}
 // fileExporter is the implementation of file exporter that writes telemetry data to a file
 // in Protobuf-JSON format.
 type fileExporter struct {
-	path              string
-	mutex             sync.Mutex
-	fileSizeKb        int64
-	eventsPerFile     int64
-	format            string
-	currentEventCount int64
+	path             string
+	fileSize         int64
+	eventsPerFile    int64
+	format           outputFormat
+	fileNameTemplate string
+	hostname         string
+	// rotationHeadroom is subtracted from the file size so that the finished files stay under it
+	rotationHeadroom int64
+	// timestampLayout and timestampZone format the {timestamp} placeholder of the file names
+	timestampLayout string
+	timestampZone   *time.Location
+	// fs is the file system the files are written to and renamed on
+	fs fileSystem
+	// writers holds the rotation state of each shard of each output directory, mutex guards it, the pending files counts
+	// and the status; each writer has its own lock so that the writes to different files do not wait for each other
+	writers     map[writerKey]*fileWriter
+	mutex       sync.Mutex
+	partitionBy []string
+	// lastRotation, lastError and lastErrorTime are reported in the status
+	lastRotation  time.Time
+	lastError     error
+	lastErrorTime time.Time
+	// reporter accounts the telemetry written in the daily reports, nil if they are not written
+	reporter *reporter
+	// tenantAttribute is the resource attribute or client metadata key of the tenant, the files of each
+	// tenant are written under their own sub directory; empty if the output is not split by tenant
+	tenantAttribute string
+	// compression is the codec used to compress finished files
+	compression      string
+	compressionLevel int
+	// keyProvider provides the key used to encrypt finished files, nil if encryption is disabled
+	keyProvider KeyProvider
+	// checksum is the algorithm of the checksum sidecar files
+	checksum string
+	// maxRecordSize is the maximum size in bytes of a marshaled batch, zero means no limit
+	maxRecordSize    int64
+	oversizeBehavior string
+	// minFreeDisk and maxDirSize are the disk usage limits in bytes, zero means no limit
+	minFreeDisk int64
+	maxDirSize  int64
+	onDiskFull  string
+	bundle      BundleConfig
+	// rotation is none when everything is appended to the fixed fileName
+	rotation        string
+	fileName        string
+	truncateOnStart bool
+	// idleFlush is the time after the last write when a non empty in process file is finished, zero disables it
+	idleFlush time.Duration
+	// maxInProcessAge is the time after the first write when an in process file is finished, zero disables it
+	maxInProcessAge time.Duration
+	// dedupe remembers the batches written recently to skip their duplicates, nil if they are not skipped
+	dedupe *deduper
+	// lateWrites remembers the writes abandoned at their deadline until their batch is retried
+	lateWrites *lateWrites
+	// dataAge tracks the age of the records written, nil if it is not tracked
+	dataAge *DataAgeConfig
+	// trash holds the files purged when the disk usage limit is reached, nil if they are deleted
+	trash *trash
+	// statusFile writes the status of the exporter to the path, nil if it is not written
+	statusFile *statusFile
+	// chunker finishes the in process files at content defined boundaries, nil if they are finished at the file size
+	chunker *chunker
+	// onForeignFiles defines what happens on start to the files of the path not named by the exporter
+	onForeignFiles string
+	// flushBytes, flushRecords and flushInterval bound the batches aggregated before being written,
+	// the batches are not aggregated if flushInterval is zero
+	flushBytes    int64
+	flushRecords  int64
+	flushInterval time.Duration
+	// fileHeader writes a header record at the start of each file, version is the exporter version it holds
+	fileHeader bool
+	version    string
+	// protoDescriptor writes the OTLP descriptors in the file header of the protobuf files
+	protoDescriptor bool
+	// maxPendingFiles is the number of finished files under a path from which onBacklog applies, zero
+	// means no limit; pendingCounts caches the number of finished files of each path
+	maxPendingFiles int
+	onBacklog       string
+	pendingCounts   map[string]pendingCount
+	// rotateAt aligns the finished files with hourly or daily clock boundaries in rotateLocation, empty if
+	// the files are not aligned
+	rotateAt       string
+	rotateLocation *time.Location
+	// rotateTrigger is the name of the marker file that forces the rotation, empty if it is not watched
+	rotateTrigger string
+	// done is closed on shutdown to stop the scheduled rotation and bundling
+	done chan struct{}
+	// identity is added to the resources or manifests, nil if it is not enabled
+	identity *identity
+	// batchSeq numbers the written batches, nil if they are not numbered
+	batchSeq *batchSequence
+	// skipEmpty drops the empty resources, scopes and metrics and does not write the batches without records
+	skipEmpty bool
+	// quarantineAfter is the number of consecutive failed writes quarantining the in process file, zero if
+	// it is never quarantined
+	quarantineAfter int
+	// inProcessSuffix is the name of the in process files, preceded by the instance when the path is shared
+	inProcessSuffix string
+	// countRecords counts the records of the batches towards the events per file instead of the batches
+	countRecords bool
+	// firstRecordTime names the finished files with the timestamp of their earliest record
+	firstRecordTime bool
+	// precreate creates the output directories on start, nil if they are created when first written to
+	precreate *PrecreateConfig
+	// degradation reduces the fidelity of the data written under file pressure, nil if it is written in full
+	degradation *degradation
+	// shards is the number of in process files written per directory and shardCounter spreads the batches across them
+	shards       int
+	shardCounter uint64
+	// notifier sends the finished files to pilot, nil if pilot polls the output directory
+	notifier *notifier
+	// manifest defines how the manifest of finished files is written
+	manifest string
+	// preallocate allocates the blocks of the in process files rotated by size when they are created
+	preallocate bool
+	// mmap writes the in process files rotated by size through a memory mapping
+	mmap bool
+	// writeBuffer is the size in bytes of the write buffer of the in process files
+	writeBuffer int
+	// directIO writes the in process files bypassing the page cache
+	directIO bool
+	// fallback is the local path written to while the path is unavailable, nil if there is none
+	fallback *fallback
+	// writeLimiter limits the bytes written per second, nil if the writes are not limited
+	writeLimiter *rateLimiter
+	// instance identifies the exporter among the processes and exporter entries sharing the path, empty if
+	// it is neither shared by processes nor named
+	instance string
+	// component is the name of the exporter entry in the file names, empty for the unnamed entry
+	component string
+	// handoff defines how the finished files are made available to the uploaders
+	handoff string
+	// recoverInProcess defines how the in process files of a previous run are handled on start
+	recoverInProcess string
+	// severityRoute routes severe log records to their own file series, nil if logs are not routed
+	severityRoute *severityRoute
+	// lanes write the matching telemetry to their own file series with their own rotation
+	lanes []*lane
+	// metricsTransform converts the metrics before they are written, nil if they are written as received
+	metricsTransform *metricsTransform
+	// logLimits caps the size of the log bodies and attributes written
+	logLimits logLimits
+	// redactor redacts the matching attribute values before writing, nil if nothing is redacted
+	redactor *redactor
+	// transforms are the statements executed on the telemetry before writing, nil if there are none
+	transforms *transforms
+	// signalDirs writes each signal to its own sub directory
+	signalDirs bool
+	// pipelines are the signals of the pipelines using the exporter and mixedPipelines is true if they are
+	// written to their own sub directories, unless interleaveSignals writes them to the same files
+	pipelines         map[string]bool
+	mixedPipelines    bool
+	interleaveSignals bool
+	// rotateHook is run for every finished file, nil if there is no command or callback
+	rotateHook *rotateHook
+	// events receives the file lifecycle events, nil if they are not sent, and eventsDropped counts the events
+	// dropped since the last one sent
+	events        chan<- FileEvent
+	eventsDropped uint64
+	// persistState saves the rotation state of the directories between runs
+	persistState bool
+	// syncWrites syncs the in process files after every batch, so that the batches removed from a
+	// persistent sending queue are on disk
+	syncWrites bool
+	// dryRun accounts the batches without writing them
+	dryRun bool
+	// siblings are the exporters of the other formats when several formats are written
+	siblings []*fileExporter
+	// filter selects the telemetry written, nil if no include or exclude filter is configured
+	filter *telemetryFilter
+	// marshaler encodes the telemetry when the format is custom
+	marshaler Marshaler
+	// exportRequest wraps the protobuf payloads in the OTLP export service request
+	exportRequest bool
+	// mirrorPaths are written with the same data as the path and mirrorPolicy decides if a failed
+	// mirror fails the write
+	mirrorPaths  []string
+	mirrorPolicy string
+	// deadLetter keeps the payloads that failed to be written, nil if no dead letter path is configured
+	deadLetter *deadLetter
+	// prettyPrint indents the json documents and recordSep is the separator written between them,
+	// jsonArray writes every file as a json array of the documents
+	prettyPrint bool
+	recordSep   string
+	jsonArray   bool
+	// csvColumns holds the columns written by the csv format
+	csvColumns []string
+	telemetry  *exporterTelemetry
+	logger     *zap.Logger
+	verbosity  configtelemetry.Level
+}
+
+// newFileExporter creates a file exporter for the passed in configuration
+func newFileExporter(cfg *Config, set component.ExporterCreateSettings, marshaler Marshaler, onRotate OnRotateFunc) *fileExporter {
+	if len(cfg.Formats) > 0 {
+		return newMultiFormatExporter(cfg, set, marshaler, onRotate)
+	}
+	logger := newExporterLogger(set.Logger, cfg.Verbosity)
+	for key, replacement := range cfg.deprecatedKeys {
+		logger.Warn("the configuration key is deprecated", zap.String("key", key), zap.String("replacement", replacement))
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warn("failed to retrieve hostname", zap.Error(err))
+		hostname = "unknown"
+	}
+	// an invalid format is rejected by the validation of the configuration
+	format, _ := parseFormat(cfg.Format)
+	template := cfg.FileNameTemplate
+	if len(template) == 0 {
+		template = defaultFileNameTemplate
+	}
+	if template == defaultFileNameTemplate && len(cfg.RotateAt) > 0 {
+		// the files aligned with clock boundaries are named with their bucket
+		template = bucketFileNameTemplate
+	}
+	template = shardFileNameTemplate(instanceFileNameTemplate(template, instanceOf(cfg)), cfg.Shards)
+	csvColumns := cfg.CsvColumns
+	if len(csvColumns) == 0 {
+		csvColumns = defaultCsvColumns
+	}
+	return &fileExporter{
+		path:              cfg.Path,
+		fileSize:          cfg.fileSizeBytes(),
+		rotationHeadroom:  cfg.RotationHeadroomBytes,
+		eventsPerFile:     cfg.EventsPerFile,
+		format:            format,
+		fileNameTemplate:  template,
+		timestampLayout:   cfg.TimestampLayout,
+		timestampZone:     rotateLocation(cfg.TimestampTimezone),
+		hostname:          hostname,
+		fs:                osFS{},
+		writers:           make(map[writerKey]*fileWriter),
+		partitionBy:       cfg.PartitionBy,
+		tenantAttribute:   cfg.TenantAttribute,
+		compression:       strings.ToLower(cfg.Compression),
+		compressionLevel:  cfg.CompressionLevel,
+		keyProvider:       cfg.Encryption.provider(),
+		checksum:          cfg.Checksum,
+		maxRecordSize:     cfg.MaxRecordSizeKb * 1024,
+		oversizeBehavior:  strings.ToLower(cfg.OversizeBehavior),
+		csvColumns:        csvColumns,
+		prettyPrint:       cfg.PrettyPrint,
+		recordSep:         recordSeparator(cfg),
+		jsonArray:         strings.EqualFold(cfg.JSONLayout, JSONLayoutArray),
+		minFreeDisk:       cfg.minFreeDiskBytes(),
+		maxDirSize:        cfg.maxDirSizeBytes(),
+		onDiskFull:        strings.ToLower(cfg.OnDiskFull),
+		bundle:            cfg.Bundle,
+		rotation:          cfg.Rotation,
+		fileName:          cfg.FileName,
+		truncateOnStart:   cfg.TruncateOnStart,
+		idleFlush:         time.Duration(cfg.IdleFlushSeconds) * time.Second,
+		maxInProcessAge:   time.Duration(cfg.MaxInProcessAgeSeconds) * time.Second,
+		dedupe:            newDeduper(cfg, logger),
+		lateWrites:        newLateWrites(),
+		dataAge:           newDataAge(cfg.DataAge),
+		trash:             newTrash(cfg.Trash, logger),
+		statusFile:        newStatusFile(cfg, logger),
+		chunker:           newChunker(cfg),
+		onForeignFiles:    strings.ToLower(cfg.OnForeignFiles),
+		rotateTrigger:     cfg.RotateTriggerFile,
+		maxPendingFiles:   cfg.MaxPendingFiles,
+		fileHeader:        cfg.FileHeader,
+		protoDescriptor:   cfg.ProtobufDescriptor,
+		version:           exporterVersion(),
+		onBacklog:         strings.ToLower(cfg.OnBacklog),
+		pendingCounts:     make(map[string]pendingCount),
+		rotateAt:          strings.ToLower(cfg.RotateAt),
+		rotateLocation:    rotateLocation(cfg.RotateTimezone),
+		flushBytes:        cfg.FlushBytes,
+		flushRecords:      cfg.FlushRecords,
+		flushInterval:     cfg.flushInterval(),
+		done:              make(chan struct{}),
+		filter:            newTelemetryFilter(cfg.Include, cfg.Exclude),
+		severityRoute:     newSeverityRoute(cfg.RouteBySeverity),
+		lanes:             newLanes(cfg.Lanes),
+		reporter:          newReporter(cfg, hostname, logger),
+		metricsTransform:  newMetricsTransform(cfg.MetricsTransform),
+		logLimits:         newLogLimits(cfg),
+		redactor:          newRedactor(cfg.Redact),
+		transforms:        newTransforms(cfg.Transforms),
+		signalDirs:        cfg.SignalDirectories,
+		interleaveSignals: cfg.InterleaveSignals,
+		rotateHook:        newRotateHook(cfg.OnRotate, onRotate),
+		persistState:      cfg.PersistState,
+		syncWrites:        cfg.usesPersistentQueue(),
+		dryRun:            cfg.DryRun,
+		mirrorPaths:       cfg.MirrorPaths,
+		exportRequest:     cfg.ProtobufExportRequest,
+		marshaler:         marshaler,
+		mirrorPolicy:      strings.ToLower(cfg.MirrorPolicy),
+		deadLetter:        newDeadLetter(cfg.DeadLetterPath, cfg.DeadLetterMaxSizeMb),
+		recoverInProcess:  strings.ToLower(cfg.RecoverInProcess),
+		handoff:           strings.ToLower(cfg.Handoff),
+		preallocate:       strings.EqualFold(cfg.WriteMode, WriteModePreallocate),
+		mmap:              strings.EqualFold(cfg.WriteMode, WriteModeMmap),
+		writeBuffer:       writeBufferBytes(cfg.WriteBufferKb),
+		directIO:          cfg.DirectIO,
+		fallback:          newFallback(cfg.Fallback),
+		writeLimiter:      newRateLimiter(cfg.MaxWriteBytesPerSecond),
+		instance:          instanceOf(cfg),
+		component:         cfg.componentInstance(),
+		manifest:          strings.ToLower(cfg.Manifest),
+		identity:          newIdentity(cfg.Identity, hostname),
+		batchSeq:          newBatchSequence(cfg, hostname),
+		skipEmpty:         cfg.SkipEmpty,
+		quarantineAfter:   cfg.QuarantineAfter,
+		inProcessSuffix:   inProcessSuffixOf(cfg),
+		countRecords:      strings.EqualFold(cfg.CountBy, CountByRecords),
+		firstRecordTime:   strings.EqualFold(cfg.FileTimestamp, FileTimestampFirstRecord),
+		precreate:         newPrecreate(cfg.Precreate),
+		degradation:       newDegradation(cfg.AdaptiveDegradation),
+		shards:            cfg.Shards,
+		notifier:          newNotifier(cfg.Notify, logger),
+		telemetry:         newExporterTelemetry(cfg.ID(), set.TelemetrySettings, cfg.DryRun, logger),
+		logger:            logger,
+		verbosity:         cfg.Verbosity,
+	}
 }
 
 func (e *fileExporter) Capabilities() consumer.Capabilities {
 	return consumer.Capabilities{MutatesData: false}
 }
 
-func (e *fileExporter) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+func (e *fileExporter) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	return multierr.Append(e.consumeTraces(ctx, td), e.eachSibling(func(s *fileExporter) error {
+		return s.ConsumeTraces(ctx, td)
+	}))
+}
 
-	var err error
-	var buf []byte
-	if strings.EqualFold(e.format, Json) {
+func (e *fileExporter) consumeTraces(ctx context.Context, td ptrace.Traces) error {
+	key, duplicate := e.duplicateOf(signalTraces, func() ([]byte, error) {
+		return pbTracesMarshaller.MarshalTraces(td)
+	})
+	if duplicate {
+		return nil
+	}
+	if td = e.filterTraces(e.pruneTraces(td)); td.SpanCount() == 0 {
+		return nil
+	}
+	if td = e.degradeTraces(td); td.SpanCount() == 0 {
+		return nil
+	}
+	td = e.identifyTraces(e.redactTraces(e.applyTraceTransforms(td)))
+	var errs error
+	tenant := e.metadataTenant(ctx)
+	for route, ltd := range e.laneTraces(td) {
+		for partition, ptd := range e.partitionTraces(ltd, tenant) {
+			errs = multierr.Append(errs, e.deadLetterTraces(ptd, e.writeTraces(ctx, partition, route, ptd)))
+		}
+	}
+	if errs == nil {
+		e.dedupe.remember(key, time.Now())
+	}
+	return errs
+}
+
+func (e *fileExporter) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	return multierr.Append(e.consumeMetrics(ctx, md), e.eachSibling(func(s *fileExporter) error {
+		return s.ConsumeMetrics(ctx, md)
+	}))
+}
+
+func (e *fileExporter) consumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	key, duplicate := e.duplicateOf(signalMetrics, func() ([]byte, error) {
+		return pbMetricsMarshaller.MarshalMetrics(md)
+	})
+	if duplicate {
+		return nil
+	}
+	if md = e.filterMetrics(e.pruneMetrics(md)); md.DataPointCount() == 0 {
+		return nil
+	}
+	if md = e.transformMetrics(md); md.DataPointCount() == 0 {
+		return nil
+	}
+	md = e.identifyMetrics(e.redactMetrics(e.applyMetricTransforms(e.degradeMetrics(md))))
+	var errs error
+	tenant := e.metadataTenant(ctx)
+	for route, lmd := range e.laneMetrics(md) {
+		for partition, pmd := range e.partitionMetrics(lmd, tenant) {
+			errs = multierr.Append(errs, e.deadLetterMetrics(pmd, e.writeMetrics(ctx, partition, route, pmd)))
+		}
+	}
+	if errs == nil {
+		e.dedupe.remember(key, time.Now())
+	}
+	return errs
+}
+
+func (e *fileExporter) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	return multierr.Append(e.consumeLogs(ctx, ld), e.eachSibling(func(s *fileExporter) error {
+		return s.ConsumeLogs(ctx, ld)
+	}))
+}
+
+func (e *fileExporter) consumeLogs(ctx context.Context, ld plog.Logs) error {
+	key, duplicate := e.duplicateOf(signalLogs, func() ([]byte, error) {
+		return pbLogsMarshaller.MarshalLogs(ld)
+	})
+	if duplicate {
+		return nil
+	}
+	if ld = e.filterLogs(e.pruneLogs(ld)); ld.LogRecordCount() == 0 {
+		return nil
+	}
+	ld = e.identifyLogs(e.redactLogs(e.limitLogs(e.applyLogTransforms(ld))))
+	var errs error
+	tenant := e.metadataTenant(ctx)
+	rest, routed := e.routeLogs(ld)
+	for route, lld := range e.laneLogs(rest) {
+		for partition, pld := range e.partitionLogs(lld, tenant) {
+			errs = multierr.Append(errs, e.deadLetterLogs(pld, e.writeLogs(ctx, partition, route, pld)))
+		}
+	}
+	if routed.LogRecordCount() > 0 {
+		for partition, pld := range e.partitionLogs(routed, tenant) {
+			errs = multierr.Append(errs, e.deadLetterLogs(pld, e.writeLogs(ctx, partition, e.severityRoute.directory, pld)))
+		}
+	}
+	if errs == nil {
+		e.dedupe.remember(key, time.Now())
+	}
+	return errs
+}
+
+// marshalTraces encodes the traces in the format of the exporter, the errors are permanent
+func (e *fileExporter) marshalTraces(td ptrace.Traces) (buf []byte, rowGroup *parquetRowGroup, err error) {
+	switch {
+	case e.format == formatJSON:
 		buf, err = jsonTracesMarshaller.MarshalTraces(td)
-	} else if strings.EqualFold(e.format, Protobuf) {
+	case e.format == formatProtobuf && e.exportRequest:
+		buf, err = ptraceotlp.NewExportRequestFromTraces(td).MarshalProto()
+	case e.format == formatProtobuf:
 		buf, err = pbTracesMarshaller.MarshalTraces(td)
-	} else {
-		return errors.New("invalid format, valid format value is either json or protobuf")
+	case e.format == formatOtlpJSON:
+		buf, err = ptraceotlp.NewExportRequestFromTraces(td).MarshalJSON()
+	case e.format == formatParquet:
+		buf, rowGroup = tracesTable(td).encode()
+	case e.format == formatJaegerJSON:
+		buf, err = tracesJaeger(td)
+	case e.format == formatZipkinJSON:
+		buf, err = tracesZipkin(td)
+	case e.format == formatCustom:
+		buf, err = e.marshaler.MarshalTraces(td)
+	default:
+		// the format is validated when the exporter is created
+		return nil, nil, consumererror.NewPermanent(&FormatError{Format: e.format.String(), Reason: e.format.unsupportedReason()})
 	}
-
 	if err != nil {
-		return err
+		// marshaling the same data again would fail so the error is not retried
+		return nil, nil, consumererror.NewPermanent(err)
 	}
-	return e.exportAsLine(buf)
+	if e.format.isJSON() {
+		buf = e.jsonRecord(buf)
+	}
+	return buf, rowGroup, nil
 }
 
-func (e *fileExporter) ConsumeMetrics(_ context.Context, md pmetric.Metrics) error {
-
-	var err error
+// writeTraces marshals the traces and writes them to the partition and route, splitting them if they are
+// oversize or larger than the files
+func (e *fileExporter) writeTraces(ctx context.Context, partition string, route string, td ptrace.Traces) error {
+	if e.isEmptyBatch(td.SpanCount()) {
+		return nil
+	}
+	// the size is known before marshaling when the marshaler can tell it, so that the batches to split or
+	// reject are not marshaled first
 	var buf []byte
-	if strings.EqualFold(e.format, Json) {
+	var rowGroup *parquetRowGroup
+	var err error
+	size, sized := e.tracesSize(route, td)
+	if !sized {
+		if buf, rowGroup, err = e.marshalTraces(td); err != nil {
+			return err
+		}
+		size = len(buf)
+	}
+	if e.isOversize(size) {
+		count := td.SpanCount()
+		if e.canSplit(count) {
+			return multierr.Append(
+				e.writeTraces(ctx, partition, route, sliceTraces(td, 0, count/2)),
+				e.writeTraces(ctx, partition, route, sliceTraces(td, count/2, count)))
+		}
+		return e.rejectOversize(signalTraces, size, count)
+	}
+	if count := td.SpanCount(); e.exceedsFileSize(route, size) && count > 1 {
+		return multierr.Append(
+			e.writeTraces(ctx, partition, route, sliceTraces(td, 0, count/2)),
+			e.writeTraces(ctx, partition, route, sliceTraces(td, count/2, count)))
+	}
+	if sized && !e.batchSeq.stampsResources() {
+		if buf, rowGroup, err = e.marshalTraces(td); err != nil {
+			return err
+		}
+	}
+	b := &batch{signal: signalTraces, records: td.SpanCount(), buf: buf, rowGroup: rowGroup, route: route}
+	if e.batchSeq.stampsResources() {
+		// the batch is numbered once it is no longer split so that every number is written
+		if b.buf, b.rowGroup, err = e.marshalTraces(e.sequenceTraces(td, e.nextBatchSequence())); err != nil {
+			return err
+		}
+	}
+	if e.needsTimeRange() {
+		b.first, b.last = tracesTimeRange(td)
+	}
+	return e.exportAsLine(ctx, partition, b)
+}
+
+// marshalMetrics encodes the metrics in the format of the exporter, the errors are permanent
+func (e *fileExporter) marshalMetrics(md pmetric.Metrics) (buf []byte, rowGroup *parquetRowGroup, err error) {
+	switch {
+	case e.format == formatJSON:
 		buf, err = jsonMetricsMarshaller.MarshalMetrics(md)
-	} else if strings.EqualFold(e.format, Protobuf) {
+	case e.format == formatProtobuf && e.exportRequest:
+		buf, err = pmetricotlp.NewExportRequestFromMetrics(md).MarshalProto()
+	case e.format == formatProtobuf:
 		buf, err = pbMetricsMarshaller.MarshalMetrics(md)
-	} else {
-		return errors.New("invalid format, valid format value is either json or protobuf")
+	case e.format == formatOtlpJSON:
+		buf, err = pmetricotlp.NewExportRequestFromMetrics(md).MarshalJSON()
+	case e.format == formatParquet:
+		buf, rowGroup = metricsTable(md).encode()
+	case e.format == formatCustom:
+		buf, err = e.marshaler.MarshalMetrics(md)
+	case e.format == formatCsv:
+		buf, err = metricsCsv(md, e.csvColumns)
+	case e.format == formatPrometheus:
+		buf = metricsPrometheus(md)
+	default:
+		// the format is validated when the exporter is created
+		return nil, nil, consumererror.NewPermanent(&FormatError{Format: e.format.String(), Reason: e.format.unsupportedReason()})
 	}
-
 	if err != nil {
-		return err
+		// marshaling the same data again would fail so the error is not retried
+		return nil, nil, consumererror.NewPermanent(err)
+	}
+	if e.format.isJSON() {
+		buf = e.jsonRecord(buf)
 	}
-	return e.exportAsLine(buf)
+	return buf, rowGroup, nil
 }
 
-func (e *fileExporter) ConsumeLogs(_ context.Context, ld plog.Logs) error {
-	var err error
+// writeMetrics marshals the metrics and writes them to the partition and route, splitting them if they are
+// oversize or larger than the files
+func (e *fileExporter) writeMetrics(ctx context.Context, partition string, route string, md pmetric.Metrics) error {
+	if e.isEmptyBatch(md.DataPointCount()) {
+		return nil
+	}
+	// the size is known before marshaling when the marshaler can tell it, so that the batches to split or
+	// reject are not marshaled first
 	var buf []byte
-	if strings.EqualFold(e.format, Json) {
+	var rowGroup *parquetRowGroup
+	var err error
+	size, sized := e.metricsSize(route, md)
+	if !sized {
+		if buf, rowGroup, err = e.marshalMetrics(md); err != nil {
+			return err
+		}
+		size = len(buf)
+	}
+	if e.isOversize(size) {
+		// metrics are split by metric so that data points are kept with their metric definition
+		count := md.MetricCount()
+		if e.canSplit(count) {
+			return multierr.Append(
+				e.writeMetrics(ctx, partition, route, sliceMetrics(md, 0, count/2)),
+				e.writeMetrics(ctx, partition, route, sliceMetrics(md, count/2, count)))
+		}
+		return e.rejectOversize(signalMetrics, size, md.DataPointCount())
+	}
+	if count := md.MetricCount(); e.exceedsFileSize(route, size) && count > 1 {
+		return multierr.Append(
+			e.writeMetrics(ctx, partition, route, sliceMetrics(md, 0, count/2)),
+			e.writeMetrics(ctx, partition, route, sliceMetrics(md, count/2, count)))
+	}
+	if sized && !e.batchSeq.stampsResources() {
+		if buf, rowGroup, err = e.marshalMetrics(md); err != nil {
+			return err
+		}
+	}
+	b := &batch{signal: signalMetrics, records: md.DataPointCount(), buf: buf, rowGroup: rowGroup, route: route}
+	if e.batchSeq.stampsResources() {
+		// the batch is numbered once it is no longer split so that every number is written
+		if b.buf, b.rowGroup, err = e.marshalMetrics(e.sequenceMetrics(md, e.nextBatchSequence())); err != nil {
+			return err
+		}
+	}
+	if e.isCsv() {
+		b.header = csvHeader(e.csvColumns)
+	}
+	if e.needsTimeRange() {
+		b.first, b.last = metricsTimeRange(md)
+	}
+	return e.exportAsLine(ctx, partition, b)
+}
+
+// marshalLogs encodes the logs in the format of the exporter, the errors are permanent
+func (e *fileExporter) marshalLogs(ld plog.Logs) (buf []byte, rowGroup *parquetRowGroup, err error) {
+	switch {
+	case e.format == formatJSON:
 		buf, err = jsonLogsMarshaller.MarshalLogs(ld)
-	} else if strings.EqualFold(e.format, Protobuf) {
+	case e.format == formatProtobuf && e.exportRequest:
+		buf, err = plogotlp.NewExportRequestFromLogs(ld).MarshalProto()
+	case e.format == formatProtobuf:
 		buf, err = pbLogsMarshaller.MarshalLogs(ld)
-	} else {
-		return errors.New("invalid format, valid format value is either json or protobuf")
+	case e.format == formatOtlpJSON:
+		buf, err = plogotlp.NewExportRequestFromLogs(ld).MarshalJSON()
+	case e.format == formatParquet:
+		buf, rowGroup = logsTable(ld).encode()
+	case e.format == formatCustom:
+		buf, err = e.marshaler.MarshalLogs(ld)
+	default:
+		// the format is validated when the exporter is created
+		return nil, nil, consumererror.NewPermanent(&FormatError{Format: e.format.String(), Reason: e.format.unsupportedReason()})
 	}
-
 	if err != nil {
-		return err
+		// marshaling the same data again would fail so the error is not retried
+		return nil, nil, consumererror.NewPermanent(err)
 	}
-	return e.exportAsLine(buf)
+	if e.format.isJSON() {
+		buf = e.jsonRecord(buf)
+	}
+	return buf, rowGroup, nil
 }
 
-func (e *fileExporter) exportAsLine(buf []byte) error {
-
-	// Ensure only one write operation happens at a time.
-	e.mutex.Lock()
-	defer e.mutex.Unlock()
-	path := e.path
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		if err = os.MkdirAll(path, 0755); err != nil {
-			log.Printf("failed to create path %s, error %s \n", path, err)
-		}
+// writeLogs marshals the logs and writes them to the partition and route, splitting them if they are oversize
+// or larger than the files
+func (e *fileExporter) writeLogs(ctx context.Context, partition string, route string, ld plog.Logs) error {
+	if e.isEmptyBatch(ld.LogRecordCount()) {
+		return nil
 	}
+	// the size is known before marshaling when the marshaler can tell it, so that the batches to split or
+	// reject are not marshaled first
+	var buf []byte
+	var rowGroup *parquetRowGroup
 	var err error
-	if e.fileSizeKb > 0 {
-		err = e.writeAsPerKb(buf, path)
-	} else if e.eventsPerFile > 0 {
-		err = e.writeAsPerEventCount(buf, path)
-	} else {
-		return errors.New("invalid option, neither file size nor events per file is defined")
+	size, sized := e.logsSize(route, ld)
+	if !sized {
+		if buf, rowGroup, err = e.marshalLogs(ld); err != nil {
+			return err
+		}
+		size = len(buf)
 	}
+	if e.isOversize(size) {
+		count := ld.LogRecordCount()
+		if e.canSplit(count) {
+			return multierr.Append(
+				e.writeLogs(ctx, partition, route, sliceLogs(ld, 0, count/2)),
+				e.writeLogs(ctx, partition, route, sliceLogs(ld, count/2, count)))
+		}
+		return e.rejectOversize(signalLogs, size, count)
+	}
+	if count := ld.LogRecordCount(); e.exceedsFileSize(route, size) && count > 1 {
+		return multierr.Append(
+			e.writeLogs(ctx, partition, route, sliceLogs(ld, 0, count/2)),
+			e.writeLogs(ctx, partition, route, sliceLogs(ld, count/2, count)))
+	}
+	if sized && !e.batchSeq.stampsResources() {
+		if buf, rowGroup, err = e.marshalLogs(ld); err != nil {
+			return err
+		}
+	}
+	b := &batch{signal: signalLogs, records: ld.LogRecordCount(), buf: buf, rowGroup: rowGroup, route: route}
+	if e.batchSeq.stampsResources() {
+		// the batch is numbered once it is no longer split so that every number is written
+		if b.buf, b.rowGroup, err = e.marshalLogs(e.sequenceLogs(ld, e.nextBatchSequence())); err != nil {
+			return err
+		}
+	}
+	if e.needsTimeRange() {
+		b.first, b.last = logsTimeRange(ld)
+	}
+	return e.exportAsLine(ctx, partition, b)
+}
+
+// exportAsLine writes the batch to the in process file of the partition sub directory of the path and
+// of every mirror path, the mirror policy decides if a failed mirror fails the write; the write is
+// abandoned if the context is done before it starts or its deadline passes while writing
+func (e *fileExporter) exportAsLine(ctx context.Context, partition string, b *batch) error {
+	e.telemetry.enqueued()
+	defer e.telemetry.dequeued()
 
+	b.lateKey = e.lateWrites.keyOf(ctx, partition, b)
+	e.sequenceHeader(b)
+	roots := e.roots()
+	errs := make([]error, len(roots))
+	for i, root := range roots {
+		errs[i] = e.exportTo(ctx, root, partition, b)
+	}
+	roots[0], errs[0] = e.writeFallback(ctx, roots[0], partition, b, errs[0])
+	err := e.mirrorResult(roots, errs)
+	e.telemetry.recordWrite(b.records, len(b.buf), err)
+	if err != nil {
+		e.recordError(err)
+	} else if b.dropped {
+		e.reporter.recordDropped(b.records)
+	} else {
+		e.reporter.recordWrite(b.signal, b.records, len(b.buf))
+	}
 	return err
 }
 
-func (e *fileExporter) Start(context.Context, component.Host) error {
-	return nil
+// exportTo writes the batch to the in process file of the partition sub directory of the root path, only
+// one write to the file happens at a time and a mirror not written because the context is done while
+// waiting or writing fails as per the mirror policy
+func (e *fileExporter) exportTo(ctx context.Context, root string, partition string, b *batch) error {
+	path := filepath.Join(root, partition, b.route)
+	if e.isSignalDir() {
+		path = filepath.Join(path, b.signal)
+	}
+	// the retried batch of a write abandoned at its deadline is not written again once it landed
+	if landed, err := e.lateWrites.landed(ctx, root, b); err != nil || landed {
+		if landed {
+			e.debug("skipping batch written by an abandoned write", zap.String("path", path))
+		}
+		return err
+	}
+	// the rate is waited for before locking the writer so that its rotation is not held up
+	if err := e.throttle(ctx, len(b.buf)); err != nil {
+		return err
+	}
+	w := e.writer(path, b.route, e.nextShard())
+	if err := w.mutex.LockContext(ctx); err != nil {
+		return err
+	}
+	if e.dryRun {
+		defer w.mutex.Unlock()
+		e.dryRunWrite(w, b)
+		return nil
+	}
+	return e.writeWithin(ctx, root, w, b, func() error {
+		return e.writeTo(ctx, root, w, b)
+	})
 }
 
-// Shutdown stops the exporter and is invoked during shutdown.
-func (e *fileExporter) Shutdown(context.Context) error {
-	return nil
+// writeTo writes the batch to the in process file of the locked writer under the root path, the batch is not
+// written if the write was abandoned while the file was rotated
+func (e *fileExporter) writeTo(ctx context.Context, root string, w *fileWriter, b *batch) error {
+	path := w.path
+	if !w.dirReady {
+		// the root path is checked on start, only the partition sub directories are created here
+		if err := e.fs.MkdirAll(path, 0755); err != nil {
+			e.logger.Error("failed to create path", zap.String("path", path), zap.Error(err))
+			return fmt.Errorf("failed to create path %s: %w", path, err)
+		}
+		w.dirReady = true
+	}
+	ok, err := e.checkDiskUsage(root, int64(len(b.buf)))
+	if err == nil && ok {
+		ok, err = e.checkBacklog(root)
+	}
+	if err != nil || !ok {
+		if err == nil && root == e.path {
+			// the batches dropped from the path are reported as dropped rather than written
+			b.dropped = true
+		}
+		return err
+	}
+	if err = e.rotateIfBoundary(w, time.Now()); err != nil {
+		return err
+	}
+	if err = e.finishIfAged(w, time.Now()); err != nil {
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		// the pipeline retries the batch so it is not written by the abandoned write
+		return err
+	}
+	if e.isRotationNone() {
+		return e.writeSingleFile(w, b)
+	} else if w.fileSize > 0 {
+		err = e.writeAsPerSize(w, b)
+	} else if w.eventsPerFile > 0 {
+		err = e.writeAsPerEventCount(w, b)
+	} else {
+		return fmt.Errorf("invalid option: %w", ErrNoRotationPolicy)
+	}
+	if err == nil {
+		e.saveState(w)
+	}
+	return err
 }
 
-func (e *fileExporter) writeAsPerKb(buf []byte, path string) error {
-	// check if there is already a file with extension .inprocess, if yes use it else create new
-	files, err := filepath.Glob(filepath.Join(path, fmt.Sprintf(".%s", ext)))
-	if err != nil {
-		log.Printf("failed to find inprocess file at path %s, error %s \n", path, err)
+func (e *fileExporter) Start(ctx context.Context, host component.Host) error {
+	if err := e.start(); err != nil {
 		return err
 	}
-	msize := int64(binary.Size(buf) / 1024)
-	er, bol := e.isFileSizeExceeding(files, msize)
-	if er != nil {
-		return er
+	return e.startSiblings(ctx, host)
+}
+
+func (e *fileExporter) start() error {
+	if e.dryRun {
+		e.logger.Info("dry run, the telemetry is not written", zap.String("path", e.path))
+		return nil
 	}
-	if len(files) == 0 || bol {
-		if bol {
-			e.renameTmpFile(files[0])
+	e.notifier.start()
+	if e.fallback != nil {
+		if err := e.startFallback(); err != nil {
+			return err
 		}
-		filename := fmt.Sprintf(".%s", ext)
-		path = filepath.Join(path, filename)
-		err = resx.AppendFileBatch(buf, path, 0755)
+	}
+	if err := e.checkOutputPaths(); err != nil {
 		return err
-	} else {
-		f := files[0]
-		if len(os.Getenv("TELE_DEBUG")) > 0 {
-			log.Printf("writeAsPerKb current inprocess file found, %s \n", f)
+	}
+	if err := e.migrateLayouts(); err != nil {
+		return err
+	}
+	if err := e.checkForeignFiles(); err != nil {
+		return err
+	}
+	if e.precreate != nil {
+		if err := e.precreateDirs(); err != nil {
+			return err
 		}
-		err = resx.AppendFileBatch(buf, f, 0755)
-		if err != nil {
-			log.Printf("failed to write data to inprocess file, %s, error %s \n", f, err)
+	}
+	if e.batchSeq != nil {
+		if err := e.batchSeq.load(e.fs); err != nil {
 			return err
 		}
-		if len(os.Getenv("TELE_DEBUG")) > 0 {
-			log.Printf("size of current inprocess file and input data size is less than the max file size, so writing to the same file")
+	}
+	if e.dedupe != nil {
+		if err := e.dedupe.load(time.Now()); err != nil {
+			return err
+		}
+	}
+	// nothing is written before the exporter starts so the files are handled without holding any lock
+	if e.isRotationNone() && e.truncateOnStart {
+		if err := e.truncateSingleFiles(); err != nil {
+			return err
+		}
+	}
+	if !e.isRotationNone() {
+		if err := e.recoverInProcessFiles(); err != nil {
+			return err
+		}
+	}
+	if interval := e.scheduleInterval(); interval > 0 {
+		go e.scheduleLoop(interval)
+	}
+	if e.reporter != nil {
+		go e.reportLoop()
+	}
+	if e.trash != nil {
+		go e.trashLoop()
+	}
+	if e.statusFile != nil {
+		go e.statusLoop()
+	}
+	return nil
+}
+
+// Shutdown stops the exporter and is invoked during shutdown.
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	close(e.done)
+	unregisterStatus(e)
+	err := e.eachWriter(context.Background(), func(w *fileWriter) error {
+		return w.close()
+	})
+	err = multierr.Append(err, e.waitRotateHooks(ctx))
+	err = multierr.Append(err, e.notifier.shutdown(ctx))
+	err = multierr.Append(err, e.dedupe.close())
+	e.reporter.flush(time.Now())
+	e.statusFile.save(e.status())
+	return multierr.Append(err, e.eachSibling(func(s *fileExporter) error {
+		return s.Shutdown(ctx)
+	}))
+}
+
+// writeAsPerSize appends the batch to the in process file, finishing the file first if the batch would
+// make it exceed the file size; a batch larger than the file size is written to a file of its own
+func (e *fileExporter) writeAsPerSize(w *fileWriter, b *batch) error {
+	f := e.inProcessFileOf(w)
+	if err := w.open(f, 0755); err != nil {
+		e.writeFailed(w, f, "failed to open inprocess file", err)
+		return fmt.Errorf("failed to open inprocess file %s: %w", f, err)
+	}
+	e.debug("before writing to inprocess file", zap.String("file", f), zap.Int64("fileSize", w.size), zap.Int("dataSize", len(b.buf)))
+	if w.size > 0 && w.size+int64(len(b.buf)) > w.fileSize {
+		// adding the current data would exceed the file size, so the current in process file is finished
+		// and treated as completed and ready for upload, and the current data is written to a new in process file
+		if err := e.finishFile(w, f); err != nil {
+			return err
 		}
 	}
+	offset := w.size
+	if err := e.appendBatch(w, b, f, 0755); err != nil {
+		e.writeFailed(w, f, "failed to write data to inprocess file", err)
+		return fmt.Errorf("failed to write data to inprocess file %s: %w", f, err)
+	}
+	if e.chunker.boundary(w, offset, b.buf) {
+		return e.finishFile(w, f)
+	}
 	return nil
 }
 
-func (e *fileExporter) writeAsPerEventCount(buf []byte, path string) error {
+func (e *fileExporter) writeAsPerEventCount(w *fileWriter, b *batch) error {
+	path := w.path
 	// check if there is already a file with extension .inprocess, if yes use it else create new
-	if len(os.Getenv("TELE_DEBUG")) > 0 {
-		log.Printf("writeAsPerEventCount current event count before writing event to file [ %d ]", e.currentEventCount)
-	}
-	if e.currentEventCount == 0 {
-		e.currentEventCount = e.currentEventCount + 1
-		filename := fmt.Sprintf(".%s", ext)
-		path = filepath.Join(path, filename)
-		err := resx.AppendFileBatch(buf, path, 0644)
+	e.debug("writeAsPerEventCount current event count before writing event to file", zap.Int64("count", w.currentEventCount))
+	if w.currentEventCount == 0 {
+		w.currentEventCount = w.currentEventCount + e.eventsOf(b)
+		path = e.inProcessFileOf(w)
+		err := e.appendBatch(w, b, path, 0644)
 		if err != nil {
-			log.Printf("failed to append data to inprocess file at path %s, error %s \n", path, err)
-			return err
+			e.writeFailed(w, path, "failed to append data to inprocess file", err)
+			return fmt.Errorf("failed to append data to inprocess file %s: %w", path, err)
 		}
-		if e.currentEventCount == e.eventsPerFile {
-			err = e.renameTmpFile(path)
+		if w.currentEventCount >= w.eventsPerFile {
+			err = e.renameTmpFile(w, path)
 			if err != nil {
-				log.Printf("failed to rename inprocess file at path %s, error %s \n", path, err)
+				e.logger.Error("failed to rename inprocess file", zap.String("file", path), zap.Error(err))
 				return err
 			}
 		}
 		return nil
 	} else {
-		files, err := filepath.Glob(filepath.Join(path, fmt.Sprintf(".%s", ext)))
-		if err != nil {
-			log.Printf("failed to find inprocess file at path %s, error %s \n", path, err)
-			return err
-		}
-		f := files[0]
-		if len(os.Getenv("TELE_DEBUG")) > 0 {
-			log.Printf("writeAsPerEventCount appending file batch to => [ %s ] \n", f)
+		f := e.inProcessFileOf(w)
+		if w.currentEventCount+e.eventsOf(b) > w.eventsPerFile {
+			// the records of the batch would exceed the events per file, so the in process file is finished
+			// first and the batch is written to a new in process file
+			if err := e.finishFile(w, f); err != nil {
+				return err
+			}
 		}
-		err = resx.AppendFileBatch(buf, f, 0644)
+		e.debug("writeAsPerEventCount appending file batch", zap.String("file", f))
+		err := e.appendBatch(w, b, f, 0644)
 		if err != nil {
-			log.Printf("failed to append data to inprocess file, %s, error %s \n", f, err)
-			return err
-		}
-		e.currentEventCount = e.currentEventCount + 1
-		if len(os.Getenv("TELE_DEBUG")) > 0 {
-			log.Printf("incremented current event count, current [ %d ], per file [ %d ] ", e.currentEventCount, e.eventsPerFile)
+			e.writeFailed(w, f, "failed to append data to inprocess file", err)
+			return fmt.Errorf("failed to append data to inprocess file %s: %w", f, err)
 		}
-		if e.currentEventCount == e.eventsPerFile {
-			err = e.renameTmpFile(f)
+		w.currentEventCount = w.currentEventCount + e.eventsOf(b)
+		e.debug("incremented current event count", zap.Int64("count", w.currentEventCount), zap.Int64("eventsPerFile", w.eventsPerFile))
+		if w.currentEventCount >= w.eventsPerFile {
+			err = e.renameTmpFile(w, f)
 			if err != nil {
-				log.Printf("failed to rename inprocess file at path %s, error %s \n", path, err)
+				e.logger.Error("failed to rename inprocess file", zap.String("file", path), zap.Error(err))
 				return err
 			}
 		}
@@ -236,64 +994,111 @@ func (e *fileExporter) writeAsPerEventCount(buf []byte, path string) error {
 	return nil
 }
 
-func (e *fileExporter) renameTmpFile(f string) error {
-	if e.currentEventCount == e.eventsPerFile {
-		currentTime := time.Now().UTC()
-		t := currentTime.Format(timeFormat)
-		var newex string
-		if strings.EqualFold(e.format, Json) {
-			newex = "json"
-		} else if strings.EqualFold(e.format, Protobuf) {
-			newex = "proto"
+func (e *fileExporter) renameTmpFile(w *fileWriter, f string) error {
+	if w.currentEventCount >= w.eventsPerFile {
+		return e.finishFile(w, f)
+	}
+	return nil
+}
+
+// finishFile renames the in process file to its finished name and applies the post processing steps
+func (e *fileExporter) finishFile(w *fileWriter, f string) (err error) {
+	defer func() {
+		if err != nil {
+			e.recordError(err)
 		} else {
-			return errors.New("invalid format, valid format value is either json or protobuf")
+			e.recordRotated()
 		}
-		fnew := fmt.Sprintf("%s.%s", t, newex)
-		fnew = strings.Replace(f, fmt.Sprintf(".%s", ext), fnew, 1)
-		if len(os.Getenv("TELE_DEBUG")) > 0 {
-			log.Printf("renaming old fine name %s to new file name %s", f, fnew)
+	}()
+	// the file is closed so that it can be renamed and post processed
+	if err := w.close(); err != nil {
+		e.logger.Error("failed to close inprocess file", zap.String("file", f), zap.Error(err))
+		return classify(ErrRotateFailed, err, "failed to close inprocess file %s", f)
+	}
+	currentTime := time.Now().UTC()
+	newex := e.finishedExt()
+	if len(newex) == 0 {
+		return classify(ErrRotateFailed, ErrInvalidFormat, "failed to finish inprocess file %s", f)
+	}
+	if e.isParquet() && !w.footerWritten {
+		if err := writeParquetFooter(e.fs, f, w.rowGroups); err != nil {
+			e.logger.Error("failed to write parquet footer", zap.String("file", f), zap.Error(err))
+			return classify(ErrRotateFailed, err, "failed to write parquet footer of %s", f)
 		}
-		err := os.Rename(f, fnew)
-		if err != nil {
-			log.Printf("failed to rename inprocess file, %s \n to new file name %s \n", f, fnew)
-			log.Printf("error %s ", err)
-			return err
+		w.footerWritten = true
+	}
+	if e.jsonArray {
+		if err := e.closeJSONArray(f); err != nil {
+			e.logger.Error("failed to close json array", zap.String("file", f), zap.Error(err))
+			return classify(ErrRotateFailed, err, "failed to close the json array of %s", f)
 		}
-		e.currentEventCount = 0
 	}
-	return nil
-}
-
-func (e *fileExporter) isFileSizeExceeding(files []string, msize int64) (error, bool) {
-	// get the size of .inprocess file
-	if len(files) == 0 {
-		return nil, false
+	bucket := w.bucket
+	if bucket.IsZero() {
+		bucket = e.bucketStart(currentTime)
+	}
+	// the instances sharing the path rename and post process their files one at a time
+	unlock, err := e.lockDir(filepath.Dir(f))
+	if err != nil {
+		e.logger.Error("failed to lock directory", zap.String("file", f), zap.Error(err))
+		return classify(ErrRotateFailed, err, "failed to lock the directory of %s", f)
+	}
+	defer unlock()
+	dir, err := e.finishDir(filepath.Dir(f))
+	if err != nil {
+		e.logger.Error("failed to create pending directory", zap.String("file", f), zap.Error(err))
+		return classify(ErrRotateFailed, err, "failed to create pending directory of %s", f)
 	}
-	f := files[0]
-	file, err := os.OpenFile(f, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// the compressed and encrypted file is staged and renamed to its final name in one step, the in process
+	// file is only removed once the finished file has its name so that a crash finishes it again
+	staged, suffix, err := e.stageFinished(f, dir)
 	if err != nil {
-		log.Printf("failed to open inprocess file, %s , error %s \n", f, err)
-		return err, false
+		e.logger.Error("failed to process inprocess file", zap.String("file", f), zap.Error(err))
+		return classify(ErrRotateFailed, err, "failed to process inprocess file %s", f)
 	}
-	defer file.Close()
-	if len(os.Getenv("TELE_DEBUG")) > 0 {
-		log.Printf("finding the size of current inprocess file ")
+	fnew, err := e.renameFinished(staged, dir, signalName(w.signals), e.fileNameTime(w, currentTime), e.bucketName(bucket), &w.seq, w.shard, w.stats.records, newex, suffix)
+	if err != nil {
+		if staged != f {
+			_ = e.fs.Remove(staged)
+		}
+		e.logger.Error("failed to rename inprocess file", zap.String("file", f), zap.String("newFile", fnew), zap.Error(err))
+		return classify(ErrRotateFailed, err, "failed to rename inprocess file %s", f)
+	}
+	if staged != f {
+		if err = e.fs.Remove(f); err != nil {
+			// the finished file is removed so that the in process file is not finished twice
+			err = multierr.Append(err, e.fs.Remove(fnew))
+			e.logger.Error("failed to remove processed inprocess file", zap.String("file", f), zap.Error(err))
+			return classify(ErrRotateFailed, err, "failed to remove processed inprocess file %s", f)
+		}
 	}
-	stat, err := file.Stat()
+	e.debug("renamed inprocess file", zap.String("file", f), zap.String("newFile", fnew))
+	e.telemetry.recordRotation(currentTime)
+	stats, signals := w.stats, signalList(w.signals)
+	e.flagStale(fnew, stats)
+	w.currentEventCount = 0
+	w.signals = make(map[string]bool)
+	w.rowGroups = nil
+	w.footerWritten = false
+	w.stats = fileStats{}
+	w.bucket = time.Time{}
+	w.opened = time.Time{}
+	e.saveState(w)
+	final, err := e.finalize(w, fnew, stats, signals)
 	if err != nil {
-		log.Printf("failed to get stats for inprocess file, %s , error %s \n", f, err)
-		return err, false
+		return classify(ErrRotateFailed, err, "failed to post process finished file %s", fnew)
 	}
-	kb := (stat.Size() / 1024)
-	if len(os.Getenv("TELE_DEBUG")) > 0 {
-		log.Printf("before writing to inprocess file %s, file size is [ %d ]kb and input data size is [ %d ]kb \n", f, kb, msize)
+	if !e.bundle.Enabled {
+		// the bundles are notified once written
+		e.notifyRotated(RotatedFile{Path: final, Signals: signals, Records: stats.records})
 	}
-	total := (kb + msize)
-	// after adding current data to existing inprocess file, if the size of in process file exceeds
-	// the maxfilesize, then close the current inprocess file and delete the extension .inprocess
-	// so it will be treated as completed and ready for upload, and the current data will be written
-	// to new inprocess file
-	size := e.fileSizeKb
+	return nil
+}
 
-	return nil, (total > size)
+// finishedExt returns the extension of the finished files, empty if the format is invalid
+func (e *fileExporter) finishedExt() string {
+	if e.format == formatCustom {
+		return e.marshaler.Extension()
+	}
+	return e.format.ext()
 }
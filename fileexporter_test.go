@@ -0,0 +1,99 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+// testConfig returns the configuration of an exporter writing json files of the size to a temporary path
+func testConfig(tb testing.TB, fileSize int64) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = tb.TempDir()
+	cfg.Format = "json"
+	cfg.FileSizeBytes = fileSize
+	if err := cfg.Validate(); err != nil {
+		tb.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestExporter creates the exporter of the configuration, it is not started
+func newTestExporter(tb testing.TB, cfg *Config) *fileExporter {
+	set := component.ExporterCreateSettings{TelemetrySettings: component.TelemetrySettings{Logger: zap.NewNop()}}
+	return newFileExporter(cfg, set, nil, nil)
+}
+
+// startTestExporter creates and starts the exporter of the configuration, it is shut down with the test
+func startTestExporter(tb testing.TB, cfg *Config) *fileExporter {
+	return startExporter(tb, newTestExporter(tb, cfg))
+}
+
+// startExporter starts the exporter, it is shut down with the test
+func startExporter(tb testing.TB, e *fileExporter) *fileExporter {
+	if err := e.Start(context.Background(), nil); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = e.Shutdown(context.Background())
+	})
+	return e
+}
+
+// writeLine writes the line as a batch of one trace record
+func writeLine(e *fileExporter, line string) error {
+	return e.exportAsLine(context.Background(), "", &batch{signal: signalTraces, records: 1, buf: []byte(line)})
+}
+
+// listFiles returns the names of the files under the path relative to it, sorted
+func listFiles(tb testing.TB, path string) []string {
+	var files []string
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// finishedFilesOf returns the finished files under the path of the exporter, oldest first
+func finishedFilesOf(tb testing.TB, e *fileExporter) []string {
+	files, err := e.finishedFiles(e.path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.path
+	}
+	return names
+}
+
+// readFile returns the content of the file
+func readFile(tb testing.TB, f string) string {
+	content, err := os.ReadFile(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(content)
+}
@@ -0,0 +1,118 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// the header record written at the start of each file describes how the file was written so that
+// parsers do not need to infer it from the file name; json files start with a {"fileHeader":{...}}
+// document and protobuf files with the header JSON in an unknown field of the first message, which
+// protobuf parsers skip
+
+const (
+	// the version of the header record, incremented when its fields change incompatibly
+	fileHeaderSchemaVersion = 1
+	// the protobuf field number holding the header, outside of the OTLP data field numbers
+	fileHeaderProtoField = 1000
+	// the module path used to find the exporter version in the build information
+	modulePath = "southwinds.dev/file-exporter"
+)
+
+// fileHeader is the header record written at the start of each file
+type fileHeader struct {
+	SchemaVersion   int               `json:"schemaVersion"`
+	LayoutVersion   int               `json:"layoutVersion"`
+	Format          string            `json:"format"`
+	ExporterVersion string            `json:"exporterVersion"`
+	Created         time.Time         `json:"created"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	// OtlpVersion, MessageType and DescriptorSet describe the messages of the protobuf files when their
+	// descriptors are embedded, the descriptor set is a base64 google.protobuf.FileDescriptorSet
+	OtlpVersion   string `json:"otlpVersion,omitempty"`
+	MessageType   string `json:"messageType,omitempty"`
+	DescriptorSet []byte `json:"descriptorSet,omitempty"`
+}
+
+// validateFileHeader checks the header record can be written in the format
+func validateFileHeader(enabled bool, format string) error {
+	if enabled && !strings.EqualFold(format, Json) && !strings.EqualFold(format, OtlpJson) && !strings.EqualFold(format, Protobuf) {
+		return errors.New("fileHeader is only supported for the json, otlp-json and protobuf formats")
+	}
+	return nil
+}
+
+// exporterVersion returns the version of the exporter module from the build information
+func exporterVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// headerOf returns the record written before the batch when it is the first of a file, the csv column
+// header or the file header record, nil if there is none
+func (e *fileExporter) headerOf(b *batch) []byte {
+	if len(b.header) > 0 || !(e.fileHeader || e.protoDescriptor) {
+		return b.header
+	}
+	header := fileHeader{
+		SchemaVersion:   fileHeaderSchemaVersion,
+		LayoutVersion:   layoutVersion,
+		Format:          e.format.String(),
+		ExporterVersion: e.version,
+		Created:         time.Now().UTC(),
+	}
+	if e.identity != nil {
+		header.Labels = e.identity.attributes
+	}
+	if e.protoDescriptor {
+		header.OtlpVersion = otlpProtoVersion
+		header.MessageType = otlpMessageType(b.signal, e.exportRequest)
+		header.DescriptorSet = otlpDescriptorSet()
+	}
+	content, err := json.Marshal(header)
+	if err != nil {
+		return nil
+	}
+	if e.format == formatProtobuf {
+		// a length delimited unknown field
+		record := binary.AppendUvarint(nil, fileHeaderProtoField<<3|2)
+		record = binary.AppendUvarint(record, uint64(len(content)))
+		return append(record, content...)
+	}
+	var record []byte
+	if e.recordSep == RecordSeparatorJSONSeq {
+		record = append(record, recordSeparatorChar)
+	}
+	record = append(record, `{"fileHeader":`...)
+	record = append(record, content...)
+	return append(record, '}', '\n')
+}
+
+// isFileHeader returns true if the json document is a file header record
+func isFileHeader(doc []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(doc), []byte(`{"fileHeader":`))
+}
@@ -0,0 +1,58 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// FileTimestampRename names the finished files with the time they are finished
+	FileTimestampRename = "rename"
+	// FileTimestampFirstRecord names the finished files with the timestamp of their earliest record, or the
+	// time they are finished if their records have no timestamp
+	FileTimestampFirstRecord = "firstRecord"
+)
+
+// validateFileTimestamp checks the time of the {timestamp} placeholder is supported
+func validateFileTimestamp(fileTimestamp string) error {
+	switch strings.ToLower(fileTimestamp) {
+	case "", FileTimestampRename, strings.ToLower(FileTimestampFirstRecord):
+		return nil
+	}
+	return fmt.Errorf("invalid fileTimestamp [%s], valid values are [ %s or %s ]", fileTimestamp, FileTimestampRename, FileTimestampFirstRecord)
+}
+
+// needsTimeRange returns true if the time range of the batches is accounted, for the manifests, the file names
+// or the data age
+func (e *fileExporter) needsTimeRange() bool {
+	return e.manifestEnabled() || e.firstRecordTime || e.dataAge != nil
+}
+
+// fileNameTime returns the time of the {timestamp} placeholder of the file finished now, it never goes back
+// from the time of the previous file of the writer so that the names keep sorting in the order the files are
+// finished when the clock is stepped back or older records arrive; the sequence number then orders the
+// files named with the same time. It must be called holding the writer lock
+func (e *fileExporter) fileNameTime(w *fileWriter, now time.Time) time.Time {
+	t := now
+	if e.firstRecordTime && w.stats.first != 0 {
+		t = w.stats.first.AsTime()
+	}
+	if t.Before(w.lastNameTime) {
+		e.debug("file name time is before the previous file, keeping the previous time",
+			zap.String("path", w.path), zap.Time("time", t), zap.Time("previous", w.lastNameTime))
+		t = w.lastNameTime
+	}
+	w.lastNameTime = t
+	return t
+}
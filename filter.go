@@ -0,0 +1,245 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// the ranges of severity numbers of each log severity
+var severityRanges = map[string][2]plog.SeverityNumber{
+	"TRACE": {plog.SeverityNumberTrace, plog.SeverityNumberTrace4},
+	"DEBUG": {plog.SeverityNumberDebug, plog.SeverityNumberDebug4},
+	"INFO":  {plog.SeverityNumberInfo, plog.SeverityNumberInfo4},
+	"WARN":  {plog.SeverityNumberWarn, plog.SeverityNumberWarn4},
+	"ERROR": {plog.SeverityNumberError, plog.SeverityNumberError4},
+	"FATAL": {plog.SeverityNumberFatal, plog.SeverityNumberFatal4},
+}
+
+// MatchConfig defines the telemetry matched by an include or exclude filter, names and resource attribute
+// values are regular expressions; a record matches if it matches all the criteria that apply to its signal
+type MatchConfig struct {
+	// SpanNames matches spans whose name matches any of the expressions
+	SpanNames []string `mapstructure:"spanNames"`
+	// MetricNames matches metrics whose name matches any of the expressions
+	MetricNames []string `mapstructure:"metricNames"`
+	// LogSeverities matches log records of any of the severities: TRACE, DEBUG, INFO, WARN, ERROR or FATAL
+	LogSeverities []string `mapstructure:"logSeverities"`
+	// ResourceAttributes matches telemetry whose resource has all the attributes with matching values
+	ResourceAttributes map[string]string `mapstructure:"resourceAttributes"`
+}
+
+// Validate checks the expressions and severities of the filter
+func (cfg *MatchConfig) Validate() error {
+	for _, exprs := range [][]string{cfg.SpanNames, cfg.MetricNames} {
+		for _, expr := range exprs {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("invalid filter expression [%s]: %w", expr, err)
+			}
+		}
+	}
+	for key, expr := range cfg.ResourceAttributes {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid filter expression [%s] for resource attribute [%s]: %w", expr, key, err)
+		}
+	}
+	for _, severity := range cfg.LogSeverities {
+		if _, ok := severityRanges[strings.ToUpper(severity)]; !ok {
+			return fmt.Errorf("invalid filter log severity [%s], valid values are [ TRACE, DEBUG, INFO, WARN, ERROR or FATAL ]", severity)
+		}
+	}
+	return nil
+}
+
+// matcher is the compiled form of a MatchConfig
+type matcher struct {
+	spanNames          []*regexp.Regexp
+	metricNames        []*regexp.Regexp
+	logSeverities      []string
+	resourceAttributes map[string]*regexp.Regexp
+}
+
+func newMatcher(cfg *MatchConfig) *matcher {
+	if cfg == nil {
+		return nil
+	}
+	m := &matcher{
+		spanNames:          compileAll(cfg.SpanNames),
+		metricNames:        compileAll(cfg.MetricNames),
+		resourceAttributes: make(map[string]*regexp.Regexp),
+	}
+	for _, severity := range cfg.LogSeverities {
+		m.logSeverities = append(m.logSeverities, strings.ToUpper(severity))
+	}
+	for key, expr := range cfg.ResourceAttributes {
+		m.resourceAttributes[key] = regexp.MustCompile(expr)
+	}
+	return m
+}
+
+func compileAll(exprs []string) []*regexp.Regexp {
+	var result []*regexp.Regexp
+	for _, expr := range exprs {
+		result = append(result, regexp.MustCompile(expr))
+	}
+	return result
+}
+
+func matchAny(exprs []*regexp.Regexp, value string) bool {
+	for _, expr := range exprs {
+		if expr.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchResource returns whether the resource matches, applies is false if the filter has no resource criteria
+func (m *matcher) matchResource(resource pcommon.Resource) (matched bool, applies bool) {
+	if len(m.resourceAttributes) == 0 {
+		return true, false
+	}
+	for key, expr := range m.resourceAttributes {
+		v, ok := resource.Attributes().Get(key)
+		if !ok || !expr.MatchString(v.AsString()) {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+// match combines the resource and record criteria, a filter without criteria for the signal matches nothing
+// when used to exclude and everything when used to include
+func (m *matcher) match(resource pcommon.Resource, recordMatched, recordApplies bool, include bool) bool {
+	resourceMatched, resourceApplies := m.matchResource(resource)
+	if !resourceApplies && !recordApplies {
+		return include
+	}
+	return resourceMatched && recordMatched
+}
+
+func (m *matcher) matchSpan(resource pcommon.Resource, span ptrace.Span, include bool) bool {
+	return m.match(resource, len(m.spanNames) == 0 || matchAny(m.spanNames, span.Name()), len(m.spanNames) > 0, include)
+}
+
+func (m *matcher) matchMetric(resource pcommon.Resource, metric pmetric.Metric, include bool) bool {
+	return m.match(resource, len(m.metricNames) == 0 || matchAny(m.metricNames, metric.Name()), len(m.metricNames) > 0, include)
+}
+
+func (m *matcher) matchLog(resource pcommon.Resource, lr plog.LogRecord, include bool) bool {
+	return m.match(resource, len(m.logSeverities) == 0 || matchSeverity(m.logSeverities, lr), len(m.logSeverities) > 0, include)
+}
+
+// matchSeverity matches the severity number of the record, or its severity text if the number is not set
+func matchSeverity(severities []string, lr plog.LogRecord) bool {
+	for _, severity := range severities {
+		if lr.SeverityNumber() == plog.SeverityNumberUnspecified {
+			if strings.EqualFold(lr.SeverityText(), severity) {
+				return true
+			}
+			continue
+		}
+		r := severityRanges[severity]
+		if lr.SeverityNumber() >= r[0] && lr.SeverityNumber() <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// telemetryFilter decides which telemetry is written, telemetry is written if it matches the include
+// filter and does not match the exclude filter
+type telemetryFilter struct {
+	include *matcher
+	exclude *matcher
+}
+
+func newTelemetryFilter(include, exclude *MatchConfig) *telemetryFilter {
+	if include == nil && exclude == nil {
+		return nil
+	}
+	return &telemetryFilter{include: newMatcher(include), exclude: newMatcher(exclude)}
+}
+
+func (f *telemetryFilter) keep(match func(m *matcher, include bool) bool) bool {
+	if f.include != nil && !match(f.include, true) {
+		return false
+	}
+	return f.exclude == nil || !match(f.exclude, false)
+}
+
+// filterTraces returns the spans to write, the passed in traces are not modified
+func (e *fileExporter) filterTraces(td ptrace.Traces) ptrace.Traces {
+	if e.filter == nil {
+		return td
+	}
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	out.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				return !e.filter.keep(func(m *matcher, include bool) bool {
+					return m.matchSpan(rs.Resource(), span, include)
+				})
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return out
+}
+
+// filterMetrics returns the metrics to write, the passed in metrics are not modified
+func (e *fileExporter) filterMetrics(md pmetric.Metrics) pmetric.Metrics {
+	if e.filter == nil {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	out.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				return !e.filter.keep(func(m *matcher, include bool) bool {
+					return m.matchMetric(rm.Resource(), metric, include)
+				})
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	return out
+}
+
+// filterLogs returns the log records to write, the passed in logs are not modified
+func (e *fileExporter) filterLogs(ld plog.Logs) plog.Logs {
+	if e.filter == nil {
+		return ld
+	}
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	out.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(lr plog.LogRecord) bool {
+				return !e.filter.keep(func(m *matcher, include bool) bool {
+					return m.matchLog(rl.Resource(), lr, include)
+				})
+			})
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+	return out
+}
@@ -0,0 +1,58 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// finalize applies the configured post processing steps to a file that has just been renamed from
+// the in process file, already compressed and encrypted, and returns the path of the resulting finished
+// file, the statistics and signals of the file are used to write its manifest
+func (e *fileExporter) finalize(w *fileWriter, f string, stats fileStats, signals []string) (string, error) {
+	var err error
+	var sum string
+	if len(e.checksum) > 0 && !strings.EqualFold(e.checksum, ChecksumNone) {
+		if sum, err = writeChecksum(f, e.checksum); err != nil {
+			e.logger.Error("failed to write checksum file", zap.String("file", f), zap.Error(err))
+			return f, err
+		}
+	}
+	// the sidecar manifest is handed off with the file, the rolling manifest only lists handed off files
+	if e.manifest == ManifestSidecar {
+		if err = e.writeManifest(f, stats, signals, sum); err != nil {
+			e.logger.Error("failed to write manifest", zap.String("file", f), zap.Error(err))
+			return f, err
+		}
+	}
+	if f, err = e.handOff(f); err != nil {
+		e.logger.Error("failed to hand off finished file", zap.String("file", f), zap.Error(err))
+		return f, err
+	}
+	if e.manifest == ManifestRolling {
+		if err = e.writeManifest(f, stats, signals, sum); err != nil {
+			e.logger.Error("failed to write manifest", zap.String("file", f), zap.Error(err))
+			return f, err
+		}
+	}
+	if e.bundle.Enabled {
+		if err = e.stageForBundle(f); err != nil {
+			e.logger.Error("failed to stage finished file for bundling", zap.String("file", f), zap.Error(err))
+			return f, err
+		}
+		if err = e.bundleIfDue(w, time.Now()); err != nil {
+			e.logger.Error("failed to bundle finished files", zap.String("path", w.path), zap.Error(err))
+			return f, err
+		}
+	}
+	return f, nil
+}
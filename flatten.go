@@ -0,0 +1,92 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// metricPoint is a flattened metric data point, histogram and summary data points are represented
+// by their sum and count
+type metricPoint struct {
+	resource   pcommon.Resource
+	metric     pmetric.Metric
+	timestamp  pcommon.Timestamp
+	value      float64
+	count      uint64
+	attributes pcommon.Map
+}
+
+// forEachMetricPoint calls the function for every data point of the metrics
+func forEachMetricPoint(md pmetric.Metrics, f func(p metricPoint)) {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			metrics := rm.ScopeMetrics().At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				m := metrics.At(k)
+				p := metricPoint{resource: rm.Resource(), metric: m}
+				switch m.Type() {
+				case pmetric.MetricTypeGauge:
+					forEachNumberDataPoint(p, m.Gauge().DataPoints(), f)
+				case pmetric.MetricTypeSum:
+					forEachNumberDataPoint(p, m.Sum().DataPoints(), f)
+				case pmetric.MetricTypeHistogram:
+					dps := m.Histogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						p.timestamp, p.value, p.count, p.attributes = dp.Timestamp(), dp.Sum(), dp.Count(), dp.Attributes()
+						f(p)
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					dps := m.ExponentialHistogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						p.timestamp, p.value, p.count, p.attributes = dp.Timestamp(), dp.Sum(), dp.Count(), dp.Attributes()
+						f(p)
+					}
+				case pmetric.MetricTypeSummary:
+					dps := m.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						p.timestamp, p.value, p.count, p.attributes = dp.Timestamp(), dp.Sum(), dp.Count(), dp.Attributes()
+						f(p)
+					}
+				}
+			}
+		}
+	}
+}
+
+func forEachNumberDataPoint(p metricPoint, dps pmetric.NumberDataPointSlice, f func(p metricPoint)) {
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		p.timestamp, p.count, p.attributes = dp.Timestamp(), 1, dp.Attributes()
+		p.value = dp.DoubleValue()
+		if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+			p.value = float64(dp.IntValue())
+		}
+		f(p)
+	}
+}
+
+// attributesJSON returns the attributes as a JSON object
+func attributesJSON(attrs pcommon.Map) string {
+	if attrs.Len() == 0 {
+		return "{}"
+	}
+	b, err := json.Marshal(attrs.AsRaw())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
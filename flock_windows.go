@@ -0,0 +1,44 @@
+//go:build windows
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const lockFileExclusiveLock = 0x2
+
+var (
+	lockFileEx   = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+	unlockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("UnlockFileEx")
+)
+
+// lockFile takes an exclusive lock on the first byte of the file, blocking until it is free
+func lockFile(f *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := lockFileEx.Call(f.Fd(), lockFileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock on the file
+func unlockFile(f *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := unlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
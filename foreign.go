@@ -0,0 +1,116 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// ForeignFilesIgnore leaves the files not named by the exporter in place, ForeignFilesQuarantine renames them
+	// with the failed extension and ForeignFilesRefuse fails the start of the exporter while there are any
+	ForeignFilesIgnore     = "ignore"
+	ForeignFilesQuarantine = "quarantine"
+	ForeignFilesRefuse     = "refuse"
+)
+
+// validateForeignFiles checks the behaviour when the path holds files not named by the exporter is supported
+func (cfg *Config) validateForeignFiles() error {
+	switch strings.ToLower(cfg.OnForeignFiles) {
+	case "", ForeignFilesIgnore, ForeignFilesQuarantine, ForeignFilesRefuse:
+		return nil
+	}
+	return fmt.Errorf("invalid onForeignFiles [%s], valid values are [ %s, %s or %s ]", cfg.OnForeignFiles, ForeignFilesIgnore, ForeignFilesQuarantine, ForeignFilesRefuse)
+}
+
+// fileNameRegex returns the expression matching the names of the finished files of the template, followed by
+// the extensions of their post processing and sidecar files
+func fileNameRegex(template string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, match := range placeholderRegex.FindAllStringSubmatchIndex(template, -1) {
+		pattern.WriteString(regexp.QuoteMeta(template[last:match[0]]))
+		switch template[match[2]:match[3]] {
+		case "seq", "count", "bytes", "shard":
+			pattern.WriteString(`\d+`)
+		default:
+			pattern.WriteString(`.+`)
+		}
+		last = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(template[last:]))
+	pattern.WriteString(`(\..+)?$`)
+	return regexp.MustCompile(pattern.String())
+}
+
+// foreignFiles returns the files under the root the exporter did not name, the files waiting to be bundled and
+// the trashed files are not checked
+func (e *fileExporter) foreignFiles(root string) ([]string, error) {
+	names := fileNameRegex(e.fileNameTemplate)
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if p != root && (d.Name() == bundleDir || d.Name() == trashDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !e.isDataFile(name) || names.MatchString(name) || (e.isRotationNone() && name == e.singleFileName()) {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+// checkForeignFiles quarantines the files of the roots not named by the exporter, or fails if the exporter must
+// not start while there are any, so that files dropped in the path are not mistaken for files of the exporter
+func (e *fileExporter) checkForeignFiles() error {
+	if e.onForeignFiles != ForeignFilesQuarantine && e.onForeignFiles != ForeignFilesRefuse {
+		return nil
+	}
+	for _, root := range e.roots() {
+		files, err := e.foreignFiles(root)
+		if err != nil {
+			return fmt.Errorf("failed to check the files of %s: %w", root, err)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if e.onForeignFiles == ForeignFilesRefuse {
+			return fmt.Errorf("output path %s holds %d files not named by the exporter, such as %s; move them away or set onForeignFiles to %s or %s",
+				root, len(files), files[0], ForeignFilesIgnore, ForeignFilesQuarantine)
+		}
+		for _, f := range files {
+			name := fmt.Sprintf("%s.%d.%s", f, time.Now().UnixNano(), quarantineExt)
+			if err = os.Rename(f, name); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to quarantine file %s not named by the exporter: %w", f, err)
+			}
+			e.logger.Warn("quarantined file not named by the exporter", zap.String("file", f), zap.String("quarantinedFile", name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import "strings"
+
+// outputFormat is the format of the exporter parsed once when it is created, so that the batches are
+// encoded without comparing format names
+type outputFormat int
+
+const (
+	formatInvalid outputFormat = iota
+	formatJSON
+	formatProtobuf
+	formatOtlpJSON
+	formatParquet
+	formatCsv
+	formatPrometheus
+	formatJaegerJSON
+	formatZipkinJSON
+	formatCustom
+)
+
+// supportedFormats are the names of the valid formats in the order they are listed in the errors
+var supportedFormats = []string{Json, Protobuf, OtlpJson, Parquet, Csv, Prometheus, JaegerJson, ZipkinJson, Custom}
+
+// parseFormat returns the format of the name, the name is case insensitive
+func parseFormat(name string) (outputFormat, error) {
+	for i, format := range supportedFormats {
+		if strings.EqualFold(name, format) {
+			return outputFormat(i + 1), nil
+		}
+	}
+	return formatInvalid, &FormatError{Format: name}
+}
+
+// String returns the name of the format in lower case, empty if the format is invalid
+func (f outputFormat) String() string {
+	if f <= formatInvalid || int(f) > len(supportedFormats) {
+		return ""
+	}
+	return supportedFormats[f-1]
+}
+
+// isJSON returns true if the format writes json documents
+func (f outputFormat) isJSON() bool {
+	return f == formatJSON || f == formatOtlpJSON || f == formatJaegerJSON || f == formatZipkinJSON
+}
+
+// ext returns the extension of the files written in the format, empty if the format is invalid or custom
+func (f outputFormat) ext() string {
+	switch f {
+	case formatJSON, formatOtlpJSON, formatJaegerJSON, formatZipkinJSON:
+		return "json"
+	case formatProtobuf:
+		return "proto"
+	case formatParquet:
+		return "parquet"
+	case formatCsv:
+		return "csv"
+	case formatPrometheus:
+		// the extension read by the node exporter textfile collector
+		return "prom"
+	}
+	return ""
+}
+
+// unsupportedReason returns why the format cannot write the signals other than the one it is restricted to
+func (f outputFormat) unsupportedReason() string {
+	if f == formatJaegerJSON || f == formatZipkinJSON {
+		return "it is only supported for traces"
+	}
+	return "it is only supported for metrics"
+}
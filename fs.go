@@ -0,0 +1,110 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"io"
+	"os"
+)
+
+// fileSystem performs the operations on the in process and finished files, so that the disk failures
+// can be simulated by a fault injecting file system instead of the os file system
+type fileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (fsFile, error)
+	Rename(oldName, newName string) error
+	Link(oldName, newName string) error
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Remove(name string) error
+	MkdirAll(path string, perm os.FileMode) error
+}
+
+// fsFile is a file opened by a fileSystem, an *os.File
+type fsFile interface {
+	io.Writer
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Stat() (os.FileInfo, error)
+	Truncate(size int64) error
+	Close() error
+	// Fd is the file descriptor the blocks of the file are allocated with
+	Fd() uintptr
+}
+
+// osFS is the file system of the os
+type osFS struct{}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (fsFile, error) {
+	file, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// a nil *os.File must not be returned as a non nil fsFile
+		return nil, err
+	}
+	return file, nil
+}
+
+func (osFS) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (osFS) Link(oldName, newName string) error {
+	return os.Link(oldName, newName)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// readFileFrom returns the content of the file read through the file system
+func readFileFrom(fsys fileSystem, name string) ([]byte, error) {
+	file, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(io.NewSectionReader(file, 0, stat.Size()))
+}
+
+// replaceFile writes the content to a temporary file renamed to the file, so that a failed write
+// leaves the previous content of the file
+func replaceFile(fsys fileSystem, name string, content []byte) error {
+	tmp := name + ".tmp"
+	file, err := fsys.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fsys.Rename(tmp, name)
+	}
+	if err != nil {
+		_ = fsys.Remove(tmp)
+	}
+	return err
+}
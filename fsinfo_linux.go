@@ -0,0 +1,56 @@
+//go:build linux
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// the names of the file system magic numbers reported by statfs
+var fsTypeNames = map[uint32]string{
+	0xEF53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x858458F6: "ramfs",
+	0x794C7630: "overlayfs",
+	0x73717368: "squashfs",
+	0x65735546: "fuse",
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x4D44:     "vfat",
+	0x5346544E: "ntfs",
+}
+
+// fsType returns the name of the file system of the path, its magic number if it is not a known one and
+// empty if it cannot be read
+func fsType(path string) string {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	if name, ok := fsTypeNames[uint32(stat.Type)]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", uint32(stat.Type))
+}
+
+// ownerOf returns the user and group owning the file
+func ownerOf(info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("owner uid %d gid %d", stat.Uid, stat.Gid)
+	}
+	return ""
+}
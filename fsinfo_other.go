@@ -0,0 +1,23 @@
+//go:build !linux
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import "os"
+
+// fsType returns the name of the file system of the path, it is only known on linux
+func fsType(string) string {
+	return ""
+}
+
+// ownerOf returns the user and group owning the file, they are only known on linux
+func ownerOf(os.FileInfo) string {
+	return ""
+}
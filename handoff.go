@@ -0,0 +1,128 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// HandoffNone makes the finished files available under their final name as soon as they are renamed
+	HandoffNone = "none"
+	// HandoffReady finishes the files in the pending sub directory and moves them with their sidecar files
+	// to the ready sub directory once they are post processed, uploaders only read the ready directory
+	HandoffReady = "ready"
+	// HandoffMarker writes an empty <file>.done marker once the finished file and its sidecar files are
+	// complete, uploaders only read the files having a marker
+	HandoffMarker = "marker"
+
+	// the sub directories of the finished files being post processed and of the files ready to upload
+	pendingDir = "pending"
+	readyDir   = "ready"
+	// the extension of the marker written once a finished file is complete
+	doneMarkerExt = "done"
+)
+
+// validateHandoff checks the handoff protocol is supported by the rotation and bundling
+func (cfg *Config) validateHandoff() error {
+	switch strings.ToLower(cfg.Handoff) {
+	case "", HandoffNone:
+		return nil
+	case HandoffReady, HandoffMarker:
+	default:
+		return fmt.Errorf("invalid handoff [%s], valid values are [ %s, %s or %s ]", cfg.Handoff, HandoffNone, HandoffReady, HandoffMarker)
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("handoff requires rotation as there are no finished files")
+	}
+	if cfg.Bundle.Enabled {
+		return errors.New("handoff is not supported with bundling, the bundles are already renamed once complete")
+	}
+	return nil
+}
+
+// finishDir returns the directory where the in process file of the directory is finished, creating it
+// if the files are handed off through the ready directory
+func (e *fileExporter) finishDir(dir string) (string, error) {
+	if e.handoff != HandoffReady {
+		return dir, nil
+	}
+	dir = filepath.Join(dir, pendingDir)
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// checkHandoffName returns a FileExistsError if the finished file would have the name of a file already in
+// the ready directory
+func (e *fileExporter) checkHandoffName(name string) error {
+	if e.handoff != HandoffReady {
+		return nil
+	}
+	ready := filepath.Join(filepath.Dir(filepath.Dir(name)), readyDir, filepath.Base(name))
+	if _, err := os.Lstat(ready); err == nil {
+		return &FileExistsError{Path: ready}
+	}
+	return nil
+}
+
+// handOff makes the post processed file and its sidecar files available to the uploaders and returns
+// the path of the file handed off; the sidecar files are moved before the file so they are there when
+// the file appears in the ready directory
+func (e *fileExporter) handOff(f string) (string, error) {
+	switch e.handoff {
+	case HandoffReady:
+		dir := filepath.Join(filepath.Dir(filepath.Dir(f)), readyDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return f, err
+		}
+		sidecars, err := filepath.Glob(f + ".*")
+		if err != nil {
+			return f, err
+		}
+		for _, sidecar := range sidecars {
+			if err = os.Rename(sidecar, filepath.Join(dir, filepath.Base(sidecar))); err != nil {
+				return f, err
+			}
+		}
+		ready := filepath.Join(dir, filepath.Base(f))
+		if err = e.renameNoReplace(f, ready); err != nil {
+			return f, err
+		}
+		return ready, nil
+	case HandoffMarker:
+		return f, os.WriteFile(fmt.Sprintf("%s.%s", f, doneMarkerExt), nil, 0644)
+	}
+	return f, nil
+}
+
+// warnPendingHandoff logs the files left in the pending directories under the root by an interrupted
+// finish, they are not handed off as their post processing may be incomplete
+func (e *fileExporter) warnPendingHandoff(root string) error {
+	if e.handoff != HandoffReady {
+		return nil
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() && filepath.Base(filepath.Dir(p)) == pendingDir {
+			e.logger.Warn("file left pending by an interrupted finish, it must be checked and moved to the ready directory", zap.String("file", p))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	// IdentityResource adds the identity as resource attributes of every batch
+	IdentityResource = "resource"
+	// IdentityManifest records the identity in the manifest of every finished file
+	IdentityManifest = "manifest"
+	// IdentityAll adds the identity to both the resources and the manifests
+	IdentityAll = "all"
+
+	// the attribute keys of the identity
+	identityHostNameKey   = "host.name"
+	identityInstanceIDKey = "collector.instance.id"
+)
+
+// IdentityConfig defines the identity of the collector or host added to the written telemetry
+type IdentityConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Hostname overrides the host name of the machine
+	Hostname string `mapstructure:"hostname"`
+	// InstanceID identifies the collector instance, a random id is generated on start if it is not defined
+	InstanceID string `mapstructure:"instanceId"`
+	// Labels are custom attributes added to the identity
+	Labels map[string]string `mapstructure:"labels"`
+	// Target defines where the identity is written, valid values are resource, manifest and all
+	Target string `mapstructure:"target"`
+}
+
+// Validate checks if the identity configuration is valid
+func (cfg *IdentityConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	switch strings.ToLower(cfg.Target) {
+	case "", IdentityResource, IdentityManifest, IdentityAll:
+	default:
+		return fmt.Errorf("invalid identity target [%s], valid values are [ %s, %s or %s ]", cfg.Target, IdentityResource, IdentityManifest, IdentityAll)
+	}
+	for key := range cfg.Labels {
+		if len(strings.TrimSpace(key)) == 0 {
+			return errors.New("identity labels must not contain empty keys")
+		}
+	}
+	return nil
+}
+
+// identity is the resolved identity of the exporter
+type identity struct {
+	attributes map[string]string
+	resource   bool
+	manifest   bool
+}
+
+func newIdentity(cfg IdentityConfig, hostname string) *identity {
+	if !cfg.Enabled {
+		return nil
+	}
+	target := strings.ToLower(cfg.Target)
+	id := &identity{
+		attributes: make(map[string]string),
+		resource:   target == "" || target == IdentityResource || target == IdentityAll,
+		manifest:   target == IdentityManifest || target == IdentityAll,
+	}
+	for key, value := range cfg.Labels {
+		id.attributes[key] = value
+	}
+	id.attributes[identityHostNameKey] = hostname
+	if len(cfg.Hostname) > 0 {
+		id.attributes[identityHostNameKey] = cfg.Hostname
+	}
+	id.attributes[identityInstanceIDKey] = cfg.InstanceID
+	if len(cfg.InstanceID) == 0 {
+		id.attributes[identityInstanceIDKey] = randomID()
+	}
+	return id
+}
+
+// randomID returns a random hex encoded id
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// apply adds the identity attributes the resource does not already have
+func (id *identity) apply(resource pcommon.Resource) {
+	keys := make([]string, 0, len(id.attributes))
+	for key := range id.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := resource.Attributes().Get(key); !ok {
+			resource.Attributes().PutStr(key, id.attributes[key])
+		}
+	}
+}
+
+// identifyTraces returns a copy of the traces with the identity added to their resources
+func (e *fileExporter) identifyTraces(td ptrace.Traces) ptrace.Traces {
+	if e.identity == nil || !e.identity.resource {
+		return td
+	}
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	for i := 0; i < out.ResourceSpans().Len(); i++ {
+		e.identity.apply(out.ResourceSpans().At(i).Resource())
+	}
+	return out
+}
+
+// identifyMetrics returns a copy of the metrics with the identity added to their resources
+func (e *fileExporter) identifyMetrics(md pmetric.Metrics) pmetric.Metrics {
+	if e.identity == nil || !e.identity.resource {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	for i := 0; i < out.ResourceMetrics().Len(); i++ {
+		e.identity.apply(out.ResourceMetrics().At(i).Resource())
+	}
+	return out
+}
+
+// identifyLogs returns a copy of the logs with the identity added to their resources
+func (e *fileExporter) identifyLogs(ld plog.Logs) plog.Logs {
+	if e.identity == nil || !e.identity.resource {
+		return ld
+	}
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	for i := 0; i < out.ResourceLogs().Len(); i++ {
+		e.identity.apply(out.ResourceLogs().At(i).Resource())
+	}
+	return out
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+// the in process file is a hidden dot file so that it is ignored by the tools listing the finished files,
+// the in process files of the instances sharing the path are named .<instance>.inproc
+const inProcessName = "." + ext
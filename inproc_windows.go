@@ -0,0 +1,16 @@
+//go:build windows
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+// dot files are not hidden on windows, the in process file is named with the .tmp extension instead so that
+// it is recognised as a temporary file by the tools listing the finished files; the in process files of the
+// instances sharing the path are named _<instance>_inproc.tmp
+const inProcessName = "_" + ext + ".tmp"
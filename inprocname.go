@@ -0,0 +1,68 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateInProcessSuffix checks the in process suffix starts with a dot or an underscore, is a file name
+// and cannot be mistaken for a finished, state or quarantined file
+func (cfg *Config) validateInProcessSuffix() error {
+	suffix := cfg.InProcessSuffix
+	if len(suffix) == 0 {
+		return nil
+	}
+	if len(suffix) < 2 || (suffix[0] != '.' && suffix[0] != '_') {
+		return fmt.Errorf("invalid inProcessSuffix [%s], it must start with a dot or an underscore followed by at least one character", suffix)
+	}
+	if strings.ContainsAny(suffix, `/\`) {
+		return fmt.Errorf("invalid inProcessSuffix [%s], it must not contain path separators", suffix)
+	}
+	if format, err := parseFormat(cfg.Format); err == nil && strings.HasSuffix(suffix, "."+format.ext()) {
+		return fmt.Errorf("invalid inProcessSuffix [%s], it must not end with the extension of the finished files", suffix)
+	}
+	if isStateName(suffix) || isQuarantineName(suffix) {
+		return fmt.Errorf("invalid inProcessSuffix [%s], it must not end with the extension of the state or quarantined files", suffix)
+	}
+	return nil
+}
+
+// inProcessSuffixOf returns the in process suffix of the configuration, the platform in process name by default
+func inProcessSuffixOf(cfg *Config) string {
+	if len(cfg.InProcessSuffix) > 0 {
+		return cfg.InProcessSuffix
+	}
+	return inProcessName
+}
+
+// inProcessNameOf returns the name of the in process file, the suffix alone or, when the path is shared, the
+// first character of the suffix followed by the instance and the suffix so that the name stays hidden or
+// temporary the same way
+func inProcessNameOf(suffix, instance string) string {
+	if len(instance) == 0 {
+		return suffix
+	}
+	return suffix[:1] + instance + suffix
+}
+
+// isInProcessName returns true if the file name is the name of an in process file, including the in
+// process files of the other instances sharing the path; the instance part must be a valid instance id
+// so that the unrelated files ending with the suffix are not taken for in process files
+func (e *fileExporter) isInProcessName(name string) bool {
+	if name == e.inProcessSuffix || name == legacyInProcessName {
+		return true
+	}
+	suffix := e.inProcessSuffix
+	if len(name) <= len(suffix)+1 || name[0] != suffix[0] || !strings.HasSuffix(name, suffix) {
+		return false
+	}
+	return instanceRegex.MatchString(name[1 : len(name)-len(suffix)])
+}
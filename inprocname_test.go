@@ -0,0 +1,91 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the in process naming is tested on every platform with the in process name of the platform
+
+func TestInProcessNameOf(t *testing.T) {
+	if got := inProcessNameOf(inProcessName, ""); got != inProcessName {
+		t.Fatalf("expected %s without instance, got %s", inProcessName, got)
+	}
+	got := inProcessNameOf(inProcessName, "node-1")
+	if got != inProcessName[:1]+"node-1"+inProcessName {
+		t.Fatalf("unexpected in process name %s of the instance", got)
+	}
+	if got[0] != '.' && got[0] != '_' {
+		t.Fatalf("the in process name %s of the instance must stay hidden or temporary", got)
+	}
+}
+
+func TestIsInProcessName(t *testing.T) {
+	e := newTestExporter(t, testConfig(t, 1<<20))
+	for _, c := range []struct {
+		name     string
+		expected bool
+	}{
+		{inProcessName, true},
+		{legacyInProcessName, true},
+		{inProcessNameOf(inProcessName, "node-1"), true},
+		{inProcessNameOf(inProcessName, "a b"), false},
+		{"report" + inProcessName, false},
+		{"2022_01_01_00_00_00_000000000-000001.json", false},
+		{"telemetry.json", false},
+	} {
+		if got := e.isInProcessName(c.name); got != c.expected {
+			t.Errorf("isInProcessName(%q) = %v, expected %v", c.name, got, c.expected)
+		}
+	}
+}
+
+func TestRotationFinishesClosedFile(t *testing.T) {
+	cfg := testConfig(t, 10)
+	e := startTestExporter(t, cfg)
+	for _, line := range []string{`{"a":1}`, `{"a":2}`, `{"a":3}`} {
+		if err := writeLine(e, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := finishedFilesOf(t, e)
+	if len(files) != 2 {
+		t.Fatalf("expected two finished files, got %v", files)
+	}
+	for i, f := range files {
+		if content := readFile(t, f); content != `{"a":`+string(rune('1'+i))+`}` {
+			t.Errorf("unexpected content %q of %s", content, f)
+		}
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":3}` {
+		t.Fatalf("unexpected in process file content %q", content)
+	}
+}
+
+func TestRecoverLegacyInProcessName(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.RecoverInProcess = RecoverFinalize
+	if err := os.WriteFile(filepath.Join(cfg.Path, legacyInProcessName), []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := startTestExporter(t, cfg)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || readFile(t, files[0]) != `{"a":1}` {
+		t.Fatalf("expected the legacy in process file to be finished, got %v", files)
+	}
+	for _, f := range listFiles(t, cfg.Path) {
+		if f == legacyInProcessName || f == inProcessName || strings.HasSuffix(f, "."+ext) {
+			t.Fatalf("in process file %s left after recovery", f)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+const (
+	// JSONLayoutDocuments writes the json documents of the batches one after the other
+	JSONLayoutDocuments = "documents"
+	// JSONLayoutArray writes every file as a single json array of the documents of its batches, the array is
+	// closed when the file is finished
+	JSONLayoutArray = "array"
+)
+
+// arrayStart returns what is written before the first batch of a file, the opening bracket of the json
+// array before the file header when the files are json arrays
+func (e *fileExporter) arrayStart(header []byte) []byte {
+	if !e.jsonArray {
+		return header
+	}
+	return append([]byte{'['}, header...)
+}
+
+// arraySeparator returns the comma written before a batch when the files are json arrays and the in process
+// file already holds a document
+func (e *fileExporter) arraySeparator(w *fileWriter) []byte {
+	if !e.jsonArray || w.size <= 1 {
+		return nil
+	}
+	return []byte{','}
+}
+
+// closeJSONArray ends the json array of the finished file with its closing bracket; the file is left as it
+// is if the array is already closed, by a previous attempt to finish the file, or if the file is not an
+// array, when it was written before the layout changed
+func (e *fileExporter) closeJSONArray(f string) error {
+	file, err := e.fs.OpenFile(f, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+	first := make([]byte, 1)
+	if size == 0 {
+		return nil
+	}
+	if _, err = file.ReadAt(first, 0); err != nil {
+		return err
+	}
+	tail := make([]byte, 16)
+	if int64(len(tail)) > size {
+		tail = tail[:size]
+	}
+	if _, err = file.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return err
+	}
+	if first[0] != '[' || bytes.HasSuffix(bytes.TrimRight(tail, " \r\n\t"), []byte{']'}) {
+		return nil
+	}
+	if _, err = file.WriteAt([]byte("]\n"), size); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// jsonDocuments decodes the json documents of a file, whatever their record separator and whether they are
+// written one after the other or as a json array
+type jsonDocuments struct {
+	decoder *json.Decoder
+	array   bool
+}
+
+// newJSONDocuments returns the decoder of the json documents read from r, it fails if the opening bracket of
+// a json array cannot be read
+func newJSONDocuments(r io.Reader) (*jsonDocuments, error) {
+	reader := bufio.NewReader(jsonSeqReader{r})
+	d := &jsonDocuments{decoder: json.NewDecoder(reader)}
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			// empty, the first decode returns the error
+			return d, nil
+		}
+		if next[0] == ' ' || next[0] == '\t' || next[0] == '\r' || next[0] == '\n' {
+			_, _ = reader.ReadByte()
+			continue
+		}
+		d.array = next[0] == '['
+		break
+	}
+	if d.array {
+		if _, err := d.decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// decode decodes the next document, it returns io.EOF once all the documents are decoded
+func (d *jsonDocuments) decode(doc *json.RawMessage) error {
+	if d.array && !d.decoder.More() {
+		return io.EOF
+	}
+	return d.decoder.Decode(doc)
+}
@@ -0,0 +1,115 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// RecordSeparatorNone writes the json documents one after the other
+	RecordSeparatorNone = "none"
+	// RecordSeparatorNewline ends every json document with a newline
+	RecordSeparatorNewline = "newline"
+	// RecordSeparatorJSONSeq writes JSON text sequences as per RFC 7464, every json document is prefixed
+	// with the record separator character and ends with a newline
+	RecordSeparatorJSONSeq = "json-seq"
+	// the character prefixing every json document of a JSON text sequence
+	recordSeparatorChar = 0x1e
+)
+
+// isJSONFormat returns true if the format writes json documents
+func isJSONFormat(format string) bool {
+	return strings.EqualFold(format, Json) || strings.EqualFold(format, OtlpJson) || isTracesOnly(format)
+}
+
+// validateJSONOutput checks the record separator is supported and the json options are only set for the
+// json formats
+func (cfg *Config) validateJSONOutput() error {
+	switch strings.ToLower(cfg.RecordSeparator) {
+	case "", RecordSeparatorNone, RecordSeparatorNewline, RecordSeparatorJSONSeq:
+	default:
+		return fmt.Errorf("invalid recordSeparator [%s], valid values are [ %s, %s or %s ]", cfg.RecordSeparator,
+			RecordSeparatorNone, RecordSeparatorNewline, RecordSeparatorJSONSeq)
+	}
+	if (cfg.PrettyPrint || len(cfg.RecordSeparator) > 0) && !isJSONFormat(cfg.Format) {
+		return errors.New("prettyPrint and recordSeparator require the json, otlp-json, jaeger-json or zipkin-json format")
+	}
+	switch strings.ToLower(cfg.JSONLayout) {
+	case "", JSONLayoutDocuments:
+		return nil
+	case JSONLayoutArray:
+	default:
+		return fmt.Errorf("invalid jsonLayout [%s], valid values are [ %s or %s ]", cfg.JSONLayout, JSONLayoutDocuments, JSONLayoutArray)
+	}
+	if !isJSONFormat(cfg.Format) {
+		return errors.New("the array jsonLayout requires the json, otlp-json, jaeger-json or zipkin-json format")
+	}
+	if strings.EqualFold(cfg.RecordSeparator, RecordSeparatorJSONSeq) {
+		return errors.New("the array jsonLayout cannot be written as a JSON text sequence, the record separator is not valid json")
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("the array jsonLayout requires rotation as the array is closed when a file is finished")
+	}
+	return nil
+}
+
+// recordSeparator returns the separator of the json documents, the json format writes the documents one
+// after the other and the other json formats and the json arrays one per line unless configured otherwise
+func recordSeparator(cfg *Config) string {
+	if len(cfg.RecordSeparator) > 0 {
+		return strings.ToLower(cfg.RecordSeparator)
+	}
+	if strings.EqualFold(cfg.Format, OtlpJson) || isTracesOnly(cfg.Format) || strings.EqualFold(cfg.JSONLayout, JSONLayoutArray) {
+		return RecordSeparatorNewline
+	}
+	return RecordSeparatorNone
+}
+
+// jsonRecord returns the json document of a batch indented if pretty printed and delimited with the
+// record separator
+func (e *fileExporter) jsonRecord(doc []byte) []byte {
+	if e.prettyPrint {
+		var out bytes.Buffer
+		if err := json.Indent(&out, doc, "", "  "); err == nil {
+			doc = out.Bytes()
+		}
+	}
+	switch e.recordSep {
+	case RecordSeparatorNewline:
+		return append(doc, '\n')
+	case RecordSeparatorJSONSeq:
+		record := make([]byte, 0, len(doc)+2)
+		record = append(record, recordSeparatorChar)
+		record = append(record, doc...)
+		return append(record, '\n')
+	}
+	return doc
+}
+
+// jsonSeqReader reads JSON text sequences as json documents separated by white space so that they can be
+// decoded by a json decoder, whatever the record separator they were written with
+type jsonSeqReader struct {
+	r io.Reader
+}
+
+func (r jsonSeqReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == recordSeparatorChar {
+			p[i] = ' '
+		}
+	}
+	return n, err
+}
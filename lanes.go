@@ -0,0 +1,254 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// LaneConfig writes the matching telemetry to its own directory and file series with its own rotation, so
+// that high priority telemetry is finished and uploaded sooner than the bulk telemetry written to large files
+type LaneConfig struct {
+	// Name is the name of the lane and of the sub directory of the path its files are written to
+	Name string `mapstructure:"name"`
+	// Signals are the signals of the lane: traces, metrics or logs; the telemetry of the signals matching the
+	// match criteria that apply to it is in the lane, all of it if there is no criteria for the signal. If no
+	// signal is listed, only the telemetry of the signals the match criteria apply to is in the lane
+	Signals []string `mapstructure:"signals"`
+	// Match selects the telemetry of the lane, the names and resource attribute values are regular expressions
+	Match *MatchConfig `mapstructure:"match"`
+	// FileSizeBytes and EventsPerFile define the rotation of the files of the lane, if neither is defined the
+	// rotation of the exporter is used
+	FileSizeBytes int64 `mapstructure:"fileSizeBytes"`
+	EventsPerFile int64 `mapstructure:"eventsPerFile"`
+}
+
+// validateLanes checks the lanes have distinct directories, valid signals and match criteria
+func (cfg *Config) validateLanes() error {
+	if len(cfg.Lanes) == 0 {
+		return nil
+	}
+	if cfg.RouteBySeverity != nil {
+		return errors.New("mention either routeBySeverity or lanes, a lane matching the log severities routes them")
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("lanes require rotation as they differ by the rotation of their files")
+	}
+	names := make(map[string]bool)
+	for _, lane := range cfg.Lanes {
+		if len(lane.Name) == 0 || strings.ContainsAny(lane.Name, `/\`) || strings.HasPrefix(lane.Name, ".") {
+			return fmt.Errorf("invalid lane name [%s], it must be a single directory name not starting with a dot", lane.Name)
+		}
+		if names[lane.Name] {
+			return fmt.Errorf("duplicate lane name [%s]", lane.Name)
+		}
+		names[lane.Name] = true
+		for _, signal := range lane.Signals {
+			switch signal {
+			case signalTraces, signalMetrics, signalLogs:
+			default:
+				return fmt.Errorf("invalid signal [%s] of lane %s, valid values are [ %s, %s or %s ]", signal, lane.Name, signalTraces, signalMetrics, signalLogs)
+			}
+		}
+		if len(lane.Signals) == 0 && lane.Match == nil {
+			return fmt.Errorf("lane %s must define its signals or match criteria", lane.Name)
+		}
+		if lane.Match != nil {
+			if err := lane.Match.Validate(); err != nil {
+				return fmt.Errorf("invalid match of lane %s: %w", lane.Name, err)
+			}
+		}
+		if lane.FileSizeBytes < 0 || lane.EventsPerFile < 0 {
+			return fmt.Errorf("fileSizeBytes and eventsPerFile of lane %s must not be negative", lane.Name)
+		}
+		if lane.FileSizeBytes > 0 && lane.EventsPerFile > 0 {
+			return fmt.Errorf("mention either fileSizeBytes or eventsPerFile for lane %s", lane.Name)
+		}
+	}
+	return nil
+}
+
+// lane is the resolved configuration of a lane
+type lane struct {
+	name string
+	// signals are the signals of the lane, nil if they are the signals the match criteria apply to
+	signals       map[string]bool
+	match         *matcher
+	fileSize      int64
+	eventsPerFile int64
+}
+
+func newLanes(cfgs []LaneConfig) []*lane {
+	var lanes []*lane
+	for _, cfg := range cfgs {
+		l := &lane{
+			name:          cfg.Name,
+			match:         newMatcher(cfg.Match),
+			fileSize:      cfg.FileSizeBytes,
+			eventsPerFile: cfg.EventsPerFile,
+		}
+		if len(cfg.Signals) > 0 {
+			l.signals = make(map[string]bool)
+			for _, signal := range cfg.Signals {
+				l.signals[signal] = true
+			}
+		}
+		lanes = append(lanes, l)
+	}
+	return lanes
+}
+
+// applies returns true if telemetry of the signal can be in the lane
+func (l *lane) applies(signal string) bool {
+	return l.signals == nil || l.signals[signal]
+}
+
+// matches returns true if the record is in the lane, a lane listing its signals matches the records of
+// the signals its criteria do not apply to as an include filter does
+func (l *lane) matches(match func(m *matcher, include bool) bool) bool {
+	if l.match == nil {
+		return true
+	}
+	return match(l.match, l.signals != nil)
+}
+
+// laneOf returns the lane writing to the route, nil if it is not the route of a lane
+func (e *fileExporter) laneOf(route string) *lane {
+	for _, l := range e.lanes {
+		if l.name == route {
+			return l
+		}
+	}
+	return nil
+}
+
+// laneTraces splits the traces into the spans of each lane, in the order of the lanes, the spans in no lane
+// are under the empty route; the passed in traces are not modified
+func (e *fileExporter) laneTraces(td ptrace.Traces) map[string]ptrace.Traces {
+	lanes := make(map[string]ptrace.Traces)
+	for _, l := range e.lanes {
+		if !l.applies(signalTraces) || td.SpanCount() == 0 {
+			continue
+		}
+		in := func(resource pcommon.Resource, span ptrace.Span) bool {
+			return l.matches(func(m *matcher, include bool) bool {
+				return m.matchSpan(resource, span, include)
+			})
+		}
+		if matched := selectSpans(td, in, true); matched.SpanCount() > 0 {
+			lanes[l.name] = matched
+			td = selectSpans(td, in, false)
+		}
+	}
+	if td.SpanCount() > 0 {
+		lanes[""] = td
+	}
+	return lanes
+}
+
+// laneMetrics splits the metrics into the metrics of each lane, see laneTraces
+func (e *fileExporter) laneMetrics(md pmetric.Metrics) map[string]pmetric.Metrics {
+	lanes := make(map[string]pmetric.Metrics)
+	for _, l := range e.lanes {
+		if !l.applies(signalMetrics) || md.DataPointCount() == 0 {
+			continue
+		}
+		in := func(resource pcommon.Resource, metric pmetric.Metric) bool {
+			return l.matches(func(m *matcher, include bool) bool {
+				return m.matchMetric(resource, metric, include)
+			})
+		}
+		if matched := selectMetrics(md, in, true); matched.DataPointCount() > 0 {
+			lanes[l.name] = matched
+			md = selectMetrics(md, in, false)
+		}
+	}
+	if md.DataPointCount() > 0 {
+		lanes[""] = md
+	}
+	return lanes
+}
+
+// laneLogs splits the logs into the log records of each lane, see laneTraces
+func (e *fileExporter) laneLogs(ld plog.Logs) map[string]plog.Logs {
+	lanes := make(map[string]plog.Logs)
+	for _, l := range e.lanes {
+		if !l.applies(signalLogs) || ld.LogRecordCount() == 0 {
+			continue
+		}
+		in := func(resource pcommon.Resource, lr plog.LogRecord) bool {
+			return l.matches(func(m *matcher, include bool) bool {
+				return m.matchLog(resource, lr, include)
+			})
+		}
+		if matched := selectLogs(ld, in, true); matched.LogRecordCount() > 0 {
+			lanes[l.name] = matched
+			ld = selectLogs(ld, in, false)
+		}
+	}
+	if ld.LogRecordCount() > 0 {
+		lanes[""] = ld
+	}
+	return lanes
+}
+
+// selectSpans returns a copy of the spans for which in returns want
+func selectSpans(td ptrace.Traces, in func(pcommon.Resource, ptrace.Span) bool, want bool) ptrace.Traces {
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	out.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			ss.Spans().RemoveIf(func(span ptrace.Span) bool {
+				return in(rs.Resource(), span) != want
+			})
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return out
+}
+
+// selectMetrics returns a copy of the metrics for which in returns want
+func selectMetrics(md pmetric.Metrics, in func(pcommon.Resource, pmetric.Metric) bool, want bool) pmetric.Metrics {
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	out.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				return in(rm.Resource(), metric) != want
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	return out
+}
+
+// selectLogs returns a copy of the log records for which in returns want
+func selectLogs(ld plog.Logs, in func(pcommon.Resource, plog.LogRecord) bool, want bool) plog.Logs {
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	out.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(lr plog.LogRecord) bool {
+				return in(rl.Resource(), lr) != want
+			})
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+	return out
+}
@@ -0,0 +1,140 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the layout of an output directory is the naming and framing of the files written under it, its version
+// is recorded in the layout file of the directory and in the file header records:
+//
+//	1 - the directories written before the layout was versioned, the in process files are named .inproc
+//	    on every platform
+//	2 - the in process file name depends on the platform and the files may start with a header record
+//
+// a change to the layout increments the version and registers the migration from the previous version
+
+const (
+	// the current version of the layout
+	layoutVersion = 2
+	// the file recording the layout version of an output directory
+	layoutFile = ".layout.json"
+)
+
+// layoutMigrations upgrades an output directory from the layout version of the key to the next version
+var layoutMigrations = map[int]func(root string) error{
+	1: migrateInProcessNames,
+}
+
+// layout is the content of the layout file
+type layout struct {
+	LayoutVersion   int       `json:"layoutVersion"`
+	ExporterVersion string    `json:"exporterVersion"`
+	Updated         time.Time `json:"updated"`
+}
+
+// LayoutVersion returns the layout version of the files under the output directory, the directories
+// written before the layout was versioned are version 1
+func LayoutVersion(path string) (int, error) {
+	content, err := os.ReadFile(filepath.Join(path, layoutFile))
+	if os.IsNotExist(err) {
+		return 1, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var l layout
+	if err = json.Unmarshal(content, &l); err != nil {
+		return 0, fmt.Errorf("invalid layout file in %s: %w", path, err)
+	}
+	return l.LayoutVersion, nil
+}
+
+// Migrate upgrades the files under the output directory to the current layout in place, it must not be
+// called while an exporter writes to the directory; directories written by a newer version are left as is
+func Migrate(path string) error {
+	version, err := LayoutVersion(path)
+	if err != nil {
+		return err
+	}
+	if version > layoutVersion {
+		return fmt.Errorf("%s has layout version %d, newer than the supported version %d", path, version, layoutVersion)
+	}
+	for ; version < layoutVersion; version++ {
+		if err = layoutMigrations[version](path); err != nil {
+			return fmt.Errorf("failed to migrate %s from layout version %d: %w", path, version, err)
+		}
+	}
+	return writeLayout(path)
+}
+
+// writeLayout records the current layout version in the output directory
+func writeLayout(path string) error {
+	content, err := json.Marshal(layout{LayoutVersion: layoutVersion, ExporterVersion: exporterVersion(), Updated: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(path, layoutFile+".tmp")
+	if err = os.WriteFile(tmp, append(content, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(path, layoutFile))
+}
+
+// migrateLayouts upgrades the path and mirror paths to the current layout on start, a directory written
+// by a newer version is written to without changing its layout file
+func (e *fileExporter) migrateLayouts() error {
+	for _, root := range e.roots() {
+		version, err := LayoutVersion(root)
+		if err != nil {
+			return err
+		}
+		if version == layoutVersion {
+			continue
+		}
+		if version > layoutVersion {
+			e.logger.Warn("output path was written by a newer exporter version", zap.String("path", root),
+				zap.Int("layoutVersion", version), zap.Int("supportedVersion", layoutVersion))
+			continue
+		}
+		e.logger.Info("migrating output path to the current layout", zap.String("path", root),
+			zap.Int("from", version), zap.Int("to", layoutVersion))
+		if err = Migrate(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateInProcessNames renames the in process files named .inproc to the in process name of the platform
+func migrateInProcessNames(root string) error {
+	if inProcessName == legacyInProcessName {
+		return nil
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != legacyInProcessName {
+			return nil
+		}
+		f := inProcessFile(filepath.Dir(p))
+		if _, err = os.Stat(f); err == nil {
+			return fmt.Errorf("cannot migrate %s as the inprocess file %s also exists", p, f)
+		}
+		return os.Rename(p, f)
+	})
+}
@@ -0,0 +1,74 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loadWrites writes the batches of the signals concurrently, one goroutine per signal, and returns the time
+// it took; every write to the file is delayed to simulate a slow disk
+func loadWrites(t *testing.T, signalDirs bool, signals []string, batches int) time.Duration {
+	cfg := testConfig(t, 1<<30)
+	cfg.SignalDirectories = signalDirs
+	e := newTestExporter(t, cfg)
+	fsys := newFaultFS(osFS{})
+	fsys.inject(fault{op: faultWrite, pattern: "*", delay: 2 * time.Millisecond})
+	e.fs = fsys
+	startExporter(t, e)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(signals))
+	start := time.Now()
+	for _, signal := range signals {
+		wg.Add(1)
+		go func(signal string) {
+			defer wg.Done()
+			for i := 0; i < batches; i++ {
+				err := e.exportAsLine(context.Background(), "", &batch{signal: signal, records: 1, buf: []byte(`{"a":1}`)})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(signal)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	var size int64
+	_ = e.eachWriter(context.Background(), func(w *fileWriter) error {
+		size += w.size
+		return nil
+	})
+	if expected := int64(len(signals) * batches * len(`{"a":1}`)); size != expected {
+		t.Fatalf("expected %d bytes written, got %d", expected, size)
+	}
+	return elapsed
+}
+
+// TestLoadSignalsWriteConcurrently checks the signals written to their own files do not wait for each other,
+// the baseline writes the same batches to a single file through its lock
+func TestLoadSignalsWriteConcurrently(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test skipped in short mode")
+	}
+	signals := []string{signalTraces, signalMetrics, signalLogs}
+	serial := loadWrites(t, false, signals, 50)
+	concurrent := loadWrites(t, true, signals, 50)
+	t.Logf("single file %s, file per signal %s", serial, concurrent)
+	if concurrent > serial*2/3 {
+		t.Fatalf("the signals written to their own files took %s, not faster than %s through a single file", concurrent, serial)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+)
+
+// writeLock is a mutex that can also be acquired with a context, so that a write waiting for the
+// lock gives up when the pipeline cancels it or its deadline expires
+type writeLock chan struct{}
+
+func newWriteLock() writeLock {
+	return make(writeLock, 1)
+}
+
+func (l writeLock) Lock() {
+	l <- struct{}{}
+}
+
+func (l writeLock) Unlock() {
+	<-l
+}
+
+// LockContext acquires the lock unless the context is done first, in which case the context error is returned
+func (l writeLock) LockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
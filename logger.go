@@ -0,0 +1,44 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newExporterLogger derives the exporter logger from the collector logger according to the verbosity:
+//   - none: nothing is logged
+//   - basic: only warnings and errors are logged
+//   - normal: the collector log level applies
+//   - detailed: debug messages are logged regardless of the collector log level
+func newExporterLogger(logger *zap.Logger, verbosity configtelemetry.Level) *zap.Logger {
+	if logger == nil {
+		return zap.NewNop()
+	}
+	logger = logger.With(zap.String("exporter", typeStr))
+	switch verbosity {
+	case configtelemetry.LevelNone:
+		return zap.NewNop()
+	case configtelemetry.LevelBasic:
+		return logger.WithOptions(zap.IncreaseLevel(zapcore.WarnLevel))
+	}
+	return logger
+}
+
+// debug logs troubleshooting information about the write path, when the verbosity is detailed the
+// message is logged at info level so that it is visible without changing the collector log level
+func (e *fileExporter) debug(msg string, fields ...zap.Field) {
+	if e.verbosity == configtelemetry.LevelDetailed {
+		e.logger.Info(msg, fields...)
+		return
+	}
+	e.logger.Debug(msg, fields...)
+}
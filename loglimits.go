@@ -0,0 +1,126 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// logLimits caps the size of the log records written, zero values mean no limit
+type logLimits struct {
+	maxBodyBytes      int
+	maxValueLength    int
+	maxAttributeCount int
+}
+
+// validateLogLimits checks the log record limits are not negative
+func (cfg *Config) validateLogLimits() error {
+	if cfg.MaxLogBodyBytes < 0 || cfg.MaxAttributeValueLength < 0 || cfg.MaxAttributesPerRecord < 0 {
+		return errors.New("maxLogBodyBytes, maxAttributeValueLength and maxAttributesPerRecord must not be negative")
+	}
+	return nil
+}
+
+func newLogLimits(cfg *Config) logLimits {
+	return logLimits{
+		maxBodyBytes:      cfg.MaxLogBodyBytes,
+		maxValueLength:    cfg.MaxAttributeValueLength,
+		maxAttributeCount: cfg.MaxAttributesPerRecord,
+	}
+}
+
+// enabled returns true if any limit is defined
+func (l logLimits) enabled() bool {
+	return l.maxBodyBytes > 0 || l.maxValueLength > 0 || l.maxAttributeCount > 0
+}
+
+// limitLogs returns a copy of the logs with the bodies truncated and the attributes limited, the passed
+// in logs are not modified
+func (e *fileExporter) limitLogs(ld plog.Logs) plog.Logs {
+	if !e.logLimits.enabled() {
+		return ld
+	}
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	for i := 0; i < out.ResourceLogs().Len(); i++ {
+		sls := out.ResourceLogs().At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				e.logLimits.apply(lrs.At(k))
+			}
+		}
+	}
+	return out
+}
+
+// apply truncates the body and limits the attributes of a log record, the dropped attributes are added
+// to the dropped attributes count of the record
+func (l logLimits) apply(lr plog.LogRecord) {
+	if l.maxBodyBytes > 0 {
+		truncateValue(lr.Body(), l.maxBodyBytes)
+	}
+	if l.maxAttributeCount > 0 && lr.Attributes().Len() > l.maxAttributeCount {
+		kept, dropped := 0, 0
+		lr.Attributes().RemoveIf(func(string, pcommon.Value) bool {
+			if kept < l.maxAttributeCount {
+				kept++
+				return false
+			}
+			dropped++
+			return true
+		})
+		lr.SetDroppedAttributesCount(lr.DroppedAttributesCount() + uint32(dropped))
+	}
+	if l.maxValueLength > 0 {
+		lr.Attributes().Range(func(_ string, v pcommon.Value) bool {
+			truncateValue(v, l.maxValueLength)
+			return true
+		})
+	}
+}
+
+// truncateValue truncates the strings and byte arrays of a value to max bytes, the values of maps and
+// slices are truncated individually
+func truncateValue(v pcommon.Value, max int) {
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		if len(v.Str()) > max {
+			v.SetStr(truncateString(v.Str(), max))
+		}
+	case pcommon.ValueTypeBytes:
+		if v.Bytes().Len() > max {
+			v.Bytes().FromRaw(v.Bytes().AsRaw()[:max])
+		}
+	case pcommon.ValueTypeMap:
+		v.Map().Range(func(_ string, mv pcommon.Value) bool {
+			truncateValue(mv, max)
+			return true
+		})
+	case pcommon.ValueTypeSlice:
+		for i := 0; i < v.Slice().Len(); i++ {
+			truncateValue(v.Slice().At(i), max)
+		}
+	}
+}
+
+// truncateString cuts a string to at most max bytes without splitting a multi byte character
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
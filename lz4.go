@@ -0,0 +1,332 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// the lz4 files are written with a minimal frame encoder: independent 4MB blocks compressed with a
+// greedy single hash match finder, without block or content checksums
+
+const (
+	lz4Magic        = 0x184D2204
+	lz4BlockMaxSize = 4 << 20
+	// the frame descriptor flags: version 01 and independent blocks
+	lz4Flags = 0x60
+	// the frame descriptor block descriptor: 4MB maximum block size
+	lz4BlockDescriptor = 0x70
+	// the high bit of a block size marks an uncompressed block
+	lz4Uncompressed = 0x80000000
+	lz4MinMatch     = 4
+	// the last match must start 12 bytes before the end of the block and the last 5 bytes are literals
+	lz4MatchLimit  = 12
+	lz4LastLiteral = 5
+	lz4MaxOffset   = 65535
+	lz4HashLog     = 16
+)
+
+// lz4Writer writes an lz4 frame, the data is buffered and compressed one block at a time
+type lz4Writer struct {
+	w             io.Writer
+	buf           []byte
+	headerWritten bool
+	err           error
+}
+
+func newLz4Writer(w io.Writer) *lz4Writer {
+	return &lz4Writer{w: w, buf: make([]byte, 0, lz4BlockMaxSize)}
+}
+
+func (z *lz4Writer) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 && z.err == nil {
+		free := lz4BlockMaxSize - len(z.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		z.buf = append(z.buf, p[:free]...)
+		p = p[free:]
+		n += free
+		if len(z.buf) == lz4BlockMaxSize {
+			z.flush()
+		}
+	}
+	return n, z.err
+}
+
+// Close writes the buffered data and the end mark of the frame, it does not close the underlying writer
+func (z *lz4Writer) Close() error {
+	z.flush()
+	z.writeHeader()
+	z.write([]byte{0, 0, 0, 0})
+	return z.err
+}
+
+func (z *lz4Writer) writeHeader() {
+	if z.headerWritten {
+		return
+	}
+	z.headerWritten = true
+	header := make([]byte, 7)
+	binary.LittleEndian.PutUint32(header, lz4Magic)
+	header[4] = lz4Flags
+	header[5] = lz4BlockDescriptor
+	header[6] = byte(xxh32(header[4:6], 0) >> 8)
+	z.write(header)
+}
+
+func (z *lz4Writer) flush() {
+	z.writeHeader()
+	if len(z.buf) == 0 {
+		return
+	}
+	block := lz4CompressBlock(z.buf)
+	size := make([]byte, 4)
+	if len(block) >= len(z.buf) {
+		binary.LittleEndian.PutUint32(size, uint32(len(z.buf))|lz4Uncompressed)
+		block = z.buf
+	} else {
+		binary.LittleEndian.PutUint32(size, uint32(len(block)))
+	}
+	z.write(size)
+	z.write(block)
+	z.buf = z.buf[:0]
+}
+
+func (z *lz4Writer) write(p []byte) {
+	if z.err == nil {
+		_, z.err = z.w.Write(p)
+	}
+}
+
+// lz4CompressBlock compresses the source to an lz4 block
+func lz4CompressBlock(src []byte) []byte {
+	dst := make([]byte, 0, len(src)+len(src)/255+16)
+	var table [1 << lz4HashLog]int32
+	anchor := 0
+	for i := 0; i+lz4MatchLimit <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashLog)
+		// positions are stored plus one so that zero means empty
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+		length := lz4MinMatch
+		for i+length < len(src)-lz4LastLiteral && src[ref+length] == src[i+length] {
+			length++
+		}
+		dst = lz4Sequence(dst, src[anchor:i], i-ref, length)
+		i += length
+		anchor = i
+	}
+	return lz4Sequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4Sequence appends a sequence of literals followed by a match, the last sequence of a block has no match
+func lz4Sequence(dst []byte, literals []byte, offset, length int) []byte {
+	token := len(dst)
+	dst = append(dst, 0)
+	if len(literals) >= 15 {
+		dst[token] = 15 << 4
+		dst = lz4Length(dst, len(literals)-15)
+	} else {
+		dst[token] = byte(len(literals)) << 4
+	}
+	dst = append(dst, literals...)
+	if length == 0 {
+		return dst
+	}
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if length-lz4MinMatch >= 15 {
+		dst[token] |= 15
+		dst = lz4Length(dst, length-lz4MinMatch-15)
+	} else {
+		dst[token] |= byte(length - lz4MinMatch)
+	}
+	return dst
+}
+
+func lz4Length(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+// xxh32 returns the xxHash32 of the data, as used by the lz4 frame header checksum
+func xxh32(data []byte, seed uint32) uint32 {
+	const (
+		prime1 uint32 = 2654435761
+		prime2 uint32 = 2246822519
+		prime3 uint32 = 3266489917
+		prime4 uint32 = 668265263
+		prime5 uint32 = 374761393
+	)
+	round := func(acc, v uint32) uint32 {
+		return bits.RotateLeft32(acc+v*prime2, 13) * prime1
+	}
+	n := len(data)
+	var h uint32
+	if n >= 16 {
+		v1, v2, v3, v4 := seed+prime1+prime2, seed+prime2, seed, seed-prime1
+		for ; len(data) >= 16; data = data[16:] {
+			v1 = round(v1, binary.LittleEndian.Uint32(data))
+			v2 = round(v2, binary.LittleEndian.Uint32(data[4:]))
+			v3 = round(v3, binary.LittleEndian.Uint32(data[8:]))
+			v4 = round(v4, binary.LittleEndian.Uint32(data[12:]))
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + prime5
+	}
+	h += uint32(n)
+	for ; len(data) >= 4; data = data[4:] {
+		h += binary.LittleEndian.Uint32(data) * prime3
+		h = bits.RotateLeft32(h, 17) * prime4
+	}
+	for _, b := range data {
+		h += uint32(b) * prime5
+		h = bits.RotateLeft32(h, 11) * prime1
+	}
+	h ^= h >> 15
+	h *= prime2
+	h ^= h >> 13
+	h *= prime3
+	h ^= h >> 16
+	return h
+}
+
+// lz4Decompress decompresses the lz4 frames of the data, linked and independent blocks are supported
+// and the optional checksums are skipped
+func lz4Decompress(data []byte) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		if len(data) < 7 || binary.LittleEndian.Uint32(data) != lz4Magic {
+			return nil, errors.New("invalid lz4 frame header")
+		}
+		flags := data[4]
+		if flags>>6 != 1 {
+			return nil, fmt.Errorf("unsupported lz4 frame version %d", flags>>6)
+		}
+		i := 6
+		if flags&0x08 != 0 {
+			// content size
+			i += 8
+		}
+		if flags&0x01 != 0 {
+			// dictionary id
+			i += 4
+		}
+		// header checksum
+		i++
+		blockChecksum, contentChecksum := flags&0x10 != 0, flags&0x04 != 0
+		for {
+			if i+4 > len(data) {
+				return nil, errors.New("truncated lz4 frame")
+			}
+			size := binary.LittleEndian.Uint32(data[i:])
+			i += 4
+			if size == 0 {
+				break
+			}
+			n := int(size &^ lz4Uncompressed)
+			if i+n > len(data) {
+				return nil, errors.New("truncated lz4 block")
+			}
+			var err error
+			if size&lz4Uncompressed != 0 {
+				out = append(out, data[i:i+n]...)
+			} else if out, err = lz4DecompressBlock(out, data[i:i+n]); err != nil {
+				return nil, err
+			}
+			i += n
+			if blockChecksum {
+				i += 4
+			}
+		}
+		if contentChecksum {
+			i += 4
+		}
+		if i > len(data) {
+			return nil, errors.New("truncated lz4 frame")
+		}
+		data = data[i:]
+	}
+	return out, nil
+}
+
+// lz4DecompressBlock appends the decompressed block to dst, the matches can refer to the data of the
+// previous blocks already in dst
+func lz4DecompressBlock(dst []byte, src []byte) ([]byte, error) {
+	i := 0
+	for i < len(src) {
+		token := src[i]
+		i++
+		literals := int(token >> 4)
+		if literals == 15 {
+			for {
+				if i >= len(src) {
+					return nil, errors.New("corrupted lz4 block")
+				}
+				b := src[i]
+				i++
+				literals += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		if i+literals > len(src) {
+			return nil, errors.New("corrupted lz4 block")
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// the last sequence only has literals
+			break
+		}
+		if i+2 > len(src) {
+			return nil, errors.New("corrupted lz4 block")
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		length := int(token & 15)
+		if length == 15 {
+			for {
+				if i >= len(src) {
+					return nil, errors.New("corrupted lz4 block")
+				}
+				b := src[i]
+				i++
+				length += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		length += lz4MinMatch
+		if offset == 0 || offset > len(dst) {
+			return nil, errors.New("corrupted lz4 block")
+		}
+		// the match can overlap the data it produces so it is copied byte by byte
+		start := len(dst) - offset
+		for k := 0; k < length; k++ {
+			dst = append(dst, dst[start+k])
+		}
+	}
+	return dst, nil
+}
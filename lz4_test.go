@@ -0,0 +1,130 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// lz4Compress compresses the data to an lz4 frame written in chunks of the size
+func lz4Compress(t *testing.T, data []byte, chunk int) []byte {
+	var out bytes.Buffer
+	w := newLz4Writer(&out)
+	for p := data; len(p) > 0; {
+		n := chunk
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// lz4Blocks returns the sizes of the blocks of the frame written by the exporter, with the uncompressed flag
+func lz4Blocks(t *testing.T, frame []byte) []uint32 {
+	var blocks []uint32
+	for i := 7; ; {
+		size := binary.LittleEndian.Uint32(frame[i:])
+		if size == 0 {
+			if i+4 != len(frame) {
+				t.Fatalf("expected the end mark to end the frame, %d bytes follow", len(frame)-i-4)
+			}
+			return blocks
+		}
+		blocks = append(blocks, size)
+		i += 4 + int(size&^lz4Uncompressed)
+	}
+}
+
+// randomBytes returns incompressible bytes
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(data)
+	return data
+}
+
+func TestLz4RoundTrip(t *testing.T) {
+	records := bytes.Repeat([]byte(`{"resourceSpans":[{"resource":{"attributes":[]}}]}`+"\n"), lz4BlockMaxSize/40)
+	for name, test := range map[string]struct {
+		data   []byte
+		chunk  int
+		blocks int
+	}{
+		"empty":                        {data: nil, chunk: 1, blocks: 0},
+		"shorter than a match":         {data: []byte("abcabcabcab"), chunk: 1, blocks: 1},
+		"overlapping match":            {data: bytes.Repeat([]byte("a"), 1000), chunk: 7, blocks: 1},
+		"incompressible":               {data: randomBytes(100000), chunk: 4096, blocks: 1},
+		"exactly one block":            {data: records[:lz4BlockMaxSize], chunk: lz4BlockMaxSize, blocks: 1},
+		"across block boundaries":      {data: append(append([]byte(nil), records...), randomBytes(lz4BlockMaxSize)...), chunk: 1000003, blocks: 3},
+		"incompressible across blocks": {data: randomBytes(lz4BlockMaxSize + 1), chunk: 65536, blocks: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			frame := lz4Compress(t, test.data, test.chunk)
+			if binary.LittleEndian.Uint32(frame) != lz4Magic || frame[6] != byte(xxh32(frame[4:6], 0)>>8) {
+				t.Fatal("invalid frame header")
+			}
+			blocks := lz4Blocks(t, frame)
+			if len(blocks) != test.blocks {
+				t.Fatalf("expected %d blocks, got %d", test.blocks, len(blocks))
+			}
+			for _, size := range blocks {
+				if size&^lz4Uncompressed > lz4BlockMaxSize {
+					t.Fatalf("block of %d bytes larger than the maximum block size", size&^lz4Uncompressed)
+				}
+			}
+			decompressed, err := lz4Decompress(frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decompressed, test.data) {
+				t.Fatalf("expected the %d bytes to round trip, got %d bytes", len(test.data), len(decompressed))
+			}
+		})
+	}
+}
+
+func TestLz4IncompressibleBlockIsStored(t *testing.T) {
+	data := randomBytes(10000)
+	frame := lz4Compress(t, data, len(data))
+	blocks := lz4Blocks(t, frame)
+	if len(blocks) != 1 || blocks[0] != uint32(len(data))|lz4Uncompressed {
+		t.Fatalf("expected the incompressible data to be stored uncompressed, got blocks %v", blocks)
+	}
+	if want := 7 + 4 + len(data) + 4; len(frame) != want {
+		t.Fatalf("expected a frame of %d bytes, got %d", want, len(frame))
+	}
+}
+
+func TestLz4DecompressRejectsCorruptedData(t *testing.T) {
+	frame := lz4Compress(t, bytes.Repeat([]byte("telemetry "), 1000), 1000)
+	truncated := frame[:len(frame)-10]
+	corrupted := append([]byte(nil), frame...)
+	// the offset of the first match, after the token and literals of the first sequence, points before the
+	// start of the block
+	offset := 7 + 4 + 1 + int(frame[7+4]>>4)
+	corrupted[offset], corrupted[offset+1] = 0xff, 0xff
+	for name, data := range map[string][]byte{
+		"without magic": frame[4:],
+		"truncated":     truncated,
+		"corrupted":     corrupted,
+	} {
+		if _, err := lz4Decompress(data); err == nil {
+			t.Errorf("expected the %s frame to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	ManifestNone = "none"
+	// ManifestSidecar writes a <file>.manifest.json file next to each finished file
+	ManifestSidecar = "sidecar"
+	// ManifestRolling appends the manifest of each finished file to the manifest.jsonl file of its directory
+	ManifestRolling = "rolling"
+
+	// the version of the manifest schema, to be increased when fields are changed or removed
+	manifestSchemaVersion = 1
+	manifestSidecarExt    = "manifest.json"
+	manifestRollingFile   = "manifest.jsonl"
+)
+
+// validateManifest checks the manifest mode is supported
+func validateManifest(manifest string) error {
+	switch strings.ToLower(manifest) {
+	case "", ManifestNone, ManifestSidecar, ManifestRolling:
+		return nil
+	}
+	return fmt.Errorf("invalid manifest [%s], valid values are [ %s, %s or %s ]", manifest, ManifestNone, ManifestSidecar, ManifestRolling)
+}
+
+// manifestEnabled returns true if a manifest is written for each finished file
+func (e *fileExporter) manifestEnabled() bool {
+	return e.manifest == ManifestSidecar || e.manifest == ManifestRolling
+}
+
+// fileStats holds the statistics of the batches written to an in process file
+type fileStats struct {
+	records int64
+	first   pcommon.Timestamp
+	last    pcommon.Timestamp
+	// maxAge is the age of the oldest record when it was written and outOfOrder the number of batches older
+	// than the telemetry written before them
+	maxAge     time.Duration
+	outOfOrder int64
+}
+
+// add records the statistics of a batch
+func (s *fileStats) add(b *batch) {
+	s.records += int64(b.records)
+	if s.isOutOfOrder(b) {
+		s.outOfOrder++
+	}
+	if age := batchAge(b, time.Now()); age > s.maxAge {
+		s.maxAge = age
+	}
+	if b.first != 0 && (s.first == 0 || b.first < s.first) {
+		s.first = b.first
+	}
+	if b.last > s.last {
+		s.last = b.last
+	}
+}
+
+// manifest describes a finished file
+type manifest struct {
+	SchemaVersion  int               `json:"schemaVersion"`
+	File           string            `json:"file"`
+	Records        int64             `json:"records"`
+	Bytes          int64             `json:"bytes"`
+	FirstTimestamp string            `json:"firstTimestamp,omitempty"`
+	LastTimestamp  string            `json:"lastTimestamp,omitempty"`
+	Signals        []string          `json:"signals"`
+	Format         string            `json:"format"`
+	Compression    string            `json:"compression,omitempty"`
+	Encrypted      bool              `json:"encrypted"`
+	Checksum       *checksum         `json:"checksum,omitempty"`
+	Created        string            `json:"created"`
+	Identity       map[string]string `json:"identity,omitempty"`
+	// MaxDataAgeSeconds, Stale and OutOfOrderBatches describe the age of the records when the data age is tracked
+	MaxDataAgeSeconds float64 `json:"maxDataAgeSeconds,omitempty"`
+	Stale             bool    `json:"stale,omitempty"`
+	OutOfOrderBatches int64   `json:"outOfOrderBatches,omitempty"`
+}
+
+type checksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// writeManifest writes the manifest of the finished file, sum is the checksum of the file if one was computed
+func (e *fileExporter) writeManifest(f string, stats fileStats, signals []string, sum string) error {
+	stat, err := os.Stat(f)
+	if err != nil {
+		return err
+	}
+	m := manifest{
+		SchemaVersion: manifestSchemaVersion,
+		File:          filepath.Base(f),
+		Records:       stats.records,
+		Bytes:         stat.Size(),
+		Signals:       signals,
+		Format:        e.format.String(),
+		Encrypted:     e.keyProvider != nil,
+		Created:       time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if e.compression != CompressionNone {
+		m.Compression = e.compression
+	}
+	if stats.first != 0 {
+		m.FirstTimestamp = stats.first.AsTime().UTC().Format(time.RFC3339Nano)
+	}
+	if stats.last != 0 {
+		m.LastTimestamp = stats.last.AsTime().UTC().Format(time.RFC3339Nano)
+	}
+	if e.identity != nil && e.identity.manifest {
+		m.Identity = e.identity.attributes
+	}
+	if e.dataAge != nil {
+		m.MaxDataAgeSeconds, m.Stale, m.OutOfOrderBatches = stats.maxAge.Seconds(), e.isStale(stats), stats.outOfOrder
+	}
+	if len(sum) > 0 {
+		m.Checksum = &checksum{Algorithm: e.checksum, Value: sum}
+	}
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if e.manifest == ManifestSidecar {
+		return os.WriteFile(fmt.Sprintf("%s.%s", f, manifestSidecarExt), append(content, '\n'), 0644)
+	}
+	file, err := os.OpenFile(filepath.Join(filepath.Dir(f), manifestRollingFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(content, '\n'))
+	return err
+}
+
+// signalList returns the sorted signal types of a file
+func signalList(signals map[string]bool) []string {
+	list := make([]string, 0, len(signals))
+	for s := range signals {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// timeRange widens the range with the timestamp, zero timestamps are ignored
+func timeRange(first, last *pcommon.Timestamp, ts pcommon.Timestamp) {
+	if ts == 0 {
+		return
+	}
+	if *first == 0 || ts < *first {
+		*first = ts
+	}
+	if ts > *last {
+		*last = ts
+	}
+}
+
+// tracesTimeRange returns the earliest span start and the latest span end
+func tracesTimeRange(td ptrace.Traces) (first, last pcommon.Timestamp) {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				timeRange(&first, &last, spans.At(k).StartTimestamp())
+				timeRange(&first, &last, spans.At(k).EndTimestamp())
+			}
+		}
+	}
+	return first, last
+}
+
+// metricsTimeRange returns the earliest and latest data point timestamps
+func metricsTimeRange(md pmetric.Metrics) (first, last pcommon.Timestamp) {
+	forEachMetricPoint(md, func(p metricPoint) {
+		timeRange(&first, &last, p.timestamp)
+	})
+	return first, last
+}
+
+// logsTimeRange returns the earliest and latest log record timestamps, the observed timestamp is used
+// for records without a timestamp
+func logsTimeRange(ld plog.Logs) (first, last pcommon.Timestamp) {
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			records := rl.ScopeLogs().At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				ts := records.At(k).Timestamp()
+				if ts == 0 {
+					ts = records.At(k).ObservedTimestamp()
+				}
+				timeRange(&first, &last, ts)
+			}
+		}
+	}
+	return first, last
+}
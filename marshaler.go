@@ -0,0 +1,53 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Marshaler encodes telemetry in a custom format, it is used when the format is custom so that
+// downstream builds can write their own encodings without forking the exporter; a marshaler that
+// does not support a signal returns an error which is not retried; a marshaler also implementing the
+// pdata Sizer interfaces of a signal has the size of its batches checked before they are marshaled
+type Marshaler interface {
+	MarshalTraces(td ptrace.Traces) ([]byte, error)
+	MarshalMetrics(md pmetric.Metrics) ([]byte, error)
+	MarshalLogs(ld plog.Logs) ([]byte, error)
+	// Extension returns the extension of the finished files, without the leading dot
+	Extension() string
+}
+
+// FactoryOption customises the factory created with NewFactory
+type FactoryOption func(f *factory)
+
+// WithMarshaler registers the marshaler used by the exporters with the custom format
+func WithMarshaler(m Marshaler) FactoryOption {
+	return func(f *factory) {
+		f.marshaler = m
+	}
+}
+
+// isCustom returns true if the format is the custom format
+func isCustom(format string) bool {
+	return strings.EqualFold(format, Custom)
+}
+
+// checkMarshaler returns an error if the format is custom and no marshaler is registered
+func checkMarshaler(format string, m Marshaler) error {
+	if isCustom(format) && m == nil {
+		return errors.New("the custom format requires a marshaler registered with WithMarshaler")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// validateMaxInProcessAge checks the maximum age is not negative and there are in process files to finish
+func (cfg *Config) validateMaxInProcessAge() error {
+	if cfg.MaxInProcessAgeSeconds < 0 {
+		return errors.New("maxInProcessAgeSeconds must not be negative")
+	}
+	if cfg.MaxInProcessAgeSeconds > 0 && strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("maxInProcessAgeSeconds requires rotation as there is no in process file to finish")
+	}
+	return nil
+}
+
+// finishIfAged finishes the in process file of the writer if its first batch was written the maximum age
+// ago, it must be called holding the writer lock
+func (e *fileExporter) finishIfAged(w *fileWriter, now time.Time) error {
+	if e.maxInProcessAge == 0 || w.opened.IsZero() || now.Sub(w.opened) < e.maxInProcessAge {
+		return nil
+	}
+	f, ok := e.pendingInProcess(w)
+	if !ok {
+		w.opened = time.Time{}
+		return nil
+	}
+	e.debug("finishing aged inprocess file", zap.String("file", f), zap.Duration("age", now.Sub(w.opened)))
+	return e.finishFile(w, f)
+}
@@ -0,0 +1,302 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	// TemporalityDelta writes the sums and histograms with delta temporality
+	TemporalityDelta = "delta"
+	// TemporalityCumulative writes the sums and histograms with cumulative temporality
+	TemporalityCumulative = "cumulative"
+
+	// the time after which the state of a stream that has not received any point is discarded
+	streamStateTTL = 10 * time.Minute
+)
+
+// MetricsTransformConfig changes the metrics before they are written
+type MetricsTransformConfig struct {
+	// Temporality converts the sums and histograms to delta or cumulative temporality, empty keeps the
+	// temporality received; exponential histograms, gauges and summaries are not converted
+	Temporality string `mapstructure:"temporality"`
+	// DropExemplars removes the exemplars of all the data points
+	DropExemplars bool `mapstructure:"dropExemplars"`
+	// DropHistogramBuckets removes the buckets of the histograms, keeping their count, sum, min and max
+	DropHistogramBuckets bool `mapstructure:"dropHistogramBuckets"`
+}
+
+// Validate checks if the metrics transform configuration is valid
+func (cfg *MetricsTransformConfig) Validate() error {
+	switch strings.ToLower(cfg.Temporality) {
+	case "", TemporalityDelta, TemporalityCumulative:
+		return nil
+	default:
+		return fmt.Errorf("invalid metricsTransform temporality [%s], valid values are [ %s or %s ]", cfg.Temporality, TemporalityDelta, TemporalityCumulative)
+	}
+}
+
+// metricsTransform is the resolved metrics transform configuration with the state of the converted streams
+type metricsTransform struct {
+	temporality   pmetric.AggregationTemporality
+	dropExemplars bool
+	dropBuckets   bool
+	// the last point of each stream, keyed by resource, scope, metric and point attributes
+	mutex     sync.Mutex
+	streams   map[string]*streamState
+	lastSweep time.Time
+}
+
+// streamState is the last point seen of a stream, the cumulative values when converting to delta or the
+// running totals when converting to cumulative
+type streamState struct {
+	start   pcommon.Timestamp
+	time    pcommon.Timestamp
+	int     int64
+	double  float64
+	count   uint64
+	sum     float64
+	buckets []uint64
+	bounds  []float64
+	seen    time.Time
+}
+
+func newMetricsTransform(cfg *MetricsTransformConfig) *metricsTransform {
+	if cfg == nil {
+		return nil
+	}
+	t := &metricsTransform{
+		dropExemplars: cfg.DropExemplars,
+		dropBuckets:   cfg.DropHistogramBuckets,
+		streams:       make(map[string]*streamState),
+		lastSweep:     time.Now(),
+	}
+	switch strings.ToLower(cfg.Temporality) {
+	case TemporalityDelta:
+		t.temporality = pmetric.AggregationTemporalityDelta
+	case TemporalityCumulative:
+		t.temporality = pmetric.AggregationTemporalityCumulative
+	}
+	return t
+}
+
+// transformMetrics returns a copy of the metrics with the temporality converted and the exemplars or
+// histogram buckets removed as configured, the passed in metrics are not modified
+func (e *fileExporter) transformMetrics(md pmetric.Metrics) pmetric.Metrics {
+	t := e.metricsTransform
+	if t == nil {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	out.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		resource := attributesJSON(rm.Resource().Attributes())
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			scope := resource + "\x00" + sm.Scope().Name() + "\x00" + sm.Scope().Version()
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				return t.apply(scope+"\x00"+metric.Name(), metric, now) == 0
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	t.sweep(now)
+	return out
+}
+
+// apply transforms the metric and returns the number of data points left
+func (t *metricsTransform) apply(key string, metric pmetric.Metric, now time.Time) int {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		t.dropNumberExemplars(metric.Gauge().DataPoints())
+		return metric.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		sum := metric.Sum()
+		if t.converts(sum.AggregationTemporality()) {
+			sum.DataPoints().RemoveIf(func(p pmetric.NumberDataPoint) bool {
+				return !t.convertNumber(key+"\x00"+attributesJSON(p.Attributes()), p, sum.IsMonotonic(), now)
+			})
+			sum.SetAggregationTemporality(t.temporality)
+		}
+		t.dropNumberExemplars(sum.DataPoints())
+		return sum.DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		histogram := metric.Histogram()
+		if t.converts(histogram.AggregationTemporality()) {
+			histogram.DataPoints().RemoveIf(func(p pmetric.HistogramDataPoint) bool {
+				return !t.convertHistogram(key+"\x00"+attributesJSON(p.Attributes()), p, now)
+			})
+			histogram.SetAggregationTemporality(t.temporality)
+		}
+		for i := 0; i < histogram.DataPoints().Len(); i++ {
+			p := histogram.DataPoints().At(i)
+			if t.dropExemplars {
+				p.Exemplars().RemoveIf(func(pmetric.Exemplar) bool { return true })
+			}
+			if t.dropBuckets {
+				p.BucketCounts().FromRaw(nil)
+				p.ExplicitBounds().FromRaw(nil)
+			}
+		}
+		return histogram.DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		points := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < points.Len(); i++ {
+			p := points.At(i)
+			if t.dropExemplars {
+				p.Exemplars().RemoveIf(func(pmetric.Exemplar) bool { return true })
+			}
+			if t.dropBuckets {
+				p.Positive().BucketCounts().FromRaw(nil)
+				p.Negative().BucketCounts().FromRaw(nil)
+			}
+		}
+		return points.Len()
+	case pmetric.MetricTypeSummary:
+		return metric.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
+// converts returns true if the points with the temporality are converted
+func (t *metricsTransform) converts(temporality pmetric.AggregationTemporality) bool {
+	return t.temporality != pmetric.AggregationTemporalityUnspecified &&
+		temporality != pmetric.AggregationTemporalityUnspecified && temporality != t.temporality
+}
+
+func (t *metricsTransform) dropNumberExemplars(points pmetric.NumberDataPointSlice) {
+	if !t.dropExemplars {
+		return
+	}
+	for i := 0; i < points.Len(); i++ {
+		points.At(i).Exemplars().RemoveIf(func(pmetric.Exemplar) bool { return true })
+	}
+}
+
+// convertNumber converts the temporality of a sum data point, it returns false if the point is out of
+// order and must be dropped
+func (t *metricsTransform) convertNumber(key string, p pmetric.NumberDataPoint, monotonic bool, now time.Time) bool {
+	s, ok := t.streams[key]
+	if t.temporality == pmetric.AggregationTemporalityDelta {
+		if ok && p.Timestamp() <= s.time {
+			return false
+		}
+		current := &streamState{start: p.StartTimestamp(), time: p.Timestamp(), int: p.IntValue(), double: p.DoubleValue(), seen: now}
+		t.streams[key] = current
+		// the first point of a stream, or of a restarted stream, is the delta since its start time
+		if !ok || p.StartTimestamp() != s.start || (monotonic && (p.IntValue() < s.int || p.DoubleValue() < s.double)) {
+			return true
+		}
+		p.SetStartTimestamp(s.time)
+		if p.ValueType() == pmetric.NumberDataPointValueTypeInt {
+			p.SetIntValue(p.IntValue() - s.int)
+		} else {
+			p.SetDoubleValue(p.DoubleValue() - s.double)
+		}
+		return true
+	}
+	if !ok {
+		s = &streamState{start: p.StartTimestamp()}
+		t.streams[key] = s
+	}
+	s.int += p.IntValue()
+	s.double += p.DoubleValue()
+	s.time, s.seen = p.Timestamp(), now
+	p.SetStartTimestamp(s.start)
+	if p.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		p.SetIntValue(s.int)
+	} else {
+		p.SetDoubleValue(s.double)
+	}
+	return true
+}
+
+// convertHistogram converts the temporality of a histogram data point, it returns false if the point is
+// out of order and must be dropped; the min and max are removed as they cannot be converted
+func (t *metricsTransform) convertHistogram(key string, p pmetric.HistogramDataPoint, now time.Time) bool {
+	s, ok := t.streams[key]
+	buckets, bounds := p.BucketCounts().AsRaw(), p.ExplicitBounds().AsRaw()
+	p.RemoveMin()
+	p.RemoveMax()
+	if t.temporality == pmetric.AggregationTemporalityDelta {
+		if ok && p.Timestamp() <= s.time {
+			return false
+		}
+		t.streams[key] = &streamState{start: p.StartTimestamp(), time: p.Timestamp(), count: p.Count(), sum: p.Sum(), buckets: buckets, bounds: bounds, seen: now}
+		// the first point of a stream, or of a restarted stream, is the delta since its start time
+		if !ok || p.StartTimestamp() != s.start || p.Count() < s.count || !sameBuckets(s, buckets, bounds) {
+			return true
+		}
+		p.SetStartTimestamp(s.time)
+		p.SetCount(p.Count() - s.count)
+		if p.HasSum() {
+			p.SetSum(p.Sum() - s.sum)
+		}
+		delta := make([]uint64, len(buckets))
+		for i := range buckets {
+			delta[i] = buckets[i] - s.buckets[i]
+		}
+		p.BucketCounts().FromRaw(delta)
+		return true
+	}
+	if !ok || !sameBuckets(s, buckets, bounds) {
+		// the totals restart when the bucket layout changes
+		s = &streamState{start: p.StartTimestamp(), buckets: make([]uint64, len(buckets)), bounds: bounds}
+		t.streams[key] = s
+	}
+	s.count += p.Count()
+	s.sum += p.Sum()
+	for i := range buckets {
+		s.buckets[i] += buckets[i]
+	}
+	s.time, s.seen = p.Timestamp(), now
+	p.SetStartTimestamp(s.start)
+	p.SetCount(s.count)
+	if p.HasSum() {
+		p.SetSum(s.sum)
+	}
+	p.BucketCounts().FromRaw(append([]uint64(nil), s.buckets...))
+	return true
+}
+
+// sameBuckets returns true if the histogram has the bucket layout of the stream state
+func sameBuckets(s *streamState, buckets []uint64, bounds []float64) bool {
+	if len(s.buckets) != len(buckets) || len(s.bounds) != len(bounds) {
+		return false
+	}
+	for i := range bounds {
+		if s.bounds[i] != bounds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sweep discards the state of the streams that have not received any point for a while
+func (t *metricsTransform) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < streamStateTTL {
+		return
+	}
+	t.lastSweep = now
+	for key, s := range t.streams {
+		if now.Sub(s.seen) > streamStateTTL {
+			delete(t.streams, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	MirrorAll     = "all"
+	MirrorAny     = "any"
+	MirrorPrimary = "primary"
+)
+
+// validateMirrors checks the mirror paths are distinct from the path and from each other and the mirror policy is supported
+func (cfg *Config) validateMirrors() error {
+	switch strings.ToLower(cfg.MirrorPolicy) {
+	case "", MirrorAll, MirrorAny, MirrorPrimary:
+	default:
+		return fmt.Errorf("invalid mirrorPolicy [%s], valid values are [ %s, %s or %s ]", cfg.MirrorPolicy, MirrorAll, MirrorAny, MirrorPrimary)
+	}
+	seen := map[string]bool{filepath.Clean(cfg.Path): true}
+	for _, path := range cfg.MirrorPaths {
+		if len(strings.TrimSpace(path)) == 0 {
+			return fmt.Errorf("mirrorPaths must not contain empty paths")
+		}
+		if seen[filepath.Clean(path)] {
+			return fmt.Errorf("mirror path [%s] is already written to, mirror paths must be distinct from the path and from each other", path)
+		}
+		seen[filepath.Clean(path)] = true
+	}
+	return nil
+}
+
+// roots returns the path, or the fallback path while the path is unavailable, followed by the mirror paths
+func (e *fileExporter) roots() []string {
+	return append([]string{e.primaryRoot()}, e.mirrorPaths...)
+}
+
+// mirrorResult returns the error of a write to the roots according to the mirror policy, errs holds
+// the error of the write to each root; the failures that do not fail the write are logged
+func (e *fileExporter) mirrorResult(roots []string, errs []error) error {
+	var failed int
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	if len(roots) == 1 {
+		return errs[0]
+	}
+	var fail bool
+	switch e.mirrorPolicy {
+	case MirrorAny:
+		fail = failed == len(roots)
+	case MirrorPrimary:
+		fail = errs[0] != nil
+	default:
+		fail = true
+	}
+	if fail {
+		var result error
+		for i, err := range errs {
+			if err != nil {
+				result = multierr.Append(result, fmt.Errorf("failed to write to %s: %w", roots[i], err))
+			}
+		}
+		return result
+	}
+	for i, err := range errs {
+		if err != nil {
+			e.logger.Warn("failed to write to mirror, the write succeeds as per the mirror policy",
+				zap.String("path", roots[i]), zap.String("policy", e.mirrorPolicy), zap.Error(err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import "errors"
+
+// the mmap write mode is rejected by the configuration validation on windows
+const mmapSupported = false
+
+func mapFile(fsFile, int) ([]byte, error) {
+	return nil, errors.New("memory mapped files are not supported on windows")
+}
+
+func unmapFile([]byte) error {
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/multierr"
+)
+
+// formats returns the formats written by the exporter
+func (cfg *Config) formats() []string {
+	if len(cfg.Formats) > 0 {
+		return cfg.Formats
+	}
+	return []string{cfg.Format}
+}
+
+// validateFormats checks the configuration of every format written when several formats are written
+func (cfg *Config) validateFormats() error {
+	if len(cfg.Format) > 0 {
+		return errors.New("mention either format or formats")
+	}
+	seen := make(map[string]bool)
+	for _, format := range cfg.Formats {
+		if seen[strings.ToLower(format)] {
+			return fmt.Errorf("the format %s is listed more than once in formats", format)
+		}
+		seen[strings.ToLower(format)] = true
+		c := cfg.formatConfig(format)
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("invalid %s configuration: %w", format, err)
+		}
+	}
+	return nil
+}
+
+// formatConfig returns the configuration writing a single format of the formats, each format is written
+// to a sub directory of the path, mirror paths and dead letter path named after the format
+func (cfg *Config) formatConfig(format string) Config {
+	n := *cfg
+	n.Format, n.Formats = format, nil
+	if !isJSONFormat(format) {
+		// the json options apply to the json formats of the formats
+		n.PrettyPrint, n.RecordSeparator, n.JSONLayout = false, "", ""
+	}
+	dir := strings.ToLower(format)
+	n.Path = filepath.Join(cfg.Path, dir)
+	n.MirrorPaths = make([]string, len(cfg.MirrorPaths))
+	for i, path := range cfg.MirrorPaths {
+		n.MirrorPaths[i] = filepath.Join(path, dir)
+	}
+	if cfg.Fallback != nil {
+		fallback := *cfg.Fallback
+		fallback.Path = filepath.Join(fallback.Path, dir)
+		n.Fallback = &fallback
+	}
+	if len(cfg.DeadLetterPath) > 0 {
+		n.DeadLetterPath = filepath.Join(cfg.DeadLetterPath, dir)
+	}
+	return n
+}
+
+// newMultiFormatExporter creates the exporter of the first format with the exporters of the other formats
+// as siblings, the batches are written to every format in turn
+func newMultiFormatExporter(cfg *Config, set component.ExporterCreateSettings, marshaler Marshaler, onRotate OnRotateFunc) *fileExporter {
+	first := cfg.formatConfig(cfg.Formats[0])
+	e := newFileExporter(&first, set, marshaler, onRotate)
+	for _, format := range cfg.Formats[1:] {
+		c := cfg.formatConfig(format)
+		e.siblings = append(e.siblings, newFileExporter(&c, set, marshaler, onRotate))
+	}
+	return e
+}
+
+// eachSibling calls f for the exporters of the other formats, all are called and their errors combined
+func (e *fileExporter) eachSibling(f func(s *fileExporter) error) error {
+	var errs error
+	for _, s := range e.siblings {
+		errs = multierr.Append(errs, f(s))
+	}
+	return errs
+}
+
+// startSiblings starts the exporters of the other formats
+func (e *fileExporter) startSiblings(ctx context.Context, host component.Host) error {
+	return e.eachSibling(func(s *fileExporter) error {
+		return s.Start(ctx, host)
+	})
+}
+
+// mergeStatus adds the status of the exporters of the other formats to the status
+func (e *fileExporter) mergeStatus(status Status) Status {
+	for _, s := range e.siblings {
+		other := s.Status()
+		status.InProcessFiles += other.InProcessFiles
+		status.InProcessBytes += other.InProcessBytes
+		status.PendingFiles += other.PendingFiles
+		status.Fallback = status.Fallback || other.Fallback
+		if other.LastRotation.After(status.LastRotation) {
+			status.LastRotation = other.LastRotation
+		}
+		if other.LastErrorTime.After(status.LastErrorTime) {
+			status.LastError, status.LastErrorTime = other.LastError, other.LastErrorTime
+		}
+	}
+	return status
+}
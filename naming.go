@@ -0,0 +1,248 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultFileNameTemplate is the historical <timestamp>.<ext> final file name with a sequence number
+	// so that files finished within the same timestamp do not collide
+	defaultFileNameTemplate = "{timestamp}-{seq}.{ext}"
+	// the number of sequence numbers tried to find a free final file name
+	maxNameAttempts = 1000
+	// signal names used in the {signal} placeholder
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+	signalMixed   = "mixed"
+
+	// TimestampEpochMillis and TimestampEpochNanos name the files with the unix time in milliseconds or
+	// nanoseconds, TimestampRFC3339 and TimestampRFC3339Nano with the RFC 3339 time, the nanoseconds
+	// always having nine digits so the names sort in time order
+	TimestampEpochMillis = "epochMillis"
+	TimestampEpochNanos  = "epochNanos"
+	TimestampRFC3339     = "rfc3339"
+	TimestampRFC3339Nano = "rfc3339nano"
+)
+
+// the layouts of the named timestamp formats
+var timestampLayouts = map[string]string{
+	strings.ToLower(TimestampRFC3339):     time.RFC3339,
+	strings.ToLower(TimestampRFC3339Nano): "2006-01-02T15:04:05.000000000Z07:00",
+}
+
+// the placeholders that can be used in a file name template
+var fileNamePlaceholders = map[string]bool{
+	"signal":    true,
+	"hostname":  true,
+	"timestamp": true,
+	"seq":       true,
+	"bucket":    true,
+	"ext":       true,
+	"count":     true,
+	"bytes":     true,
+	"instance":  true,
+	"shard":     true,
+}
+
+var placeholderRegex = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// validateFileNameTemplate checks the template only uses known placeholders and does not try to
+// escape the output path
+func validateFileNameTemplate(template string) error {
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("invalid fileNameTemplate [%s], it must not contain path separators", template)
+	}
+	for _, match := range placeholderRegex.FindAllStringSubmatch(template, -1) {
+		if !fileNamePlaceholders[match[1]] {
+			return fmt.Errorf("invalid fileNameTemplate [%s], unknown placeholder {%s}", template, match[1])
+		}
+	}
+	return nil
+}
+
+// validateTimestampLayout checks the timestamp of the file names cannot produce invalid file names
+func (cfg *Config) validateTimestampLayout() error {
+	if _, err := time.LoadLocation(cfg.TimestampTimezone); err != nil {
+		return fmt.Errorf("invalid timestampTimezone [%s]: %w", cfg.TimestampTimezone, err)
+	}
+	sample := formatTimestamp(time.Now(), cfg.TimestampLayout, time.UTC)
+	if strings.ContainsAny(sample, `/\`) {
+		return fmt.Errorf("invalid timestampLayout [%s], it must not produce path separators", cfg.TimestampLayout)
+	}
+	if runtime.GOOS == "windows" && strings.ContainsAny(sample, `:*?"<>|`) {
+		return fmt.Errorf("invalid timestampLayout [%s], it produces characters not allowed in windows file names", cfg.TimestampLayout)
+	}
+	return nil
+}
+
+// formatTimestamp formats the time of the {timestamp} placeholder in the location, layout is a named
+// format or a go time layout and defaults to timeFormat
+func formatTimestamp(t time.Time, layout string, loc *time.Location) string {
+	switch {
+	case len(layout) == 0:
+		layout = timeFormat
+	case strings.EqualFold(layout, TimestampEpochMillis):
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case strings.EqualFold(layout, TimestampEpochNanos):
+		return strconv.FormatInt(t.UnixNano(), 10)
+	case len(timestampLayouts[strings.ToLower(layout)]) > 0:
+		layout = timestampLayouts[strings.ToLower(layout)]
+	}
+	return t.In(loc).Format(layout)
+}
+
+// fileNameValues are the values of the placeholders of a finished file name, count is the number of records
+// and bytes the size of the file before it is compressed or encrypted
+type fileNameValues struct {
+	signal    string
+	hostname  string
+	timestamp string
+	bucket    string
+	seq       int64
+	count     int64
+	bytes     int64
+	instance  string
+	shard     int
+	ext       string
+}
+
+// formatFileName resolves the placeholders in the file name template
+func formatFileName(template string, v fileNameValues) string {
+	return placeholderRegex.ReplaceAllStringFunc(template, func(p string) string {
+		switch p {
+		case "{signal}":
+			return v.signal
+		case "{hostname}":
+			return v.hostname
+		case "{timestamp}":
+			return v.timestamp
+		case "{bucket}":
+			return v.bucket
+		case "{seq}":
+			return fmt.Sprintf("%06d", v.seq)
+		case "{count}":
+			return strconv.FormatInt(v.count, 10)
+		case "{bytes}":
+			return strconv.FormatInt(v.bytes, 10)
+		case "{instance}":
+			return v.instance
+		case "{shard}":
+			return strconv.Itoa(v.shard)
+		case "{ext}":
+			return v.ext
+		}
+		return p
+	})
+}
+
+// isSignalDir returns true if each signal is written to its own sub directory, parquet files have a single
+// schema so they are always written this way, as are the signals of pipelines sharing the exporter unless
+// they are interleaved
+func (e *fileExporter) isSignalDir() bool {
+	return e.signalDirs || e.isParquet() || e.mixedPipelines
+}
+
+// signalName returns the value for the {signal} placeholder from the signals written to a file,
+// files holding more than one signal are named as mixed
+func signalName(signals map[string]bool) string {
+	if len(signals) == 1 {
+		for s := range signals {
+			return s
+		}
+	}
+	return signalMixed
+}
+
+// FileExistsError is returned when a finished file cannot be named as a file of the same name already
+// exists, existing files are never overwritten
+type FileExistsError struct {
+	Path string
+}
+
+func (e *FileExistsError) Error() string {
+	return fmt.Sprintf("finished file %s already exists and is not overwritten", e.Path)
+}
+
+// renameFinished renames the file of the shard holding count records to its final name in the directory followed by
+// the suffix of its processing, incrementing
+// the sequence number until a free name is found; if the template has no sequence number a FileExistsError
+// is returned when the name is already taken. The sequence number is only used up by a rename or a name
+// already taken, so that a failed rename leaves no gap in the numbers
+func (e *fileExporter) renameFinished(f, dir, signal string, t time.Time, bucket string, seq *int64, shard int, count int64, ext, suffix string) (string, error) {
+	v := fileNameValues{
+		signal:    signal,
+		hostname:  e.hostname,
+		timestamp: formatTimestamp(t, e.timestampLayout, e.timestampZone),
+		bucket:    bucket,
+		count:     count,
+		instance:  e.instance,
+		shard:     shard,
+		ext:       ext,
+	}
+	if strings.Contains(e.fileNameTemplate, "{bytes}") {
+		stat, err := e.fs.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		v.bytes = stat.Size()
+	}
+	retry := strings.Contains(e.fileNameTemplate, "{seq}")
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		v.seq = *seq + 1
+		name := filepath.Join(dir, formatFileName(e.fileNameTemplate, v)+suffix)
+		err := e.checkHandoffName(name)
+		if err == nil {
+			err = e.renameNoReplace(f, name)
+		}
+		var exists *FileExistsError
+		if err == nil || errors.As(err, &exists) {
+			*seq = v.seq
+		}
+		if !errors.As(err, &exists) || !retry {
+			return name, err
+		}
+		e.logger.Warn("finished file name is already taken, trying the next sequence number", zap.String("file", name))
+	}
+	return "", fmt.Errorf("no free finished file name found for %s after %d attempts", f, maxNameAttempts)
+}
+
+// renameNoReplace renames the file failing with a FileExistsError if the new file already exists, the
+// file is hard linked to its new name so the check and the rename are atomic where hard links are supported;
+// if the old name cannot be removed the new name is removed so that the file never has two names
+func (e *fileExporter) renameNoReplace(oldName, newName string) error {
+	err := e.fs.Link(oldName, newName)
+	if err == nil {
+		if err = e.fs.Remove(oldName); err != nil {
+			return multierr.Append(err, e.fs.Remove(newName))
+		}
+		return nil
+	}
+	if os.IsExist(err) {
+		return &FileExistsError{Path: newName}
+	}
+	// the file system does not support hard links
+	if _, statErr := e.fs.Lstat(newName); statErr == nil {
+		return &FileExistsError{Path: newName}
+	}
+	return e.fs.Rename(oldName, newName)
+}
@@ -0,0 +1,97 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestRenameNoReplaceKeepsOneName(t *testing.T) {
+	cfg := testConfig(t, 10)
+	e := newTestExporter(t, cfg)
+	fsys := newFaultFS(osFS{})
+	e.fs = fsys
+	startExporter(t, e)
+	if err := writeLine(e, `{"a":1}`); err != nil {
+		t.Fatal(err)
+	}
+	// the in process file is linked to its finished name but cannot be removed
+	fsys.inject(fault{op: faultRemove, pattern: e.inProcessSuffix, err: syscall.EIO, times: 1})
+	if err := writeLine(e, `{"a":2}`); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the failure to remove the in process file, got %v", err)
+	}
+	if files := finishedFilesOf(t, e); len(files) != 0 {
+		t.Fatalf("the file must keep its in process name only, finished files %v", files)
+	}
+	inProcess := filepath.Join(cfg.Path, e.inProcessSuffix)
+	if content := readFile(t, inProcess); content != `{"a":1}` {
+		t.Fatalf("unexpected in process file content %q", content)
+	}
+	// the file is finished once the in process file can be removed
+	if err := writeLine(e, `{"a":2}`); err != nil {
+		t.Fatal(err)
+	}
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || readFile(t, files[0]) != `{"a":1}` {
+		t.Fatalf("expected one finished file holding the first batch, got %v", files)
+	}
+	if content := readFile(t, inProcess); content != `{"a":2}` {
+		t.Fatalf("unexpected in process file content %q", content)
+	}
+}
+
+func TestFailedRenameKeepsSequenceContiguous(t *testing.T) {
+	cfg := testConfig(t, 10)
+	cfg.FileNameTemplate = "file-{seq}.{ext}"
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	writeLines(t, e, `{"a":1}`)
+	failRename(fsys)
+	if err := writeLine(e, `{"a":2}`); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the rename to fail, got %v", err)
+	}
+	fsys.clear()
+	writeLines(t, e, `{"a":2}`, `{"a":3}`)
+	var names []string
+	for _, f := range finishedFilesOf(t, e) {
+		names = append(names, filepath.Base(f))
+	}
+	if len(names) != 2 || names[0] != "file-000001.json" || names[1] != "file-000002.json" {
+		t.Fatalf("expected the finished files to be numbered without gap, got %v", names)
+	}
+}
+
+func TestCountAndBytesOfCompressedFile(t *testing.T) {
+	cfg := gzipConfig(t, 16)
+	cfg.FileNameTemplate = "file-{seq}-{count}-{bytes}.{ext}"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e := startTestExporter(t, cfg)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`, `{"a":3}`, `{"a":4}`, `{"a":5}`)
+	files := finishedFilesOf(t, e)
+	if len(files) != 2 {
+		t.Fatalf("expected two finished files, got %v", files)
+	}
+	for i, f := range files {
+		stat, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the records are counted per file and the size is the size of the compressed file
+		if want := fmt.Sprintf("file-%06d-2-%d.json.gz", i+1, stat.Size()); filepath.Base(f) != want {
+			t.Fatalf("expected the finished file %s, got %s", want, filepath.Base(f))
+		}
+	}
+}
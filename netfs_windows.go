@@ -0,0 +1,35 @@
+//go:build windows
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"syscall"
+)
+
+// the errors returned when the share of a path is unavailable
+const (
+	errorBadNetPath         syscall.Errno = 53
+	errorUnexpectedNetError syscall.Errno = 59
+	errorNetNameDeleted     syscall.Errno = 64
+	errorBadNetName         syscall.Errno = 67
+	errorNetworkUnreachable syscall.Errno = 1231
+)
+
+// isMountLost returns true if the error is returned by a network share that is unavailable
+func isMountLost(err error) bool {
+	for _, errno := range []syscall.Errno{errorBadNetPath, errorUnexpectedNetError, errorNetNameDeleted, errorBadNetName, errorNetworkUnreachable} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,337 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// the schemes of the notify endpoints
+	notifyUnix     = "unix://"
+	notifyGrpc     = "grpc://"
+	notifyGrpcUnix = "grpc+unix://"
+	// notifyMethod is the gRPC method called with a google.protobuf.Struct describing the finished file,
+	// pilot replies with a google.protobuf.Empty
+	notifyMethod = "/pilot.v1.FileNotifier/FileFinished"
+
+	defaultNotifyQueueSize = 1000
+	defaultNotifyRetry     = time.Second
+	defaultNotifyTimeout   = 5 * time.Second
+	// the longest wait between two attempts to reconnect to pilot
+	maxNotifyRetry = 30 * time.Second
+)
+
+// NotifyConfig sends the path of every finished file to pilot as soon as it is finished, so that pilot
+// does not have to poll the output directory
+type NotifyConfig struct {
+	// Endpoint is unix:///<socket> to write every notification as a line of JSON to a unix domain socket,
+	// grpc://<host>:<port> or grpc+unix:///<socket> to call the FileFinished method of pilot
+	Endpoint string `mapstructure:"endpoint"`
+	// QueueSize is the number of notifications held while pilot is unreachable, the oldest are dropped
+	// once it is full; it defaults to 1000
+	QueueSize int `mapstructure:"queueSize"`
+	// RetryInterval is the first wait before reconnecting to pilot, doubled up to 30 seconds while it is
+	// unreachable; it defaults to one second
+	RetryInterval time.Duration `mapstructure:"retryInterval"`
+	// Timeout bounds the connection and the sending of a notification, it defaults to five seconds
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// validateNotify checks the endpoint scheme is supported and the queue and durations are not negative
+func (cfg *Config) validateNotify() error {
+	if cfg.Notify == nil {
+		return nil
+	}
+	if _, _, err := parseNotifyEndpoint(cfg.Notify.Endpoint); err != nil {
+		return err
+	}
+	if cfg.Notify.QueueSize < 0 || cfg.Notify.RetryInterval < 0 || cfg.Notify.Timeout < 0 {
+		return errors.New("notify queueSize, retryInterval and timeout must not be negative")
+	}
+	return nil
+}
+
+// parseNotifyEndpoint returns the scheme of the endpoint and the address to connect to
+func parseNotifyEndpoint(endpoint string) (string, string, error) {
+	for _, scheme := range []string{notifyUnix, notifyGrpc, notifyGrpcUnix} {
+		if strings.HasPrefix(endpoint, scheme) {
+			address := strings.TrimPrefix(endpoint, scheme)
+			if len(address) == 0 {
+				break
+			}
+			if scheme == notifyGrpcUnix {
+				return scheme, "unix://" + address, nil
+			}
+			return scheme, address, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid notify endpoint [%s], it must be %s<socket>, %s<host>:<port> or %s<socket>", endpoint, notifyUnix, notifyGrpc, notifyGrpcUnix)
+}
+
+// fileNotification is the notification of a finished file sent to pilot
+type fileNotification struct {
+	Path    string    `json:"path"`
+	Signals []string  `json:"signals,omitempty"`
+	Records int64     `json:"records,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// notifyConn is a connection to pilot
+type notifyConn interface {
+	send(ctx context.Context, n fileNotification) error
+	close() error
+}
+
+// notifier queues the notifications of the finished files and sends them to pilot in the background,
+// reconnecting while pilot is unreachable
+type notifier struct {
+	scheme  string
+	address string
+	retry   time.Duration
+	timeout time.Duration
+	queue   chan fileNotification
+	stop    chan struct{}
+	stopped chan struct{}
+	// started is true once the notifications are sent in the background
+	started bool
+	dropped uint64
+	logger  *zap.Logger
+}
+
+func newNotifier(cfg *NotifyConfig, logger *zap.Logger) *notifier {
+	if cfg == nil {
+		return nil
+	}
+	// an invalid endpoint is rejected by the validation of the configuration
+	scheme, address, _ := parseNotifyEndpoint(cfg.Endpoint)
+	size := cfg.QueueSize
+	if size == 0 {
+		size = defaultNotifyQueueSize
+	}
+	n := &notifier{
+		scheme:  scheme,
+		address: address,
+		retry:   cfg.RetryInterval,
+		timeout: cfg.Timeout,
+		queue:   make(chan fileNotification, size),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+	if n.retry == 0 {
+		n.retry = defaultNotifyRetry
+	}
+	if n.timeout == 0 {
+		n.timeout = defaultNotifyTimeout
+	}
+	return n
+}
+
+// start sends the queued notifications in the background until the notifier is stopped
+func (n *notifier) start() {
+	if n != nil {
+		n.started = true
+		go n.run()
+	}
+}
+
+// notify queues the notification of the finished file, dropping the oldest notification if the queue is full
+func (n *notifier) notify(file RotatedFile) {
+	if n == nil {
+		return
+	}
+	notification := fileNotification{Path: file.Path, Signals: file.Signals, Records: file.Records, Time: time.Now().UTC()}
+	for {
+		select {
+		case n.queue <- notification:
+			return
+		default:
+		}
+		select {
+		case old := <-n.queue:
+			// the drops are logged at increasing intervals so that an unreachable pilot does not flood the logs
+			if dropped := atomic.AddUint64(&n.dropped, 1); dropped&(dropped-1) == 0 {
+				n.logger.Warn("notification queue is full, dropping the oldest notification",
+					zap.String("file", old.Path), zap.Uint64("dropped", dropped))
+			}
+		default:
+		}
+	}
+}
+
+// run sends the notifications in order, a notification that cannot be sent is retried once reconnected
+func (n *notifier) run() {
+	defer close(n.stopped)
+	var conn notifyConn
+	var pending *fileNotification
+	retry, failing := n.retry, false
+	for {
+		if pending == nil {
+			select {
+			case notification := <-n.queue:
+				pending = &notification
+			case <-n.stop:
+				n.flush(conn, nil)
+				return
+			}
+		}
+		if conn == nil {
+			c, err := n.dial()
+			if err != nil {
+				if !failing {
+					n.logger.Warn("failed to connect to pilot, the notifications are queued until it is reachable",
+						zap.String("address", n.address), zap.Error(err))
+					failing = true
+				}
+				select {
+				case <-time.After(retry):
+				case <-n.stop:
+					n.flush(nil, pending)
+					return
+				}
+				if retry *= 2; retry > maxNotifyRetry {
+					retry = maxNotifyRetry
+				}
+				continue
+			}
+			if failing {
+				n.logger.Info("reconnected to pilot", zap.String("address", n.address))
+				failing = false
+			}
+			conn, retry = c, n.retry
+		}
+		if err := n.send(conn, *pending); err != nil {
+			n.logger.Warn("failed to notify pilot of finished file, reconnecting", zap.String("file", pending.Path), zap.Error(err))
+			_ = conn.close()
+			conn = nil
+			continue
+		}
+		pending = nil
+	}
+}
+
+// flush sends the notifications left on the connection when the notifier is stopped and closes it, the
+// notifications that cannot be sent are counted in the log
+func (n *notifier) flush(conn notifyConn, pending *fileNotification) {
+	var left []fileNotification
+	if pending != nil {
+		left = append(left, *pending)
+	}
+	for len(n.queue) > 0 {
+		left = append(left, <-n.queue)
+	}
+	if conn != nil {
+		for len(left) > 0 && n.send(conn, left[0]) == nil {
+			left = left[1:]
+		}
+		_ = conn.close()
+	}
+	if len(left) > 0 {
+		n.logger.Warn("notifications not sent to pilot on shutdown", zap.Int("count", len(left)))
+	}
+}
+
+// shutdown stops the notifier once the queued notifications are sent or the context is done
+func (n *notifier) shutdown(ctx context.Context) error {
+	if n == nil || !n.started {
+		return nil
+	}
+	close(n.stop)
+	select {
+	case <-n.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *notifier) dial() (notifyConn, error) {
+	if n.scheme == notifyUnix {
+		conn, err := net.DialTimeout("unix", n.address, n.timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &socketConn{conn: conn, timeout: n.timeout}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, n.address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	return &grpcConn{conn: conn}, nil
+}
+
+func (n *notifier) send(conn notifyConn, notification fileNotification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	return conn.send(ctx, notification)
+}
+
+// socketConn writes the notifications as lines of JSON to a unix domain socket
+type socketConn struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (c *socketConn) send(_ context.Context, n fileNotification) error {
+	line, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	if err = c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err = c.conn.Write(append(line, '\n'))
+	return err
+}
+
+func (c *socketConn) close() error {
+	return c.conn.Close()
+}
+
+// grpcConn calls the FileFinished method of pilot for every notification
+type grpcConn struct {
+	conn *grpc.ClientConn
+}
+
+func (c *grpcConn) send(ctx context.Context, n fileNotification) error {
+	signals := make([]interface{}, len(n.Signals))
+	for i, signal := range n.Signals {
+		signals[i] = signal
+	}
+	request, err := structpb.NewStruct(map[string]interface{}{
+		"path":    n.Path,
+		"signals": signals,
+		"records": n.Records,
+		"time":    n.Time.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return err
+	}
+	return c.conn.Invoke(ctx, notifyMethod, request, &emptypb.Empty{})
+}
+
+func (c *grpcConn) close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,134 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the time the on rotate command can run when no timeout is configured
+const defaultOnRotateTimeout = time.Minute
+
+// OnRotateConfig defines the command run for every finished file once it is post processed and handed off,
+// or for every bundle when the finished files are bundled
+type OnRotateConfig struct {
+	// Command is the executable run, the path of the finished file is passed after the Args
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	// Timeout is the time after which the command is killed, it defaults to one minute
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks the command of the hook is defined
+func (cfg *OnRotateConfig) Validate() error {
+	if len(cfg.Command) == 0 {
+		return errors.New("onRotate requires a command to be defined")
+	}
+	if cfg.Timeout < 0 {
+		return errors.New("onRotate timeout must not be negative")
+	}
+	return nil
+}
+
+// RotatedFile describes a finished file passed to the on rotate callback, the signals and records are
+// empty for bundles
+type RotatedFile struct {
+	Path    string
+	Signals []string
+	Records int64
+}
+
+// OnRotateFunc is called for every finished file, it is called from a background goroutine so the
+// writes are not held up but it must return for the exporter to shut down
+type OnRotateFunc func(RotatedFile)
+
+// WithOnRotate registers the function called for every finished file of the exporters, for instance to
+// start an upload without polling the output directory
+func WithOnRotate(fn OnRotateFunc) FactoryOption {
+	return func(f *factory) {
+		f.onRotate = fn
+	}
+}
+
+// rotateHook runs the on rotate command and callback
+type rotateHook struct {
+	command  string
+	args     []string
+	timeout  time.Duration
+	callback OnRotateFunc
+	// running tracks the hooks in progress so that shutdown waits for them
+	running sync.WaitGroup
+}
+
+func newRotateHook(cfg *OnRotateConfig, callback OnRotateFunc) *rotateHook {
+	if cfg == nil && callback == nil {
+		return nil
+	}
+	h := &rotateHook{callback: callback}
+	if cfg != nil {
+		h.command, h.args, h.timeout = cfg.Command, cfg.Args, cfg.Timeout
+		if h.timeout == 0 {
+			h.timeout = defaultOnRotateTimeout
+		}
+	}
+	return h
+}
+
+// notifyRotated notifies pilot and runs the hook for the finished file in the background, a failed command is logged
+func (e *fileExporter) notifyRotated(file RotatedFile) {
+	e.notifier.notify(file)
+	e.emitRotated(file)
+	h := e.rotateHook
+	if h == nil {
+		return
+	}
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		if h.callback != nil {
+			h.callback(file)
+		}
+		if len(h.command) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+		defer cancel()
+		args := append(h.args[:len(h.args):len(h.args)], file.Path)
+		if out, err := exec.CommandContext(ctx, h.command, args...).CombinedOutput(); err != nil {
+			e.logger.Error("onRotate command failed", zap.String("file", file.Path), zap.String("command", h.command),
+				zap.ByteString("output", out), zap.Error(err))
+			return
+		}
+		e.debug("onRotate command completed", zap.String("file", file.Path), zap.String("command", h.command))
+	}()
+}
+
+// waitRotateHooks waits for the hooks in progress unless the context is done first
+func (e *fileExporter) waitRotateHooks(ctx context.Context) error {
+	if e.rotateHook == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		e.rotateHook.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
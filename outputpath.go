@@ -0,0 +1,109 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// checkOutputPaths creates the path, the mirror paths and the dead letter path and checks they can be
+// written to, so that a bad path fails the start of the exporter instead of every write
+func (e *fileExporter) checkOutputPaths() error {
+	paths := e.roots()
+	if e.deadLetter != nil {
+		paths = append(paths, e.deadLetter.path)
+	}
+	for _, path := range paths {
+		if err := checkOutputPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOutputPath creates the path if it does not exist and runs a self-test writing, renaming and deleting a
+// probe file in it, the errors describe the file system, free space and permissions of the path
+func checkOutputPath(path string) error {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		return fmt.Errorf("output path %s is a file, it must be a directory", path)
+	}
+	if os.IsNotExist(err) {
+		if err = os.MkdirAll(path, 0755); err != nil {
+			return fmt.Errorf("failed to create output path %s, check its parent directories are writable (%s): %w", path, pathDiagnostics(path), err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to access output path %s (%s): %w", path, pathDiagnostics(path), err)
+	}
+	probe, err := os.CreateTemp(path, ".probe-*")
+	if err != nil {
+		if errors.Is(err, syscall.EROFS) {
+			return fmt.Errorf("output path %s is on a read-only file system (%s): %w", path, pathDiagnostics(path), err)
+		}
+		return fmt.Errorf("output path %s is not writable, check its permissions (%s): %w", path, pathDiagnostics(path), err)
+	}
+	name := probe.Name()
+	_, err = probe.Write([]byte("probe\n"))
+	if err == nil {
+		err = probe.Sync()
+	}
+	if cErr := probe.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return fmt.Errorf("self-test failed to write to output path %s (%s): %w", path, pathDiagnostics(path), err)
+	}
+	// the probe keeps its prefix so that it is never taken for a finished file
+	renamed := name + "-renamed"
+	if err = os.Rename(name, renamed); err != nil {
+		_ = os.Remove(name)
+		return fmt.Errorf("self-test failed to rename a file in output path %s (%s): %w", path, pathDiagnostics(path), err)
+	}
+	if err = os.Remove(renamed); err != nil {
+		return fmt.Errorf("self-test failed to delete a file in output path %s (%s): %w", path, pathDiagnostics(path), err)
+	}
+	return nil
+}
+
+// pathDiagnostics describes the file system, free space and permissions of the path, or of its closest
+// existing parent, for the errors of the self-test; the details that cannot be read are left out
+func pathDiagnostics(path string) string {
+	dir := path
+	info, err := os.Stat(dir)
+	for err != nil && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+		info, err = os.Stat(dir)
+	}
+	var details []string
+	if dir != path {
+		details = append(details, "closest existing parent "+dir)
+	}
+	if fs := fsType(dir); len(fs) > 0 {
+		details = append(details, "file system "+fs)
+	}
+	if free, err := diskFree(dir); err == nil {
+		details = append(details, fmt.Sprintf("%d MB free", free/(1024*1024)))
+	}
+	if info != nil {
+		details = append(details, "mode "+info.Mode().String())
+		if owner := ownerOf(info); len(owner) > 0 {
+			details = append(details, owner)
+		}
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		details = append(details, fmt.Sprintf("exporter uid %d gid %d", uid, os.Getgid()))
+	}
+	return strings.Join(details, ", ")
+}
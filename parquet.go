@@ -0,0 +1,431 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
+)
+
+// the parquet files are written with a minimal encoder: every column is required, values are PLAIN encoded
+// and uncompressed, each batch is written as a row group and the footer is written when the in process
+// file is finalized
+
+const (
+	parquetMagic     = "PAR1"
+	parquetCreatedBy = "southwinds file-exporter"
+
+	// parquet physical types
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+
+	// parquet converted type for strings
+	parquetUTF8 int32 = 0
+
+	// parquet encodings
+	parquetPlain int32 = 0
+	parquetRLE   int32 = 3
+
+	// thrift compact protocol types
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// isParquet returns true if the exporter writes parquet files
+func (e *fileExporter) isParquet() bool {
+	return e.format == formatParquet
+}
+
+// parquetColumn holds the values of a column of a flattened telemetry table
+type parquetColumn struct {
+	name   string
+	ptype  int32
+	ints   []int64
+	floats []float64
+	bytes  [][]byte
+}
+
+// parquetTable is a flattened, columnar representation of a telemetry batch
+type parquetTable struct {
+	columns []*parquetColumn
+	index   map[string]*parquetColumn
+	rows    int64
+}
+
+func newParquetTable(columns ...*parquetColumn) *parquetTable {
+	t := &parquetTable{columns: columns, index: make(map[string]*parquetColumn)}
+	for _, c := range columns {
+		t.index[c.name] = c
+	}
+	return t
+}
+
+func int64Column(name string) *parquetColumn { return &parquetColumn{name: name, ptype: parquetInt64} }
+func doubleColumn(name string) *parquetColumn {
+	return &parquetColumn{name: name, ptype: parquetDouble}
+}
+func stringColumn(name string) *parquetColumn {
+	return &parquetColumn{name: name, ptype: parquetByteArray}
+}
+
+func (t *parquetTable) setInt(name string, v int64) {
+	t.index[name].ints = append(t.index[name].ints, v)
+}
+func (t *parquetTable) setFloat(name string, v float64) {
+	t.index[name].floats = append(t.index[name].floats, v)
+}
+func (t *parquetTable) setString(name string, v string) {
+	t.index[name].bytes = append(t.index[name].bytes, []byte(v))
+}
+
+// parquetColumnChunk describes a column chunk written to a parquet file
+type parquetColumnChunk struct {
+	name      string
+	ptype     int32
+	numValues int64
+	// offset of the chunk relative to the start of the row group
+	offset int64
+	size   int64
+}
+
+// parquetRowGroup describes a row group written to a parquet file
+type parquetRowGroup struct {
+	numRows int64
+	// offset of the row group in the file
+	offset  int64
+	size    int64
+	columns []parquetColumnChunk
+}
+
+// encode returns the column chunks of the table and the row group describing them
+func (t *parquetTable) encode() ([]byte, *parquetRowGroup) {
+	var data bytes.Buffer
+	rg := &parquetRowGroup{numRows: t.rows}
+	for _, c := range t.columns {
+		var values bytes.Buffer
+		var num int
+		switch c.ptype {
+		case parquetInt64:
+			num = len(c.ints)
+			for _, v := range c.ints {
+				_ = binary.Write(&values, binary.LittleEndian, v)
+			}
+		case parquetDouble:
+			num = len(c.floats)
+			for _, v := range c.floats {
+				_ = binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+			}
+		case parquetByteArray:
+			num = len(c.bytes)
+			for _, v := range c.bytes {
+				_ = binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+				values.Write(v)
+			}
+		}
+		header := newThriftWriter()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(values.Len()))
+		header.i32(3, int32(values.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(num))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+		chunk := parquetColumnChunk{name: c.name, ptype: c.ptype, numValues: int64(num), offset: int64(data.Len())}
+		data.Write(header.bytes())
+		data.Write(values.Bytes())
+		chunk.size = int64(data.Len()) - chunk.offset
+		rg.columns = append(rg.columns, chunk)
+	}
+	rg.size = int64(data.Len())
+	return data.Bytes(), rg
+}
+
+// appendParquetRowGroup appends the row group of the batch to the in process parquet file, writing the
+// parquet magic number first if the file is new
+func appendParquetRowGroup(w *fileWriter, b *batch) error {
+	if w.size == 0 {
+		if err := w.write([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+	rg := *b.rowGroup
+	rg.offset = w.size
+	if err := w.write(b.buf); err != nil {
+		return err
+	}
+	w.rowGroups = append(w.rowGroups, &rg)
+	return nil
+}
+
+// writeParquetFooter appends the parquet file metadata of the written row groups to the in process file, a
+// footer partially written is truncated so that the rotation can be retried
+func writeParquetFooter(fsys fileSystem, f string, rowGroups []*parquetRowGroup) error {
+	t := newThriftWriter()
+	t.i32(1, 1)
+	// the schema is taken from the first row group as all the row groups in a file share the same signal
+	var schema []parquetColumnChunk
+	if len(rowGroups) > 0 {
+		schema = rowGroups[0].columns
+	}
+	t.listHeader(2, thriftStruct, len(schema)+1)
+	t.beginElement()
+	t.binary(4, []byte("schema"))
+	t.i32(5, int32(len(schema)))
+	t.endElement()
+	for _, c := range schema {
+		t.beginElement()
+		t.i32(1, c.ptype)
+		t.i32(3, 0) // REQUIRED
+		t.binary(4, []byte(c.name))
+		if c.ptype == parquetByteArray {
+			t.i32(6, parquetUTF8)
+		}
+		t.endElement()
+	}
+	var rows int64
+	for _, rg := range rowGroups {
+		rows += rg.numRows
+	}
+	t.i64(3, rows)
+	t.listHeader(4, thriftStruct, len(rowGroups))
+	for _, rg := range rowGroups {
+		t.beginElement()
+		t.listHeader(1, thriftStruct, len(rg.columns))
+		for _, c := range rg.columns {
+			offset := rg.offset + c.offset
+			t.beginElement()
+			t.i64(2, offset)
+			t.beginStruct(3)
+			t.i32(1, c.ptype)
+			t.listHeader(2, thriftI32, 2)
+			t.i32Element(parquetPlain)
+			t.i32Element(parquetRLE)
+			t.listHeader(3, thriftBinary, 1)
+			t.binaryElement([]byte(c.name))
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, c.numValues)
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, offset)
+			t.endStruct()
+			t.endElement()
+		}
+		t.i64(2, rg.size)
+		t.i64(3, rg.numRows)
+		t.endElement()
+	}
+	t.binary(6, []byte(parquetCreatedBy))
+	t.stop()
+
+	file, err := fsys.OpenFile(f, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	footer := t.bytes()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	footer = append(footer, parquetMagic...)
+	if _, err = file.Write(footer); err != nil {
+		err = multierr.Append(err, file.Truncate(stat.Size()))
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// tracesTable flattens the traces into one row per span
+func tracesTable(td ptrace.Traces) *parquetTable {
+	t := newParquetTable(int64Column("start_time_unix_nano"), int64Column("end_time_unix_nano"),
+		stringColumn("trace_id"), stringColumn("span_id"), stringColumn("parent_span_id"), stringColumn("name"),
+		stringColumn("kind"), stringColumn("status_code"), stringColumn("resource_attributes"), stringColumn("attributes"))
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resourceAttrs := attributesJSON(rs.Resource().Attributes())
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			spans := rs.ScopeSpans().At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				t.setInt("start_time_unix_nano", int64(span.StartTimestamp()))
+				t.setInt("end_time_unix_nano", int64(span.EndTimestamp()))
+				t.setString("trace_id", span.TraceID().String())
+				t.setString("span_id", span.SpanID().String())
+				t.setString("parent_span_id", span.ParentSpanID().String())
+				t.setString("name", span.Name())
+				t.setString("kind", span.Kind().String())
+				t.setString("status_code", span.Status().Code().String())
+				t.setString("resource_attributes", resourceAttrs)
+				t.setString("attributes", attributesJSON(span.Attributes()))
+				t.rows++
+			}
+		}
+	}
+	return t
+}
+
+// metricsTable flattens the metrics into one row per data point
+func metricsTable(md pmetric.Metrics) *parquetTable {
+	t := newParquetTable(int64Column("time_unix_nano"), stringColumn("metric_name"), stringColumn("metric_type"),
+		stringColumn("unit"), doubleColumn("value"), int64Column("count"), stringColumn("resource_attributes"),
+		stringColumn("attributes"))
+	forEachMetricPoint(md, func(p metricPoint) {
+		t.setInt("time_unix_nano", int64(p.timestamp))
+		t.setString("metric_name", p.metric.Name())
+		t.setString("metric_type", p.metric.Type().String())
+		t.setString("unit", p.metric.Unit())
+		t.setFloat("value", p.value)
+		t.setInt("count", int64(p.count))
+		t.setString("resource_attributes", attributesJSON(p.resource.Attributes()))
+		t.setString("attributes", attributesJSON(p.attributes))
+		t.rows++
+	})
+	return t
+}
+
+// logsTable flattens the logs into one row per log record
+func logsTable(ld plog.Logs) *parquetTable {
+	t := newParquetTable(int64Column("time_unix_nano"), int64Column("observed_time_unix_nano"),
+		int64Column("severity_number"), stringColumn("severity_text"), stringColumn("body"), stringColumn("trace_id"),
+		stringColumn("span_id"), stringColumn("resource_attributes"), stringColumn("attributes"))
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		resourceAttrs := attributesJSON(rl.Resource().Attributes())
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			records := rl.ScopeLogs().At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				lr := records.At(k)
+				t.setInt("time_unix_nano", int64(lr.Timestamp()))
+				t.setInt("observed_time_unix_nano", int64(lr.ObservedTimestamp()))
+				t.setInt("severity_number", int64(lr.SeverityNumber()))
+				t.setString("severity_text", lr.SeverityText())
+				t.setString("body", lr.Body().AsString())
+				t.setString("trace_id", lr.TraceID().String())
+				t.setString("span_id", lr.SpanID().String())
+				t.setString("resource_attributes", resourceAttrs)
+				t.setString("attributes", attributesJSON(lr.Attributes()))
+				t.rows++
+			}
+		}
+	}
+	return t
+}
+
+// thriftWriter encodes parquet metadata using the thrift compact protocol
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+func (t *thriftWriter) bytes() []byte {
+	return t.buf.Bytes()
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	delta := id - t.lastID
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v []byte) {
+	t.fieldHeader(id, thriftBinary)
+	t.binaryElement(v)
+}
+
+func (t *thriftWriter) listHeader(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) i32Element(v int32) {
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) binaryElement(v []byte) {
+	t.varint(uint64(len(v)))
+	t.buf.Write(v)
+}
+
+// beginStruct starts a struct field
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginElement()
+}
+
+func (t *thriftWriter) endStruct() {
+	t.endElement()
+}
+
+// beginElement starts a struct element of a list
+func (t *thriftWriter) beginElement() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) endElement() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop writes the end of the current struct
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -0,0 +1,267 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// thriftReader decodes the thrift compact protocol the parquet metadata is encoded with, independently of
+// the encoder of the exporter; the structs are decoded to maps of their field ids
+type thriftReader struct {
+	t   *testing.T
+	buf *bytes.Reader
+}
+
+func (r *thriftReader) byte() byte {
+	b, err := r.buf.ReadByte()
+	if err != nil {
+		r.t.Fatalf("truncated thrift data: %v", err)
+	}
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, err := binary.ReadUvarint(r.buf)
+	if err != nil {
+		r.t.Fatalf("invalid thrift varint: %v", err)
+	}
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// value decodes a value of the compact type
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 5, 6:
+		return r.zigzag()
+	case 8:
+		v := make([]byte, r.varint())
+		if _, err := r.buf.Read(v); err != nil && len(v) > 0 {
+			r.t.Fatalf("truncated thrift binary: %v", err)
+		}
+		return string(v)
+	case 9:
+		header := r.byte()
+		size := uint64(header >> 4)
+		if size == 15 {
+			size = r.varint()
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	r.t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
+
+// structure decodes a struct to the values of its field ids
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta > 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// parquetFileReader reads the columns of a parquet file through its footer
+type parquetFileReader struct {
+	t        *testing.T
+	content  []byte
+	metadata map[int16]interface{}
+}
+
+func readParquetFile(t *testing.T, content []byte) *parquetFileReader {
+	if !bytes.HasPrefix(content, []byte(parquetMagic)) || !bytes.HasSuffix(content, []byte(parquetMagic)) {
+		t.Fatal("expected the file to start and end with the parquet magic")
+	}
+	size := int(binary.LittleEndian.Uint32(content[len(content)-8:]))
+	footer := content[len(content)-8-size : len(content)-8]
+	r := &thriftReader{t: t, buf: bytes.NewReader(footer)}
+	metadata := r.structure()
+	if r.buf.Len() != 0 {
+		t.Fatalf("expected the footer to be %d bytes of file metadata, %d bytes are left", size, r.buf.Len())
+	}
+	return &parquetFileReader{t: t, content: content, metadata: metadata}
+}
+
+// schema returns the names of the columns and their physical types
+func (p *parquetFileReader) schema() map[string]int64 {
+	elements := p.metadata[2].([]interface{})
+	root := elements[0].(map[int16]interface{})
+	if root[4] != "schema" || root[5] != int64(len(elements)-1) {
+		p.t.Fatalf("unexpected schema root %v", root)
+	}
+	columns := make(map[string]int64)
+	for _, element := range elements[1:] {
+		column := element.(map[int16]interface{})
+		if column[3] != int64(0) {
+			p.t.Fatalf("expected the column %v to be required", column[4])
+		}
+		columns[column[4].(string)] = column[1].(int64)
+	}
+	return columns
+}
+
+// rowGroups returns the number of rows of the row groups
+func (p *parquetFileReader) rowGroups() []int64 {
+	var rows []int64
+	for _, rg := range p.metadata[4].([]interface{}) {
+		rows = append(rows, rg.(map[int16]interface{})[3].(int64))
+	}
+	return rows
+}
+
+// page returns the plain encoded values of the data page of the column in the row group
+func (p *parquetFileReader) page(rowGroup int, name string) (values []byte, count int) {
+	rg := p.metadata[4].([]interface{})[rowGroup].(map[int16]interface{})
+	for _, c := range rg[1].([]interface{}) {
+		meta := c.(map[int16]interface{})[3].(map[int16]interface{})
+		if meta[3].([]interface{})[0] != name {
+			continue
+		}
+		if meta[4] != int64(0) {
+			p.t.Fatalf("expected the column %s to be uncompressed", name)
+		}
+		offset := meta[9].(int64)
+		r := &thriftReader{t: p.t, buf: bytes.NewReader(p.content[offset:])}
+		header := r.structure()
+		if header[1] != int64(0) {
+			p.t.Fatalf("expected a data page for the column %s, got %v", name, header)
+		}
+		start := offset + int64(len(p.content[offset:])-r.buf.Len())
+		if size := header[3].(int64); start+size-offset != meta[6].(int64) {
+			p.t.Fatalf("expected the page of the column %s to fill its chunk", name)
+		}
+		count = int(header[5].(map[int16]interface{})[1].(int64))
+		if count != int(meta[5].(int64)) {
+			p.t.Fatalf("expected the page of the column %s to hold the %d values of its chunk", name, meta[5])
+		}
+		return p.content[start : start+header[3].(int64)], count
+	}
+	p.t.Fatalf("no column %s in the row group", name)
+	return nil, 0
+}
+
+// strings returns the values of the string column of the row group
+func (p *parquetFileReader) strings(rowGroup int, name string) []string {
+	values, count := p.page(rowGroup, name)
+	var out []string
+	for i := 0; i < count; i++ {
+		size := binary.LittleEndian.Uint32(values)
+		out = append(out, string(values[4:4+size]))
+		values = values[4+size:]
+	}
+	return out
+}
+
+// ints returns the values of the int64 column of the row group
+func (p *parquetFileReader) ints(rowGroup int, name string) []int64 {
+	values, count := p.page(rowGroup, name)
+	var out []int64
+	for i := 0; i < count; i++ {
+		out = append(out, int64(binary.LittleEndian.Uint64(values[i*8:])))
+	}
+	return out
+}
+
+// spans returns traces holding a span of every name, started at the index of the name in seconds
+func spans(names ...string) ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "test")
+	for i, name := range names {
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetName(name)
+		span.SetTraceID(pcommon.TraceID([16]byte{byte(i + 1)}))
+		span.SetSpanID(pcommon.SpanID([8]byte{byte(i + 1)}))
+		span.SetStartTimestamp(pcommon.Timestamp(int64(i+1) * 1e9))
+		span.SetEndTimestamp(pcommon.Timestamp(int64(i+2) * 1e9))
+	}
+	return td
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.Format = Parquet
+	cfg.RecoverInProcess = RecoverDiscard
+	e, err := New(*cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, td := range []ptrace.Traces{spans("a", "b"), spans("c")} {
+		if err = e.WriteTraces(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = e.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, f := range listFiles(t, cfg.Path) {
+		if strings.HasSuffix(f, ".parquet") {
+			files = append(files, f)
+		}
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected one parquet file, got %v", listFiles(t, cfg.Path))
+	}
+	p := readParquetFile(t, []byte(readFile(t, filepath.Join(cfg.Path, files[0]))))
+	if p.metadata[1] != int64(1) || p.metadata[3] != int64(3) || p.metadata[6] != parquetCreatedBy {
+		t.Fatalf("unexpected file metadata version %v, rows %v, created by %v", p.metadata[1], p.metadata[3], p.metadata[6])
+	}
+	schema := p.schema()
+	if schema["name"] != int64(parquetByteArray) || schema["start_time_unix_nano"] != int64(parquetInt64) || len(schema) != 10 {
+		t.Fatalf("unexpected schema %v", schema)
+	}
+	if rows := p.rowGroups(); !reflect.DeepEqual(rows, []int64{2, 1}) {
+		t.Fatalf("expected a row group per batch, got %v", rows)
+	}
+	if names := append(p.strings(0, "name"), p.strings(1, "name")...); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected span names %v", names)
+	}
+	if starts := p.ints(0, "start_time_unix_nano"); !reflect.DeepEqual(starts, []int64{1e9, 2e9}) {
+		t.Fatalf("unexpected span start times %v", starts)
+	}
+	if ids := p.strings(1, "trace_id"); !reflect.DeepEqual(ids, []string{"01000000000000000000000000000000"}) {
+		t.Fatalf("unexpected trace ids %v", ids)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// the partition value used when a resource does not have the partition attribute
+const unknownPartition = "_unknown"
+
+// partitionKey returns the sub directory of the resource, made of the tenant followed by the values of
+// the partitionBy resource attributes in the configured order; tenant is the tenant of the request
+// metadata used when the resource does not have the tenant attribute
+func (e *fileExporter) partitionKey(resource pcommon.Resource, tenant string) string {
+	var parts []string
+	if len(e.tenantAttribute) > 0 {
+		if v, ok := resource.Attributes().Get(e.tenantAttribute); ok && len(v.AsString()) > 0 {
+			tenant = v.AsString()
+		}
+		if len(tenant) == 0 {
+			tenant = unknownPartition
+		}
+		parts = append(parts, sanitisePartition(tenant))
+	}
+	for _, key := range e.partitionBy {
+		part := unknownPartition
+		if v, ok := resource.Attributes().Get(key); ok && len(v.AsString()) > 0 {
+			part = sanitisePartition(v.AsString())
+		}
+		parts = append(parts, part)
+	}
+	return filepath.Join(parts...)
+}
+
+// partitioned returns true if the telemetry is split by tenant or resource attributes
+func (e *fileExporter) partitioned() bool {
+	return len(e.partitionBy) > 0 || len(e.tenantAttribute) > 0
+}
+
+// metadataTenant returns the tenant in the client metadata of the request, empty if there is none;
+// the client metadata is only available when the sending queue is disabled
+func (e *fileExporter) metadataTenant(ctx context.Context) string {
+	if len(e.tenantAttribute) == 0 {
+		return ""
+	}
+	if values := client.FromContext(ctx).Metadata.Get(e.tenantAttribute); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// sanitisePartition ensures an attribute value can be safely used as a directory name
+func sanitisePartition(value string) string {
+	value = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(value)
+	if value == "." || value == ".." {
+		return unknownPartition
+	}
+	return value
+}
+
+// partitionTraces splits the traces by tenant and by the partitionBy resource attributes
+func (e *fileExporter) partitionTraces(td ptrace.Traces, tenant string) map[string]ptrace.Traces {
+	if !e.partitioned() {
+		return map[string]ptrace.Traces{"": td}
+	}
+	result := make(map[string]ptrace.Traces)
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		key := e.partitionKey(rs.Resource(), tenant)
+		ptd, ok := result[key]
+		if !ok {
+			ptd = ptrace.NewTraces()
+			result[key] = ptd
+		}
+		rs.CopyTo(ptd.ResourceSpans().AppendEmpty())
+	}
+	return result
+}
+
+// partitionMetrics splits the metrics by tenant and by the partitionBy resource attributes
+func (e *fileExporter) partitionMetrics(md pmetric.Metrics, tenant string) map[string]pmetric.Metrics {
+	if !e.partitioned() {
+		return map[string]pmetric.Metrics{"": md}
+	}
+	result := make(map[string]pmetric.Metrics)
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		key := e.partitionKey(rm.Resource(), tenant)
+		pmd, ok := result[key]
+		if !ok {
+			pmd = pmetric.NewMetrics()
+			result[key] = pmd
+		}
+		rm.CopyTo(pmd.ResourceMetrics().AppendEmpty())
+	}
+	return result
+}
+
+// partitionLogs splits the logs by tenant and by the partitionBy resource attributes
+func (e *fileExporter) partitionLogs(ld plog.Logs, tenant string) map[string]plog.Logs {
+	if !e.partitioned() {
+		return map[string]plog.Logs{"": ld}
+	}
+	result := make(map[string]plog.Logs)
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		key := e.partitionKey(rl.Resource(), tenant)
+		pld, ok := result[key]
+		if !ok {
+			pld = plog.NewLogs()
+			result[key] = pld
+		}
+		rl.CopyTo(pld.ResourceLogs().AppendEmpty())
+	}
+	return result
+}
@@ -0,0 +1,34 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+)
+
+// the sending queue persists the batches in the storage extension referenced by sending_queue::storage
+// until they are exported, a batch is only removed from the queue once the exporter returns so the
+// exporter must not return before the batch is on disk for the delivery to be at least once
+
+// usesPersistentQueue returns true if the sending queue is backed by a storage extension
+func (cfg *Config) usesPersistentQueue() bool {
+	return cfg.QueueSettings.Enabled && cfg.QueueSettings.StorageID != nil
+}
+
+// validatePersistentQueue checks the batches are written before they are removed from the persistent queue
+func (cfg *Config) validatePersistentQueue() error {
+	if cfg.QueueSettings.StorageID != nil && !cfg.QueueSettings.Enabled {
+		return errors.New("sending_queue storage requires the sending queue to be enabled")
+	}
+	if cfg.usesPersistentQueue() && cfg.flushInterval() > 0 {
+		return errors.New("flushBytes, flushRecords and flushIntervalMs cannot be used with a persistent sending queue, " +
+			"the aggregated batches would be removed from the queue before they are written")
+	}
+	return nil
+}
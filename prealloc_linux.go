@@ -0,0 +1,24 @@
+//go:build linux
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import "syscall"
+
+// the fallocate mode allocating the blocks of a file without changing its size
+const fallocKeepSize = 0x1
+
+const preallocateSupported = true
+
+// preallocate allocates the blocks of the first size bytes of the file without changing its size, so
+// the appended data is not fragmented and the blocks are not allocated on every write
+func preallocate(file fsFile, size int64) error {
+	return syscall.Fallocate(int(file.Fd()), fallocKeepSize, 0, size)
+}
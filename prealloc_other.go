@@ -0,0 +1,19 @@
+//go:build !linux
+
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+// the blocks cannot be reserved, the writes that must not run out of space are rejected
+const preallocateSupported = false
+
+// preallocate does nothing where the blocks of a file cannot be allocated without changing its size
+func preallocate(fsFile, int64) error {
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrecreateConfig creates the output directories on start, so that the writes to them never create
+// directories; the partitions not listed are still created when they are first written to
+type PrecreateConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Partitions are the partition sub directories created under the path and the mirror paths, made of the
+	// tenant and the partitionBy attribute values in the configured order joined with /
+	Partitions []string `mapstructure:"partitions"`
+}
+
+// validatePrecreate checks the partitions are relative sub directories of the configured depth
+func (cfg *Config) validatePrecreate() error {
+	if cfg.Precreate == nil || !cfg.Precreate.Enabled {
+		return nil
+	}
+	depth := len(cfg.PartitionBy)
+	if len(cfg.TenantAttribute) > 0 {
+		depth++
+	}
+	if len(cfg.Precreate.Partitions) > 0 && depth == 0 {
+		return errors.New("precreate partitions require partitionBy or tenantAttribute")
+	}
+	for _, partition := range cfg.Precreate.Partitions {
+		parts := strings.Split(filepath.ToSlash(partition), "/")
+		if len(parts) != depth {
+			return fmt.Errorf("invalid precreate partition [%s], it must have %d parts separated by /", partition, depth)
+		}
+		for _, part := range parts {
+			if len(part) == 0 || sanitisePartition(part) != part {
+				return fmt.Errorf("invalid precreate partition [%s], its parts must be non empty directory names", partition)
+			}
+		}
+	}
+	return nil
+}
+
+// newPrecreate returns the precreate configuration, nil if the directories are not created on start
+func newPrecreate(cfg *PrecreateConfig) *PrecreateConfig {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	return cfg
+}
+
+// precreateDirs creates the directories of the partitions, routes and signals under the path and the mirror
+// paths and marks their writers ready, it must be called on start before anything is written
+func (e *fileExporter) precreateDirs() error {
+	partitions := []string{""}
+	for _, partition := range e.precreate.Partitions {
+		partitions = append(partitions, filepath.FromSlash(partition))
+	}
+	routes := []string{""}
+	if e.severityRoute != nil {
+		routes = append(routes, e.severityRoute.directory)
+	}
+	for _, l := range e.lanes {
+		routes = append(routes, l.name)
+	}
+	signals := []string{""}
+	if e.isSignalDir() {
+		signals = []string{signalTraces, signalMetrics, signalLogs}
+	}
+	for _, root := range e.roots() {
+		for _, partition := range partitions {
+			for _, route := range routes {
+				for _, signal := range signals {
+					path := filepath.Join(root, partition, route, signal)
+					if err := os.MkdirAll(path, 0755); err != nil {
+						return fmt.Errorf("failed to create directory %s (%s): %w", path, pathDiagnostics(path), err)
+					}
+					for shard := 0; shard < e.shards || shard == 0; shard++ {
+						e.writer(path, route, shard).dirReady = true
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// checksSize returns true if the size of the batches of the route is checked against the maximum record
+// size or the file size before they are written
+func (e *fileExporter) checksSize(route string) bool {
+	if e.maxRecordSize > 0 {
+		return true
+	}
+	fileSize, _ := e.rotationLimits(route)
+	return fileSize > 0 && !e.isRotationNone()
+}
+
+// tracesSize returns the size of the marshaled traces without marshaling them, false if the size is not
+// checked or cannot be known in advance; the protobuf export requests hold the resource spans under the
+// same field as the traces so they have the same size
+func (e *fileExporter) tracesSize(route string, td ptrace.Traces) (int, bool) {
+	if !e.checksSize(route) {
+		return 0, false
+	}
+	switch e.format {
+	case formatProtobuf:
+		return pbTracesMarshaller.TracesSize(td), true
+	case formatCustom:
+		if sizer, ok := e.marshaler.(ptrace.Sizer); ok {
+			return sizer.TracesSize(td), true
+		}
+	}
+	return 0, false
+}
+
+// metricsSize returns the size of the marshaled metrics without marshaling them, see tracesSize
+func (e *fileExporter) metricsSize(route string, md pmetric.Metrics) (int, bool) {
+	if !e.checksSize(route) {
+		return 0, false
+	}
+	switch e.format {
+	case formatProtobuf:
+		return pbMetricsMarshaller.MetricsSize(md), true
+	case formatCustom:
+		if sizer, ok := e.marshaler.(pmetric.Sizer); ok {
+			return sizer.MetricsSize(md), true
+		}
+	}
+	return 0, false
+}
+
+// logsSize returns the size of the marshaled logs without marshaling them, see tracesSize
+func (e *fileExporter) logsSize(route string, ld plog.Logs) (int, bool) {
+	if !e.checksSize(route) {
+		return 0, false
+	}
+	switch e.format {
+	case formatProtobuf:
+		return pbLogsMarshaller.LogsSize(ld), true
+	case formatCustom:
+		if sizer, ok := e.marshaler.(plog.Sizer); ok {
+			return sizer.LogsSize(ld), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,206 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	promCounter   = "counter"
+	promGauge     = "gauge"
+	promHistogram = "histogram"
+	promSummary   = "summary"
+)
+
+// isMetricsOnly returns true if the format can only write metrics
+func isMetricsOnly(format string) bool {
+	return strings.EqualFold(format, Csv) || strings.EqualFold(format, Prometheus)
+}
+
+// promFamily holds the samples of a metric family, the samples of all the resources are grouped under
+// the family as the exposition format allows a single TYPE line per family
+type promFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples bytes.Buffer
+}
+
+// metricsPrometheus writes the metrics in the prometheus text exposition format as a single block, without
+// timestamps as the node exporter textfile collector rejects them; monotonic cumulative sums are counters,
+// other sums and gauges are gauges, exponential histograms are not written
+func metricsPrometheus(md pmetric.Metrics) []byte {
+	var families []*promFamily
+	byName := make(map[string]*promFamily)
+	family := func(name, kind, help string) *promFamily {
+		if f, ok := byName[name]; ok {
+			return f
+		}
+		f := &promFamily{name: name, kind: kind, help: help}
+		byName[name] = f
+		families = append(families, f)
+		return f
+	}
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		resourceLabels := promResourceLabels(rm.Resource())
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			metrics := rm.ScopeMetrics().At(j).Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				m := metrics.At(k)
+				name := promName(m.Name())
+				switch m.Type() {
+				case pmetric.MetricTypeGauge:
+					f := family(name, promGauge, m.Description())
+					promNumberSamples(f, name, resourceLabels, m.Gauge().DataPoints())
+				case pmetric.MetricTypeSum:
+					if m.Sum().IsMonotonic() && m.Sum().AggregationTemporality() == pmetric.AggregationTemporalityCumulative {
+						name = strings.TrimSuffix(name, "_total") + "_total"
+						f := family(name, promCounter, m.Description())
+						promNumberSamples(f, name, resourceLabels, m.Sum().DataPoints())
+					} else {
+						f := family(name, promGauge, m.Description())
+						promNumberSamples(f, name, resourceLabels, m.Sum().DataPoints())
+					}
+				case pmetric.MetricTypeHistogram:
+					f := family(name, promHistogram, m.Description())
+					dps := m.Histogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						labels := promLabels(resourceLabels, dp.Attributes())
+						bounds, counts := dp.ExplicitBounds(), dp.BucketCounts()
+						var cumulative uint64
+						for b := 0; b < bounds.Len() && b < counts.Len(); b++ {
+							cumulative += counts.At(b)
+							promSample(f, name+"_bucket", labels, "le", promFloat(bounds.At(b)), float64(cumulative))
+						}
+						promSample(f, name+"_bucket", labels, "le", "+Inf", float64(dp.Count()))
+						promSample(f, name+"_sum", labels, "", "", dp.Sum())
+						promSample(f, name+"_count", labels, "", "", float64(dp.Count()))
+					}
+				case pmetric.MetricTypeSummary:
+					f := family(name, promSummary, m.Description())
+					dps := m.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dp := dps.At(l)
+						labels := promLabels(resourceLabels, dp.Attributes())
+						for q := 0; q < dp.QuantileValues().Len(); q++ {
+							qv := dp.QuantileValues().At(q)
+							promSample(f, name, labels, "quantile", promFloat(qv.Quantile()), qv.Value())
+						}
+						promSample(f, name+"_sum", labels, "", "", dp.Sum())
+						promSample(f, name+"_count", labels, "", "", float64(dp.Count()))
+					}
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	for _, f := range families {
+		if len(f.help) > 0 {
+			buf.WriteString("# HELP " + f.name + " " + promHelpReplacer.Replace(f.help) + "\n")
+		}
+		buf.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+		buf.Write(f.samples.Bytes())
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+func promNumberSamples(f *promFamily, name string, resourceLabels []string, dps pmetric.NumberDataPointSlice) {
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		value := dp.DoubleValue()
+		if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+			value = float64(dp.IntValue())
+		}
+		promSample(f, name, promLabels(resourceLabels, dp.Attributes()), "", "", value)
+	}
+}
+
+// promSample writes a sample line, extraName and extraValue is an optional label such as le or quantile
+func promSample(f *promFamily, name string, labels []string, extraName, extraValue string, value float64) {
+	f.samples.WriteString(name)
+	if len(extraName) > 0 {
+		labels = append(labels[:len(labels):len(labels)], extraName+`="`+extraValue+`"`)
+	}
+	if len(labels) > 0 {
+		f.samples.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+	f.samples.WriteString(" " + promFloat(value) + "\n")
+}
+
+// promResourceLabels returns the job and instance labels of the resource, as set by the prometheus exporters
+func promResourceLabels(resource pcommon.Resource) []string {
+	var labels []string
+	attrs := resource.Attributes()
+	if name, ok := attrs.Get("service.name"); ok {
+		job := name.AsString()
+		if ns, ok := attrs.Get("service.namespace"); ok && len(ns.AsString()) > 0 {
+			job = ns.AsString() + "/" + job
+		}
+		labels = append(labels, promLabel("job", job))
+	}
+	if instance, ok := attrs.Get("service.instance.id"); ok {
+		labels = append(labels, promLabel("instance", instance.AsString()))
+	}
+	return labels
+}
+
+// promLabels returns the resource labels followed by the data point attributes sorted by name
+func promLabels(resourceLabels []string, attrs pcommon.Map) []string {
+	labels := make([]string, 0, len(resourceLabels)+attrs.Len())
+	labels = append(labels, resourceLabels...)
+	var points []string
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		points = append(points, promLabel(promLabelName(k), v.AsString()))
+		return true
+	})
+	sort.Strings(points)
+	return append(labels, points...)
+}
+
+var (
+	promLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	promHelpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func promLabel(name, value string) string {
+	return name + `="` + promLabelReplacer.Replace(value) + `"`
+}
+
+// promName replaces the characters not allowed in prometheus metric names with underscores
+func promName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// promLabelName replaces the characters not allowed in prometheus label names with underscores
+func promLabelName(name string) string {
+	return strings.ReplaceAll(promName(name), ":", "_")
+}
+
+func promFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,146 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the protobuf files can embed the OTLP proto descriptors in their file header so that they can be decoded
+// by generic tools without the OTLP protos of the version they were written with, for instance with
+// jq -r .descriptorSet | base64 -d > otlp.pb and protoc --decode=<messageType> --descriptor_set_in=otlp.pb
+
+// otlpProtoVersion is the version of the OTLP protos the pdata module is generated from
+const otlpProtoVersion = "0.19.0"
+
+// otlpMessage is the message written for a signal and the proto file defining it
+type otlpMessage struct {
+	file string
+	name string
+}
+
+// otlpMessages are the messages of the signals written as is or wrapped in the export service request
+var otlpMessages = map[string][2]otlpMessage{
+	signalTraces: {
+		{"opentelemetry/proto/trace/v1/trace.proto", "opentelemetry.proto.trace.v1.TracesData"},
+		{"opentelemetry/proto/collector/trace/v1/trace_service.proto", "opentelemetry.proto.collector.trace.v1.ExportTraceServiceRequest"},
+	},
+	signalMetrics: {
+		{"opentelemetry/proto/metrics/v1/metrics.proto", "opentelemetry.proto.metrics.v1.MetricsData"},
+		{"opentelemetry/proto/collector/metrics/v1/metrics_service.proto", "opentelemetry.proto.collector.metrics.v1.ExportMetricsServiceRequest"},
+	},
+	signalLogs: {
+		{"opentelemetry/proto/logs/v1/logs.proto", "opentelemetry.proto.logs.v1.LogsData"},
+		{"opentelemetry/proto/collector/logs/v1/logs_service.proto", "opentelemetry.proto.collector.logs.v1.ExportLogsServiceRequest"},
+	},
+}
+
+var (
+	otlpDescriptorsOnce sync.Once
+	otlpDescriptors     []byte
+)
+
+// validateProtobufDescriptor checks the descriptors are only embedded in protobuf files
+func validateProtobufDescriptor(enabled bool, format string) error {
+	if enabled && !strings.EqualFold(format, Protobuf) {
+		return errors.New("protobufDescriptor is only supported for the protobuf format")
+	}
+	return nil
+}
+
+// otlpMessageType returns the full name of the message the batches of the signal are written as
+func otlpMessageType(signal string, exportRequest bool) string {
+	messages, ok := otlpMessages[signal]
+	if !ok {
+		return ""
+	}
+	if exportRequest {
+		return messages[1].name
+	}
+	return messages[0].name
+}
+
+// otlpDescriptorSet returns the google.protobuf.FileDescriptorSet of the OTLP protos of every signal and
+// the protos they import, the imports before the files importing them as protoc expects
+func otlpDescriptorSet() []byte {
+	otlpDescriptorsOnce.Do(func() {
+		included := make(map[string]bool)
+		for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+			for _, message := range otlpMessages[signal] {
+				otlpDescriptors = appendFileDescriptor(otlpDescriptors, message.file, included)
+			}
+		}
+	})
+	return otlpDescriptors
+}
+
+// appendFileDescriptor appends the descriptor of the proto file to the set after its imports, the files
+// not registered by the generated code linked in the binary are left out; they only hold the gogo options
+// of the generated code so they are also removed from the imports of the files
+func appendFileDescriptor(set []byte, file string, included map[string]bool) []byte {
+	if _, visited := included[file]; visited {
+		return set
+	}
+	included[file] = false
+	descriptor := registeredFileDescriptor(file)
+	if descriptor == nil {
+		return set
+	}
+	var kept []byte
+	for len(descriptor) > 0 {
+		number, typ, n := protowire.ConsumeTag(descriptor)
+		if n < 0 {
+			return set
+		}
+		m := protowire.ConsumeFieldValue(number, typ, descriptor[n:])
+		if m < 0 {
+			return set
+		}
+		field := descriptor[:n+m]
+		descriptor = descriptor[n+m:]
+		// the dependency field of the google.protobuf.FileDescriptorProto
+		if number == 3 && typ == protowire.BytesType {
+			dependency, _ := protowire.ConsumeBytes(field[n:])
+			if set = appendFileDescriptor(set, string(dependency), included); !included[string(dependency)] {
+				continue
+			}
+		}
+		kept = append(kept, field...)
+	}
+	included[file] = true
+	// the file field of the google.protobuf.FileDescriptorSet
+	set = protowire.AppendTag(set, 1, protowire.BytesType)
+	return protowire.AppendBytes(set, kept)
+}
+
+// registeredFileDescriptor returns the google.protobuf.FileDescriptorProto of the proto file registered
+// by the generated code, nil if it is not registered
+func registeredFileDescriptor(file string) []byte {
+	compressed := gogoproto.FileDescriptor(file)
+	if len(compressed) == 0 {
+		return nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil
+	}
+	descriptor, err := io.ReadAll(reader)
+	if err != nil {
+		return nil
+	}
+	return descriptor
+}
@@ -0,0 +1,80 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// the default number of consecutive failed writes after which the in process file is quarantined
+	defaultQuarantineAfter = 5
+	// the extension of the quarantined in process files, they are neither finished nor recovered
+	quarantineExt = "failed"
+)
+
+// validateQuarantine checks the number of failed writes quarantining the in process file is not negative
+func (cfg *Config) validateQuarantine() error {
+	if cfg.QuarantineAfter < 0 {
+		return errors.New("quarantineAfter must not be negative")
+	}
+	return nil
+}
+
+// isQuarantineName returns true if the file name is the name of a quarantined in process file
+func isQuarantineName(name string) bool {
+	return strings.HasSuffix(name, "."+quarantineExt)
+}
+
+// writeFailed accounts a failed write to the in process file f of the writer, the failure is logged when the
+// number of consecutive failures is a power of two so that a file failing every write does not flood the
+// log; once the writes failed quarantineAfter consecutive times the file is quarantined
+func (e *fileExporter) writeFailed(w *fileWriter, f string, msg string, err error) {
+	w.failures++
+	if w.failures&(w.failures-1) == 0 {
+		e.logger.Error(msg, zap.String("file", f), zap.Int("consecutiveFailures", w.failures), zap.Error(err))
+	}
+	if e.quarantineAfter > 0 && w.failures >= e.quarantineAfter && !e.isRotationNone() {
+		e.quarantine(w, f)
+	}
+}
+
+// quarantine renames the in process file with the failed extension and resets the writer, so that the next
+// batch starts a fresh in process file; the batches buffered and not yet written are lost with the file
+// handle and the file is kept for inspection. A file that cannot be renamed is retried on the next failure
+func (e *fileExporter) quarantine(w *fileWriter, f string) {
+	// the failed write discarded the buffer, the file is closed if a later write reopened it
+	_ = w.close()
+	name := fmt.Sprintf("%s.%d.%s", f, time.Now().UnixNano(), quarantineExt)
+	if err := e.fs.Rename(f, name); os.IsNotExist(err) {
+		// the file cannot even be created, there is nothing to quarantine
+		return
+	} else if err != nil {
+		e.logger.Error("failed to quarantine inprocess file", zap.String("file", f), zap.Error(err))
+		return
+	}
+	e.logger.Warn("quarantined inprocess file after consecutive write failures, writing to a new inprocess file",
+		zap.String("file", f), zap.String("quarantinedFile", name), zap.Int("consecutiveFailures", w.failures))
+	w.failures = 0
+	w.currentEventCount = 0
+	w.signals = make(map[string]bool)
+	w.rowGroups = nil
+	w.footerWritten = false
+	w.stats = fileStats{}
+	w.bucket = time.Time{}
+	w.opened = time.Time{}
+	w.lastWrite = time.Time{}
+	e.saveState(w)
+}
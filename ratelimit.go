@@ -0,0 +1,107 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a batch cannot be written within the deadline of the export without
+// exceeding the write rate, the error is retryable so the data is held by the queues upstream
+var ErrRateLimited = errors.New("write rate limit exceeded")
+
+// validateWriteRate checks the write rate limit
+func (cfg *Config) validateWriteRate() error {
+	if cfg.MaxWriteBytesPerSecond < 0 {
+		return errors.New("maxWriteBytesPerSecond must not be negative")
+	}
+	return nil
+}
+
+// rateLimiter is a token bucket of bytes shared by the writers of the exporter, it holds up to a second
+// worth of bytes so that a burst after an idle period is written at once
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n bytes from the bucket and returns how long to wait before writing them; the bucket may
+// go into debt so that batches larger than a second worth of bytes are written at the rate, nothing is
+// taken and false is returned if the wait would be longer than maxWait
+func (l *rateLimiter) reserve(n int, maxWait time.Duration) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	var wait time.Duration
+	if deficit := float64(n) - l.tokens; deficit > 0 {
+		wait = time.Duration(deficit / l.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+	l.tokens -= float64(n)
+	return wait, true
+}
+
+// cancel returns the bytes of a reservation which was not written
+func (l *rateLimiter) cancel(n int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.tokens += float64(n)
+}
+
+// throttle waits until the batch of n bytes can be written without exceeding the write rate, holding the
+// export until then; if the wait would outlast the deadline of the context a retryable error is returned
+func (e *fileExporter) throttle(ctx context.Context, n int) error {
+	if e.writeLimiter == nil {
+		return nil
+	}
+	maxWait := time.Duration(1<<63 - 1)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+	wait, ok := e.writeLimiter.reserve(n, maxWait)
+	if !ok {
+		return fmt.Errorf("%w: a batch of %d bytes cannot be written before the export deadline", ErrRateLimited, n)
+	}
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		e.writeLimiter.cancel(n)
+		return ctx.Err()
+	}
+}
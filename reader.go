@@ -0,0 +1,280 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// the finished files are read back by detecting their encryption, compression and format from the
+// extensions of their name; json files hold one document per batch and are read one record at a time,
+// protobuf files hold concatenated messages which decode as a single record
+
+// ErrUnsupportedFile is returned when the format of a file cannot be read back
+var ErrUnsupportedFile = errors.New("unsupported file")
+
+// ReadOption customises how finished files are read
+type ReadOption func(o *readOptions)
+
+type readOptions struct {
+	keyProvider KeyProvider
+}
+
+// WithDecryptionKey sets the key provider used to decrypt encrypted files
+func WithDecryptionKey(provider KeyProvider) ReadOption {
+	return func(o *readOptions) {
+		o.keyProvider = provider
+	}
+}
+
+// ReadTracesFile returns the traces of all the records of a finished file
+func ReadTracesFile(path string, opts ...ReadOption) (ptrace.Traces, error) {
+	td := ptrace.NewTraces()
+	err := IterateTracesFile(path, func(record ptrace.Traces) error {
+		record.ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+		return nil
+	}, opts...)
+	return td, err
+}
+
+// ReadMetricsFile returns the metrics of all the records of a finished file
+func ReadMetricsFile(path string, opts ...ReadOption) (pmetric.Metrics, error) {
+	md := pmetric.NewMetrics()
+	err := IterateMetricsFile(path, func(record pmetric.Metrics) error {
+		record.ResourceMetrics().MoveAndAppendTo(md.ResourceMetrics())
+		return nil
+	}, opts...)
+	return md, err
+}
+
+// ReadLogsFile returns the logs of all the records of a finished file
+func ReadLogsFile(path string, opts ...ReadOption) (plog.Logs, error) {
+	ld := plog.NewLogs()
+	err := IterateLogsFile(path, func(record plog.Logs) error {
+		record.ResourceLogs().MoveAndAppendTo(ld.ResourceLogs())
+		return nil
+	}, opts...)
+	return ld, err
+}
+
+// IterateTracesFile calls fn with the traces of each record of a finished file, the iteration stops at
+// the first error returned by fn
+func IterateTracesFile(path string, fn func(ptrace.Traces) error, opts ...ReadOption) error {
+	return iterateFile(path, opts, func(record []byte, isJSON bool) error {
+		var td ptrace.Traces
+		var err error
+		if isJSON {
+			td, err = (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(record)
+		} else {
+			td, err = (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(record)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode traces of %s: %w", path, err)
+		}
+		return fn(td)
+	})
+}
+
+// IterateMetricsFile calls fn with the metrics of each record of a finished file, the iteration stops at
+// the first error returned by fn
+func IterateMetricsFile(path string, fn func(pmetric.Metrics) error, opts ...ReadOption) error {
+	return iterateFile(path, opts, func(record []byte, isJSON bool) error {
+		var md pmetric.Metrics
+		var err error
+		if isJSON {
+			md, err = (&pmetric.JSONUnmarshaler{}).UnmarshalMetrics(record)
+		} else {
+			md, err = (&pmetric.ProtoUnmarshaler{}).UnmarshalMetrics(record)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode metrics of %s: %w", path, err)
+		}
+		return fn(md)
+	})
+}
+
+// IterateLogsFile calls fn with the logs of each record of a finished file, the iteration stops at
+// the first error returned by fn
+func IterateLogsFile(path string, fn func(plog.Logs) error, opts ...ReadOption) error {
+	return iterateFile(path, opts, func(record []byte, isJSON bool) error {
+		var ld plog.Logs
+		var err error
+		if isJSON {
+			ld, err = (&plog.JSONUnmarshaler{}).UnmarshalLogs(record)
+		} else {
+			ld, err = (&plog.ProtoUnmarshaler{}).UnmarshalLogs(record)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode logs of %s: %w", path, err)
+		}
+		return fn(ld)
+	})
+}
+
+// IterateRecords calls fn with each record of a finished file and whether it is json encoded, the header
+// records are skipped; the iteration stops at the first error returned by fn
+func IterateRecords(path string, fn func(record []byte, isJSON bool) error, opts ...ReadOption) error {
+	return iterateFile(path, opts, fn)
+}
+
+// DecodeFile returns the decrypted and decompressed content of a finished file with the extension of its
+// format, for instance json or proto
+func DecodeFile(path string, opts ...ReadOption) ([]byte, string, error) {
+	o := readOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return readFinishedFile(path, o)
+}
+
+// FileInfo describes how a finished file is encoded
+type FileInfo struct {
+	// Format is the extension of the format of the records, for instance json or proto
+	Format string
+	// Compression is the codec the file is compressed with, empty if it is not compressed
+	Compression string
+	Encrypted   bool
+}
+
+// ParseFileName returns how a finished file is encoded from the extensions of its name, false if it is not
+// the name of a finished file of a built in format, for instance a sidecar, a marker or an in process file
+func ParseFileName(name string) (FileInfo, bool) {
+	var info FileInfo
+	if strings.HasPrefix(name, ".") {
+		return info, false
+	}
+	for {
+		ext := strings.TrimPrefix(filepath.Ext(name), ".")
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		if ext == encryptedExt && !info.Encrypted {
+			info.Encrypted = true
+			continue
+		}
+		if codec := compressionOfExt(ext); len(codec) > 0 && len(info.Compression) == 0 {
+			info.Compression = codec
+			continue
+		}
+		for f := formatJSON; f < formatCustom; f++ {
+			if ext == f.ext() && len(name) > 0 {
+				info.Format = ext
+				return info, true
+			}
+		}
+		return FileInfo{}, false
+	}
+}
+
+// compressionOfExt returns the codec of the compression extension, empty if it is not one
+func compressionOfExt(ext string) string {
+	for codec, e := range compressionExt {
+		if e == ext {
+			return codec
+		}
+	}
+	return ""
+}
+
+// iterateFile decodes the file and calls fn with each record and whether it is json encoded
+func iterateFile(path string, opts []ReadOption, fn func(record []byte, isJSON bool) error) error {
+	o := readOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	data, ext, err := readFinishedFile(path, o)
+	if err != nil {
+		return err
+	}
+	switch ext {
+	case "proto":
+		return fn(data, false)
+	case "json":
+		documents, err := newJSONDocuments(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		for {
+			var record json.RawMessage
+			if err = documents.decode(&record); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("failed to read record of %s: %w", path, err)
+			}
+			if isFileHeader(record) || isBatchHeader(record) {
+				continue
+			}
+			if err = fn(record, true); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("%w: the %s files of %s cannot be read back", ErrUnsupportedFile, ext, path)
+}
+
+// readFinishedFile returns the decrypted and decompressed content of the file and the extension of its format
+func readFinishedFile(path string, o readOptions) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	name := filepath.Base(path)
+	for {
+		ext := strings.TrimPrefix(filepath.Ext(name), ".")
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		switch ext {
+		case encryptedExt:
+			if o.keyProvider == nil {
+				return nil, "", fmt.Errorf("%s is encrypted, a key must be provided with WithDecryptionKey", path)
+			}
+			key, err := o.keyProvider.Key()
+			if err != nil {
+				return nil, "", err
+			}
+			if data, err = Decrypt(data, key); err != nil {
+				return nil, "", fmt.Errorf("failed to decrypt %s: %w", path, err)
+			}
+		case compressionExt[CompressionGzip]:
+			r, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to decompress %s: %w", path, err)
+			}
+			if data, err = io.ReadAll(r); err != nil {
+				return nil, "", fmt.Errorf("failed to decompress %s: %w", path, err)
+			}
+		case compressionExt[CompressionZstd]:
+			r, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, "", err
+			}
+			data, err = r.DecodeAll(data, nil)
+			r.Close()
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to decompress %s: %w", path, err)
+			}
+		case compressionExt[CompressionLz4]:
+			if data, err = lz4Decompress(data); err != nil {
+				return nil, "", fmt.Errorf("failed to decompress %s: %w", path, err)
+			}
+		default:
+			return data, ext, nil
+		}
+	}
+}
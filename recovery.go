@@ -0,0 +1,226 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// RecoverResume keeps appending to the in process files left by a previous run
+	RecoverResume = "resume"
+	// RecoverFinalize finishes the in process files left by a previous run
+	RecoverFinalize = "finalize"
+	// RecoverDiscard deletes the in process files left by a previous run
+	RecoverDiscard = "discard"
+)
+
+// validateRecoverInProcess checks the recovery of orphan in process files is supported for the format,
+// parquet in process files have no footer and the row groups written by a previous run are unknown
+func validateRecoverInProcess(recover string, format string) error {
+	switch strings.ToLower(recover) {
+	case "", RecoverResume, RecoverFinalize, RecoverDiscard:
+	default:
+		return fmt.Errorf("invalid recoverInProcess [%s], valid values are [ %s, %s or %s ]", recover, RecoverResume, RecoverFinalize, RecoverDiscard)
+	}
+	if strings.EqualFold(format, Parquet) && len(recover) > 0 && !strings.EqualFold(recover, RecoverDiscard) {
+		return errors.New("parquet in process files can only be discarded, recoverInProcess must be discard")
+	}
+	return nil
+}
+
+// recoverInProcessFiles handles the in process files left under the path and mirror paths by a
+// previous run, it must be called before anything is written
+func (e *fileExporter) recoverInProcessFiles() error {
+	for _, root := range e.roots() {
+		if err := e.recoverInProcessFilesOf(root); err != nil {
+			return err
+		}
+		if err := e.warnPendingHandoff(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *fileExporter) recoverInProcessFilesOf(root string) error {
+	var files, staged []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == bundleDir {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && e.isInProcessName(d.Name()) && e.ownsInProcessName(d.Name()) {
+			files = append(files, p)
+		}
+		if !d.IsDir() && len(e.component) > 0 && (d.Name() == e.inProcessSuffix || d.Name() == legacyInProcessName) {
+			e.logger.Warn("leaving the inprocess file of the unnamed exporter, or written before the exporter name was part of the file names, to the unnamed exporter",
+				zap.String("file", p))
+		}
+		if !d.IsDir() && e.ownsStagingName(d.Name()) {
+			staged = append(staged, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// the partial compressed or encrypted files are removed before the in process files are finished again
+	for _, f := range staged {
+		if err = e.removeStagingFile(f); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err = e.recoverInProcessFile(f); err != nil {
+			e.logger.Error("failed to recover inprocess file", zap.String("file", f), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *fileExporter) recoverInProcessFile(f string) error {
+	if e.mmap {
+		if err := e.trimMmapTail(f); err != nil {
+			return err
+		}
+	}
+	dir := filepath.Dir(f)
+	shard, sharded := e.inProcessShard(filepath.Base(f))
+	if !sharded && f != e.inProcessFile(dir) {
+		// the file was left with the in process name of an earlier version or another platform
+		if _, err := e.fs.Stat(e.inProcessFile(dir)); err == nil {
+			return fmt.Errorf("cannot recover %s as the inprocess file %s also exists", f, e.inProcessFile(dir))
+		}
+		if err := e.fs.Rename(f, e.inProcessFile(dir)); err != nil {
+			return err
+		}
+		f = e.inProcessFile(dir)
+	}
+	w := e.writer(dir, e.routeOf(dir), shard)
+	recover := e.recoverInProcess
+	if len(recover) == 0 {
+		recover = RecoverResume
+	}
+	if sharded && shard >= e.shards && recover == RecoverResume {
+		// the shard is no longer configured so nothing would be appended to the file
+		recover = RecoverFinalize
+	}
+	if e.isSignalDir() && !isSignalDirName(dir) && recover == RecoverResume {
+		// the signals were interleaved by a previous run and are now written to their sub directories
+		recover = RecoverFinalize
+	}
+	if e.isParquet() {
+		recover = RecoverDiscard
+	}
+	restored := recover != RecoverDiscard && e.restoreInProcess(w, f)
+	if restored && w.eventsPerFile > 0 && w.currentEventCount >= w.eventsPerFile {
+		recover = RecoverFinalize
+	}
+	if recover == RecoverResume && w.eventsPerFile > 0 && !restored {
+		count, ok, err := e.countBatches(f)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			e.logger.Warn("the batches of the inprocess file cannot be counted for the format, finalizing it instead of resuming",
+				zap.String("file", f), zap.Stringer("format", e.format))
+			recover = RecoverFinalize
+		} else if count >= w.eventsPerFile {
+			recover = RecoverFinalize
+		} else {
+			w.currentEventCount = count
+		}
+	}
+	switch recover {
+	case RecoverDiscard:
+		e.logger.Warn("discarding inprocess file left by a previous run", zap.String("file", f))
+		return e.fs.Remove(f)
+	case RecoverFinalize:
+		e.logger.Info("finalizing inprocess file left by a previous run", zap.String("file", f))
+		return e.finishFile(w, f)
+	default:
+		e.logger.Info("resuming inprocess file left by a previous run", zap.String("file", f), zap.Int64("count", w.currentEventCount))
+		// the idle time of the resumed file starts now
+		w.lastWrite = time.Now()
+		if stat, err := e.fs.Stat(f); err == nil {
+			if w.bucket.IsZero() {
+				w.bucket = e.bucketStart(stat.ModTime())
+			}
+			// the time of the first write is not known, the age of the resumed file counts from its last write
+			w.opened = stat.ModTime()
+		}
+		return nil
+	}
+}
+
+// routeOf returns the route of the output directory
+func (e *fileExporter) routeOf(dir string) string {
+	if e.isSignalDir() && isSignalDirName(dir) {
+		// the signal sub directories are under the route directory
+		dir = filepath.Dir(dir)
+	}
+	if e.severityRoute != nil && filepath.Base(dir) == e.severityRoute.directory {
+		return e.severityRoute.directory
+	}
+	if l := e.laneOf(filepath.Base(dir)); l != nil {
+		return l.name
+	}
+	return ""
+}
+
+// countBatches returns the number of batches in the in process file, ok is false if the batches
+// cannot be counted for the format of the exporter or the events are counted by records
+func (e *fileExporter) countBatches(f string) (count int64, ok bool, err error) {
+	if !e.format.isJSON() || e.countRecords {
+		return 0, false, nil
+	}
+	file, err := e.fs.OpenFile(f, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	// every batch is a JSON document, a truncated last document is counted as it is kept in the file
+	documents, err := newJSONDocuments(io.NewSectionReader(file, 0, stat.Size()))
+	if err != nil {
+		return 0, true, nil
+	}
+	for {
+		var doc json.RawMessage
+		if err = documents.decode(&doc); err != nil {
+			if err == io.EOF {
+				return count, true, nil
+			}
+			return count + 1, true, nil
+		}
+		if !isFileHeader(doc) && !isBatchHeader(doc) {
+			count++
+		}
+	}
+}
@@ -0,0 +1,208 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	// RedactMask replaces the redacted values with redactedValue
+	RedactMask = "mask"
+	// RedactHash replaces the redacted values with the hex encoded SHA-256 hash of the value
+	RedactHash = "hash"
+
+	// the value written in place of a masked value
+	redactedValue = "***"
+)
+
+// RedactConfig defines the attributes whose values are redacted before any telemetry is written
+type RedactConfig struct {
+	// Keys are the attribute keys redacted
+	Keys []string `mapstructure:"keys"`
+	// KeyPatterns are regular expressions matching the attribute keys redacted
+	KeyPatterns []string `mapstructure:"keyPatterns"`
+	// Action defines how the values are redacted, valid values are mask and hash, it defaults to mask
+	Action string `mapstructure:"action"`
+}
+
+// Validate checks the expressions and action of the redaction
+func (cfg *RedactConfig) Validate() error {
+	if len(cfg.Keys) == 0 && len(cfg.KeyPatterns) == 0 {
+		return errors.New("redact requires keys or keyPatterns to be defined")
+	}
+	for _, expr := range cfg.KeyPatterns {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid redact key pattern [%s]: %w", expr, err)
+		}
+	}
+	switch strings.ToLower(cfg.Action) {
+	case "", RedactMask, RedactHash:
+		return nil
+	default:
+		return fmt.Errorf("invalid redact action [%s], valid values are [ %s or %s ]", cfg.Action, RedactMask, RedactHash)
+	}
+}
+
+// redactor is the compiled form of a RedactConfig
+type redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+	hash     bool
+}
+
+func newRedactor(cfg *RedactConfig) *redactor {
+	if cfg == nil {
+		return nil
+	}
+	r := &redactor{
+		keys:     make(map[string]bool),
+		patterns: compileAll(cfg.KeyPatterns),
+		hash:     strings.EqualFold(cfg.Action, RedactHash),
+	}
+	for _, key := range cfg.Keys {
+		r.keys[key] = true
+	}
+	return r
+}
+
+// matches returns true if the values of the attribute key are redacted
+func (r *redactor) matches(key string) bool {
+	return r.keys[key] || matchAny(r.patterns, key)
+}
+
+// redact replaces the values of the matching attributes, the keys of nested maps are matched as well
+func (r *redactor) redact(attrs pcommon.Map) {
+	attrs.Range(func(key string, v pcommon.Value) bool {
+		switch {
+		case r.matches(key):
+			v.SetStr(r.value(v))
+		case v.Type() == pcommon.ValueTypeMap:
+			r.redact(v.Map())
+		}
+		return true
+	})
+}
+
+// value returns the redacted form of a value
+func (r *redactor) value(v pcommon.Value) string {
+	if !r.hash {
+		return redactedValue
+	}
+	sum := sha256.Sum256([]byte(v.AsString()))
+	return hex.EncodeToString(sum[:])
+}
+
+// redactTraces returns a copy of the traces with the matching attributes redacted, the passed in traces
+// are not modified
+func (e *fileExporter) redactTraces(td ptrace.Traces) ptrace.Traces {
+	if e.redactor == nil {
+		return td
+	}
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	for i := 0; i < out.ResourceSpans().Len(); i++ {
+		rs := out.ResourceSpans().At(i)
+		e.redactor.redact(rs.Resource().Attributes())
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			e.redactor.redact(ss.Scope().Attributes())
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				e.redactor.redact(span.Attributes())
+				for l := 0; l < span.Events().Len(); l++ {
+					e.redactor.redact(span.Events().At(l).Attributes())
+				}
+				for l := 0; l < span.Links().Len(); l++ {
+					e.redactor.redact(span.Links().At(l).Attributes())
+				}
+			}
+		}
+	}
+	return out
+}
+
+// redactMetrics returns a copy of the metrics with the matching attributes redacted, the passed in metrics
+// are not modified
+func (e *fileExporter) redactMetrics(md pmetric.Metrics) pmetric.Metrics {
+	if e.redactor == nil {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	for i := 0; i < out.ResourceMetrics().Len(); i++ {
+		rm := out.ResourceMetrics().At(i)
+		e.redactor.redact(rm.Resource().Attributes())
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			e.redactor.redact(sm.Scope().Attributes())
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				e.redactDataPoints(sm.Metrics().At(k))
+			}
+		}
+	}
+	return out
+}
+
+func (e *fileExporter) redactDataPoints(metric pmetric.Metric) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			e.redactor.redact(metric.Gauge().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			e.redactor.redact(metric.Sum().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			e.redactor.redact(metric.Histogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			e.redactor.redact(metric.ExponentialHistogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			e.redactor.redact(metric.Summary().DataPoints().At(i).Attributes())
+		}
+	}
+}
+
+// redactLogs returns a copy of the logs with the matching attributes redacted, the passed in logs are not
+// modified
+func (e *fileExporter) redactLogs(ld plog.Logs) plog.Logs {
+	if e.redactor == nil {
+		return ld
+	}
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	for i := 0; i < out.ResourceLogs().Len(); i++ {
+		rl := out.ResourceLogs().At(i)
+		e.redactor.redact(rl.Resource().Attributes())
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			e.redactor.redact(sl.Scope().Attributes())
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				e.redactor.redact(sl.LogRecords().At(k).Attributes())
+			}
+		}
+	}
+	return out
+}
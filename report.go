@@ -0,0 +1,237 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// reportsDir is the sub directory of the path holding the daily reports
+	reportsDir = "reports"
+	// the layout of the date of the daily reports
+	reportDateLayout = "2006-01-02"
+	// reportInterval is how often the report of the day is saved while telemetry is written
+	reportInterval = time.Minute
+)
+
+// the name of the daily reports, with the instance when the path is shared
+var reportNameRegex = regexp.MustCompile(`^report-\d{4}-\d{2}-\d{2}(-.+)?\.json(\.tmp)?$`)
+
+// isReportName returns true if the file name is the name of a daily report
+func isReportName(name string) bool {
+	return reportNameRegex.MatchString(name)
+}
+
+// signalReport is the telemetry of a signal written during the day
+type signalReport struct {
+	Records int64 `json:"records"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// dailyReport is the rollup of the telemetry written during a day, saved under the reports sub directory of
+// the path so that a device can be checked without access to the backend
+type dailyReport struct {
+	Date            string                   `json:"date"`
+	Hostname        string                   `json:"hostname"`
+	Instance        string                   `json:"instance,omitempty"`
+	ExporterVersion string                   `json:"exporterVersion"`
+	Signals         map[string]*signalReport `json:"signals"`
+	FilesRotated    int64                    `json:"filesRotated"`
+	Errors          int64                    `json:"errors"`
+	// DroppedBatches and DroppedRecords are the telemetry dropped as per the oversize, backlog and disk full
+	// behaviours
+	DroppedBatches int64     `json:"droppedBatches"`
+	DroppedRecords int64     `json:"droppedRecords"`
+	FirstWrite     time.Time `json:"firstWrite,omitempty"`
+	LastWrite      time.Time `json:"lastWrite,omitempty"`
+	Updated        time.Time `json:"updated"`
+}
+
+// reporter accounts the telemetry written in the report of the day and saves it
+type reporter struct {
+	dir      string
+	instance string
+	hostname string
+	version  string
+	location *time.Location
+	logger   *zap.Logger
+	mutex    sync.Mutex
+	report   *dailyReport
+	// changed is true if the report changed since it was saved
+	changed bool
+}
+
+// newReporter returns the reporter of the exporter, nil if the daily reports are disabled
+func newReporter(cfg *Config, hostname string, logger *zap.Logger) *reporter {
+	if !cfg.DailyReport || cfg.DryRun {
+		return nil
+	}
+	return &reporter{
+		dir:      filepath.Join(cfg.Path, reportsDir),
+		instance: instanceOf(cfg),
+		hostname: hostname,
+		version:  exporterVersion(),
+		location: rotateLocation(cfg.TimestampTimezone),
+		logger:   logger,
+	}
+}
+
+// fileOf returns the report file of the day
+func (r *reporter) fileOf(date string) string {
+	name := "report-" + date
+	if len(r.instance) > 0 {
+		name += "-" + r.instance
+	}
+	return filepath.Join(r.dir, name+".json")
+}
+
+// current returns the report of the day, the report of the previous day is saved and a report written
+// earlier in the day by a previous run is continued; it must be called holding the mutex
+func (r *reporter) current(now time.Time) *dailyReport {
+	date := now.In(r.location).Format(reportDateLayout)
+	if r.report != nil && r.report.Date == date {
+		return r.report
+	}
+	if r.report != nil && r.changed {
+		r.save()
+	}
+	// the report is saved even if nothing is written during the day
+	r.changed = true
+	r.report = &dailyReport{
+		Date:            date,
+		Hostname:        r.hostname,
+		Instance:        r.instance,
+		ExporterVersion: r.version,
+		Signals:         make(map[string]*signalReport),
+	}
+	if content, err := os.ReadFile(r.fileOf(date)); err == nil {
+		if err = json.Unmarshal(content, r.report); err != nil {
+			r.logger.Warn("failed to read the daily report, starting a new one", zap.String("file", r.fileOf(date)), zap.Error(err))
+		}
+		r.report.Hostname, r.report.ExporterVersion = r.hostname, r.version
+		if r.report.Signals == nil {
+			r.report.Signals = make(map[string]*signalReport)
+		}
+	}
+	return r.report
+}
+
+// recordWrite accounts a batch written to the files
+func (r *reporter) recordWrite(signal string, records int, bytes int) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	report := r.current(now)
+	s, ok := report.Signals[signal]
+	if !ok {
+		s = &signalReport{}
+		report.Signals[signal] = s
+	}
+	s.Records += int64(records)
+	s.Bytes += int64(bytes)
+	if report.FirstWrite.IsZero() {
+		report.FirstWrite = now.UTC()
+	}
+	report.LastWrite = now.UTC()
+	r.changed = true
+}
+
+// recordRotated accounts a finished file
+func (r *reporter) recordRotated() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.current(time.Now()).FilesRotated++
+	r.changed = true
+}
+
+// recordError accounts an error writing or finishing a file
+func (r *reporter) recordError() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.current(time.Now()).Errors++
+	r.changed = true
+}
+
+// recordDropped accounts a dropped batch
+func (r *reporter) recordDropped(records int) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	report := r.current(time.Now())
+	report.DroppedBatches++
+	report.DroppedRecords += int64(records)
+	r.changed = true
+}
+
+// flush saves the report of the day if it changed, the report of the previous day is saved once the day
+// is over
+func (r *reporter) flush(now time.Time) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.current(now)
+	if r.changed {
+		r.save()
+	}
+}
+
+// save writes the report through a temporary file so that a report being read is never partial, it must
+// be called holding the mutex
+func (r *reporter) save() {
+	r.report.Updated = time.Now().UTC()
+	f := r.fileOf(r.report.Date)
+	content, err := json.MarshalIndent(r.report, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(r.dir, 0755); err == nil {
+			if err = os.WriteFile(f+".tmp", append(content, '\n'), 0644); err == nil {
+				err = os.Rename(f+".tmp", f)
+			}
+		}
+	}
+	if err != nil {
+		r.logger.Warn("failed to save the daily report", zap.String("file", f), zap.Error(err))
+		return
+	}
+	r.changed = false
+}
+
+// reportLoop saves the report of the day every minute until the exporter is shut down
+func (e *fileExporter) reportLoop() {
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			e.reporter.flush(now)
+		}
+	}
+}
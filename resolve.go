@@ -0,0 +1,186 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// the ${env:NAME} and ${file:path} placeholders of the configuration values resolved when the exporter starts
+var secretRegex = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// resolveValue replaces the ${env:NAME} placeholders of the value with the environment variable and the
+// ${file:path} placeholders with the content of the file, without its trailing white space, so that the
+// paths and secrets of a device can be provisioned without templating the configuration
+func resolveValue(value string) (string, error) {
+	var err error
+	resolved := secretRegex.ReplaceAllStringFunc(value, func(placeholder string) string {
+		match := secretRegex.FindStringSubmatch(placeholder)
+		if match[1] == "env" {
+			env, ok := os.LookupEnv(match[2])
+			if !ok && err == nil {
+				err = fmt.Errorf("the environment variable %s of %s is not set", match[2], placeholder)
+			}
+			return env
+		}
+		content, readErr := os.ReadFile(match[2])
+		if readErr != nil && err == nil {
+			err = fmt.Errorf("cannot read the secret file of %s: %w", placeholder, readErr)
+		}
+		return string(bytes.TrimRight(content, " \r\n\t"))
+	})
+	return resolved, err
+}
+
+// resolve returns a copy of the configuration with the placeholders of the paths, the encryption key and the
+// batch sequence source resolved, and checks the resolved values are valid; the configuration is already
+// validated so only the checks of the resolved values run again
+func (cfg *Config) resolve() (*Config, error) {
+	n := *cfg
+	values := []*string{&n.Path, &n.DeadLetterPath, &n.Encryption.Key, &n.Encryption.KeyFile}
+	n.MirrorPaths = append([]string(nil), cfg.MirrorPaths...)
+	for i := range n.MirrorPaths {
+		values = append(values, &n.MirrorPaths[i])
+	}
+	if cfg.Fallback != nil {
+		fallback := *cfg.Fallback
+		n.Fallback = &fallback
+		values = append(values, &n.Fallback.Path)
+	}
+	if cfg.BatchSequence != nil {
+		batchSequence := *cfg.BatchSequence
+		n.BatchSequence = &batchSequence
+		values = append(values, &n.BatchSequence.Source)
+	}
+	resolved := false
+	for _, value := range values {
+		if !secretRegex.MatchString(*value) {
+			continue
+		}
+		var err error
+		if *value, err = resolveValue(*value); err != nil {
+			return nil, err
+		}
+		resolved = true
+	}
+	if resolved {
+		if err := n.validateResolved(); err != nil {
+			return nil, fmt.Errorf("invalid configuration once resolved: %w", err)
+		}
+	}
+	return &n, nil
+}
+
+// validateResolved checks the values holding placeholders are still valid once resolved, a placeholder
+// may resolve to an empty value or to a path that overlaps another one
+func (cfg *Config) validateResolved() error {
+	if len(cfg.Path) == 0 {
+		return errors.New("path must be defined")
+	}
+	if err := cfg.Encryption.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.validateMirrors(); err != nil {
+		return err
+	}
+	return cfg.validateFallback()
+}
+
+// errNotStarted is returned by the exporters created by the factory for the telemetry sent before they start
+var errNotStarted = errors.New("the file exporter is not started")
+
+// resolvingExporter is the exporter created by the factory for the pipelines sharing a configuration, the
+// placeholders of the configuration are resolved and checked when it starts and the exporter of the resolved
+// configuration is only created then, so that the values provisioned before the collector starts are used
+type resolvingExporter struct {
+	cfg       *Config
+	set       component.ExporterCreateSettings
+	marshaler Marshaler
+	onRotate  OnRotateFunc
+	mutex     sync.Mutex
+	// signals are the signals of the pipelines using the exporter
+	signals []string
+	// fe is the exporter of the resolved configuration, nil until it starts
+	fe *fileExporter
+}
+
+// usedBy records the exporter is used by a pipeline of the signal
+func (r *resolvingExporter) usedBy(signal string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.signals = append(r.signals, signal)
+}
+
+// Start resolves the configuration and starts the exporter of the resolved configuration
+func (r *resolvingExporter) Start(ctx context.Context, host component.Host) error {
+	resolved, err := r.cfg.resolve()
+	if err != nil {
+		return err
+	}
+	fe := newFileExporter(resolved, r.set, r.marshaler, r.onRotate)
+	r.mutex.Lock()
+	for _, signal := range r.signals {
+		fe.usedBy(signal)
+	}
+	r.fe = fe
+	r.mutex.Unlock()
+	registerStatus(r.cfg.ID(), fe)
+	return fe.Start(ctx, host)
+}
+
+// Shutdown shuts the exporter of the resolved configuration down, if it was started
+func (r *resolvingExporter) Shutdown(ctx context.Context) error {
+	fe := r.started()
+	if fe == nil {
+		return nil
+	}
+	return fe.Shutdown(ctx)
+}
+
+// started returns the exporter of the resolved configuration, nil if the exporter is not started
+func (r *resolvingExporter) started() *fileExporter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.fe
+}
+
+func (r *resolvingExporter) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	fe := r.started()
+	if fe == nil {
+		return errNotStarted
+	}
+	return fe.ConsumeTraces(ctx, td)
+}
+
+func (r *resolvingExporter) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	fe := r.started()
+	if fe == nil {
+		return errNotStarted
+	}
+	return fe.ConsumeMetrics(ctx, md)
+}
+
+func (r *resolvingExporter) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	fe := r.started()
+	if fe == nil {
+		return errNotStarted
+	}
+	return fe.ConsumeLogs(ctx, ld)
+}
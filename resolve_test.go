@@ -0,0 +1,112 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestResolveValidatedDefaultRotation(t *testing.T) {
+	for _, def := range []string{fileSize, eventsSize} {
+		t.Run(def, func(t *testing.T) {
+			path := t.TempDir()
+			t.Setenv("FILE_EXPORTER_TEST_PATH", path)
+			cfg := DefaultConfig()
+			cfg.Path = "${env:FILE_EXPORTER_TEST_PATH}"
+			cfg.Format = "json"
+			cfg.Default = def
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			resolved, err := cfg.resolve()
+			if err != nil {
+				t.Fatalf("expected the validated configuration to resolve, got %v", err)
+			}
+			if resolved.Path != path || cfg.Path != "${env:FILE_EXPORTER_TEST_PATH}" {
+				t.Fatalf("expected the path of the copy to be resolved to %s, got %s", path, resolved.Path)
+			}
+			// New validates the configuration before resolving it
+			cfg.FileSizeKb, cfg.EventsPerFile = 0, 0
+			e, err := New(cfg)
+			if err != nil {
+				t.Fatalf("expected the exporter to be created, got %v", err)
+			}
+			if err = e.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestResolveRejectsEmptyPath(t *testing.T) {
+	t.Setenv("FILE_EXPORTER_TEST_PATH", "")
+	cfg := testConfig(t, 1<<20)
+	cfg.Path = "${env:FILE_EXPORTER_TEST_PATH}"
+	if _, err := cfg.resolve(); err == nil {
+		t.Fatal("expected the path resolved to an empty value to be rejected")
+	}
+}
+
+func TestFactoryResolvesOnStart(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t, 1<<20)
+	cfg.Path = "${env:FILE_EXPORTER_TEST_PATH}"
+	t.Setenv("FILE_EXPORTER_TEST_PATH", "")
+	f := NewFactory()
+	set := componenttest.NewNopExporterCreateSettings()
+	// the path is only provisioned once the exporters are created
+	traces, err := f.CreateTracesExporter(ctx, set, cfg)
+	if err != nil {
+		t.Fatalf("expected the exporter to be created before its path is provisioned, got %v", err)
+	}
+	logs, err := f.CreateLogsExporter(ctx, set, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = traces.Start(ctx, componenttest.NewNopHost()); err == nil {
+		t.Fatal("expected the start to fail while the path resolves to an empty value")
+	}
+	if err = traces.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir()
+	t.Setenv("FILE_EXPORTER_TEST_PATH", path)
+	traces, err = f.CreateTracesExporter(ctx, set, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, err = f.CreateLogsExporter(ctx, set, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []component.Component{traces, logs} {
+		if err = c.Start(ctx, componenttest.NewNopHost()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, c := range []component.Component{traces, logs} {
+			_ = c.Shutdown(ctx)
+		}
+	}()
+	if _, ok := ExporterStatus(cfg.ID()); !ok {
+		t.Fatal("expected the status of the started exporter")
+	}
+	if err = traces.ConsumeTraces(ctx, testTraces()); err != nil {
+		t.Fatal(err)
+	}
+	// the pipelines of both signals share the exporter writing to the resolved path
+	if files := listFiles(t, path); len(files) == 0 {
+		t.Fatalf("expected the traces to be written to the resolved path %s", path)
+	}
+}
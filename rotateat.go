@@ -0,0 +1,111 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	RotateHourly = "hourly"
+	RotateDaily  = "daily"
+
+	// the file name template used by default when the files are aligned with clock boundaries
+	bucketFileNameTemplate = "{bucket}-{seq}.{ext}"
+	// how often the clock boundaries are checked
+	rotateAtInterval = time.Second
+)
+
+// validateRotateAt checks the clock boundary and its time zone are supported
+func (cfg *Config) validateRotateAt() error {
+	switch strings.ToLower(cfg.RotateAt) {
+	case "":
+		if strings.Contains(cfg.FileNameTemplate, "{bucket}") {
+			return errors.New("the {bucket} placeholder requires rotateAt to be defined")
+		}
+		return nil
+	case RotateHourly, RotateDaily:
+	default:
+		return fmt.Errorf("invalid rotateAt [%s], valid values are [ %s or %s ]", cfg.RotateAt, RotateHourly, RotateDaily)
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("rotateAt requires rotation as there is no in process file to finish")
+	}
+	if _, err := time.LoadLocation(cfg.RotateTimezone); err != nil {
+		return fmt.Errorf("invalid rotateTimezone [%s]: %w", cfg.RotateTimezone, err)
+	}
+	return nil
+}
+
+// rotateLocation returns the IANA time zone of the clock boundaries or timestamps, UTC if it cannot be loaded
+func rotateLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// bucketStart returns the start of the clock boundary bucket holding the time, zero if the files are
+// not aligned with clock boundaries
+func (e *fileExporter) bucketStart(t time.Time) time.Time {
+	t = t.In(e.rotateLocation)
+	switch e.rotateAt {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, e.rotateLocation)
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, e.rotateLocation)
+	}
+	return time.Time{}
+}
+
+// bucketName returns the value of the {bucket} placeholder for the bucket start
+func (e *fileExporter) bucketName(start time.Time) string {
+	switch e.rotateAt {
+	case RotateHourly:
+		return start.Format("2006_01_02_15")
+	case RotateDaily:
+		return start.Format("2006_01_02")
+	}
+	return ""
+}
+
+// rotateIfBoundary finishes the in process file of the writer if it holds data of a bucket before the
+// bucket of the time, so that no file spans a clock boundary; it must be called holding the writer lock
+func (e *fileExporter) rotateIfBoundary(w *fileWriter, now time.Time) error {
+	if len(e.rotateAt) == 0 {
+		return nil
+	}
+	bucket := e.bucketStart(now)
+	if !w.bucket.IsZero() && w.bucket.Before(bucket) {
+		if f, ok := e.pendingInProcess(w); ok {
+			e.debug("finishing inprocess file at clock boundary", zap.String("file", f), zap.Time("bucket", w.bucket))
+			if err := e.finishFile(w, f); err != nil {
+				return err
+			}
+		}
+	}
+	if w.bucket.IsZero() || w.bucket.Before(bucket) {
+		w.bucket = bucket
+	}
+	return nil
+}
+
+// rotateAtBoundaries finishes the in process files of all the writers crossing a clock boundary
+func (e *fileExporter) rotateAtBoundaries(now time.Time) error {
+	return e.eachWriter(context.Background(), func(w *fileWriter) error {
+		return e.rotateIfBoundary(w, now)
+	})
+}
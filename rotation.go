@@ -0,0 +1,139 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// RotationNone appends everything to a single fixed file that is never rotated
+	RotationNone = "none"
+)
+
+// validateRotation checks the rotation mode is supported and compatible with the other settings,
+// without rotation there are no finished files to post process
+func (cfg *Config) validateRotation() error {
+	switch strings.ToLower(cfg.Rotation) {
+	case "":
+		if cfg.TruncateOnStart {
+			return errors.New("truncateOnStart is only supported when rotation is none")
+		}
+		return nil
+	case RotationNone:
+	default:
+		return fmt.Errorf("invalid rotation [%s], valid value is [ %s ]", cfg.Rotation, RotationNone)
+	}
+	if cfg.IdleFlushSeconds > 0 {
+		return errors.New("idleFlushSeconds requires rotation as there is no in process file to finish")
+	}
+	if strings.ContainsAny(cfg.FileName, `/\`) {
+		return fmt.Errorf("invalid fileName [%s], it must not contain path separators", cfg.FileName)
+	}
+	if strings.EqualFold(cfg.Format, Parquet) {
+		return errors.New("the parquet format requires rotation as its footer is written when a file is finished")
+	}
+	if cfg.Encryption.Enabled || cfg.Bundle.Enabled || (len(cfg.Compression) > 0 && !strings.EqualFold(cfg.Compression, CompressionNone)) {
+		return errors.New("compression, encryption and bundle require rotation as they apply to finished files")
+	}
+	return nil
+}
+
+// validateRotationHeadroom checks the headroom leaves room for the batches in the files rotated by size
+func (cfg *Config) validateRotationHeadroom() error {
+	headroom := cfg.RotationHeadroomBytes
+	if headroom == 0 {
+		return nil
+	}
+	if headroom < 0 {
+		return errors.New("rotationHeadroomBytes must not be negative")
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("rotationHeadroomBytes requires rotation as the single file is never rotated")
+	}
+	sizes := []int64{cfg.fileSizeBytes()}
+	if cfg.RouteBySeverity != nil {
+		sizes = append(sizes, cfg.RouteBySeverity.FileSizeBytes)
+	}
+	for _, lane := range cfg.Lanes {
+		sizes = append(sizes, lane.FileSizeBytes)
+	}
+	rotatedBySize := false
+	for _, size := range sizes {
+		if size == 0 {
+			continue
+		}
+		if headroom >= size {
+			return fmt.Errorf("invalid rotationHeadroomBytes [%d], it must be less than the file size of %d bytes", headroom, size)
+		}
+		rotatedBySize = true
+	}
+	if !rotatedBySize {
+		return errors.New("rotationHeadroomBytes requires the files to be rotated by fileSize")
+	}
+	return nil
+}
+
+// isRotationNone returns true if the exporter appends to a single fixed file
+func (e *fileExporter) isRotationNone() bool {
+	return strings.EqualFold(e.rotation, RotationNone)
+}
+
+// singleFileName returns the name of the fixed file, defaulting to telemetry.<ext>
+func (e *fileExporter) singleFileName() string {
+	if len(e.fileName) > 0 {
+		return e.fileName
+	}
+	return fmt.Sprintf("telemetry.%s", e.finishedExt())
+}
+
+// writeSingleFile appends the batch to the fixed file of the writer directory
+func (e *fileExporter) writeSingleFile(w *fileWriter, b *batch) error {
+	f := filepath.Join(w.path, e.singleFileName())
+	if err := e.appendBatch(w, b, f, 0644); err != nil {
+		e.writeFailed(w, f, "failed to append data to file", err)
+		return err
+	}
+	return nil
+}
+
+// truncateSingleFiles truncates the fixed files under the path and mirror paths, including the partition
+// sub directories
+func (e *fileExporter) truncateSingleFiles() error {
+	for _, root := range e.roots() {
+		if err := e.truncateSingleFilesOf(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *fileExporter) truncateSingleFilesOf(root string) error {
+	name := e.singleFileName()
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != name {
+			return nil
+		}
+		e.debug("truncating file on start", zap.String("file", p))
+		return os.Truncate(p, 0)
+	})
+}
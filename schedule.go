@@ -0,0 +1,110 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// the shortest interval between two runs of the scheduled tasks
+const minScheduleInterval = 100 * time.Millisecond
+
+// scheduleInterval returns how often the time based rotation and flush, the rotate trigger, the bundling
+// and the probe of the unavailable path run, zero if none is configured
+func (e *fileExporter) scheduleInterval() time.Duration {
+	var interval time.Duration
+	if len(e.rotateTrigger) > 0 {
+		interval = rotateTriggerInterval
+	}
+	if len(e.rotateAt) > 0 && (interval == 0 || rotateAtInterval < interval) {
+		interval = rotateAtInterval
+	}
+	if flush := e.flushInterval / 2; flush > 0 && (interval == 0 || flush < interval) {
+		interval = flush
+	}
+	if idle := e.idleFlush / 10; idle > 0 && (interval == 0 || idle < interval) {
+		interval = idle
+	}
+	if age := e.maxInProcessAge / 10; age > 0 && (interval == 0 || age < interval) {
+		interval = age
+	}
+	if e.bundle.Enabled && e.bundle.MaxAge > 0 {
+		if bundle := e.bundle.MaxAge / 4; interval == 0 || bundle < interval {
+			interval = bundle
+		}
+	}
+	if e.fallback != nil && (interval == 0 || e.fallback.probeInterval < interval) {
+		interval = e.fallback.probeInterval
+	}
+	if interval > 0 && interval < minScheduleInterval {
+		interval = minScheduleInterval
+	}
+	return interval
+}
+
+// scheduleLoop runs the time based rotation, the rotate trigger and the bundling of all the writers until
+// the exporter shuts down
+func (e *fileExporter) scheduleLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			if e.fallback != nil {
+				e.probeFallback(now)
+			}
+			if len(e.rotateTrigger) > 0 {
+				if err := e.rotateIfTriggered(); err != nil {
+					e.logger.Error("failed to rotate on trigger", zap.Error(err))
+				}
+			}
+			if err := e.rotateAtBoundaries(now); err != nil {
+				e.logger.Error("failed to rotate at clock boundary", zap.Error(err))
+			}
+			_ = e.eachWriter(context.Background(), func(w *fileWriter) error {
+				if err := e.flushIfDue(w, now); err != nil {
+					e.logger.Error("failed to flush aggregated batches", zap.String("path", w.path), zap.Error(err))
+				}
+				if err := e.flushIfIdle(w, now); err != nil {
+					e.logger.Error("failed to finish idle inprocess file", zap.String("path", w.path), zap.Error(err))
+				}
+				if err := e.finishIfAged(w, now); err != nil {
+					e.logger.Error("failed to finish aged inprocess file", zap.String("path", w.path), zap.Error(err))
+				}
+				if e.bundle.Enabled && e.bundle.MaxAge > 0 {
+					if err := e.bundleIfDue(w, now); err != nil {
+						e.logger.Error("failed to bundle finished files", zap.String("path", w.path), zap.Error(err))
+					}
+				}
+				return nil
+			})
+		}
+	}
+}
+
+// flushIfIdle finishes the in process file of the writer if it is not empty and no data has been written
+// to it for the idle flush time, it must be called holding the writer lock
+func (e *fileExporter) flushIfIdle(w *fileWriter, now time.Time) error {
+	if e.idleFlush == 0 || w.lastWrite.IsZero() || now.Sub(w.lastWrite) < e.idleFlush {
+		return nil
+	}
+	f, ok := e.pendingInProcess(w)
+	if !ok {
+		w.lastWrite = time.Time{}
+		return nil
+	}
+	e.debug("finishing idle inprocess file", zap.String("file", f), zap.Duration("idle", now.Sub(w.lastWrite)))
+	w.lastWrite = time.Time{}
+	return e.finishFile(w, f)
+}
@@ -0,0 +1,165 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	// the default minimum severity of the log records routed to their own file series
+	defaultRouteMinSeverity = "ERROR"
+	// the default sub directory of the routed log records
+	defaultRouteDirectory = "severe"
+)
+
+// SeverityRoutingConfig routes the log records at or above a severity to their own directory and file series
+type SeverityRoutingConfig struct {
+	// MinSeverity is the lowest severity routed, one of TRACE, DEBUG, INFO, WARN, ERROR or FATAL, defaults to ERROR
+	MinSeverity string `mapstructure:"minSeverity"`
+	// Directory is the sub directory of the path receiving the routed log records, defaults to severe
+	Directory string `mapstructure:"directory"`
+	// FileSizeBytes and EventsPerFile define the rotation of the routed files, if neither is defined the
+	// rotation of the exporter is used
+	FileSizeBytes int64 `mapstructure:"fileSizeBytes"`
+	EventsPerFile int64 `mapstructure:"eventsPerFile"`
+}
+
+// Validate checks if the severity routing configuration is valid
+func (cfg *SeverityRoutingConfig) Validate() error {
+	if len(cfg.MinSeverity) > 0 {
+		if _, ok := severityRanges[strings.ToUpper(cfg.MinSeverity)]; !ok {
+			return fmt.Errorf("invalid routeBySeverity minSeverity [%s], valid values are [ TRACE, DEBUG, INFO, WARN, ERROR or FATAL ]", cfg.MinSeverity)
+		}
+	}
+	if strings.ContainsAny(cfg.Directory, `/\`) || cfg.Directory == "." || cfg.Directory == ".." {
+		return fmt.Errorf("invalid routeBySeverity directory [%s], it must be a single directory name", cfg.Directory)
+	}
+	if cfg.FileSizeBytes < 0 || cfg.EventsPerFile < 0 {
+		return errors.New("routeBySeverity fileSizeBytes and eventsPerFile must not be negative")
+	}
+	if cfg.FileSizeBytes > 0 && cfg.EventsPerFile > 0 {
+		return errors.New("mention either fileSizeBytes or eventsPerFile for routeBySeverity")
+	}
+	return nil
+}
+
+// severityRoute is the resolved severity routing configuration
+type severityRoute struct {
+	minSeverity   plog.SeverityNumber
+	directory     string
+	fileSize      int64
+	eventsPerFile int64
+}
+
+func newSeverityRoute(cfg *SeverityRoutingConfig) *severityRoute {
+	if cfg == nil {
+		return nil
+	}
+	r := &severityRoute{
+		minSeverity:   severityRanges[defaultRouteMinSeverity][0],
+		directory:     defaultRouteDirectory,
+		fileSize:      cfg.FileSizeBytes,
+		eventsPerFile: cfg.EventsPerFile,
+	}
+	if len(cfg.MinSeverity) > 0 {
+		r.minSeverity = severityRanges[strings.ToUpper(cfg.MinSeverity)][0]
+	}
+	if len(cfg.Directory) > 0 {
+		r.directory = cfg.Directory
+	}
+	return r
+}
+
+// routed returns true if the log record is at or above the minimum severity, the severity text is
+// used for records without a severity number
+func (r *severityRoute) routed(lr plog.LogRecord) bool {
+	severity := lr.SeverityNumber()
+	if severity == plog.SeverityNumberUnspecified {
+		sr, ok := severityRanges[strings.ToUpper(lr.SeverityText())]
+		if !ok {
+			return false
+		}
+		severity = sr[0]
+	}
+	return severity >= r.minSeverity
+}
+
+// routeLogs splits the logs into the records written to the default files and the records routed by severity
+func (e *fileExporter) routeLogs(ld plog.Logs) (plog.Logs, plog.Logs) {
+	if e.severityRoute == nil {
+		return ld, plog.NewLogs()
+	}
+	rest, routed := newLogsBuilder(), newLogsBuilder()
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		rest.resource, routed.resource = nil, nil
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			rest.scope, routed.scope = nil, nil
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				lr := sl.LogRecords().At(k)
+				if e.severityRoute.routed(lr) {
+					routed.append(rl, sl, lr)
+				} else {
+					rest.append(rl, sl, lr)
+				}
+			}
+		}
+	}
+	return rest.logs, routed.logs
+}
+
+// logsBuilder copies log records to new logs keeping their resource and scope
+type logsBuilder struct {
+	logs     plog.Logs
+	resource *plog.ResourceLogs
+	scope    *plog.ScopeLogs
+}
+
+func newLogsBuilder() *logsBuilder {
+	return &logsBuilder{logs: plog.NewLogs()}
+}
+
+// append copies the log record, the resource and scope are copied the first time a record of them is appended
+func (b *logsBuilder) append(rl plog.ResourceLogs, sl plog.ScopeLogs, lr plog.LogRecord) {
+	if b.resource == nil {
+		outRl := b.logs.ResourceLogs().AppendEmpty()
+		rl.Resource().CopyTo(outRl.Resource())
+		outRl.SetSchemaUrl(rl.SchemaUrl())
+		b.resource = &outRl
+	}
+	if b.scope == nil {
+		outSl := b.resource.ScopeLogs().AppendEmpty()
+		sl.Scope().CopyTo(outSl.Scope())
+		outSl.SetSchemaUrl(sl.SchemaUrl())
+		b.scope = &outSl
+	}
+	lr.CopyTo(b.scope.LogRecords().AppendEmpty())
+}
+
+// rotationLimits returns the file size in bytes and events per file of the files of a route or lane, the file size
+// is lowered by the rotation headroom
+func (e *fileExporter) rotationLimits(route string) (int64, int64) {
+	fileSize, eventsPerFile := e.fileSize, e.eventsPerFile
+	if len(route) > 0 && e.severityRoute != nil && (e.severityRoute.fileSize > 0 || e.severityRoute.eventsPerFile > 0) {
+		fileSize, eventsPerFile = e.severityRoute.fileSize, e.severityRoute.eventsPerFile
+	}
+	if l := e.laneOf(route); len(route) > 0 && l != nil && (l.fileSize > 0 || l.eventsPerFile > 0) {
+		fileSize, eventsPerFile = l.fileSize, l.eventsPerFile
+	}
+	if fileSize > 0 {
+		fileSize -= e.rotationHeadroom
+	}
+	return fileSize, eventsPerFile
+}
@@ -0,0 +1,108 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// the maximum number of in process files written concurrently to a directory
+const maxShards = 256
+
+// writerKey identifies the writer of a shard of an output directory
+type writerKey struct {
+	path  string
+	shard int
+}
+
+// validateShards checks the shards can be told apart by their file names
+func (cfg *Config) validateShards() error {
+	if cfg.Shards < 0 || cfg.Shards > maxShards {
+		return fmt.Errorf("invalid shards [%d], it must be between 0 and %d", cfg.Shards, maxShards)
+	}
+	if cfg.Shards <= 1 {
+		if strings.Contains(cfg.FileNameTemplate, "{shard}") {
+			return errors.New("the {shard} placeholder requires more than one shard")
+		}
+		return nil
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("shards require rotation as the shards cannot share a single file")
+	}
+	if cfg.Bundle.Enabled {
+		return errors.New("bundling is not supported with shards, the shards would bundle each other's files")
+	}
+	custom := len(cfg.FileNameTemplate) > 0 && cfg.FileNameTemplate != defaultFileNameTemplate
+	if custom && !strings.Contains(cfg.FileNameTemplate, "{shard}") {
+		return fmt.Errorf("invalid fileNameTemplate [%s], it must contain the {shard} placeholder so that the files of the shards can be told apart", cfg.FileNameTemplate)
+	}
+	return nil
+}
+
+// shardFileNameTemplate adds the shard to the default file name template so that the shards do not
+// compete for the same sequence numbers
+func shardFileNameTemplate(template string, shards int) string {
+	if shards <= 1 || strings.Contains(template, "{shard}") {
+		return template
+	}
+	return strings.Replace(template, "-{seq}", "-{shard}-{seq}", 1)
+}
+
+// nextShard returns the shard the next batch is written to, the batches are spread round robin
+func (e *fileExporter) nextShard() int {
+	if e.shards <= 1 {
+		return 0
+	}
+	return int((atomic.AddUint64(&e.shardCounter, 1) - 1) % uint64(e.shards))
+}
+
+// shardInstance returns the instance part of the in process file name of the shard, the first shard keeps
+// the name of the in process file written without shards
+func (e *fileExporter) shardInstance(shard int) string {
+	if shard == 0 {
+		return e.instance
+	}
+	return e.shardPrefix() + strconv.Itoa(shard)
+}
+
+// shardPrefix is the instance part of the in process file names of the shards before the shard number
+func (e *fileExporter) shardPrefix() string {
+	if len(e.instance) > 0 {
+		return e.instance + "-shard"
+	}
+	return "shard"
+}
+
+// inProcessFileOf returns the in process file of the writer
+func (e *fileExporter) inProcessFileOf(w *fileWriter) string {
+	return filepath.Join(w.path, inProcessNameOf(e.inProcessSuffix, e.shardInstance(w.shard)))
+}
+
+// inProcessShard returns the shard of the in process file written by this exporter, false if the name is not
+// the name of a shard after the first, including the shards no longer configured
+func (e *fileExporter) inProcessShard(name string) (int, bool) {
+	suffix := e.inProcessSuffix
+	if len(name) <= len(suffix)+1 || name[0] != suffix[0] || !strings.HasSuffix(name, suffix) {
+		return 0, false
+	}
+	part := name[1 : len(name)-len(suffix)]
+	if !strings.HasPrefix(part, e.shardPrefix()) {
+		return 0, false
+	}
+	shard, err := strconv.Atoi(strings.TrimPrefix(part, e.shardPrefix()))
+	if err != nil || shard < 1 || strconv.Itoa(shard) != strings.TrimPrefix(part, e.shardPrefix()) {
+		return 0, false
+	}
+	return shard, true
+}
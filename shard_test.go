@@ -0,0 +1,69 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestShardsWithDefaultFileNameTemplate(t *testing.T) {
+	cfg := testConfig(t, 16)
+	cfg.Shards = 3
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected shards to be valid with the default file name template, got %v", err)
+	}
+	e := startTestExporter(t, cfg)
+	var lines []string
+	for i := 0; i < 12; i++ {
+		line := fmt.Sprintf(`{"a":%d}`, i)
+		lines = append(lines, line)
+		if err := writeLine(e, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inProcess := map[string]bool{}
+	for shard := 0; shard < cfg.Shards; shard++ {
+		inProcess[inProcessNameOf(e.inProcessSuffix, e.shardInstance(shard))] = true
+	}
+	if len(inProcess) != cfg.Shards {
+		t.Fatalf("expected an in process file per shard, got %v", inProcess)
+	}
+	finished := finishedFilesOf(t, e)
+	if len(finished) < cfg.Shards {
+		t.Fatalf("expected every shard to rotate, got %v", finished)
+	}
+	// the finished files of the shards have their own names and hold every line once with the in process files
+	var written []string
+	for _, f := range finished {
+		written = append(written, splitLines(readFile(t, f))...)
+	}
+	for name := range inProcess {
+		written = append(written, splitLines(readFile(t, filepath.Join(cfg.Path, name)))...)
+	}
+	sort.Strings(lines)
+	sort.Strings(written)
+	if strings.Join(written, " ") != strings.Join(lines, " ") {
+		t.Fatalf("expected the lines %v to be written once, got %v", lines, written)
+	}
+}
+
+// splitLines splits the content of raw batches written without a separator into its json lines
+func splitLines(content string) []string {
+	var lines []string
+	for _, l := range strings.SplitAfter(content, "}") {
+		if len(l) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -18,6 +18,7 @@ import (
 // SharedComponents a map that keeps reference of all created instances for a given configuration,
 // and ensures that the shared state is started and stopped only once.
 type SharedComponents struct {
+	mu    sync.Mutex
 	comps map[interface{}]*SharedComponent
 }
 
@@ -31,12 +32,16 @@ func NewSharedComponents() *SharedComponents {
 // GetOrAdd returns the already created instance if exists, otherwise creates a new instance
 // and adds it to the map of references.
 func (scs *SharedComponents) GetOrAdd(key interface{}, create func() component.Component) *SharedComponent {
+	scs.mu.Lock()
+	defer scs.mu.Unlock()
 	if c, ok := scs.comps[key]; ok {
 		return c
 	}
 	newComp := &SharedComponent{
 		Component: create(),
 		removeFunc: func() {
+			scs.mu.Lock()
+			defer scs.mu.Unlock()
 			delete(scs.comps, key)
 		},
 	}
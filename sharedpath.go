@@ -0,0 +1,123 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// the file locked while the finished files of a directory shared by several instances are renamed
+const dirLockFile = ".rotate.lock"
+
+var (
+	instanceRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// the characters of the component names that cannot be used in the file names
+	invalidInstanceChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+)
+
+// validateInstanceID checks the instance id can be used in file names and the path can be shared
+func (cfg *Config) validateInstanceID() error {
+	if len(cfg.InstanceID) == 0 {
+		if strings.Contains(cfg.FileNameTemplate, "{instance}") && len(cfg.componentInstance()) == 0 {
+			return errors.New("the {instance} placeholder requires instanceId to be defined or the exporter to be named")
+		}
+		return nil
+	}
+	if !instanceRegex.MatchString(cfg.InstanceID) {
+		return fmt.Errorf("invalid instanceId [%s], it must only contain letters, digits, underscores and hyphens", cfg.InstanceID)
+	}
+	if strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("instanceId requires rotation as the instances cannot share a single file")
+	}
+	if cfg.Bundle.Enabled {
+		return errors.New("bundling is not supported with instanceId, the instances would bundle each other's files")
+	}
+	return nil
+}
+
+// componentInstance returns the name of the exporter entry as it appears in the file names, file/a writes
+// its files as the instance a so that the entries of a collector sharing the path never write to the same
+// names and their files can be told apart; it is empty for the unnamed entry and when the files are not
+// rotated or are bundled, as the entries cannot share a single file or their bundles either way
+func (cfg *Config) componentInstance() string {
+	if strings.EqualFold(cfg.Rotation, RotationNone) || cfg.Bundle.Enabled {
+		return ""
+	}
+	return invalidInstanceChars.ReplaceAllString(cfg.ID().Name(), "_")
+}
+
+// instanceOf returns the instance of the exporter in the names of its files, the instance id followed by the
+// name of the exporter entry
+func instanceOf(cfg *Config) string {
+	component := cfg.componentInstance()
+	switch {
+	case len(cfg.InstanceID) == 0:
+		return component
+	case len(component) == 0:
+		return cfg.InstanceID
+	}
+	return cfg.InstanceID + "-" + component
+}
+
+// instanceFileNameTemplate adds the instance to the default file name templates so that the instances
+// sharing the path do not compete for the same sequence numbers
+func instanceFileNameTemplate(template, instance string) string {
+	if len(instance) == 0 || (template != defaultFileNameTemplate && template != bucketFileNameTemplate) {
+		return template
+	}
+	return strings.Replace(template, "-{seq}", "-{instance}-{seq}", 1)
+}
+
+// inProcessFile returns the in process file of the exporter in the directory, named after the instance
+// when the path is shared
+func (e *fileExporter) inProcessFile(dir string) string {
+	return filepath.Join(dir, inProcessNameOf(e.inProcessSuffix, e.instance))
+}
+
+// ownsInProcessName returns true if the in process file was written by this exporter or one of its shards,
+// the in process files of the other instances sharing the path are left to them
+func (e *fileExporter) ownsInProcessName(name string) bool {
+	if _, ok := e.inProcessShard(name); ok {
+		return true
+	}
+	if len(e.instance) > 0 {
+		return name == inProcessNameOf(e.inProcessSuffix, e.instance)
+	}
+	return name == e.inProcessSuffix || name == legacyInProcessName
+}
+
+// lockDir takes the advisory lock of the directory so that one instance or shard at a time renames and
+// post processes its finished files, the returned function releases it; nothing is locked if the path is
+// neither shared nor sharded
+func (e *fileExporter) lockDir(dir string) (func(), error) {
+	if len(e.instance) == 0 && e.shards <= 1 {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(filepath.Join(dir, dirLockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() {
+		if err := unlockFile(f); err != nil {
+			e.logger.Warn("failed to unlock directory", zap.String("file", f.Name()), zap.Error(err))
+		}
+		_ = f.Close()
+	}, nil
+}
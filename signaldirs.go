@@ -0,0 +1,54 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// validateInterleaveSignals checks the signals can be written to the same files
+func (cfg *Config) validateInterleaveSignals() error {
+	if !cfg.InterleaveSignals {
+		return nil
+	}
+	if cfg.SignalDirectories {
+		return errors.New("mention either interleaveSignals or signalDirectories")
+	}
+	for _, format := range cfg.formats() {
+		if strings.EqualFold(format, Parquet) {
+			return errors.New("interleaveSignals is not supported for parquet, the signals have different schemas")
+		}
+	}
+	return nil
+}
+
+// usedBy records the exporter is used by a pipeline of the signal, it must be called before the exporter
+// starts; the signals of the pipelines sharing the exporter are written to their own sub directories
+// unless they are interleaved
+func (e *fileExporter) usedBy(signal string) {
+	if e.pipelines == nil {
+		e.pipelines = make(map[string]bool)
+	}
+	e.pipelines[signal] = true
+	e.mixedPipelines = len(e.pipelines) > 1 && !e.interleaveSignals
+	for _, s := range e.siblings {
+		s.usedBy(signal)
+	}
+}
+
+// isSignalDirName returns true if the directory is the sub directory of a signal
+func isSignalDirName(dir string) bool {
+	switch filepath.Base(dir) {
+	case signalTraces, signalMetrics, signalLogs:
+		return true
+	}
+	return false
+}
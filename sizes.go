@@ -0,0 +1,125 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/confmap"
+)
+
+// the bytes of the size units, the IEC units are powers of 1024 and the SI units powers of 1000
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+var sizeRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)$`)
+
+// parseSize parses a size such as 5MiB, 512KB or 1048576 in bytes, a fractional size is rounded to the
+// nearest byte; an empty size is zero
+func parseSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	if len(size) == 0 {
+		return 0, nil
+	}
+	match := sizeRegex.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size [%s], it must be a number optionally followed by a unit such as KiB, MiB or GB", size)
+	}
+	unit, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size [%s], unknown unit %s, valid units are [ B, KB, MB, GB, TB, KiB, MiB, GiB or TiB ]", size, match[2])
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size [%s]: %w", size, err)
+	}
+	bytes := math.Round(value * unit)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size [%s], it is too large", size)
+	}
+	return int64(bytes), nil
+}
+
+// Unmarshal decodes the configuration, the deprecated filesizekb key is converted to bytes and the sizes
+// with units are parsed so that an invalid size is reported when the configuration is loaded
+func (cfg *Config) Unmarshal(conf *confmap.Conf) error {
+	if conf == nil {
+		return nil
+	}
+	if err := conf.Unmarshal(cfg, confmap.WithErrorUnused()); err != nil {
+		return err
+	}
+	if cfg.FileSizeKb > 0 {
+		if cfg.FileSizeBytes > 0 || len(cfg.FileSize) > 0 {
+			return errors.New("mention either fileSize, fileSizeBytes or the deprecated filesizekb")
+		}
+		cfg.FileSizeBytes, cfg.FileSizeKb = cfg.FileSizeKb*1024, 0
+		cfg.deprecatedKeys = map[string]string{"filesizekb": "fileSize"}
+	}
+	return cfg.validateSizes()
+}
+
+// validateSizes checks the sizes with units can be parsed and are not defined twice
+func (cfg *Config) validateSizes() error {
+	sizes := []struct {
+		key, size, alternative string
+		defined                bool
+	}{
+		{"fileSize", cfg.FileSize, "fileSizeBytes", cfg.FileSizeKb > 0 || cfg.FileSizeBytes > 0},
+		{"maxDirSize", cfg.MaxDirSize, "maxDirSizeMb", cfg.MaxDirSizeMb > 0},
+		{"minFreeDisk", cfg.MinFreeDisk, "minFreeDiskMb", cfg.MinFreeDiskMb > 0},
+	}
+	for _, s := range sizes {
+		if len(s.size) == 0 {
+			continue
+		}
+		if _, err := parseSize(s.size); err != nil {
+			return fmt.Errorf("invalid %s: %w", s.key, err)
+		}
+		if s.defined {
+			return fmt.Errorf("mention either %s or %s", s.key, s.alternative)
+		}
+	}
+	return nil
+}
+
+// sizeBytes returns the bytes of the size with units if it is defined, or of the size in the unit of
+// the numeric key otherwise; an invalid size is rejected by the validation of the configuration
+func sizeBytes(size string, value int64, unit int64) int64 {
+	if len(size) > 0 {
+		bytes, _ := parseSize(size)
+		return bytes
+	}
+	return value * unit
+}
+
+// maxDirSizeBytes returns the maximum total size of the files under the path in bytes
+func (cfg *Config) maxDirSizeBytes() int64 {
+	return sizeBytes(cfg.MaxDirSize, cfg.MaxDirSizeMb, 1024*1024)
+}
+
+// minFreeDiskBytes returns the minimum free space to keep on the file system of the path in bytes
+func (cfg *Config) minFreeDiskBytes() int64 {
+	return sizeBytes(cfg.MinFreeDisk, cfg.MinFreeDiskMb, 1024*1024)
+}
@@ -0,0 +1,126 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// pruneTraces returns the traces without the scopes holding no span and the resources holding no scope
+// when the empty payloads are skipped, the passed in traces are returned if nothing is empty
+func (e *fileExporter) pruneTraces(td ptrace.Traces) ptrace.Traces {
+	if !e.skipEmpty || !hasEmptyTraces(td) {
+		return td
+	}
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	out.ResourceSpans().RemoveIf(func(rs ptrace.ResourceSpans) bool {
+		rs.ScopeSpans().RemoveIf(func(ss ptrace.ScopeSpans) bool {
+			return ss.Spans().Len() == 0
+		})
+		return rs.ScopeSpans().Len() == 0
+	})
+	return out
+}
+
+func hasEmptyTraces(td ptrace.Traces) bool {
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		if rs.ScopeSpans().Len() == 0 {
+			return true
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			if rs.ScopeSpans().At(j).Spans().Len() == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pruneMetrics returns the metrics without the metrics holding no data point and the scopes and resources
+// left empty when the empty payloads are skipped, the passed in metrics are returned if nothing is empty
+func (e *fileExporter) pruneMetrics(md pmetric.Metrics) pmetric.Metrics {
+	if !e.skipEmpty || !hasEmptyMetrics(md) {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	out.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				return len(dataPointAttributes(metric)) == 0
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	return out
+}
+
+func hasEmptyMetrics(md pmetric.Metrics) bool {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		if rm.ScopeMetrics().Len() == 0 {
+			return true
+		}
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			if sm.Metrics().Len() == 0 {
+				return true
+			}
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				if len(dataPointAttributes(sm.Metrics().At(k))) == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pruneLogs returns the logs without the scopes holding no log record and the resources holding no scope
+// when the empty payloads are skipped, the passed in logs are returned if nothing is empty
+func (e *fileExporter) pruneLogs(ld plog.Logs) plog.Logs {
+	if !e.skipEmpty || !hasEmptyLogs(ld) {
+		return ld
+	}
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	out.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+	return out
+}
+
+func hasEmptyLogs(ld plog.Logs) bool {
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		if rl.ScopeLogs().Len() == 0 {
+			return true
+		}
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			if rl.ScopeLogs().At(j).LogRecords().Len() == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEmptyBatch returns true if the batch of the signal holds no record and the empty payloads are skipped,
+// so that it is neither written nor counted as an event of the in process file
+func (e *fileExporter) isEmptyBatch(records int) bool {
+	return e.skipEmpty && records == 0
+}
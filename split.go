@@ -0,0 +1,173 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const (
+	OversizeSplit = "split"
+	OversizeDrop  = "drop"
+	OversizeError = "error"
+)
+
+// validateOversizeBehavior checks the behaviour for batches exceeding maxRecordSizeKb is supported
+func validateOversizeBehavior(behavior string) error {
+	switch strings.ToLower(behavior) {
+	case "", OversizeSplit, OversizeDrop, OversizeError:
+		return nil
+	}
+	return fmt.Errorf("invalid oversizeBehavior [%s], valid values are [ %s, %s or %s ]", behavior, OversizeSplit, OversizeDrop, OversizeError)
+}
+
+// isOversize returns true if the size of the marshaled batch exceeds the maximum record size
+func (e *fileExporter) isOversize(size int) bool {
+	return e.maxRecordSize > 0 && int64(size) > e.maxRecordSize
+}
+
+// canSplit returns true if an oversize batch with the passed in number of units should be split
+func (e *fileExporter) canSplit(count int) bool {
+	return e.oversizeBehavior == OversizeSplit && count > 1
+}
+
+// exceedsFileSize returns true if the size of the marshaled batch is larger than the files of the route,
+// such a batch is split so that no finished file exceeds the file size; a single record larger than the
+// file size cannot be split and is written to a file of its own
+func (e *fileExporter) exceedsFileSize(route string, size int) bool {
+	if e.isRotationNone() {
+		return false
+	}
+	fileSize, _ := e.rotationLimits(route)
+	return fileSize > 0 && int64(size) > fileSize
+}
+
+// rejectOversize drops or fails an oversize batch that cannot be split any further, the error is
+// permanent as retrying the same batch would fail again
+func (e *fileExporter) rejectOversize(signal string, size int, records int) error {
+	if e.oversizeBehavior == OversizeDrop {
+		e.logger.Warn("dropping batch exceeding the maximum record size",
+			zap.String("signal", signal), zap.Int("size", size), zap.Int("records", records), zap.Int64("maxRecordSize", e.maxRecordSize))
+		e.reporter.recordDropped(records)
+		return nil
+	}
+	return consumererror.NewPermanent(fmt.Errorf("%s batch of %d bytes exceeds the maximum record size of %d bytes", signal, size, e.maxRecordSize))
+}
+
+// sliceTraces returns a copy of the spans in the [from, to) range, keeping their resource and scope
+func sliceTraces(td ptrace.Traces, from, to int) ptrace.Traces {
+	out := ptrace.NewTraces()
+	index := 0
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		var outRs ptrace.ResourceSpans
+		hasRs := false
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			var outSs ptrace.ScopeSpans
+			hasSs := false
+			for k := 0; k < ss.Spans().Len(); k++ {
+				if index >= from && index < to {
+					if !hasRs {
+						outRs = out.ResourceSpans().AppendEmpty()
+						rs.Resource().CopyTo(outRs.Resource())
+						outRs.SetSchemaUrl(rs.SchemaUrl())
+						hasRs = true
+					}
+					if !hasSs {
+						outSs = outRs.ScopeSpans().AppendEmpty()
+						ss.Scope().CopyTo(outSs.Scope())
+						outSs.SetSchemaUrl(ss.SchemaUrl())
+						hasSs = true
+					}
+					ss.Spans().At(k).CopyTo(outSs.Spans().AppendEmpty())
+				}
+				index++
+			}
+		}
+	}
+	return out
+}
+
+// sliceMetrics returns a copy of the metrics in the [from, to) range, keeping their resource and scope
+func sliceMetrics(md pmetric.Metrics, from, to int) pmetric.Metrics {
+	out := pmetric.NewMetrics()
+	index := 0
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		var outRm pmetric.ResourceMetrics
+		hasRm := false
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			var outSm pmetric.ScopeMetrics
+			hasSm := false
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				if index >= from && index < to {
+					if !hasRm {
+						outRm = out.ResourceMetrics().AppendEmpty()
+						rm.Resource().CopyTo(outRm.Resource())
+						outRm.SetSchemaUrl(rm.SchemaUrl())
+						hasRm = true
+					}
+					if !hasSm {
+						outSm = outRm.ScopeMetrics().AppendEmpty()
+						sm.Scope().CopyTo(outSm.Scope())
+						outSm.SetSchemaUrl(sm.SchemaUrl())
+						hasSm = true
+					}
+					sm.Metrics().At(k).CopyTo(outSm.Metrics().AppendEmpty())
+				}
+				index++
+			}
+		}
+	}
+	return out
+}
+
+// sliceLogs returns a copy of the log records in the [from, to) range, keeping their resource and scope
+func sliceLogs(ld plog.Logs, from, to int) plog.Logs {
+	out := plog.NewLogs()
+	index := 0
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		var outRl plog.ResourceLogs
+		hasRl := false
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			var outSl plog.ScopeLogs
+			hasSl := false
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				if index >= from && index < to {
+					if !hasRl {
+						outRl = out.ResourceLogs().AppendEmpty()
+						rl.Resource().CopyTo(outRl.Resource())
+						outRl.SetSchemaUrl(rl.SchemaUrl())
+						hasRl = true
+					}
+					if !hasSl {
+						outSl = outRl.ScopeLogs().AppendEmpty()
+						sl.Scope().CopyTo(outSl.Scope())
+						outSl.SetSchemaUrl(sl.SchemaUrl())
+						hasSl = true
+					}
+					sl.LogRecords().At(k).CopyTo(outSl.LogRecords().AppendEmpty())
+				}
+				index++
+			}
+		}
+	}
+	return out
+}
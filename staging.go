@@ -0,0 +1,137 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// the extension of the hidden files the compressed or encrypted finished files are written to before
+// they are renamed to their final name
+const stagingExt = "staging"
+
+// stagingSuffix returns the suffix of the staging files of the exporter, preceded by the instance when the
+// path is shared so that the instances only clean up their own staging files
+func (e *fileExporter) stagingSuffix() string {
+	if len(e.instance) > 0 {
+		return "." + e.instance + "." + stagingExt
+	}
+	return "." + stagingExt
+}
+
+// isStagingName returns true if the file name is the name of a staging file of any instance
+func isStagingName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, "."+stagingExt)
+}
+
+// ownsStagingName returns true if the staging file was written by this exporter
+func (e *fileExporter) ownsStagingName(name string) bool {
+	if len(e.instance) == 0 {
+		return isStagingName(name)
+	}
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, e.stagingSuffix())
+}
+
+// stageFinished writes the in process file f compressed and encrypted as configured to a staging file of
+// the directory, reading it straight from the in process file, and returns the staging file and the
+// extensions it adds to the finished file name; the finished file is then renamed from the staging file in
+// one step so that it never appears unprocessed. The in process file is returned as is if it is not
+// processed, and the staging file is removed if anything fails
+func (e *fileExporter) stageFinished(f, dir string) (string, string, error) {
+	var suffix string
+	compress := len(e.compression) > 0 && e.compression != CompressionNone
+	if compress {
+		suffix += "." + compressionExt[e.compression]
+	}
+	if e.keyProvider != nil {
+		suffix += "." + encryptedExt
+	}
+	if len(suffix) == 0 {
+		return f, "", nil
+	}
+	var key []byte
+	if e.keyProvider != nil {
+		var err error
+		if key, err = e.keyProvider.Key(); err != nil {
+			return "", "", err
+		}
+	}
+	in, err := e.fs.OpenFile(f, os.O_RDONLY, 0)
+	if err != nil {
+		return "", "", err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	tmp := filepath.Join(dir, "."+filepath.Base(f)+e.stagingSuffix())
+	out, err := e.fs.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", "", err
+	}
+	err = e.process(out, io.NewSectionReader(in, 0, stat.Size()), compress, key)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = e.fs.Remove(tmp)
+		return "", "", err
+	}
+	return tmp, suffix, nil
+}
+
+// process copies the data to w compressed, and then encrypted with the key if it is not nil
+func (e *fileExporter) process(w io.Writer, data io.Reader, compress bool, key []byte) error {
+	var enc io.WriteCloser
+	if key != nil {
+		var err error
+		if enc, err = NewEncryptWriter(w, key); err != nil {
+			return err
+		}
+		w = enc
+	}
+	if compress {
+		c, err := newCompressor(w, e.compression, e.compressionLevel)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(c, data); err != nil {
+			return err
+		}
+		if err = c.Close(); err != nil {
+			return err
+		}
+	} else if _, err := io.Copy(w, data); err != nil {
+		return err
+	}
+	if enc != nil {
+		return enc.Close()
+	}
+	return nil
+}
+
+// removeStagingFile removes the staging file left by a crash while a finished file was compressed or
+// encrypted, the in process file it was written from is only removed once the finished file is renamed
+// so it is finished again
+func (e *fileExporter) removeStagingFile(f string) error {
+	e.logger.Warn("removing partial file left by a previous run", zap.String("file", f))
+	if err := e.fs.Remove(f); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// gzipConfig returns the configuration of an exporter writing json files of the size compressed with gzip
+func gzipConfig(t *testing.T, fileSize int64) *Config {
+	cfg := testConfig(t, fileSize)
+	cfg.Compression = CompressionGzip
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// gunzipFile returns the decompressed content of the file
+func gunzipFile(t *testing.T, f string) string {
+	file, err := os.Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// assertNoStagingFile fails the test if a staging file is left under the path
+func assertNoStagingFile(t *testing.T, path string) {
+	for _, f := range listFiles(t, path) {
+		if isStagingName(filepath.Base(f)) {
+			t.Fatalf("staging file %s left under the path", f)
+		}
+	}
+}
+
+func TestCompressedFileOnlyAppearsProcessed(t *testing.T) {
+	cfg := gzipConfig(t, 16)
+	e := startTestExporter(t, cfg)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`, `{"a":3}`)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".json.gz") || gunzipFile(t, files[0]) != `{"a":1}{"a":2}` {
+		t.Fatalf("expected one compressed finished file, got %v", files)
+	}
+	for _, f := range listFiles(t, cfg.Path) {
+		if name := filepath.Base(f); strings.HasSuffix(name, ".json") && !strings.HasPrefix(name, ".") && !e.isInProcessName(name) {
+			t.Fatalf("uncompressed finished file %s under the path", f)
+		}
+	}
+	assertNoStagingFile(t, cfg.Path)
+}
+
+func TestFailedStagingKeepsInProcessFile(t *testing.T) {
+	key := hex.EncodeToString(testKey(t))
+	for name, test := range map[string]struct {
+		configure func(*Config)
+		read      func(t *testing.T, f string) string
+	}{
+		"compressed": {
+			configure: func(cfg *Config) { cfg.Compression = CompressionGzip },
+			read:      gunzipFile,
+		},
+		"encrypted": {
+			configure: func(cfg *Config) { cfg.Encryption = EncryptionConfig{Enabled: true, Key: key} },
+			read: func(t *testing.T, f string) string {
+				var out bytes.Buffer
+				if err := DecryptFile(f, hexKeyProvider(key), &out); err != nil {
+					t.Fatal(err)
+				}
+				return out.String()
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := testConfig(t, 16)
+			test.configure(cfg)
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			e, fsys := newFaultExporter(t, cfg)
+			startExporter(t, e)
+			writeLines(t, e, `{"a":1}`, `{"a":2}`)
+			fsys.inject(fault{op: faultWrite, pattern: "*" + e.stagingSuffix(), err: syscall.ENOSPC, allowed: 4, times: 1})
+			if err := writeLine(e, `{"a":3}`); !errors.Is(err, syscall.ENOSPC) {
+				t.Fatalf("expected the processing to fail, got %v", err)
+			}
+			if files := finishedFilesOf(t, e); len(files) != 0 {
+				t.Fatalf("expected no finished file, got %v", files)
+			}
+			assertNoStagingFile(t, cfg.Path)
+			if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}{"a":2}` {
+				t.Fatalf("expected the in process file to keep its batches, got %q", content)
+			}
+			writeLines(t, e, `{"a":3}`)
+			files := finishedFilesOf(t, e)
+			if len(files) != 1 || test.read(t, files[0]) != `{"a":1}{"a":2}` {
+				t.Fatalf("expected the in process file to be finished on retry, got %v", files)
+			}
+		})
+	}
+}
+
+func TestFailedRenameOfStagedFileKeepsInProcessFile(t *testing.T) {
+	cfg := gzipConfig(t, 16)
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`)
+	fsys.inject(fault{op: faultLink, pattern: "*" + e.stagingSuffix(), err: syscall.EIO})
+	fsys.inject(fault{op: faultRename, pattern: "*" + e.stagingSuffix(), err: syscall.EIO})
+	if err := writeLine(e, `{"a":3}`); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("expected the rename to fail, got %v", err)
+	}
+	if files := finishedFilesOf(t, e); len(files) != 0 {
+		t.Fatalf("expected no finished file, got %v", files)
+	}
+	assertNoStagingFile(t, cfg.Path)
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}{"a":2}` {
+		t.Fatalf("expected the in process file to keep its batches, got %q", content)
+	}
+}
+
+func TestRecoveryRemovesStagingFile(t *testing.T) {
+	cfg := gzipConfig(t, 1<<20)
+	cfg.RecoverInProcess = RecoverFinalize
+	e := newTestExporter(t, cfg)
+	// a crash while the in process file was compressed leaves both files
+	if err := os.WriteFile(filepath.Join(cfg.Path, inProcessName), []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Path, "."+inProcessName+e.stagingSuffix()), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	startExporter(t, e)
+	assertNoStagingFile(t, cfg.Path)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || gunzipFile(t, files[0]) != `{"a":1}` {
+		t.Fatalf("expected the in process file to be finished on start, got %v", files)
+	}
+}
+
+func TestCompressedAndEncryptedFileOnlyAppearsProcessed(t *testing.T) {
+	key := hex.EncodeToString(testKey(t))
+	cfg := testConfig(t, 16)
+	cfg.Compression = CompressionGzip
+	cfg.Encryption = EncryptionConfig{Enabled: true, Key: key}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e := startTestExporter(t, cfg)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`, `{"a":3}`)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".json.gz."+encryptedExt) {
+		t.Fatalf("expected one compressed and encrypted finished file, got %v", files)
+	}
+	var decrypted bytes.Buffer
+	if err := DecryptFile(files[0], hexKeyProvider(key), &decrypted); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(&decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := io.ReadAll(r); err != nil || string(content) != `{"a":1}{"a":2}` {
+		t.Fatalf("unexpected content %q, %v", content, err)
+	}
+	assertNoStagingFile(t, cfg.Path)
+}
@@ -0,0 +1,154 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// the suffix of the file holding the rotation state of a directory, it is a dot file prefixed with the
+// instance when the path is shared
+const stateSuffix = "state.json"
+
+// writerState is the rotation state of a directory persisted between runs
+type writerState struct {
+	// InProcessFile is the name of the in process file the state applies to and InProcessSize its size
+	// when the state was saved, the state of the file is only restored if its size is unchanged
+	InProcessFile string    `json:"inProcessFile"`
+	InProcessSize int64     `json:"inProcessSize"`
+	EventCount    int64     `json:"eventCount"`
+	Records       int64     `json:"records"`
+	First         uint64    `json:"first,omitempty"`
+	Last          uint64    `json:"last,omitempty"`
+	MaxAgeMs      int64     `json:"maxAgeMs,omitempty"`
+	OutOfOrder    int64     `json:"outOfOrder,omitempty"`
+	Signals       []string  `json:"signals,omitempty"`
+	Bucket        time.Time `json:"bucket,omitempty"`
+	Seq           int64     `json:"seq"`
+	BundleSeq     int64     `json:"bundleSeq"`
+	NameTime      time.Time `json:"nameTime,omitempty"`
+	Updated       time.Time `json:"updated"`
+}
+
+// validatePersistState checks the rotation state can be persisted
+func (cfg *Config) validatePersistState() error {
+	if cfg.PersistState && strings.EqualFold(cfg.Rotation, RotationNone) {
+		return errors.New("persistState requires rotation as there is no rotation state")
+	}
+	return nil
+}
+
+// stateFile returns the state file of the shard of the directory
+func (e *fileExporter) stateFile(dir string, shard int) string {
+	if instance := e.shardInstance(shard); len(instance) > 0 {
+		return filepath.Join(dir, "."+instance+"."+stateSuffix)
+	}
+	return filepath.Join(dir, "."+stateSuffix)
+}
+
+// isStateName returns true if the file name is the name of a state file of any instance
+func isStateName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, "."+stateSuffix)
+}
+
+// saveState records the rotation state of the writer, it must be called holding the writer lock; the state
+// is not saved while batches are buffered as the in process file does not hold them yet, and a failure is
+// logged rather than failing the write as the state is only used to resume more precisely
+func (e *fileExporter) saveState(w *fileWriter) {
+	if !e.persistState || w.pendingBytes > 0 {
+		return
+	}
+	s := writerState{
+		EventCount: w.currentEventCount,
+		Records:    w.stats.records,
+		First:      uint64(w.stats.first),
+		Last:       uint64(w.stats.last),
+		MaxAgeMs:   w.stats.maxAge.Milliseconds(),
+		OutOfOrder: w.stats.outOfOrder,
+		Signals:    signalList(w.signals),
+		Bucket:     w.bucket,
+		Seq:        w.seq,
+		BundleSeq:  w.bundleSeq,
+		NameTime:   w.lastNameTime,
+		Updated:    time.Now().UTC(),
+	}
+	if w.size > 0 {
+		s.InProcessFile, s.InProcessSize = filepath.Base(e.inProcessFileOf(w)), w.size
+	}
+	f := e.stateFile(w.path, w.shard)
+	content, err := json.Marshal(s)
+	if err == nil {
+		if err = os.WriteFile(f+".tmp", append(content, '\n'), 0644); err == nil {
+			err = os.Rename(f+".tmp", f)
+		}
+	}
+	if err != nil {
+		e.logger.Warn("failed to save rotation state", zap.String("file", f), zap.Error(err))
+	}
+}
+
+// loadState returns the rotation state saved for the shard of the directory, false if there is none
+func (e *fileExporter) loadState(dir string, shard int) (writerState, bool) {
+	var s writerState
+	if !e.persistState {
+		return s, false
+	}
+	f := e.stateFile(dir, shard)
+	content, err := os.ReadFile(f)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			e.logger.Warn("failed to read rotation state", zap.String("file", f), zap.Error(err))
+		}
+		return s, false
+	}
+	if err = json.Unmarshal(content, &s); err != nil {
+		e.logger.Warn("ignoring invalid rotation state", zap.String("file", f), zap.Error(err))
+		return s, false
+	}
+	return s, true
+}
+
+// restoreSequences restores the sequence numbers and the time of the last file name of a new writer so that
+// the file names continue from the previous run
+func (e *fileExporter) restoreSequences(w *fileWriter) {
+	if s, ok := e.loadState(w.path, w.shard); ok {
+		w.seq, w.bundleSeq, w.lastNameTime = s.Seq, s.BundleSeq, s.NameTime
+	}
+}
+
+// restoreInProcess restores the counters and statistics of the in process file left by the previous run,
+// it returns false if the state is missing or does not match the file, for instance after a crash between
+// a write and the state update
+func (e *fileExporter) restoreInProcess(w *fileWriter, f string) bool {
+	s, ok := e.loadState(w.path, w.shard)
+	if !ok {
+		return false
+	}
+	stat, err := os.Stat(f)
+	if err != nil || s.InProcessFile != filepath.Base(f) || s.InProcessSize != stat.Size() {
+		e.logger.Info("rotation state does not match the inprocess file", zap.String("file", f))
+		return false
+	}
+	w.currentEventCount = s.EventCount
+	w.stats = fileStats{records: s.Records, first: pcommon.Timestamp(s.First), last: pcommon.Timestamp(s.Last),
+		maxAge: time.Duration(s.MaxAgeMs) * time.Millisecond, outOfOrder: s.OutOfOrder}
+	for _, signal := range s.Signals {
+		w.signals[signal] = true
+	}
+	w.bucket = s.Bucket
+	return true
+}
@@ -0,0 +1,143 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Status is a snapshot of the state of an exporter, it allows detecting an exporter that is stuck
+type Status struct {
+	// InProcessFiles is the number of non empty in process files and InProcessBytes their size on disk,
+	// the batches aggregated in memory are not included
+	InProcessFiles int
+	InProcessBytes int64
+	// PendingFiles is the number of finished files under the path and mirror paths
+	PendingFiles int
+	// LastRotation is the time the last in process file was finished, zero if none was finished yet
+	LastRotation time.Time
+	// LastError is the last error writing or finishing a file and LastErrorTime the time it happened,
+	// empty if there was no error
+	LastError     string
+	LastErrorTime time.Time
+	// Fallback is true while the path is unavailable and the batches are written to the fallback path
+	Fallback bool
+}
+
+// StatusReporter is implemented by the exporters reporting their status
+type StatusReporter interface {
+	Status() Status
+}
+
+var _ StatusReporter = (*fileExporter)(nil)
+
+// the exporters created by the factory, by id, so that their status can be queried
+var (
+	statusMutex     sync.Mutex
+	statusReporters = make(map[component.ID]*fileExporter)
+)
+
+// ExporterStatus returns the status of the running exporter created by the collector with the id, false
+// if there is no such exporter; the health check and zpages extensions of the collector cannot query
+// exporters so this is how the embedding application reads it
+func ExporterStatus(id component.ID) (Status, bool) {
+	statusMutex.Lock()
+	e, ok := statusReporters[id]
+	statusMutex.Unlock()
+	if !ok {
+		return Status{}, false
+	}
+	return e.Status(), true
+}
+
+// registerStatus makes the status of the exporter available by id
+func registerStatus(id component.ID, e *fileExporter) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	statusReporters[id] = e
+}
+
+// unregisterStatus removes the exporter from the exporters whose status can be queried
+func unregisterStatus(e *fileExporter) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	for id, r := range statusReporters {
+		if r == e {
+			delete(statusReporters, id)
+		}
+	}
+}
+
+// Status returns the current state of the exporter, the in process files are read from disk so the
+// status can be queried while files are being written
+func (e *fileExporter) Status() Status {
+	return e.mergeStatus(e.status())
+}
+
+// status returns the state of the files of the exporter, without the exporters of the other formats
+func (e *fileExporter) status() Status {
+	e.mutex.Lock()
+	s := Status{
+		LastRotation:  e.lastRotation,
+		LastErrorTime: e.lastErrorTime,
+		Fallback:      e.fallback.isActive(),
+	}
+	if e.lastError != nil {
+		s.LastError = e.lastError.Error()
+	}
+	files := make([]string, 0, len(e.writers))
+	slacks := make([]int64, 0, len(e.writers))
+	for _, w := range e.writers {
+		if e.isRotationNone() {
+			files = append(files, filepath.Join(w.path, e.singleFileName()))
+		} else {
+			files = append(files, e.inProcessFileOf(w))
+		}
+		slacks = append(slacks, atomic.LoadInt64(&w.mappedSlack))
+	}
+	e.mutex.Unlock()
+	for i, f := range files {
+		// the mapped in process files are counted without the bytes mapped beyond their data
+		if stat, err := os.Stat(f); err == nil && stat.Size()-slacks[i] > 0 {
+			s.InProcessFiles++
+			s.InProcessBytes += stat.Size() - slacks[i]
+		}
+	}
+	if !e.isRotationNone() {
+		for _, root := range e.roots() {
+			if pending, err := e.pendingFiles(root); err == nil {
+				s.PendingFiles += pending
+			}
+		}
+	}
+	return s
+}
+
+// recordError records the last error writing or finishing a file and sends it as an event
+func (e *fileExporter) recordError(err error) {
+	e.mutex.Lock()
+	e.lastError, e.lastErrorTime = err, time.Now()
+	e.mutex.Unlock()
+	e.reporter.recordError()
+	e.emit(FileEvent{Type: WriteError, Err: err})
+}
+
+// recordRotated records the time a file was finished
+func (e *fileExporter) recordRotated() {
+	e.mutex.Lock()
+	e.lastRotation = time.Now()
+	e.mutex.Unlock()
+	e.reporter.recordRotated()
+}
@@ -0,0 +1,146 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// statusFileName is the name of the status file in the path, preceded by the instance when the path is shared
+	statusFileName = "status.json"
+
+	defaultStatusFileInterval = 10 * time.Second
+)
+
+// the name of the status files and their temporary files, with the instance when the path is shared
+var statusNameRegex = regexp.MustCompile(`^\.(.+\.)?status\.json(\.tmp)?$`)
+
+// isStatusName returns true if the file name is the name of a status file
+func isStatusName(name string) bool {
+	return statusNameRegex.MatchString(name)
+}
+
+// StatusFileConfig writes the status of the exporter to a hidden file of the path, so that pilot watching the
+// path reads the health of the exporter without another channel
+type StatusFileConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Interval is how often the status file is updated, it defaults to ten seconds
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// validateStatusFile checks the interval of the status file is not negative
+func (cfg *Config) validateStatusFile() error {
+	if cfg.StatusFile != nil && cfg.StatusFile.Interval < 0 {
+		return errors.New("statusFile interval must not be negative")
+	}
+	return nil
+}
+
+// statusDocument is the content of the status file
+type statusDocument struct {
+	Updated        time.Time  `json:"updated"`
+	InProcessFiles int        `json:"inProcessFiles"`
+	InProcessBytes int64      `json:"inProcessBytes"`
+	PendingFiles   int        `json:"pendingFiles"`
+	LastRotation   *time.Time `json:"lastRotation,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	LastErrorTime  *time.Time `json:"lastErrorTime,omitempty"`
+	Fallback       bool       `json:"fallback"`
+}
+
+// statusFile writes the status of the exporter to the status file of the path
+type statusFile struct {
+	file     string
+	interval time.Duration
+	// mutex serialises the writes of the status loop and the shutdown
+	mutex sync.Mutex
+	// failing is true while the status file cannot be written, so that the failure is logged once
+	failing bool
+	logger  *zap.Logger
+}
+
+// newStatusFile returns the status file of the exporter, nil if it is not written
+func newStatusFile(cfg *Config, logger *zap.Logger) *statusFile {
+	if cfg.StatusFile == nil || !cfg.StatusFile.Enabled || cfg.DryRun {
+		return nil
+	}
+	name := "." + statusFileName
+	if instance := instanceOf(cfg); len(instance) > 0 {
+		name = "." + instance + "." + statusFileName
+	}
+	s := &statusFile{file: filepath.Join(cfg.Path, name), interval: cfg.StatusFile.Interval, logger: logger}
+	if s.interval == 0 {
+		s.interval = defaultStatusFileInterval
+	}
+	return s
+}
+
+// save writes the status through a temporary file renamed over the status file, so that a status being
+// read is never partial; the path is not created so that nothing is written to an unmounted path
+func (s *statusFile) save(status Status) {
+	if s == nil {
+		return
+	}
+	doc := statusDocument{
+		Updated:        time.Now().UTC(),
+		InProcessFiles: status.InProcessFiles,
+		InProcessBytes: status.InProcessBytes,
+		PendingFiles:   status.PendingFiles,
+		LastError:      status.LastError,
+		Fallback:       status.Fallback,
+	}
+	if !status.LastRotation.IsZero() {
+		rotation := status.LastRotation.UTC()
+		doc.LastRotation = &rotation
+	}
+	if !status.LastErrorTime.IsZero() {
+		errorTime := status.LastErrorTime.UTC()
+		doc.LastErrorTime = &errorTime
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err == nil {
+		if err = os.WriteFile(s.file+".tmp", append(content, '\n'), 0644); err == nil {
+			err = os.Rename(s.file+".tmp", s.file)
+		}
+	}
+	if err != nil {
+		if !s.failing {
+			s.logger.Warn("failed to save the status file", zap.String("file", s.file), zap.Error(err))
+			s.failing = true
+		}
+		return
+	}
+	s.failing = false
+}
+
+// statusLoop saves the status file at its interval until the exporter is shut down
+func (e *fileExporter) statusLoop() {
+	e.statusFile.save(e.status())
+	ticker := time.NewTicker(e.statusFile.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.statusFile.save(e.status())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncint64"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.uber.org/zap"
+)
+
+const (
+	// the name of the meter used to record the exporter internal metrics
+	meterName = "southwinds.dev/file-exporter"
+	// the prefix of all the exporter internal metric names
+	metricPrefix = "fileexporter_"
+)
+
+// exporterTelemetry records the exporter internal metrics using the meter provider of the collector
+// so operators can alert when the exporter stalls or the disk fills
+type exporterTelemetry struct {
+	recordsWritten  syncint64.Counter
+	bytesWritten    syncint64.Counter
+	filesRotated    syncint64.Counter
+	writeErrors     syncint64.Counter
+	rotationLatency syncfloat64.Histogram
+	queueDepth      asyncint64.Gauge
+	// the age of the oldest record of the batches, the batches out of order and the stale files, recorded
+	// when the data age is tracked
+	dataAge           syncfloat64.Histogram
+	outOfOrderBatches syncint64.Counter
+	staleFiles        syncint64.Counter
+	// the number of write operations waiting for or holding the write lock
+	pending int64
+	// the attributes added to every measurement
+	attrs []attribute.KeyValue
+}
+
+// newExporterTelemetry creates the exporter internal metric instruments, if the instruments cannot be
+// created the exporter falls back to no-op instruments so telemetry never prevents data from being written;
+// the measurements of a dry run have the dry_run attribute
+func newExporterTelemetry(id component.ID, set component.TelemetrySettings, dryRun bool, logger *zap.Logger) *exporterTelemetry {
+	provider := set.MeterProvider
+	if provider == nil {
+		provider = metric.NewNoopMeterProvider()
+	}
+	t, err := createInstruments(provider.Meter(meterName), id)
+	if err != nil {
+		logger.Warn("failed to create exporter telemetry instruments", zap.Error(err))
+		t, _ = createInstruments(metric.NewNoopMeter(), id)
+	}
+	if dryRun {
+		t.attrs = append(t.attrs, attribute.Bool("dry_run", true))
+	}
+	return t
+}
+
+func createInstruments(meter metric.Meter, id component.ID) (*exporterTelemetry, error) {
+	var err error
+	t := &exporterTelemetry{attrs: []attribute.KeyValue{attribute.String("exporter", id.String())}}
+	if t.recordsWritten, err = meter.SyncInt64().Counter(metricPrefix+"records_written",
+		instrument.WithDescription("Number of telemetry records written to file"),
+		instrument.WithUnit(unit.Dimensionless)); err != nil {
+		return nil, err
+	}
+	if t.bytesWritten, err = meter.SyncInt64().Counter(metricPrefix+"bytes_written",
+		instrument.WithDescription("Number of bytes written to file"),
+		instrument.WithUnit(unit.Bytes)); err != nil {
+		return nil, err
+	}
+	if t.filesRotated, err = meter.SyncInt64().Counter(metricPrefix+"files_rotated",
+		instrument.WithDescription("Number of in process files finalized"),
+		instrument.WithUnit(unit.Dimensionless)); err != nil {
+		return nil, err
+	}
+	if t.writeErrors, err = meter.SyncInt64().Counter(metricPrefix+"write_errors",
+		instrument.WithDescription("Number of failed write operations"),
+		instrument.WithUnit(unit.Dimensionless)); err != nil {
+		return nil, err
+	}
+	if t.rotationLatency, err = meter.SyncFloat64().Histogram(metricPrefix+"rotation_latency",
+		instrument.WithDescription("Time taken to finalize an in process file"),
+		instrument.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, err
+	}
+	if t.dataAge, err = meter.SyncFloat64().Histogram(metricPrefix+"data_age",
+		instrument.WithDescription("Age of the oldest record of a batch when written to file"),
+		instrument.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, err
+	}
+	if t.outOfOrderBatches, err = meter.SyncInt64().Counter(metricPrefix+"out_of_order_batches",
+		instrument.WithDescription("Number of batches older than the telemetry already written to their file"),
+		instrument.WithUnit(unit.Dimensionless)); err != nil {
+		return nil, err
+	}
+	if t.staleFiles, err = meter.SyncInt64().Counter(metricPrefix+"stale_files",
+		instrument.WithDescription("Number of finished files holding records older than the stale threshold"),
+		instrument.WithUnit(unit.Dimensionless)); err != nil {
+		return nil, err
+	}
+	if t.queueDepth, err = meter.AsyncInt64().Gauge(metricPrefix+"queue_depth",
+		instrument.WithDescription("Number of write operations waiting to be written to file"),
+		instrument.WithUnit(unit.Dimensionless)); err != nil {
+		return nil, err
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{t.queueDepth}, func(ctx context.Context) {
+		t.queueDepth.Observe(ctx, atomic.LoadInt64(&t.pending), t.attrs...)
+	})
+	return t, err
+}
+
+// enqueued records a write operation waiting for the write lock
+func (t *exporterTelemetry) enqueued() {
+	atomic.AddInt64(&t.pending, 1)
+}
+
+// dequeued records a write operation that has completed
+func (t *exporterTelemetry) dequeued() {
+	atomic.AddInt64(&t.pending, -1)
+}
+
+// recordWrite records the outcome of a write operation
+func (t *exporterTelemetry) recordWrite(records int, bytes int, err error) {
+	ctx := context.Background()
+	if err != nil {
+		t.writeErrors.Add(ctx, 1, t.attrs...)
+		return
+	}
+	t.recordsWritten.Add(ctx, int64(records), t.attrs...)
+	t.bytesWritten.Add(ctx, int64(bytes), t.attrs...)
+}
+
+// recordRotation records a finalized in process file and the time taken to finalize it
+func (t *exporterTelemetry) recordRotation(start time.Time) {
+	ctx := context.Background()
+	t.filesRotated.Add(ctx, 1, t.attrs...)
+	t.rotationLatency.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), t.attrs...)
+}
+
+// recordDataAge records the age of the oldest record of a batch written and whether it is out of order
+func (t *exporterTelemetry) recordDataAge(age time.Duration, outOfOrder bool) {
+	ctx := context.Background()
+	t.dataAge.Record(ctx, float64(age)/float64(time.Millisecond), t.attrs...)
+	if outOfOrder {
+		t.outOfOrderBatches.Add(ctx, 1, t.attrs...)
+	}
+}
+
+// recordStaleFile records a finished file holding records older than the stale threshold
+func (t *exporterTelemetry) recordStaleFile() {
+	t.staleFiles.Add(context.Background(), 1, t.attrs...)
+}
@@ -0,0 +1,148 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// how long the outcome of a write abandoned at its deadline is kept for the retry of its batch
+const lateWriteWindow = 5 * time.Minute
+
+// validateTimeout checks the timeout of the writes is not negative
+func (cfg *Config) validateTimeout() error {
+	if cfg.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// lateWriteKey identifies the write of a batch to a root path
+type lateWriteKey struct {
+	root  string
+	batch [sha256.Size]byte
+}
+
+// lateWrite is a write abandoned at its deadline, done is closed once it completes with err
+type lateWrite struct {
+	done      chan struct{}
+	err       error
+	completed time.Time
+}
+
+// lateWrites remembers the writes abandoned at their deadline, so that the batch retried by the pipeline
+// waits for its abandoned write and is skipped if the abandoned write landed
+type lateWrites struct {
+	mutex  sync.Mutex
+	writes map[lateWriteKey]*lateWrite
+}
+
+func newLateWrites() *lateWrites {
+	return &lateWrites{writes: make(map[lateWriteKey]*lateWrite)}
+}
+
+// keyOf returns the key of the batch of the partition, nil if the write cannot be abandoned as it has no
+// deadline and is not the retry of an abandoned write; the batch is keyed before it is numbered so that
+// its retry has the same key
+func (l *lateWrites) keyOf(ctx context.Context, partition string, b *batch) *[sha256.Size]byte {
+	if _, ok := ctx.Deadline(); !ok {
+		l.mutex.Lock()
+		pending := len(l.writes)
+		l.mutex.Unlock()
+		if pending == 0 {
+			return nil
+		}
+	}
+	key := dedupeKey(b.signal+"\x00"+partition+"\x00"+b.route, b.buf)
+	return &key
+}
+
+// abandon remembers the write of the batch to the root, result receives its error once it completes
+func (l *lateWrites) abandon(root string, b *batch, result <-chan error) {
+	if b.lateKey == nil {
+		return
+	}
+	lw := &lateWrite{done: make(chan struct{})}
+	l.mutex.Lock()
+	now := time.Now()
+	for key, w := range l.writes {
+		// the writes whose batch was not retried within the window are forgotten
+		if !w.completed.IsZero() && now.Sub(w.completed) > lateWriteWindow {
+			delete(l.writes, key)
+		}
+	}
+	key := lateWriteKey{root: root, batch: *b.lateKey}
+	l.writes[key] = lw
+	l.mutex.Unlock()
+	go func() {
+		err := <-result
+		l.mutex.Lock()
+		lw.err, lw.completed = err, time.Now()
+		l.mutex.Unlock()
+		close(lw.done)
+	}()
+}
+
+// landed waits for the abandoned write of the batch to the root if there is one, and returns true if it
+// wrote the batch so that it is not written again; the wait is abandoned if the context is done
+func (l *lateWrites) landed(ctx context.Context, root string, b *batch) (bool, error) {
+	if b.lateKey == nil {
+		return false, nil
+	}
+	key := lateWriteKey{root: root, batch: *b.lateKey}
+	l.mutex.Lock()
+	lw, ok := l.writes[key]
+	l.mutex.Unlock()
+	if !ok {
+		return false, nil
+	}
+	select {
+	case <-lw.done:
+	case <-ctx.Done():
+		return false, fmt.Errorf("the abandoned write of the batch to %s is still running: %w", root, ctx.Err())
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.writes[key] == lw {
+		delete(l.writes, key)
+	}
+	return lw.err == nil, nil
+}
+
+// writeWithin runs the write of the locked writer and unlocks it once done, the write is abandoned if the
+// deadline of the context passes first: the write cannot be interrupted so it completes in the background
+// holding the writer and the error returned is retryable; the retry of the batch waits for the abandoned
+// write and skips the batch if it landed. The write is not bounded if the context has no deadline
+func (e *fileExporter) writeWithin(ctx context.Context, root string, w *fileWriter, b *batch, write func() error) error {
+	if _, ok := ctx.Deadline(); !ok {
+		defer w.mutex.Unlock()
+		return write()
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer w.mutex.Unlock()
+		done <- write()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		e.logger.Warn("write timed out, it is abandoned and completes in the background",
+			zap.String("path", w.path), zap.Error(ctx.Err()))
+		e.lateWrites.abandon(root, b, done)
+		return fmt.Errorf("write to %s abandoned: %w", w.path, ctx.Err())
+	}
+}
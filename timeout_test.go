@@ -0,0 +1,66 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// writeLineWithin writes the line as a batch of one trace record within the timeout
+func writeLineWithin(e *fileExporter, line string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return e.exportAsLine(ctx, "", &batch{signal: signalTraces, records: 1, buf: []byte(line)})
+}
+
+func TestLateWriteIsNotWrittenAgain(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	fsys.inject(fault{op: faultWrite, pattern: inProcessName, delay: 50 * time.Millisecond, times: 1})
+	start := time.Now()
+	err := writeLineWithin(e, `{"a":1}`, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) || consumererror.IsPermanent(err) {
+		t.Fatalf("expected a retryable deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Fatalf("expected the write to be abandoned at its deadline, it returned after %v", elapsed)
+	}
+	// the retry waits for the abandoned write, which lands
+	if err = writeLineWithin(e, `{"a":1}`, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, e, `{"a":2}`)
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}{"a":2}` {
+		t.Fatalf("expected the batch written late to be written once, got %q", content)
+	}
+}
+
+func TestLateFailedWriteIsWrittenAgain(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	e, fsys := newFaultExporter(t, cfg)
+	startExporter(t, e)
+	fsys.inject(fault{op: faultWrite, pattern: inProcessName, err: syscall.EIO, delay: 50 * time.Millisecond, times: 1})
+	if err := writeLineWithin(e, `{"a":1}`, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if err := writeLineWithin(e, `{"a":1}`, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}` {
+		t.Fatalf("expected the batch to be written by its retry, got %q", content)
+	}
+}
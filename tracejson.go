@@ -0,0 +1,354 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// the attributes and tags shared by the jaeger and zipkin translations, as named by the collector translators
+const (
+	serviceNameKey       = "service.name"
+	peerServiceKey       = "peer.service"
+	spanKindTag          = "span.kind"
+	statusCodeTag        = "otel.status_code"
+	statusDescriptionTag = "otel.status_description"
+	scopeNameTag         = "otel.library.name"
+	scopeVersionTag      = "otel.library.version"
+	traceStateTag        = "w3c.tracestate"
+	errorTag             = "error"
+	// the service name of the resources without service.name
+	unknownServiceName = "unknown_service"
+)
+
+// isTracesOnly returns true if the format is only supported for traces
+func isTracesOnly(format string) bool {
+	return strings.EqualFold(format, JaegerJson) || strings.EqualFold(format, ZipkinJson)
+}
+
+// jaegerKeyValue is a tag or log field of the jaeger json model
+type jaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerLog struct {
+	Timestamp uint64           `json:"timestamp"`
+	Fields    []jaegerKeyValue `json:"fields"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	Flags         uint32            `json:"flags"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     uint64            `json:"startTime"`
+	Duration      uint64            `json:"duration"`
+	Tags          []jaegerKeyValue  `json:"tags"`
+	Logs          []jaegerLog       `json:"logs"`
+	ProcessID     string            `json:"processID"`
+}
+
+type jaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []jaegerKeyValue `json:"tags"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+// tracesJaeger returns the traces in the jaeger json format read by the jaeger UI file import, the spans
+// are grouped by trace and every resource of a trace is one of its processes
+func tracesJaeger(td ptrace.Traces) ([]byte, error) {
+	traces := make([]*jaegerTrace, 0)
+	byID := make(map[pcommon.TraceID]*jaegerTrace)
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		process := jaegerProcessOf(rs.Resource())
+		// the process id of the resource in each of the traces holding its spans
+		processIDs := make(map[pcommon.TraceID]string)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				trace, ok := byID[span.TraceID()]
+				if !ok {
+					trace = &jaegerTrace{TraceID: traceIDHex(span.TraceID()), Processes: make(map[string]jaegerProcess)}
+					byID[span.TraceID()] = trace
+					traces = append(traces, trace)
+				}
+				processID, ok := processIDs[span.TraceID()]
+				if !ok {
+					processID = fmt.Sprintf("p%d", len(trace.Processes)+1)
+					trace.Processes[processID] = process
+					processIDs[span.TraceID()] = processID
+				}
+				trace.Spans = append(trace.Spans, jaegerSpanOf(span, ss.Scope(), processID))
+			}
+		}
+	}
+	return json.Marshal(struct {
+		Data []*jaegerTrace `json:"data"`
+	}{Data: traces})
+}
+
+func jaegerProcessOf(resource pcommon.Resource) jaegerProcess {
+	p := jaegerProcess{ServiceName: unknownServiceName, Tags: make([]jaegerKeyValue, 0, resource.Attributes().Len())}
+	resource.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if k == serviceNameKey {
+			p.ServiceName = v.AsString()
+		} else {
+			p.Tags = append(p.Tags, jaegerKeyValueOf(k, v))
+		}
+		return true
+	})
+	return p
+}
+
+func jaegerSpanOf(span ptrace.Span, scope pcommon.InstrumentationScope, processID string) jaegerSpan {
+	s := jaegerSpan{
+		TraceID:       traceIDHex(span.TraceID()),
+		SpanID:        span.SpanID().String(),
+		Flags:         1,
+		OperationName: span.Name(),
+		References:    make([]jaegerReference, 0, span.Links().Len()+1),
+		StartTime:     micros(span.StartTimestamp()),
+		Duration:      durationMicros(span.StartTimestamp(), span.EndTimestamp()),
+		Tags:          make([]jaegerKeyValue, 0, span.Attributes().Len()),
+		Logs:          make([]jaegerLog, 0, span.Events().Len()),
+		ProcessID:     processID,
+	}
+	if !span.ParentSpanID().IsEmpty() {
+		s.References = append(s.References, jaegerReference{RefType: "CHILD_OF", TraceID: s.TraceID, SpanID: span.ParentSpanID().String()})
+	}
+	for i := 0; i < span.Links().Len(); i++ {
+		link := span.Links().At(i)
+		s.References = append(s.References, jaegerReference{RefType: "FOLLOWS_FROM", TraceID: traceIDHex(link.TraceID()), SpanID: link.SpanID().String()})
+	}
+	span.Attributes().Range(func(k string, v pcommon.Value) bool {
+		s.Tags = append(s.Tags, jaegerKeyValueOf(k, v))
+		return true
+	})
+	for k, v := range spanTags(span, scope) {
+		s.Tags = append(s.Tags, jaegerKeyValue{Key: k, Type: "string", Value: v})
+	}
+	if span.Status().Code() == ptrace.StatusCodeError {
+		s.Tags = append(s.Tags, jaegerKeyValue{Key: errorTag, Type: "bool", Value: true})
+	}
+	for i := 0; i < span.Events().Len(); i++ {
+		event := span.Events().At(i)
+		l := jaegerLog{Timestamp: micros(event.Timestamp()), Fields: make([]jaegerKeyValue, 0, event.Attributes().Len()+1)}
+		if len(event.Name()) > 0 {
+			l.Fields = append(l.Fields, jaegerKeyValue{Key: "event", Type: "string", Value: event.Name()})
+		}
+		event.Attributes().Range(func(k string, v pcommon.Value) bool {
+			l.Fields = append(l.Fields, jaegerKeyValueOf(k, v))
+			return true
+		})
+		s.Logs = append(s.Logs, l)
+	}
+	return s
+}
+
+// jaegerKeyValueOf returns the typed tag of the attribute, the values without a jaeger type are written as strings
+func jaegerKeyValueOf(k string, v pcommon.Value) jaegerKeyValue {
+	switch v.Type() {
+	case pcommon.ValueTypeBool:
+		return jaegerKeyValue{Key: k, Type: "bool", Value: v.Bool()}
+	case pcommon.ValueTypeInt:
+		return jaegerKeyValue{Key: k, Type: "int64", Value: v.Int()}
+	case pcommon.ValueTypeDouble:
+		return jaegerKeyValue{Key: k, Type: "float64", Value: v.Double()}
+	case pcommon.ValueTypeBytes:
+		return jaegerKeyValue{Key: k, Type: "binary", Value: v.Bytes().AsRaw()}
+	}
+	return jaegerKeyValue{Key: k, Type: "string", Value: v.AsString()}
+}
+
+// spanTags returns the tags translating the fields of the span that are not attributes
+func spanTags(span ptrace.Span, scope pcommon.InstrumentationScope) map[string]string {
+	tags := make(map[string]string)
+	switch span.Kind() {
+	case ptrace.SpanKindClient:
+		tags[spanKindTag] = "client"
+	case ptrace.SpanKindServer:
+		tags[spanKindTag] = "server"
+	case ptrace.SpanKindProducer:
+		tags[spanKindTag] = "producer"
+	case ptrace.SpanKindConsumer:
+		tags[spanKindTag] = "consumer"
+	case ptrace.SpanKindInternal:
+		tags[spanKindTag] = "internal"
+	}
+	switch span.Status().Code() {
+	case ptrace.StatusCodeOk:
+		tags[statusCodeTag] = "OK"
+	case ptrace.StatusCodeError:
+		tags[statusCodeTag] = "ERROR"
+	}
+	if len(span.Status().Message()) > 0 {
+		tags[statusDescriptionTag] = span.Status().Message()
+	}
+	if len(scope.Name()) > 0 {
+		tags[scopeNameTag] = scope.Name()
+	}
+	if len(scope.Version()) > 0 {
+		tags[scopeVersionTag] = scope.Version()
+	}
+	if state := span.TraceState().AsRaw(); len(state) > 0 {
+		tags[traceStateTag] = state
+	}
+	return tags
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Kind           string             `json:"kind,omitempty"`
+	Name           string             `json:"name"`
+	Timestamp      uint64             `json:"timestamp,omitempty"`
+	Duration       uint64             `json:"duration,omitempty"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+// tracesZipkin returns the traces as a zipkin v2 json list of spans, the resource attributes other than
+// the service name are added to the tags of the spans as zipkin has no resources
+func tracesZipkin(td ptrace.Traces) ([]byte, error) {
+	spans := make([]zipkinSpan, 0, td.SpanCount())
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		endpoint := &zipkinEndpoint{ServiceName: unknownServiceName}
+		resourceTags := make(map[string]string)
+		rs.Resource().Attributes().Range(func(k string, v pcommon.Value) bool {
+			if k == serviceNameKey {
+				endpoint.ServiceName = v.AsString()
+			} else {
+				resourceTags[k] = v.AsString()
+			}
+			return true
+		})
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				spans = append(spans, zipkinSpanOf(ss.Spans().At(k), ss.Scope(), endpoint, resourceTags))
+			}
+		}
+	}
+	return json.Marshal(spans)
+}
+
+func zipkinSpanOf(span ptrace.Span, scope pcommon.InstrumentationScope, endpoint *zipkinEndpoint, resourceTags map[string]string) zipkinSpan {
+	s := zipkinSpan{
+		TraceID:       traceIDHex(span.TraceID()),
+		ID:            span.SpanID().String(),
+		Name:          span.Name(),
+		Timestamp:     micros(span.StartTimestamp()),
+		Duration:      durationMicros(span.StartTimestamp(), span.EndTimestamp()),
+		LocalEndpoint: endpoint,
+		Tags:          make(map[string]string, len(resourceTags)+span.Attributes().Len()),
+	}
+	if s.Duration == 0 && span.EndTimestamp() > span.StartTimestamp() {
+		// zipkin omits a zero duration, a span shorter than a microsecond lasts one
+		s.Duration = 1
+	}
+	if !span.ParentSpanID().IsEmpty() {
+		s.ParentID = span.ParentSpanID().String()
+	}
+	for k, v := range resourceTags {
+		s.Tags[k] = v
+	}
+	span.Attributes().Range(func(k string, v pcommon.Value) bool {
+		s.Tags[k] = v.AsString()
+		return true
+	})
+	for k, v := range spanTags(span, scope) {
+		if k == spanKindTag {
+			// zipkin has no internal kind, the spans without kind are local
+			if v != "internal" {
+				s.Kind = strings.ToUpper(v)
+			}
+			continue
+		}
+		s.Tags[k] = v
+	}
+	if span.Status().Code() == ptrace.StatusCodeError {
+		s.Tags[errorTag] = span.Status().Message()
+		if len(s.Tags[errorTag]) == 0 {
+			s.Tags[errorTag] = "true"
+		}
+	}
+	if peer, ok := span.Attributes().Get(peerServiceKey); ok {
+		s.RemoteEndpoint = &zipkinEndpoint{ServiceName: peer.AsString()}
+	}
+	for i := 0; i < span.Events().Len(); i++ {
+		event := span.Events().At(i)
+		value := event.Name()
+		if event.Attributes().Len() > 0 {
+			// the attributes are kept in the annotation as zipkin annotations are plain strings
+			if attrs, err := json.Marshal(event.Attributes().AsRaw()); err == nil {
+				value = fmt.Sprintf("%s|%s", value, attrs)
+			}
+		}
+		s.Annotations = append(s.Annotations, zipkinAnnotation{Timestamp: micros(event.Timestamp()), Value: value})
+	}
+	return s
+}
+
+// micros returns the timestamp in microseconds since the epoch as written by jaeger and zipkin
+func micros(ts pcommon.Timestamp) uint64 {
+	return uint64(ts) / 1000
+}
+
+// durationMicros returns the duration between the timestamps in microseconds, zero if the end is before the start
+func durationMicros(start, end pcommon.Timestamp) uint64 {
+	if end < start {
+		return 0
+	}
+	return uint64(end-start) / 1000
+}
+
+// traceIDHex returns the trace id as written by jaeger and zipkin, the 64 bit trace ids are written without
+// their zero high half
+func traceIDHex(id pcommon.TraceID) string {
+	if binary.BigEndian.Uint64(id[:8]) == 0 {
+		return hex.EncodeToString(id[8:])
+	}
+	return id.String()
+}
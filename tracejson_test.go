@@ -0,0 +1,241 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	// the start of the test spans, 1700000000123456 microseconds and 789 nanoseconds
+	testSpanStart = 1700000000123456789
+	// the duration of the test spans, 1500 microseconds once truncated while the difference of the start and
+	// end timestamps truncated to microseconds is 1501
+	testSpanDuration = 1500600
+)
+
+var (
+	testTraceID  = pcommon.TraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	testShortID  = pcommon.TraceID([16]byte{8: 0xab, 15: 0xcd})
+	testSpanID   = pcommon.SpanID([8]byte{0xa1, 2, 3, 4, 5, 6, 7, 8})
+	testParentID = pcommon.SpanID([8]byte{0xb1, 2, 3, 4, 5, 6, 7, 8})
+)
+
+// translatedTraces returns the traces exercising the translation of every field of a span
+func translatedTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "pump")
+	rs.Resource().Attributes().PutStr("host.name", "device-1")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("lib")
+	ss.Scope().SetVersion("1.0")
+
+	server := ss.Spans().AppendEmpty()
+	server.SetTraceID(testTraceID)
+	server.SetSpanID(testSpanID)
+	server.SetParentSpanID(testParentID)
+	server.SetName("read")
+	server.SetKind(ptrace.SpanKindServer)
+	server.SetStartTimestamp(testSpanStart)
+	server.SetEndTimestamp(testSpanStart + testSpanDuration)
+	server.Attributes().PutInt("http.status_code", 200)
+	server.Attributes().PutBool("cached", false)
+	server.Attributes().PutDouble("ratio", 0.5)
+	server.Status().SetCode(ptrace.StatusCodeError)
+	server.Status().SetMessage("boom")
+	event := server.Events().AppendEmpty()
+	event.SetName("retry")
+	event.SetTimestamp(testSpanStart + 2000)
+	event.Attributes().PutInt("attempt", 2)
+	link := server.Links().AppendEmpty()
+	link.SetTraceID(testShortID)
+	link.SetSpanID(testParentID)
+
+	client := ss.Spans().AppendEmpty()
+	client.SetTraceID(testShortID)
+	client.SetSpanID(testParentID)
+	client.SetName("query")
+	client.SetKind(ptrace.SpanKindClient)
+	client.SetStartTimestamp(testSpanStart)
+	client.SetEndTimestamp(testSpanStart + 999)
+	client.Attributes().PutStr("peer.service", "db")
+
+	internal := ss.Spans().AppendEmpty()
+	internal.SetTraceID(testShortID)
+	internal.SetSpanID(testSpanID)
+	internal.SetName("compute")
+	internal.SetKind(ptrace.SpanKindInternal)
+	return td
+}
+
+// decodeJSON decodes the json keeping the numbers exact
+func decodeJSON(t *testing.T, content []byte, v any) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tagsOf returns the type and value of the jaeger tags by key
+func tagsOf(t *testing.T, tags []any) map[string][2]any {
+	out := make(map[string][2]any)
+	for _, tag := range tags {
+		kv := tag.(map[string]any)
+		if _, ok := out[kv["key"].(string)]; ok {
+			t.Fatalf("duplicate tag %s", kv["key"])
+		}
+		out[kv["key"].(string)] = [2]any{kv["type"], kv["value"]}
+	}
+	return out
+}
+
+func TestJaegerJSON(t *testing.T) {
+	content, err := tracesJaeger(translatedTraces())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Data []struct {
+			TraceID   string
+			Spans     []map[string]any
+			Processes map[string]map[string]any
+		}
+	}
+	decodeJSON(t, content, &doc)
+	if len(doc.Data) != 2 || doc.Data[0].TraceID != "0102030405060708090a0b0c0d0e0f10" || doc.Data[1].TraceID != "ab000000000000cd" {
+		t.Fatalf("expected the spans grouped by trace with the 64 bit trace ids written in 16 hex digits, got %+v", doc.Data)
+	}
+	process := doc.Data[0].Processes["p1"]
+	if process["serviceName"] != "pump" || !reflect.DeepEqual(tagsOf(t, process["tags"].([]any)), map[string][2]any{"host.name": {"string", "device-1"}}) {
+		t.Fatalf("unexpected process %v", process)
+	}
+	server := doc.Data[0].Spans[0]
+	want := map[string]any{
+		"traceID":       "0102030405060708090a0b0c0d0e0f10",
+		"spanID":        "a102030405060708",
+		"flags":         json.Number("1"),
+		"operationName": "read",
+		"startTime":     json.Number("1700000000123456"),
+		"duration":      json.Number("1500"),
+		"processID":     "p1",
+	}
+	for k, v := range want {
+		if server[k] != v {
+			t.Errorf("expected the %s of the span to be %v, got %v", k, v, server[k])
+		}
+	}
+	references := []any{
+		map[string]any{"refType": "CHILD_OF", "traceID": "0102030405060708090a0b0c0d0e0f10", "spanID": "b102030405060708"},
+		map[string]any{"refType": "FOLLOWS_FROM", "traceID": "ab000000000000cd", "spanID": "b102030405060708"},
+	}
+	if !reflect.DeepEqual(server["references"], references) {
+		t.Errorf("unexpected references %v", server["references"])
+	}
+	tags := map[string][2]any{
+		"http.status_code":        {"int64", json.Number("200")},
+		"cached":                  {"bool", false},
+		"ratio":                   {"float64", json.Number("0.5")},
+		"span.kind":               {"string", "server"},
+		"otel.status_code":        {"string", "ERROR"},
+		"otel.status_description": {"string", "boom"},
+		"otel.library.name":       {"string", "lib"},
+		"otel.library.version":    {"string", "1.0"},
+		"error":                   {"bool", true},
+	}
+	if got := tagsOf(t, server["tags"].([]any)); !reflect.DeepEqual(got, tags) {
+		t.Errorf("unexpected tags %v", got)
+	}
+	logs := []any{map[string]any{
+		"timestamp": json.Number("1700000000123458"),
+		"fields": []any{
+			map[string]any{"key": "event", "type": "string", "value": "retry"},
+			map[string]any{"key": "attempt", "type": "int64", "value": json.Number("2")},
+		},
+	}}
+	if !reflect.DeepEqual(server["logs"], logs) {
+		t.Errorf("unexpected logs %v", server["logs"])
+	}
+	spans := doc.Data[1].Spans
+	if len(spans) != 2 || spans[0]["duration"] != json.Number("0") || len(spans[0]["references"].([]any)) != 0 {
+		t.Fatalf("unexpected spans of the second trace %v", spans)
+	}
+	if kind := tagsOf(t, spans[1]["tags"].([]any))["span.kind"]; kind != [2]any{"string", "internal"} {
+		t.Errorf("unexpected kind of the internal span %v", kind)
+	}
+}
+
+func TestZipkinJSON(t *testing.T) {
+	content, err := tracesZipkin(translatedTraces())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spans []map[string]any
+	decodeJSON(t, content, &spans)
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	server := map[string]any{
+		"traceId":       "0102030405060708090a0b0c0d0e0f10",
+		"id":            "a102030405060708",
+		"parentId":      "b102030405060708",
+		"kind":          "SERVER",
+		"name":          "read",
+		"timestamp":     json.Number("1700000000123456"),
+		"duration":      json.Number("1500"),
+		"localEndpoint": map[string]any{"serviceName": "pump"},
+		"annotations":   []any{map[string]any{"timestamp": json.Number("1700000000123458"), "value": `retry|{"attempt":2}`}},
+		"tags": map[string]any{
+			"host.name":               "device-1",
+			"http.status_code":        "200",
+			"cached":                  "false",
+			"ratio":                   "0.5",
+			"otel.status_code":        "ERROR",
+			"otel.status_description": "boom",
+			"otel.library.name":       "lib",
+			"otel.library.version":    "1.0",
+			"error":                   "boom",
+		},
+	}
+	if !reflect.DeepEqual(spans[0], server) {
+		t.Errorf("unexpected server span\n%v\nexpected\n%v", spans[0], server)
+	}
+	client := map[string]any{
+		"traceId":        "ab000000000000cd",
+		"id":             "b102030405060708",
+		"kind":           "CLIENT",
+		"name":           "query",
+		"timestamp":      json.Number("1700000000123456"),
+		"duration":       json.Number("1"),
+		"localEndpoint":  map[string]any{"serviceName": "pump"},
+		"remoteEndpoint": map[string]any{"serviceName": "db"},
+		"tags": map[string]any{
+			"host.name":            "device-1",
+			"peer.service":         "db",
+			"otel.library.name":    "lib",
+			"otel.library.version": "1.0",
+		},
+	}
+	if !reflect.DeepEqual(spans[1], client) {
+		t.Errorf("unexpected client span\n%v\nexpected\n%v", spans[1], client)
+	}
+	// an internal span without timestamps has no kind, timestamp nor duration
+	for _, key := range []string{"kind", "timestamp", "duration", "parentId", "remoteEndpoint", "annotations"} {
+		if v, ok := spans[2][key]; ok {
+			t.Errorf("expected no %s for the internal span, got %v", key, v)
+		}
+	}
+}
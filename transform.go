@@ -0,0 +1,695 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// TransformConfig holds the statements executed on the telemetry before it is written, they are a subset
+// of the OTTL statements of the transform processor:
+//
+//	set(attributes["env"], "edge") where resource.attributes["service.name"] == "pump"
+//	delete_key(resource.attributes, "host.id")
+//	delete_matching_keys(attributes, "^http\\.request\\.header\\.")
+//	keep_keys(instrumentation_scope.attributes, ["name", "version"])
+//
+// the paths common to all signals are resource.attributes, instrumentation_scope.attributes, the name and
+// version of instrumentation_scope and the attributes of the record; spans also have name, log records
+// body, severity_text and severity_number, and data points the name, description and unit of metric;
+// the conditions compare paths and literals with == and != and are joined with and
+type TransformConfig struct {
+	// Traces are executed for each span
+	Traces []string `mapstructure:"traces"`
+	// Metrics are executed for each data point
+	Metrics []string `mapstructure:"metrics"`
+	// Logs are executed for each log record
+	Logs []string `mapstructure:"logs"`
+}
+
+// Validate checks the statements can be parsed for their signal
+func (cfg *TransformConfig) Validate() error {
+	_, err := parseTransforms(cfg)
+	return err
+}
+
+// the paths of maps, they are read and written by key or passed to the functions editing the keys
+var transformMaps = map[string]bool{
+	"attributes":                       true,
+	"resource.attributes":              true,
+	"instrumentation_scope.attributes": true,
+}
+
+// the paths of the fields of the records of each signal
+var transformFields = map[string]map[string]bool{
+	signalTraces:  {"instrumentation_scope.name": true, "instrumentation_scope.version": true, "name": true},
+	signalMetrics: {"instrumentation_scope.name": true, "instrumentation_scope.version": true, "metric.name": true, "metric.description": true, "metric.unit": true},
+	signalLogs:    {"instrumentation_scope.name": true, "instrumentation_scope.version": true, "body": true, "severity_text": true, "severity_number": true},
+}
+
+// transforms are the parsed statements of each signal
+type transforms struct {
+	traces  []*transformStatement
+	metrics []*transformStatement
+	logs    []*transformStatement
+}
+
+func newTransforms(cfg *TransformConfig) *transforms {
+	if cfg == nil {
+		return nil
+	}
+	// the statements are validated with the configuration
+	t, _ := parseTransforms(cfg)
+	return t
+}
+
+func parseTransforms(cfg *TransformConfig) (*transforms, error) {
+	t := &transforms{}
+	for _, signal := range []struct {
+		name       string
+		statements []string
+		parsed     *[]*transformStatement
+	}{
+		{signalTraces, cfg.Traces, &t.traces},
+		{signalMetrics, cfg.Metrics, &t.metrics},
+		{signalLogs, cfg.Logs, &t.logs},
+	} {
+		for _, text := range signal.statements {
+			st, err := parseStatement(signal.name, text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s transform [%s]: %w", signal.name, text, err)
+			}
+			*signal.parsed = append(*signal.parsed, st)
+		}
+	}
+	return t, nil
+}
+
+// transformStatement is a function call executed on a record when all its comparisons hold
+type transformStatement struct {
+	text       string
+	function   string
+	args       []transformArg
+	conditions []transformComparison
+	// pattern is the compiled expression of delete_matching_keys
+	pattern *regexp.Regexp
+}
+
+// transformArg is either a path or a literal string, int64, float64, bool, nil or list of literals
+type transformArg struct {
+	path    *transformPath
+	literal any
+}
+
+// transformPath is a field of the record or a map, optionally indexed by a key
+type transformPath struct {
+	name  string
+	key   string
+	keyed bool
+}
+
+type transformComparison struct {
+	left  transformArg
+	right transformArg
+	equal bool
+}
+
+// statementParser parses a statement of the signal
+type statementParser struct {
+	scanner scanner.Scanner
+	tok     rune
+	signal  string
+	err     error
+}
+
+func parseStatement(signal, text string) (*transformStatement, error) {
+	p := &statementParser{signal: signal}
+	p.scanner.Init(strings.NewReader(text))
+	p.scanner.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings
+	p.scanner.Error = func(_ *scanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = errors.New(msg)
+		}
+	}
+	p.next()
+	st := &transformStatement{text: text}
+	if p.tok != scanner.Ident {
+		return nil, p.errorf("expected a function name")
+	}
+	st.function = p.scanner.TokenText()
+	p.next()
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for p.tok != ')' {
+		if len(st.args) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		st.args = append(st.args, arg)
+	}
+	p.next()
+	if p.tok == scanner.Ident && p.scanner.TokenText() == "where" {
+		for {
+			p.next()
+			c, err := p.parseComparison()
+			if err != nil {
+				return nil, err
+			}
+			st.conditions = append(st.conditions, c)
+			if p.tok != scanner.Ident || p.scanner.TokenText() != "and" {
+				break
+			}
+		}
+	}
+	if p.tok != scanner.EOF {
+		return nil, p.errorf("unexpected %s", p.scanner.TokenText())
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return st, st.check(signal)
+}
+
+func (p *statementParser) next() {
+	p.tok = p.scanner.Scan()
+}
+
+func (p *statementParser) errorf(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("%s at column %d", fmt.Sprintf(format, args...), p.scanner.Position.Column)
+}
+
+func (p *statementParser) expect(tok rune) error {
+	if p.tok != tok {
+		return p.errorf("expected %s", scanner.TokenString(tok))
+	}
+	p.next()
+	return nil
+}
+
+func (p *statementParser) parseComparison() (transformComparison, error) {
+	left, err := p.parseArg()
+	if err != nil {
+		return transformComparison{}, err
+	}
+	c := transformComparison{left: left}
+	switch p.tok {
+	case '=':
+		c.equal = true
+	case '!':
+	default:
+		return c, p.errorf("expected == or !=")
+	}
+	p.next()
+	if err = p.expect('='); err != nil {
+		return c, err
+	}
+	if c.right, err = p.parseArg(); err != nil {
+		return c, err
+	}
+	if _, ok := c.left.literal.([]any); ok {
+		return c, p.errorf("lists cannot be compared")
+	}
+	if _, ok := c.right.literal.([]any); ok {
+		return c, p.errorf("lists cannot be compared")
+	}
+	return c, nil
+}
+
+func (p *statementParser) parseArg() (transformArg, error) {
+	text := p.scanner.TokenText()
+	switch p.tok {
+	case scanner.String:
+		p.next()
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return transformArg{}, p.errorf("invalid string %s", text)
+		}
+		return transformArg{literal: s}, nil
+	case scanner.Int, scanner.Float, '-':
+		return p.parseNumber()
+	case '[':
+		p.next()
+		var list []any
+		for p.tok != ']' {
+			if len(list) > 0 {
+				if err := p.expect(','); err != nil {
+					return transformArg{}, err
+				}
+			}
+			arg, err := p.parseArg()
+			if err != nil {
+				return transformArg{}, err
+			}
+			if arg.path != nil {
+				return transformArg{}, p.errorf("lists can only hold literals")
+			}
+			list = append(list, arg.literal)
+		}
+		p.next()
+		return transformArg{literal: list}, nil
+	case scanner.Ident:
+		switch text {
+		case "true", "false":
+			p.next()
+			return transformArg{literal: text == "true"}, nil
+		case "nil":
+			p.next()
+			return transformArg{literal: nil}, nil
+		}
+		return p.parsePath()
+	}
+	return transformArg{}, p.errorf("unexpected %s", text)
+}
+
+func (p *statementParser) parseNumber() (transformArg, error) {
+	sign := ""
+	if p.tok == '-' {
+		sign = "-"
+		p.next()
+	}
+	text := sign + p.scanner.TokenText()
+	tok := p.tok
+	p.next()
+	switch tok {
+	case scanner.Int:
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return transformArg{literal: i}, nil
+		}
+	case scanner.Float:
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return transformArg{literal: f}, nil
+		}
+	}
+	return transformArg{}, p.errorf("invalid number %s", text)
+}
+
+func (p *statementParser) parsePath() (transformArg, error) {
+	fields := []string{p.scanner.TokenText()}
+	p.next()
+	for p.tok == '.' {
+		p.next()
+		if p.tok != scanner.Ident {
+			return transformArg{}, p.errorf("expected a field name")
+		}
+		fields = append(fields, p.scanner.TokenText())
+		p.next()
+	}
+	path := &transformPath{name: strings.Join(fields, ".")}
+	if p.tok == '[' {
+		p.next()
+		if p.tok != scanner.String {
+			return transformArg{}, p.errorf("expected a string key")
+		}
+		key, err := strconv.Unquote(p.scanner.TokenText())
+		if err != nil {
+			return transformArg{}, p.errorf("invalid key %s", p.scanner.TokenText())
+		}
+		path.key, path.keyed = key, true
+		p.next()
+		if err = p.expect(']'); err != nil {
+			return transformArg{}, err
+		}
+	}
+	switch {
+	case transformMaps[path.name]:
+	case path.keyed:
+		return transformArg{}, fmt.Errorf("%s is not a map", path.name)
+	case !transformFields[p.signal][path.name]:
+		return transformArg{}, fmt.Errorf("unknown path %s for %s", path.name, p.signal)
+	}
+	return transformArg{path: path}, nil
+}
+
+// check checks the arguments of the function and that the maps are only read by key
+func (st *transformStatement) check(signal string) error {
+	isMap := func(arg transformArg) bool {
+		return arg.path != nil && !arg.path.keyed && transformMaps[arg.path.name]
+	}
+	mapArgs := 0
+	for i, arg := range st.args {
+		if isMap(arg) {
+			mapArgs++
+			if i != 0 || st.function == "set" {
+				return fmt.Errorf("the map %s must be read by key", arg.path.name)
+			}
+		}
+	}
+	for _, c := range st.conditions {
+		if isMap(c.left) || isMap(c.right) {
+			return errors.New("maps must be compared by key")
+		}
+	}
+	switch st.function {
+	case "set":
+		if len(st.args) != 2 || st.args[0].path == nil {
+			return errors.New("set requires a path and a value")
+		}
+		if _, ok := st.args[1].literal.([]any); ok {
+			return errors.New("set does not support lists")
+		}
+		if st.args[0].path.keyed || st.args[1].path != nil {
+			return nil
+		}
+		return checkFieldValue(st.args[0].path.name, st.args[1].literal)
+	case "delete_key", "delete_matching_keys":
+		if len(st.args) != 2 || mapArgs != 1 {
+			return fmt.Errorf("%s requires a map and a key", st.function)
+		}
+		key, ok := st.args[1].literal.(string)
+		if !ok {
+			return fmt.Errorf("%s requires a string key", st.function)
+		}
+		if st.function == "delete_matching_keys" {
+			pattern, err := regexp.Compile(key)
+			if err != nil {
+				return fmt.Errorf("invalid pattern [%s]: %w", key, err)
+			}
+			st.pattern = pattern
+		}
+		return nil
+	case "keep_keys":
+		if len(st.args) != 2 || mapArgs != 1 {
+			return errors.New("keep_keys requires a map and a list of keys")
+		}
+		keys, ok := st.args[1].literal.([]any)
+		if !ok {
+			return errors.New("keep_keys requires a list of keys")
+		}
+		for _, key := range keys {
+			if _, ok = key.(string); !ok {
+				return errors.New("keep_keys requires string keys")
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown function %s, valid functions are [ set, delete_key, delete_matching_keys or keep_keys ]", st.function)
+}
+
+// checkFieldValue checks the value can be set to the field
+func checkFieldValue(field string, v any) error {
+	switch field {
+	case "body":
+		return nil
+	case "severity_number":
+		if _, ok := v.(int64); !ok {
+			return fmt.Errorf("%s must be set to an integer", field)
+		}
+		return nil
+	}
+	if _, ok := v.(string); !ok {
+		return fmt.Errorf("%s must be set to a string", field)
+	}
+	return nil
+}
+
+// transformRecord is the record a statement is executed on, only the record of the signal is set
+type transformRecord struct {
+	resource   pcommon.Resource
+	scope      pcommon.InstrumentationScope
+	attributes pcommon.Map
+	span       ptrace.Span
+	log        plog.LogRecord
+	metric     pmetric.Metric
+}
+
+func (r *transformRecord) mapOf(name string) pcommon.Map {
+	switch name {
+	case "resource.attributes":
+		return r.resource.Attributes()
+	case "instrumentation_scope.attributes":
+		return r.scope.Attributes()
+	}
+	return r.attributes
+}
+
+func (r *transformRecord) value(arg transformArg) any {
+	if arg.path == nil {
+		return arg.literal
+	}
+	p := arg.path
+	if p.keyed {
+		if v, ok := r.mapOf(p.name).Get(p.key); ok {
+			return v.AsRaw()
+		}
+		return nil
+	}
+	switch p.name {
+	case "instrumentation_scope.name":
+		return r.scope.Name()
+	case "instrumentation_scope.version":
+		return r.scope.Version()
+	case "name":
+		return r.span.Name()
+	case "body":
+		return r.log.Body().AsRaw()
+	case "severity_text":
+		return r.log.SeverityText()
+	case "severity_number":
+		return int64(r.log.SeverityNumber())
+	case "metric.name":
+		return r.metric.Name()
+	case "metric.description":
+		return r.metric.Description()
+	case "metric.unit":
+		return r.metric.Unit()
+	}
+	return nil
+}
+
+func (r *transformRecord) set(p *transformPath, v any) error {
+	if p.keyed {
+		m := r.mapOf(p.name)
+		if v == nil {
+			m.Remove(p.key)
+			return nil
+		}
+		return m.PutEmpty(p.key).FromRaw(v)
+	}
+	if err := checkFieldValue(p.name, v); err != nil {
+		return err
+	}
+	switch p.name {
+	case "instrumentation_scope.name":
+		r.scope.SetName(v.(string))
+	case "instrumentation_scope.version":
+		r.scope.SetVersion(v.(string))
+	case "name":
+		r.span.SetName(v.(string))
+	case "body":
+		return r.log.Body().FromRaw(v)
+	case "severity_text":
+		r.log.SetSeverityText(v.(string))
+	case "severity_number":
+		r.log.SetSeverityNumber(plog.SeverityNumber(v.(int64)))
+	case "metric.name":
+		r.metric.SetName(v.(string))
+	case "metric.description":
+		r.metric.SetDescription(v.(string))
+	case "metric.unit":
+		r.metric.SetUnit(v.(string))
+	}
+	return nil
+}
+
+// execute executes the statement on the record if its conditions hold
+func (st *transformStatement) execute(r *transformRecord) error {
+	for _, c := range st.conditions {
+		if transformEqual(r.value(c.left), r.value(c.right)) != c.equal {
+			return nil
+		}
+	}
+	switch st.function {
+	case "set":
+		return r.set(st.args[0].path, r.value(st.args[1]))
+	case "delete_key":
+		r.mapOf(st.args[0].path.name).Remove(st.args[1].literal.(string))
+	case "delete_matching_keys":
+		r.mapOf(st.args[0].path.name).RemoveIf(func(key string, _ pcommon.Value) bool {
+			return st.pattern.MatchString(key)
+		})
+	case "keep_keys":
+		keep := make(map[string]bool)
+		for _, key := range st.args[1].literal.([]any) {
+			keep[key.(string)] = true
+		}
+		r.mapOf(st.args[0].path.name).RemoveIf(func(key string, _ pcommon.Value) bool {
+			return !keep[key]
+		})
+	}
+	return nil
+}
+
+// transformEqual compares the values, integers and doubles are compared by value
+func transformEqual(a, b any) bool {
+	if x, ok := transformNumber(a); ok {
+		if y, ok := transformNumber(b); ok {
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func transformNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// executeAll executes the statements on the record in order, the statements failing on the record are
+// skipped and the first error is returned
+func executeAll(statements []*transformStatement, r *transformRecord) error {
+	var first error
+	for _, st := range statements {
+		if err := st.execute(r); err != nil && first == nil {
+			first = fmt.Errorf("transform [%s] failed: %w", st.text, err)
+		}
+	}
+	return first
+}
+
+// applyTraceTransforms returns a copy of the traces with the statements executed on each span, the passed
+// in traces are not modified
+func (e *fileExporter) applyTraceTransforms(td ptrace.Traces) ptrace.Traces {
+	if e.transforms == nil || len(e.transforms.traces) == 0 {
+		return td
+	}
+	out := ptrace.NewTraces()
+	td.CopyTo(out)
+	var first error
+	for i := 0; i < out.ResourceSpans().Len(); i++ {
+		rs := out.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				r := &transformRecord{resource: rs.Resource(), scope: ss.Scope(), attributes: span.Attributes(), span: span}
+				if err := executeAll(e.transforms.traces, r); err != nil && first == nil {
+					first = err
+				}
+			}
+		}
+	}
+	e.warnTransform(first)
+	return out
+}
+
+// applyMetricTransforms returns a copy of the metrics with the statements executed on each data point, the
+// passed in metrics are not modified
+func (e *fileExporter) applyMetricTransforms(md pmetric.Metrics) pmetric.Metrics {
+	if e.transforms == nil || len(e.transforms.metrics) == 0 {
+		return md
+	}
+	out := pmetric.NewMetrics()
+	md.CopyTo(out)
+	var first error
+	for i := 0; i < out.ResourceMetrics().Len(); i++ {
+		rm := out.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				metric := sm.Metrics().At(k)
+				for _, attrs := range dataPointAttributes(metric) {
+					r := &transformRecord{resource: rm.Resource(), scope: sm.Scope(), attributes: attrs, metric: metric}
+					if err := executeAll(e.transforms.metrics, r); err != nil && first == nil {
+						first = err
+					}
+				}
+			}
+		}
+	}
+	e.warnTransform(first)
+	return out
+}
+
+// dataPointAttributes returns the attributes of the data points of the metric
+func dataPointAttributes(metric pmetric.Metric) []pcommon.Map {
+	var attrs []pcommon.Map
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Gauge().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Sum().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Histogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.ExponentialHistogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			attrs = append(attrs, metric.Summary().DataPoints().At(i).Attributes())
+		}
+	}
+	return attrs
+}
+
+// applyLogTransforms returns a copy of the logs with the statements executed on each log record, the passed
+// in logs are not modified
+func (e *fileExporter) applyLogTransforms(ld plog.Logs) plog.Logs {
+	if e.transforms == nil || len(e.transforms.logs) == 0 {
+		return ld
+	}
+	out := plog.NewLogs()
+	ld.CopyTo(out)
+	var first error
+	for i := 0; i < out.ResourceLogs().Len(); i++ {
+		rl := out.ResourceLogs().At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				lr := sl.LogRecords().At(k)
+				r := &transformRecord{resource: rl.Resource(), scope: sl.Scope(), attributes: lr.Attributes(), log: lr}
+				if err := executeAll(e.transforms.logs, r); err != nil && first == nil {
+					first = err
+				}
+			}
+		}
+	}
+	e.warnTransform(first)
+	return out
+}
+
+// warnTransform logs the first statement that failed on a batch, the records are written without the
+// failed statements
+func (e *fileExporter) warnTransform(err error) {
+	if err != nil {
+		e.logger.Warn("failed to transform some records", zap.Error(err))
+	}
+}
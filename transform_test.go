@@ -0,0 +1,179 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestTransformGrammar(t *testing.T) {
+	for _, test := range []struct {
+		signal    string
+		statement string
+		// err is a part of the error of an invalid statement, empty if the statement is valid
+		err string
+	}{
+		{signalTraces, `set(attributes["env"], "edge") where resource.attributes["service.name"] == "pump"`, ""},
+		{signalTraces, `set(name, "checkout") where attributes["a"] != nil and instrumentation_scope.name == "lib"`, ""},
+		{signalTraces, `set(attributes["n"], -1)`, ""},
+		{signalTraces, `set(attributes["ratio"], 0.5) where attributes["enabled"] == true`, ""},
+		{signalTraces, `set(attributes["copy"], resource.attributes["host.name"])`, ""},
+		{signalTraces, `delete_key(resource.attributes, "host.id")`, ""},
+		{signalTraces, `delete_matching_keys(attributes, "^http\\.request\\.header\\.")`, ""},
+		{signalTraces, `keep_keys(instrumentation_scope.attributes, ["name", "version"])`, ""},
+		{signalTraces, `keep_keys(attributes, [])`, ""},
+		{signalMetrics, `set(metric.unit, "ms") where metric.name == "latency"`, ""},
+		{signalLogs, `set(severity_number, 9) where severity_text == "INFO"`, ""},
+		{signalLogs, `set(body, 1.5)`, ""},
+
+		{signalTraces, ``, "expected a function name"},
+		{signalTraces, `set attributes["env"], "edge")`, `expected "("`},
+		{signalTraces, `set(attributes["env"] "edge")`, `expected ","`},
+		{signalTraces, `set(attributes["env"], "edge"`, `expected ","`},
+		{signalTraces, `set(attributes["env"], "edge") extra`, "unexpected extra"},
+		{signalTraces, `set(attributes["env"], "edge") where name = "x"`, `expected "="`},
+		{signalTraces, `set(attributes["env"], "edge") where name == "x" or name == "y"`, "unexpected or"},
+		{signalTraces, `set(attributes["env"], "edge") where name < "x"`, "expected == or !="},
+		{signalTraces, `set(attributes["a"], "unterminated)`, "literal not terminated"},
+		{signalTraces, `set(attributes[env], "x")`, "expected a string key"},
+		{signalTraces, `set(attributes["a"], [name])`, "lists can only hold literals"},
+		{signalTraces, `set(attributes["a"], ["x"])`, "set does not support lists"},
+		{signalTraces, `set(attributes["a"])`, "set requires a path and a value"},
+		{signalTraces, `set("a", "b")`, "set requires a path and a value"},
+		{signalTraces, `set(attributes, "x")`, "the map attributes must be read by key"},
+		{signalTraces, `set(attributes["a"], "x") where attributes == nil`, "maps must be compared by key"},
+		{signalTraces, `set(attributes["a"], "x") where attributes["b"] == ["x"]`, "lists cannot be compared"},
+		{signalTraces, `set(name["k"], "x")`, "name is not a map"},
+		{signalTraces, `set(name, 1)`, "name must be set to a string"},
+		{signalTraces, `set(body, "x")`, "unknown path body for traces"},
+		{signalTraces, `set(resource.name, "x")`, "unknown path resource.name for traces"},
+		{signalMetrics, `set(name, "x")`, "unknown path name for metrics"},
+		{signalLogs, `set(severity_number, "INFO")`, "severity_number must be set to an integer"},
+		{signalTraces, `drop(attributes)`, "unknown function drop"},
+		{signalTraces, `delete_key(attributes, 1)`, "delete_key requires a string key"},
+		{signalTraces, `delete_key("a", attributes)`, "the map attributes must be read by key"},
+		{signalTraces, `delete_key(attributes["a"], "b")`, "delete_key requires a map and a key"},
+		{signalTraces, `delete_matching_keys(attributes, "(")`, "invalid pattern [(]"},
+		{signalTraces, `keep_keys(attributes, "a")`, "keep_keys requires a list of keys"},
+		{signalTraces, `keep_keys(attributes, ["a", 1])`, "keep_keys requires string keys"},
+		{signalTraces, `set(attributes["a"], 99999999999999999999)`, "invalid number"},
+	} {
+		t.Run(test.signal+" "+test.statement, func(t *testing.T) {
+			cfg := testConfig(t, 1<<20)
+			cfg.Transforms = &TransformConfig{}
+			switch test.signal {
+			case signalTraces:
+				cfg.Transforms.Traces = []string{test.statement}
+			case signalMetrics:
+				cfg.Transforms.Metrics = []string{test.statement}
+			case signalLogs:
+				cfg.Transforms.Logs = []string{test.statement}
+			}
+			err := cfg.Validate()
+			switch {
+			case len(test.err) == 0 && err != nil:
+				t.Fatalf("expected the statement to be valid, got %v", err)
+			case len(test.err) > 0 && err == nil:
+				t.Fatalf("expected the statement to be rejected with %q", test.err)
+			case len(test.err) > 0 && !strings.Contains(err.Error(), test.err):
+				t.Fatalf("expected the statement to be rejected with %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestFilterGrammar(t *testing.T) {
+	for name, test := range map[string]struct {
+		filter MatchConfig
+		err    string
+	}{
+		"valid":                        {filter: MatchConfig{SpanNames: []string{"^GET /"}, MetricNames: []string{"cpu.*"}, LogSeverities: []string{"warn", "ERROR"}, ResourceAttributes: map[string]string{"service.name": "pump|valve"}}},
+		"invalid span name":            {filter: MatchConfig{SpanNames: []string{"("}}, err: "invalid filter expression [(]"},
+		"invalid metric name":          {filter: MatchConfig{MetricNames: []string{"[a-"}}, err: "invalid filter expression [[a-]"},
+		"invalid resource attribute":   {filter: MatchConfig{ResourceAttributes: map[string]string{"host": "*"}}, err: "for resource attribute [host]"},
+		"invalid severity":             {filter: MatchConfig{LogSeverities: []string{"VERBOSE"}}, err: "invalid filter log severity [VERBOSE]"},
+		"invalid severity of excluded": {filter: MatchConfig{LogSeverities: []string{""}}, err: "invalid filter log severity []"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, include := range []bool{true, false} {
+				cfg := testConfig(t, 1<<20)
+				filter := test.filter
+				if include {
+					cfg.Include = &filter
+				} else {
+					cfg.Exclude = &filter
+				}
+				err := cfg.Validate()
+				switch {
+				case len(test.err) == 0 && err != nil:
+					t.Fatalf("expected the filter to be valid, got %v", err)
+				case len(test.err) > 0 && (err == nil || !strings.Contains(err.Error(), test.err)):
+					t.Fatalf("expected the filter to be rejected with %q, got %v", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformExecution(t *testing.T) {
+	cfg := testConfig(t, 1<<20)
+	cfg.Transforms = &TransformConfig{
+		Traces: []string{
+			`set(attributes["env"], "edge") where resource.attributes["service.name"] == "pump"`,
+			`set(attributes["retried"], true) where attributes["attempt"] == 2.0`,
+			`set(attributes["host"], resource.attributes["host.name"])`,
+			`delete_matching_keys(attributes, "^secret\\.")`,
+			`keep_keys(resource.attributes, ["service.name"])`,
+			`set(name, "renamed") where name != "keep"`,
+		},
+		Logs: []string{`set(severity_number, 13) where severity_text == "WARN"`},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestExporter(t, cfg)
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "pump")
+	rs.Resource().Attributes().PutStr("host.name", "device-1")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	span := spans.AppendEmpty()
+	span.SetName("read")
+	span.Attributes().PutInt("attempt", 2)
+	span.Attributes().PutStr("secret.token", "x")
+	kept := spans.AppendEmpty()
+	kept.SetName("keep")
+	out := e.applyTraceTransforms(td)
+	if td.ResourceSpans().At(0).Resource().Attributes().Len() != 2 {
+		t.Fatal("expected the traces passed in to be left unchanged")
+	}
+	outRs := out.ResourceSpans().At(0)
+	if attrs := outRs.Resource().Attributes().AsRaw(); !reflect.DeepEqual(attrs, map[string]any{"service.name": "pump"}) {
+		t.Fatalf("unexpected resource attributes %v", attrs)
+	}
+	outSpan := outRs.ScopeSpans().At(0).Spans().At(0)
+	want := map[string]any{"env": "edge", "attempt": int64(2), "retried": true, "host": "device-1"}
+	if attrs := outSpan.Attributes().AsRaw(); !reflect.DeepEqual(attrs, want) || outSpan.Name() != "renamed" {
+		t.Fatalf("unexpected span %s with attributes %v", outSpan.Name(), attrs)
+	}
+	if name := outRs.ScopeSpans().At(0).Spans().At(1).Name(); name != "keep" {
+		t.Fatalf("expected the span not matching the condition to keep its name, got %s", name)
+	}
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetSeverityText("WARN")
+	if severity := e.applyLogTransforms(ld).ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).SeverityNumber(); severity != plog.SeverityNumberWarn {
+		t.Fatalf("expected the severity number to be set, got %v", severity)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// trashDir is the sub directory of the roots the purged files are moved to, it is not counted in the
+	// directory size and its files are never uploaded
+	trashDir = ".trash"
+
+	defaultTrashMaxAge        = 24 * time.Hour
+	defaultTrashPurgeInterval = 10 * time.Minute
+)
+
+// TrashConfig moves the files purged when the disk usage limit is reached to a trash directory instead of
+// deleting them, so that the files purged by a misconfigured limit can be recovered for a while
+type TrashConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxSize is the maximum size of the trash of every root such as 500MiB, the oldest trashed files are
+	// deleted once it is exceeded; empty means no limit
+	MaxSize string `mapstructure:"maxSize"`
+	// MaxAge is how long the files are kept in the trash, it defaults to a day
+	MaxAge time.Duration `mapstructure:"maxAge"`
+	// PurgeInterval is how often the files past their age are deleted from the trash, it defaults to ten minutes
+	PurgeInterval time.Duration `mapstructure:"purgeInterval"`
+}
+
+// validateTrash checks the trash holds the files purged from the path and its limits are valid
+func (cfg *Config) validateTrash() error {
+	if cfg.Trash == nil || !cfg.Trash.Enabled {
+		return nil
+	}
+	if !strings.EqualFold(cfg.OnDiskFull, DiskFullPurgeOldest) {
+		return fmt.Errorf("trash requires onDiskFull %s as only the purged files are moved to the trash", DiskFullPurgeOldest)
+	}
+	if _, err := parseSize(cfg.Trash.MaxSize); err != nil {
+		return fmt.Errorf("invalid trash maxSize: %w", err)
+	}
+	if cfg.Trash.MaxAge < 0 || cfg.Trash.PurgeInterval < 0 {
+		return errors.New("trash maxAge and purgeInterval must not be negative")
+	}
+	return nil
+}
+
+// trash holds the files purged from the roots until they are past their age or the trash is full
+type trash struct {
+	maxSize  int64
+	maxAge   time.Duration
+	interval time.Duration
+	// mutex serialises the purges of the trash by the writers and the purge loop
+	mutex  sync.Mutex
+	logger *zap.Logger
+}
+
+// newTrash returns the trash of the exporter, nil if the purged files are deleted
+func newTrash(cfg *TrashConfig, logger *zap.Logger) *trash {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	// an invalid size is rejected by the validation of the configuration
+	maxSize, _ := parseSize(cfg.MaxSize)
+	t := &trash{maxSize: maxSize, maxAge: cfg.MaxAge, interval: cfg.PurgeInterval, logger: logger}
+	if t.maxAge == 0 {
+		t.maxAge = defaultTrashMaxAge
+	}
+	if t.interval == 0 {
+		t.interval = defaultTrashPurgeInterval
+	}
+	return t
+}
+
+// move moves the file under the root to the trash of the root keeping its relative path, its modification
+// time is set to the time it is trashed so that it is kept for the age of the trash
+func (t *trash) move(root, f string) error {
+	rel, err := filepath.Rel(root, f)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(root, trashDir, rel)
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err = os.Rename(f, dest); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err = os.Chtimes(dest, now, now); err != nil {
+		return err
+	}
+	return t.purge(root, now)
+}
+
+// trashedFiles returns the files in the trash of the root sorted from oldest to newest
+func trashedFiles(root string) ([]finishedFile, error) {
+	var files []finishedFile
+	err := filepath.WalkDir(filepath.Join(root, trashDir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, finishedFile{path: p, info: info})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+	return files, err
+}
+
+// purge deletes the files of the trash of the root past their age, and the oldest files while the trash
+// exceeds its size
+func (t *trash) purge(root string, now time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	files, err := trashedFiles(root)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.info.Size()
+	}
+	for _, f := range files {
+		if now.Sub(f.info.ModTime()) < t.maxAge && (t.maxSize == 0 || size <= t.maxSize) {
+			break
+		}
+		t.logger.Info("deleting file from the trash", zap.String("file", f.path), zap.Time("trashed", f.info.ModTime()))
+		if err = os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= f.info.Size()
+	}
+	return nil
+}
+
+// purgeOldest deletes the oldest file of the trash of the root, it returns false if the trash is empty
+func (t *trash) purgeOldest(root string) (bool, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	files, err := trashedFiles(root)
+	if err != nil || len(files) == 0 {
+		return false, err
+	}
+	t.logger.Warn("deleting oldest file from the trash as the free disk space is below the minimum",
+		zap.String("file", files[0].path), zap.Int64("size", files[0].info.Size()))
+	if err = os.Remove(files[0].path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// trashRoots returns the roots the purged files may have been moved from
+func (e *fileExporter) trashRoots() []string {
+	roots := append([]string{e.path}, e.mirrorPaths...)
+	if e.fallback != nil {
+		roots = append(roots, e.fallback.path)
+	}
+	return roots
+}
+
+// trashLoop deletes the files of the trash past their age until the exporter is shut down
+func (e *fileExporter) trashLoop() {
+	ticker := time.NewTicker(e.trash.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			for _, root := range e.trashRoots() {
+				if err := e.trash.purge(root, now); err != nil {
+					e.logger.Error("failed to purge the trash", zap.String("path", root), zap.Error(err))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// how often the rotate trigger file is looked for
+const rotateTriggerInterval = time.Second
+
+// validateRotateTrigger checks the rotate trigger is a file name
+func validateRotateTrigger(name string) error {
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid rotateTriggerFile [%s], it must be a file name", name)
+	}
+	return nil
+}
+
+// rotateIfTriggered finishes all the in process files if the rotate trigger file exists in the path or
+// in a mirror path, the trigger file is removed once the files are finished
+func (e *fileExporter) rotateIfTriggered() error {
+	var triggers []string
+	for _, root := range e.roots() {
+		trigger := filepath.Join(root, e.rotateTrigger)
+		if _, err := os.Stat(trigger); err == nil {
+			triggers = append(triggers, trigger)
+		}
+	}
+	if len(triggers) == 0 {
+		return nil
+	}
+	e.logger.Info("rotate trigger file found, finishing all inprocess files", zap.Strings("triggers", triggers))
+	err := e.rotateAll(context.Background())
+	if err != nil {
+		// the trigger files are kept so the rotation is tried again
+		return err
+	}
+	for _, trigger := range triggers {
+		err = multierr.Append(err, os.Remove(trigger))
+	}
+	return err
+}
+
+// rotateAll finishes the non empty in process files of all the writers regardless of the size and
+// count thresholds, it gives up if the context is done while waiting for a writer
+func (e *fileExporter) rotateAll(ctx context.Context) error {
+	err := e.eachSibling(func(s *fileExporter) error {
+		return s.rotateAll(ctx)
+	})
+	if e.isRotationNone() {
+		return err
+	}
+	return multierr.Append(err, e.eachWriter(ctx, func(w *fileWriter) error {
+		f, ok := e.pendingInProcess(w)
+		if !ok {
+			return nil
+		}
+		e.debug("finishing inprocess file on rotate", zap.String("file", f))
+		w.lastWrite = time.Time{}
+		err := e.finishFile(w, f)
+		if err != nil {
+			e.logger.Error("failed to finish inprocess file on rotate", zap.String("file", f), zap.Error(err))
+		}
+		return err
+	}))
+}
+
+// pendingInProcess returns the in process file of the writer and true if it holds data, the size of
+// a file resumed from a previous run and not yet opened is read from the file
+func (e *fileExporter) pendingInProcess(w *fileWriter) (string, bool) {
+	f := e.inProcessFileOf(w)
+	if w.file != nil && w.fileName == f {
+		return f, w.size > 0
+	}
+	stat, err := os.Stat(f)
+	return f, err == nil && stat.Size() > 0
+}
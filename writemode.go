@@ -0,0 +1,196 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// WriteModeAppend appends the batches to the in process files as they grow
+	WriteModeAppend = "append"
+	// WriteModePreallocate allocates the blocks of the in process files up to the rotation size when they
+	// are created and releases the unused blocks when they are finished, it only applies to the files
+	// rotated by size and only allocates on linux
+	WriteModePreallocate = "preallocate"
+	// WriteModeMmap allocates the in process files up to the rotation size when they are created and copies
+	// the batches to the memory mapped file instead of writing them, the file is truncated to its data when
+	// it is finished; it only applies to the files rotated by size and to the json and csv formats, whose
+	// records never end with a zero byte so that the unwritten tail of a file left by a crash is trimmed,
+	// and only on linux where the blocks are reserved so that a full disk fails the write
+	WriteModeMmap = "mmap"
+)
+
+// validateWriteMode checks the write mode is supported by the rotation
+func (cfg *Config) validateWriteMode() error {
+	switch strings.ToLower(cfg.WriteMode) {
+	case "", WriteModeAppend:
+		return nil
+	case WriteModePreallocate:
+		if strings.EqualFold(cfg.Rotation, RotationNone) {
+			return errors.New("the preallocate writeMode requires rotation as the fixed file has no size")
+		}
+		return nil
+	case WriteModeMmap:
+		if !mmapSupported || !preallocateSupported {
+			return errors.New("the mmap writeMode is only supported on linux, where the space of the mapped file can be reserved")
+		}
+		if strings.EqualFold(cfg.Rotation, RotationNone) {
+			return errors.New("the mmap writeMode requires rotation as the fixed file has no size")
+		}
+		if !isJSONFormat(cfg.Format) && !strings.EqualFold(cfg.Format, Csv) {
+			return fmt.Errorf("the mmap writeMode requires a json or csv format, the records of the %s format cannot be told apart from the unwritten tail of a file", cfg.Format)
+		}
+		if cfg.DirectIO {
+			return errors.New("directIO cannot be used with the mmap write mode as the mapped file is written through the page cache")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid writeMode [%s], valid values are [ %s, %s or %s ]", cfg.WriteMode, WriteModeAppend, WriteModePreallocate, WriteModeMmap)
+}
+
+// mmapWriter copies the writes to the in process file mapped in memory, the file is extended and mapped
+// again when the data outgrows it
+type mmapWriter struct {
+	file fsFile
+	data []byte
+	// size is the number of bytes of data written to the file
+	size int64
+	// slack is the number of bytes mapped beyond the data, so that the size of the file is accounted without
+	// them; it is read without the writer lock
+	slack *int64
+}
+
+// newMmapWriter maps the file holding size bytes of data, extended to at least the file size
+func newMmapWriter(file fsFile, size, fileSize int64, slack *int64) (*mmapWriter, error) {
+	w := &mmapWriter{file: file, size: size, slack: slack}
+	if err := w.remap(size + fileSize - size%fileSize); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// remap extends the file to the length and maps it
+func (w *mmapWriter) remap(length int64) error {
+	if err := w.release(); err != nil {
+		return err
+	}
+	// the blocks must be reserved, a store to a mapped page the disk has no room for kills the process
+	if err := preallocate(w.file, length); err != nil {
+		return fmt.Errorf("cannot reserve %d bytes for the mapped file: %w", length, err)
+	}
+	if err := w.file.Truncate(length); err != nil {
+		return err
+	}
+	data, err := mapFile(w.file, int(length))
+	if err != nil {
+		return err
+	}
+	w.data = data
+	atomic.StoreInt64(w.slack, length-w.size)
+	return nil
+}
+
+func (w *mmapWriter) Write(p []byte) (int, error) {
+	if need := w.size + int64(len(p)); need > int64(len(w.data)) {
+		length := 2 * int64(len(w.data))
+		if length < need {
+			length = need
+		}
+		if err := w.remap(length); err != nil {
+			return 0, err
+		}
+	}
+	copy(w.data[w.size:], p)
+	w.size += int64(len(p))
+	atomic.StoreInt64(w.slack, int64(len(w.data))-w.size)
+	return len(p), nil
+}
+
+// Flush does nothing as the mapped pages are written back by the page cache, and by the sync of the file
+func (w *mmapWriter) Flush() error {
+	return nil
+}
+
+// release unmaps the file, the file is left with its mapped length
+func (w *mmapWriter) release() error {
+	if w.data == nil {
+		return nil
+	}
+	err := unmapFile(w.data)
+	w.data = nil
+	// the file is truncated to its data once unmapped
+	atomic.StoreInt64(w.slack, 0)
+	return err
+}
+
+// mappedSlack returns the number of bytes the in process files under the root are mapped beyond their data,
+// they are reserved on disk but not yet written
+func (e *fileExporter) mappedSlack(root string) int64 {
+	if !e.mmap {
+		return 0
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var slack int64
+	for _, w := range e.writers {
+		if isUnder(root, w.path) {
+			slack += atomic.LoadInt64(&w.mappedSlack)
+		}
+	}
+	return slack
+}
+
+// mmapDataSize returns the size of the data of the file of the size written through a mapping, without the
+// unwritten zero filled tail of the mapped length
+func mmapDataSize(file io.ReaderAt, size int64) (int64, error) {
+	buf := make([]byte, 64*1024)
+	for size > 0 {
+		offset := size - int64(len(buf))
+		if offset < 0 {
+			offset = 0
+		}
+		n, err := file.ReadAt(buf[:size-offset], offset)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] != 0 {
+				return offset + int64(i) + 1, nil
+			}
+		}
+		size = offset
+	}
+	return 0, nil
+}
+
+// trimMmapTail truncates the in process file left by a crash while it was mapped to its data
+func (e *fileExporter) trimMmapTail(f string) error {
+	file, err := e.fs.OpenFile(f, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err == nil {
+		var size int64
+		if size, err = mmapDataSize(file, stat.Size()); err == nil && size < stat.Size() {
+			e.logger.Info("trimming the unwritten tail of the mapped inprocess file", zap.String("file", f), zap.Int64("size", size))
+			err = file.Truncate(size)
+		}
+	}
+	return multierr.Append(err, file.Close())
+}
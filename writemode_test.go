@@ -0,0 +1,125 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mmapConfig returns the configuration of an exporter writing json files of the size through a mapping
+func mmapConfig(t *testing.T, fileSize int64) *Config {
+	if !mmapSupported || !preallocateSupported {
+		t.Skip("memory mapped files are not supported")
+	}
+	cfg := testConfig(t, fileSize)
+	cfg.WriteMode = WriteModeMmap
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestValidateWriteModeMmap(t *testing.T) {
+	cfg := mmapConfig(t, 1<<20)
+	cfg.Rotation = RotationNone
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected the mmap write mode to be rejected without rotation")
+	}
+	cfg = mmapConfig(t, 1<<20)
+	cfg.Format = "proto"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected the mmap write mode to be rejected with the proto format")
+	}
+}
+
+func TestWriteModeMmapTruncatesClosedFile(t *testing.T) {
+	cfg := mmapConfig(t, 1<<20)
+	e := newTestExporter(t, cfg)
+	if err := e.Start(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`{"a":1}`, `{"a":2}`, `{"a":3}`} {
+		if err := writeLine(e, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}{"a":2}{"a":3}` {
+		t.Fatalf("unexpected in process file content %q", content)
+	}
+}
+
+func TestWriteModeMmapRotation(t *testing.T) {
+	cfg := mmapConfig(t, 16)
+	e := startTestExporter(t, cfg)
+	for _, line := range []string{`{"a":1}`, `{"a":2}`, `{"a":3}`, `{"a":4}`, `{"a":5}`} {
+		if err := writeLine(e, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := finishedFilesOf(t, e)
+	if len(files) == 0 {
+		t.Fatal("expected the files to be rotated")
+	}
+	for _, f := range files {
+		if content := readFile(t, f); len(content) == 0 || bytes.IndexByte([]byte(content), 0) >= 0 {
+			t.Errorf("unexpected content %q of the finished file %s", content, f)
+		}
+	}
+}
+
+func TestRecoverMmapInProcessFile(t *testing.T) {
+	cfg := mmapConfig(t, 1<<20)
+	cfg.RecoverInProcess = RecoverFinalize
+	// the file left by a crash keeps the length it was mapped with
+	data := append([]byte(`{"a":1}{"a":2}`), make([]byte, 4096)...)
+	if err := os.WriteFile(filepath.Join(cfg.Path, inProcessName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := startTestExporter(t, cfg)
+	files := finishedFilesOf(t, e)
+	if len(files) != 1 || readFile(t, files[0]) != `{"a":1}{"a":2}` {
+		t.Fatalf("expected the in process file to be finished without its tail, got %v", files)
+	}
+}
+
+func TestMmapDataSize(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		size int64
+	}{
+		{"", 0},
+		{"\x00\x00", 0},
+		{"{}", 2},
+		{"{}\n\x00\x00\x00", 3},
+	} {
+		size, err := mmapDataSize(bytes.NewReader([]byte(c.data)), int64(len(c.data)))
+		if err != nil || size != c.size {
+			t.Errorf("expected the data size %d of %q, got %d, %v", c.size, c.data, size, err)
+		}
+	}
+}
+
+func TestWriteModeMmapAccountsData(t *testing.T) {
+	cfg := mmapConfig(t, 1<<20)
+	e := startTestExporter(t, cfg)
+	writeLines(t, e, `{"a":1}`, `{"a":2}`)
+	if slack := e.mappedSlack(cfg.Path); slack != 1<<20-14 {
+		t.Fatalf("expected the bytes mapped beyond the data to be accounted, got %d", slack)
+	}
+	if s := e.status(); s.InProcessFiles != 1 || s.InProcessBytes != 14 {
+		t.Fatalf("expected the in process file to count for its data, got %d files of %d bytes", s.InProcessFiles, s.InProcessBytes)
+	}
+}
@@ -0,0 +1,351 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// the size of the buffer of the in process file writes
+const writeBufferSize = 64 * 1024
+
+// batch is a unit of marshaled telemetry appended to an in process file
+type batch struct {
+	signal  string
+	records int
+	buf     []byte
+	// rowGroup describes the column chunks in buf when writing parquet files
+	rowGroup *parquetRowGroup
+	// route is the sub directory of the records routed to their own file series, empty for the default files
+	route string
+	// first and last are the earliest and latest timestamps of the records, set when manifests are written
+	first pcommon.Timestamp
+	last  pcommon.Timestamp
+	// header is written before the batch when the in process file is empty
+	header []byte
+	// dropped is true if the batch was dropped from the path instead of being written
+	dropped bool
+	// lateKey identifies the batch when its write is abandoned at its deadline, nil without deadline
+	lateKey *[sha256.Size]byte
+}
+
+// fileWriter holds the rotation state of the in process file of an output directory, each output
+// directory rotates independently of the others
+type fileWriter struct {
+	// mutex ensures only one operation on the in process file happens at a time
+	mutex writeLock
+	// fs is the file system the in process file is written to
+	fs fileSystem
+	// the directory of the in process file and the shard of the directory it is written to
+	path  string
+	shard int
+	// dirReady is true once the directory has been created
+	dirReady          bool
+	currentEventCount int64
+	// seq is the sequence number of the last finished file
+	seq int64
+	// signals holds the signal types written to the current in process file
+	signals map[string]bool
+	// rowGroups holds the row groups written to the current in process parquet file
+	rowGroups []*parquetRowGroup
+	// footerWritten is true once the parquet footer is appended to the in process file, so that a retried
+	// rotation does not append it again
+	footerWritten bool
+	// bundleSeq is the sequence number of the last bundle
+	bundleSeq int64
+	// stats holds the statistics of the batches written to the in process file
+	stats fileStats
+	// lastWrite is the time of the last batch written to the in process file, zero if it is empty
+	lastWrite time.Time
+	// opened is the time of the first batch written to the in process file, zero if it is empty
+	opened time.Time
+	// file is the open in process file and out buffers the writes to it
+	file     fsFile
+	out      fileBuffer
+	fileName string
+	// size is the number of bytes of the in process file, it is read from the file when it is opened
+	// and then accounted in memory
+	size int64
+	// bucket is the start of the clock boundary bucket of the data in the in process file, zero if unknown
+	bucket time.Time
+	// bufferSize is the size of the write buffer, large enough to aggregate the batches up to the flush bytes
+	bufferSize int
+	// directIO opens the in process file for direct writes
+	directIO bool
+	// pendingBytes and pendingRecords are the bytes and records buffered and not yet written to the file,
+	// firstPending is the time the oldest of them was buffered
+	pendingBytes   int64
+	pendingRecords int64
+	firstPending   time.Time
+	// fileSize in bytes and eventsPerFile define when the in process file is rotated
+	fileSize      int64
+	eventsPerFile int64
+	// preallocate allocates the blocks of the in process file up to the file size when it is opened
+	preallocate bool
+	// mmap writes the in process file through a memory mapping extended to the file size
+	mmap bool
+	// mappedSlack is the number of bytes the in process file is mapped beyond its data, accessed atomically
+	mappedSlack int64
+	// failures is the number of consecutive failed writes to the in process file
+	failures int
+	// lastNameTime is the time the previous finished file was named with
+	lastNameTime time.Time
+	// chunkHash is the rolling hash of the bytes written to the in process file when it is chunked
+	chunkHash uint64
+}
+
+// writer returns the writer of the shard of the passed in directory of the route, creating it if it does not
+// exist yet; the writer must be locked before it is used
+func (e *fileExporter) writer(path string, route string, shard int) *fileWriter {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	key := writerKey{path: path, shard: shard}
+	if w, ok := e.writers[key]; ok {
+		return w
+	}
+	w := &fileWriter{
+		path:    path,
+		shard:   shard,
+		fs:      e.fs,
+		mutex:   newWriteLock(),
+		signals: make(map[string]bool),
+	}
+	w.fileSize, w.eventsPerFile = e.rotationLimits(route)
+	w.bufferSize = e.writeBuffer
+	if int(e.flushBytes) > w.bufferSize {
+		w.bufferSize = int(e.flushBytes)
+	}
+	w.directIO = e.directIO
+	w.preallocate = e.preallocate && w.fileSize > 0
+	w.mmap = e.mmap && w.fileSize > 0
+	e.restoreSequences(w)
+	e.writers[key] = w
+	return w
+}
+
+// eachWriter calls f for every writer holding the writer lock, the writers are locked one at a time so
+// the others keep being written to; it gives up if the context is done while waiting for a writer
+func (e *fileExporter) eachWriter(ctx context.Context, f func(w *fileWriter) error) error {
+	e.mutex.Lock()
+	writers := make([]*fileWriter, 0, len(e.writers))
+	for _, w := range e.writers {
+		writers = append(writers, w)
+	}
+	e.mutex.Unlock()
+	var errs error
+	for _, w := range writers {
+		if err := w.mutex.LockContext(ctx); err != nil {
+			return multierr.Append(errs, err)
+		}
+		errs = multierr.Append(errs, f(w))
+		w.mutex.Unlock()
+	}
+	return errs
+}
+
+// legacyInProcessName is the in process file name written before the name depended on the platform
+const legacyInProcessName = "." + ext
+
+// inProcessFile returns the in process file of the directory when the path is not shared
+func inProcessFile(dir string) string {
+	return filepath.Join(dir, inProcessName)
+}
+
+// appendBatch appends the batch to the in process file and records its signal, unless the batches are
+// aggregated the batch is flushed to the file so a crash loses at most the batch being written; a failed
+// write is discarded so that the file does not end with a torn record and the retried batch is written
+// to a clean buffer
+func (e *fileExporter) appendBatch(w *fileWriter, b *batch, f string, perm os.FileMode) error {
+	err := w.open(f, perm)
+	before := w.size
+	flushed := w.size - w.pendingBytes
+	if err == nil {
+		if b.rowGroup != nil {
+			err = appendParquetRowGroup(w, b)
+		} else {
+			if w.size == 0 {
+				err = w.write(e.arrayStart(e.headerOf(b)))
+			}
+			if sep := e.arraySeparator(w); err == nil && len(sep) > 0 {
+				err = w.write(sep)
+			}
+			if err == nil {
+				err = w.write(b.buf)
+			}
+		}
+	}
+	if err == nil {
+		if w.firstPending.IsZero() {
+			w.firstPending = time.Now()
+		}
+		w.pendingBytes += w.size - before
+		w.pendingRecords += int64(b.records)
+		if e.shouldFlush(w) {
+			err = w.flush()
+		}
+		if err == nil && e.syncWrites {
+			err = w.file.Sync()
+		}
+	}
+	if err != nil {
+		if w.pendingRecords > int64(b.records) {
+			e.logger.Warn("discarding the batches buffered with the failed write", zap.String("file", f),
+				zap.Int64("records", w.pendingRecords-int64(b.records)))
+		}
+		if discardErr := w.discard(flushed); discardErr != nil {
+			e.logger.Error("failed to discard the failed write", zap.String("file", f), zap.Error(discardErr))
+		}
+		return err
+	}
+	if before == 0 {
+		e.emit(FileEvent{Type: FileOpened, Path: f})
+		w.opened = time.Now()
+	}
+	w.failures = 0
+	w.signals[b.signal] = true
+	e.trackDataAge(w, b)
+	w.stats.add(b)
+	w.lastWrite = time.Now()
+	return nil
+}
+
+// open opens the in process file for appending, the file is kept open between batches until it is
+// closed on rotation or shutdown; direct writes position the blocks themselves and read back the partial
+// last block so the file is not opened in append mode
+func (w *fileWriter) open(f string, perm os.FileMode) error {
+	if w.file != nil && w.fileName == f {
+		return nil
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	if w.directIO {
+		flags = os.O_CREATE | os.O_RDWR | directIOFlag
+	}
+	if w.mmap {
+		flags = os.O_CREATE | os.O_RDWR
+	}
+	file, err := w.fs.OpenFile(f, flags, perm)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file, w.fileName, w.size = file, f, stat.Size()
+	if w.preallocate && w.size < w.fileSize {
+		// the allocation is an optimisation, the file is written the same if the file system does not support it
+		_ = preallocate(file, w.fileSize)
+	}
+	if w.mmap {
+		out, err := w.openMmap(file)
+		if err != nil {
+			_ = file.Close()
+			w.file, w.fileName, w.size = nil, "", 0
+			return err
+		}
+		w.out = out
+		return nil
+	}
+	if w.directIO {
+		out, err := newDirectWriter(file, w.size, w.bufferSize)
+		if err != nil {
+			_ = file.Close()
+			w.file, w.fileName, w.size = nil, "", 0
+			return err
+		}
+		w.out = out
+		return nil
+	}
+	w.out = bufio.NewWriterSize(file, w.bufferSize)
+	return nil
+}
+
+// openMmap maps the opened in process file, the data it holds is what precedes its zero filled tail
+func (w *fileWriter) openMmap(file fsFile) (*mmapWriter, error) {
+	size, err := mmapDataSize(file, w.size)
+	if err != nil {
+		return nil, err
+	}
+	w.size = size
+	return newMmapWriter(file, size, w.fileSize, &w.mappedSlack)
+}
+
+// write appends the data to the in process file and accounts its size
+func (w *fileWriter) write(p []byte) error {
+	n, err := w.out.Write(p)
+	w.size += int64(n)
+	return err
+}
+
+// flush writes the buffered batches to the in process file, if it fails the batches are discarded
+func (w *fileWriter) flush() error {
+	flushed := w.size - w.pendingBytes
+	w.pendingBytes, w.pendingRecords, w.firstPending = 0, 0, time.Time{}
+	if w.out == nil {
+		return nil
+	}
+	if err := w.out.Flush(); err != nil {
+		return multierr.Append(err, w.discard(flushed))
+	}
+	return nil
+}
+
+// discard drops the buffer of the in process file after a failed write and truncates the file back to
+// the size it had before the writes that were not flushed, so that a partial write leaves no torn record;
+// the buffer keeps its write error so the file is closed and reopened by the next write
+func (w *fileWriter) discard(size int64) error {
+	if w.file == nil {
+		return nil
+	}
+	var err error
+	if m, ok := w.out.(*mmapWriter); ok {
+		err = m.release()
+	}
+	err = multierr.Append(err, w.file.Truncate(size))
+	err = multierr.Append(err, w.file.Close())
+	w.file, w.out, w.fileName, w.size = nil, nil, "", 0
+	w.pendingBytes, w.pendingRecords, w.firstPending = 0, 0, time.Time{}
+	return err
+}
+
+// close flushes and closes the in process file, the size of the next in process file is read when it is opened;
+// the blocks preallocated beyond the data are released by truncating the file to its size
+func (w *fileWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.flush()
+	if w.file == nil {
+		// the failed flush discarded and closed the file
+		return err
+	}
+	if m, ok := w.out.(*mmapWriter); ok {
+		err = multierr.Append(err, m.release())
+	}
+	if err == nil && (w.preallocate || w.mmap) {
+		err = w.file.Truncate(w.size)
+	}
+	err = multierr.Append(err, w.file.Close())
+	w.file, w.out, w.fileName, w.size = nil, nil, "", 0
+	return err
+}
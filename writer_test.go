@@ -0,0 +1,100 @@
+/*
+  open telemetry file exporter for pilot
+  © 2018-Present - SouthWinds Tech Ltd - www.southwinds.io
+  Licensed under the Apache License, Version 2.0 at http://www.apache.org/licenses/LICENSE-2.0
+  Contributors to this project, hereby assign copyright in this code to the project,
+  to be licensed under the same terms as the rest of the code.
+*/
+
+package fileexporter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// the record written by the write benchmarks
+var benchmarkLine = `{"resourceSpans":[{"scopeSpans":[{"spans":[{"name":"` + strings.Repeat("x", 1000) + `"}]}]}]}`
+
+func benchmarkWrite(b *testing.B, fileSize int64, reopen bool) {
+	e := startTestExporter(b, testConfig(b, fileSize))
+	b.SetBytes(int64(len(benchmarkLine)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := writeLine(e, benchmarkLine); err != nil {
+			b.Fatal(err)
+		}
+		if reopen {
+			// closing the file after every batch is what every write did before the file was kept open
+			_ = e.eachWriter(context.Background(), func(w *fileWriter) error {
+				return w.close()
+			})
+		}
+	}
+}
+
+// BenchmarkWriteKeepOpen appends the batches to the in process file kept open between writes
+func BenchmarkWriteKeepOpen(b *testing.B) {
+	benchmarkWrite(b, 1<<30, false)
+}
+
+// BenchmarkWriteReopen opens the in process file for every batch, the baseline of BenchmarkWriteKeepOpen
+func BenchmarkWriteReopen(b *testing.B) {
+	benchmarkWrite(b, 1<<30, true)
+}
+
+// BenchmarkWriteRotate appends the batches to in process files finished every megabyte
+func BenchmarkWriteRotate(b *testing.B) {
+	benchmarkWrite(b, 1<<20, false)
+}
+
+func TestWriteKeepsFileOpen(t *testing.T) {
+	e := startTestExporter(t, testConfig(t, 1<<20))
+	for i := 0; i < 3; i++ {
+		if err := writeLine(e, `{"a":1}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var open int
+	_ = e.eachWriter(context.Background(), func(w *fileWriter) error {
+		if w.file != nil {
+			open++
+		}
+		if w.size != 3*int64(len(`{"a":1}`)) {
+			t.Errorf("unexpected size %d accounted for three batches", w.size)
+		}
+		return nil
+	})
+	if open != 1 {
+		t.Fatalf("expected the in process file to be kept open, %d open", open)
+	}
+}
+
+func TestWriteRecoversAfterTransientFault(t *testing.T) {
+	for _, ft := range []fault{
+		{op: faultWrite, pattern: inProcessName, err: syscall.ENOSPC, allowed: 10, times: 1},
+		{op: faultWrite, pattern: inProcessName, err: syscall.EIO, times: 1},
+	} {
+		t.Run(ft.err.Error(), func(t *testing.T) {
+			cfg := testConfig(t, 1<<20)
+			cfg.QuarantineAfter = 0
+			e, fsys := newFaultExporter(t, cfg)
+			startExporter(t, e)
+			writeLines(t, e, `{"a":1}`)
+			fsys.inject(ft)
+			if err := writeLine(e, `{"a":2}`); !errors.Is(err, ft.err) {
+				t.Fatalf("expected the write to fail, got %v", err)
+			}
+			fsys.clear()
+			// the pipeline retries the batch once the fault clears
+			writeLines(t, e, `{"a":2}`, `{"a":3}`)
+			if content := readFile(t, filepath.Join(cfg.Path, inProcessName)); content != `{"a":1}{"a":2}{"a":3}` {
+				t.Fatalf("expected the retried batch to follow the whole batches, got %q", content)
+			}
+		})
+	}
+}